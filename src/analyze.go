@@ -0,0 +1,385 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// AnalyzeOptions describes one analysis of a transcript, from a hook or the analyze command.
+type AnalyzeOptions struct {
+	TranscriptPath string
+	ProjectRoot    string // directory whose CLAUDE.md is updated and where claude runs
+	HookInfo       string // how the analysis was started, recorded in the log
+	OutputDir      string // overrides the per-user output directory
+	Format         string // overrides suggestion_format of the config file
+	PromptFile     string // replaces the built-in prompt
+	SkipPrune      bool   // keep old outputs, e.g. while a batch still needs them
+	// PendingSuggestions are suggestions of earlier analyses not applied yet.
+	PendingSuggestions []string
+}
+
+// AnalyzeResult is where an analysis wrote its output.
+type AnalyzeResult struct {
+	SuggestionFile string
+	LogFile        string
+	Valid          bool
+	Usage          ClaudeUsage // reported by claude, zero if it did not run
+	Skipped        bool        // nothing was analyzed
+	SkipReason     string      // why, e.g. "会話履歴が空のため"
+}
+
+// analyzeTranscript runs the analysis pipeline shared by hooks and the analyze command:
+// it extracts the conversation history, runs claude with the prompt and validates the suggestion.
+func analyzeTranscript(opts AnalyzeOptions, output io.Writer, getenv func(string) string, now func() time.Time) (result AnalyzeResult, err error) {
+	// ~をホームディレクトリに展開
+	transcriptPath := ExpandTilde(opts.TranscriptPath)
+
+	// CONVERSATION_IDの抽出
+	conversationID := transcriptSessionID(transcriptPath)
+	log := sessionLogger(conversationID, opts.ProjectRoot)
+	log.Info("analysis started", "hook_info", opts.HookInfo, "transcript", transcriptPath)
+	defer func() {
+		if err != nil {
+			log.Error("analysis failed", "error", strings.TrimPrefix(err.Error(), "❌ "))
+		}
+	}()
+
+	// ファイルの存在確認
+	if _, err := os.Stat(transcriptPath); os.IsNotExist(err) {
+		return result, fmt.Errorf("❌ ファイルが存在しません: %s", transcriptPath)
+	}
+
+	// suggest-claude-mdが起動したclaudeのセッションは分析しない
+	if isAnalysisTranscript(transcriptPath) {
+		return skipAnalysis(result, "suggest-claude-mdの分析用のセッションのため（プロンプトのマーカーを検出）", output, log), nil
+	}
+
+	a := &analysis{
+		opts:           opts,
+		output:         output,
+		getenv:         getenv,
+		now:            now,
+		log:            log,
+		transcriptPath: transcriptPath,
+		conversationID: conversationID,
+	}
+	if err := a.prepare(); err != nil {
+		return result, err
+	}
+
+	_, _ = fmt.Fprintln(output, "🤖 会話履歴を分析中...") // nolint:errcheck // Output to user, error not critical
+	_, _ = fmt.Fprintln(output, opts.HookInfo)   // nolint:errcheck // Output to user, error not critical
+	_, _ = fmt.Fprintf(output, "📋 実行中...\n")     // nolint:errcheck // Output to user, error not critical
+	_, _ = fmt.Fprintf(output, "\n")             // nolint:errcheck // Output to user, error not critical
+
+	// 会話履歴の抽出
+	stageStart := time.Now()
+	conversationHistory, err := ExtractConversationHistory(transcriptPath)
+	if err != nil {
+		return result, fmt.Errorf("❌ 会話履歴の抽出に失敗: %w", err)
+	}
+	log.Info("transcript parsed", "history_chars", utf8.RuneCountInString(conversationHistory), "duration_ms", elapsedMillis(stageStart))
+
+	if conversationHistory == "" {
+		return skipAnalysis(result, "会話履歴が空のため", output, log), nil
+	}
+
+	// 同じセッションを同時に分析しない。PIDファイルは、claudeから起動されたフックが
+	// 分析中のプロセスの子孫であることの判定にも使う
+	release, holder, err := acquireSessionLock(sessionLockDir(), conversationID)
+	if err != nil {
+		return result, fmt.Errorf("❌ %w", err)
+	}
+	if release == nil {
+		return skipAnalysis(result, fmt.Sprintf("このセッションは既に分析中のため（PID %d）", holder), output, log), nil
+	}
+	defer release()
+
+	// スキップした分析が空のファイルを残さないよう、分析が決まってから作成する（0600）
+	for _, path := range []string{a.logFile, a.suggestionFile} {
+		if err := createPrivateFile(path); err != nil {
+			return result, fmt.Errorf("❌ 出力ファイルの作成に失敗: %w", err)
+		}
+	}
+	result.LogFile = a.logFile
+
+	record, err := a.runClaude(conversationHistory)
+	result.Usage = record.Usage
+	if err != nil {
+		return result, err
+	}
+	return a.finish(result, record)
+}
+
+// analysis is the state of one analyzeTranscript run shared by its stages.
+type analysis struct {
+	opts           AnalyzeOptions
+	output         io.Writer
+	getenv         func(string) string
+	now            func() time.Time
+	log            *slog.Logger
+	transcriptPath string
+	conversationID string
+
+	config         *Config
+	format         SuggestionFormat
+	promptTemplate string
+	outputDir      string
+	logFile        string
+	suggestionFile string
+}
+
+// prepare resolves the suggestion format, the prompt and the output files.
+func (a *analysis) prepare() error {
+	// 設定ファイルから提案形式を決定（オプションの指定を優先）
+	config, err := LoadConfig(ConfigPath(a.getenv))
+	if err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+	a.config = config
+	formatName := config.SuggestionFormat
+	if a.opts.Format != "" {
+		formatName = a.opts.Format
+	}
+	if a.format, err = ParseSuggestionFormat(formatName); err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+
+	// プロンプトの決定
+	a.promptTemplate = PromptContentFor(a.format)
+	if a.opts.PromptFile != "" {
+		content, err := os.ReadFile(ExpandTilde(a.opts.PromptFile))
+		if err != nil {
+			return fmt.Errorf("❌ プロンプトファイルの読み込みに失敗: %w", err)
+		}
+		a.promptTemplate = string(content)
+	}
+
+	// ユーザー専用の出力ディレクトリ（0700）
+	a.outputDir = ResolveOutputDir(a.getenv)
+	if a.opts.OutputDir != "" {
+		a.outputDir = ExpandTilde(a.opts.OutputDir)
+	}
+	if err := EnsurePrivateDir(a.outputDir); err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+
+	// 保持期間を過ぎたログと提案ファイルを削除
+	if !a.opts.SkipPrune {
+		if _, err := PruneOutputs(a.outputDir, RetentionPolicyFromEnv(a.getenv), a.now()); err != nil {
+			_, _ = fmt.Fprintf(a.output, "⚠️  %v\n", err) // nolint:errcheck // Output to user, error not critical
+		}
+	}
+
+	// ログファイルと提案ファイルのパス
+	base := filepath.Join(a.outputDir, fmt.Sprintf("%s%s-%s", outputFilePrefix, a.conversationID, a.now().Format("20060102-150405")))
+	a.logFile = base + ".log"
+	a.suggestionFile = base + ".md"
+	if a.format == FormatOperations {
+		a.suggestionFile = base + ".json"
+	}
+	return nil
+}
+
+// runClaude generates the prompt and runs claude. The returned record has the
+// usage of the run; a failed run is recorded here.
+func (a *analysis) runClaude(conversationHistory string) (UsageRecord, error) {
+	// 既存のCLAUDE.mdを読み込む
+	var existingClaudeMd string
+	if content, err := os.ReadFile(filepath.Join(a.opts.ProjectRoot, "CLAUDE.md")); err == nil {
+		existingClaudeMd = string(content)
+	} else {
+		_, _ = fmt.Fprintln(a.output, "💡 CLAUDE.mdがありません。suggest-claude-md init でリポジトリの内容から雛形を作成できます") // nolint:errcheck // Output to user, error not critical
+	}
+
+	// プロンプトファイルの生成
+	pending := a.opts.PendingSuggestions
+	if len(pending) > 0 {
+		_, _ = fmt.Fprintf(a.output, "📎 未適用の提案%d件と重複しないよう分析します\n", len(pending)) // nolint:errcheck // Output to user, error not critical
+	}
+	promptContent := GeneratePromptWithPending(a.promptTemplate, conversationHistory, existingClaudeMd, pending)
+	a.log.Info("prompt generated", "format", string(a.format), "prompt_bytes", len(promptContent),
+		"existing_claude_md_bytes", len(existingClaudeMd), "pending_suggestions", len(pending), "custom_prompt", a.opts.PromptFile != "")
+
+	// 一時ファイルの作成
+	tempPromptFile, err := os.CreateTemp(a.outputDir, promptFilePattern)
+	if err != nil {
+		return UsageRecord{}, fmt.Errorf("❌ 一時ファイルの作成に失敗: %w", err)
+	}
+	tempPromptFilePath := tempPromptFile.Name()
+
+	if _, err := tempPromptFile.WriteString(promptContent); err != nil {
+		_ = tempPromptFile.Close()        // nolint:errcheck // Best-effort cleanup in error path
+		_ = os.Remove(tempPromptFilePath) // nolint:errcheck // Best-effort cleanup in error path
+		return UsageRecord{}, fmt.Errorf("❌ 一時ファイルへの書き込みに失敗: %w", err)
+	}
+	_ = tempPromptFile.Close() // nolint:errcheck // File is read-only from here
+
+	// 同期実行
+	config := &ExecutorConfig{
+		ProjectRoot:        a.opts.ProjectRoot,
+		TempPromptFilePath: tempPromptFilePath,
+		LogFile:            a.logFile,
+		HookInfo:           a.opts.HookInfo,
+		SuggestionFile:     a.suggestionFile,
+		Output:             a.output,
+	}
+
+	a.log.Debug("running claude", "prompt_file", tempPromptFilePath, "log_file", a.logFile, "suggestion_file", a.suggestionFile)
+	stageStart := time.Now()
+	usage, err := ExecuteSynchronously(config)
+	// 失敗した実行も課金されるため、claudeを実行したら必ず使用量を記録する
+	record := UsageRecord{
+		Time:           a.now(),
+		SessionID:      a.conversationID,
+		ProjectRoot:    a.opts.ProjectRoot,
+		HookInfo:       a.opts.HookInfo,
+		DurationMillis: elapsedMillis(stageStart),
+		Usage:          usage,
+	}
+	if err != nil {
+		_ = os.Remove(tempPromptFilePath) // nolint:errcheck // Best-effort cleanup in error path
+		// 失敗した実行の応答は調査用に残すが、適用対象や分析済みの判定からは外す
+		_ = os.Rename(a.suggestionFile, InvalidSuggestionPath(a.suggestionFile)) // nolint:errcheck // Best-effort, the error of claude is reported
+		a.log.Error("claude failed", "duration_ms", record.DurationMillis, "error", err)
+		record.Status = JobFailed
+		recordUsage(a.config, a.getenv, record, a.output)
+		return record, fmt.Errorf("❌ 実行に失敗: %w", err)
+	}
+	a.log.Info("claude finished", "duration_ms", record.DurationMillis, "input_tokens", usage.TotalInputTokens(),
+		"output_tokens", usage.OutputTokens, "cost_usd", usage.CostUSD, "usage_reported", usage.Reported)
+	return record, nil
+}
+
+// finish validates the suggestion written by claude, records the usage of the
+// run and shows where the suggestion is. Invalid suggestions are renamed so
+// that they are not applied.
+func (a *analysis) finish(result AnalyzeResult, record UsageRecord) (AnalyzeResult, error) {
+	// 出力を検証し、前置きや区切り線などを取り除く
+	validation, err := validateSuggestionFile(a.suggestionFile, a.format)
+	if err != nil {
+		record.Status = JobFailed
+		recordUsage(a.config, a.getenv, record, a.output)
+		return result, fmt.Errorf("❌ 提案ファイルの検証に失敗: %w", err)
+	}
+	validationLevel := slog.LevelInfo
+	if !validation.Valid() {
+		validationLevel = slog.LevelWarn
+	}
+	a.log.Log(context.Background(), validationLevel, "suggestion validated", "valid", validation.Valid(),
+		"output_bytes", len(validation.Content), "fixes", validation.Fixes, "problems", validation.Problems)
+
+	output := a.output
+	_, _ = fmt.Fprintf(output, "\n")            // nolint:errcheck // Output to user, error not critical
+	_, _ = fmt.Fprintf(output, "✅ 分析が完了しました\n") // nolint:errcheck // Output to user, error not critical
+	for _, fix := range validation.Fixes {
+		_, _ = fmt.Fprintf(output, "🧹 %s\n", fix) // nolint:errcheck // Output to user, error not critical
+	}
+
+	if !validation.Valid() {
+		// 不正な提案は適用対象から外し、確認用にファイル名を変えて残す
+		invalidFile := InvalidSuggestionPath(a.suggestionFile)
+		if err := os.Rename(a.suggestionFile, invalidFile); err != nil {
+			return result, fmt.Errorf("❌ 提案ファイルの名前変更に失敗: %w", err)
+		}
+		result.SuggestionFile = invalidFile
+		record.Status, record.SuggestionFile = JobInvalid, invalidFile
+		recordUsage(a.config, a.getenv, record, output)
+		a.log.Info("analysis finished", "suggestion_file", invalidFile, "log_file", a.logFile)
+		_, _ = fmt.Fprintf(output, "⚠️  提案が不正なため、適用対象から除外しました\n") // nolint:errcheck // Output to user, error not critical
+		for _, problem := range validation.Problems {
+			_, _ = fmt.Fprintf(output, "  - %s\n", problem) // nolint:errcheck // Output to user, error not critical
+		}
+		_, _ = fmt.Fprintf(output, "📄 提案ファイル: %s\n", invalidFile) // nolint:errcheck // Output to user, error not critical
+		_, _ = fmt.Fprintf(output, "詳細なログ: %s\n", a.logFile)      // nolint:errcheck // Output to user, error not critical
+		return result, nil
+	}
+	result.SuggestionFile = a.suggestionFile
+	result.Valid = true
+	record.Status, record.SuggestionFile = JobSucceeded, a.suggestionFile
+	recordUsage(a.config, a.getenv, record, output)
+	a.log.Info("analysis finished", "suggestion_file", a.suggestionFile, "log_file", a.logFile)
+
+	_, _ = fmt.Fprintf(output, "📄 提案ファイル: %s\n", a.suggestionFile)                   // nolint:errcheck // Output to user, error not critical
+	_, _ = fmt.Fprintf(output, "\n")                                                 // nolint:errcheck // Output to user, error not critical
+	_, _ = fmt.Fprintf(output, "以下のコマンドで提案を適用できます：\n")                               // nolint:errcheck // Output to user, error not critical
+	_, _ = fmt.Fprintf(output, "  suggest-claude-md --apply %s\n", a.suggestionFile) // nolint:errcheck // Output to user, error not critical
+	_, _ = fmt.Fprintf(output, "\n")                                                 // nolint:errcheck // Output to user, error not critical
+	_, _ = fmt.Fprintf(output, "詳細なログ: %s\n", a.logFile)                             // nolint:errcheck // Output to user, error not critical
+
+	return result, nil
+}
+
+// runAnalyzeCommand runs `suggest-claude-md analyze`, analyzing a transcript given
+// by path or session ID without hook input.
+func runAnalyzeCommand(args []string, output io.Writer, getwd func() (string, error), getenv func(string) string, now func() time.Time) error {
+	const usage = "使い方: suggest-claude-md analyze (--transcript <file> | --session <id>) [--project <dir>] [--output-dir <dir>] [--format markdown|operations] [--prompt <file>]"
+
+	var opts AnalyzeOptions
+	var sessionID string
+	fs := flag.NewFlagSet("analyze", flag.ContinueOnError)
+	fs.StringVar(&opts.TranscriptPath, "transcript", "", "Transcript file (.jsonl) to analyze")
+	fs.StringVar(&sessionID, "session", "", "Session ID of the project to analyze")
+	fs.StringVar(&opts.ProjectRoot, "project", "", "Project directory (default: current directory)")
+	fs.StringVar(&opts.OutputDir, "output-dir", "", "Directory for the suggestion and log files")
+	fs.StringVar(&opts.Format, "format", "", "Suggestion format (markdown, operations)")
+	fs.StringVar(&opts.PromptFile, "prompt", "", "Prompt file used instead of the built-in prompt")
+	rest, err := parseSubcommandFlags(fs, args)
+	if err != nil {
+		return fmt.Errorf("%w\n%s", err, usage)
+	}
+	if len(rest) > 0 || (opts.TranscriptPath == "") == (sessionID == "") {
+		return fmt.Errorf("%s", usage)
+	}
+
+	if opts.ProjectRoot, err = resolveProjectRoot(opts.ProjectRoot, getwd); err != nil {
+		return err
+	}
+
+	if sessionID != "" {
+		if opts.TranscriptPath, err = FindSessionTranscript(opts.ProjectRoot, sessionID); err != nil {
+			return err
+		}
+		opts.HookInfo = fmt.Sprintf("Manual: analyze (session: %s)", sessionID)
+	} else {
+		opts.HookInfo = fmt.Sprintf("Manual: analyze (transcript: %s)", opts.TranscriptPath)
+	}
+
+	_, err = analyzeTranscript(opts, output, getenv, now)
+	return err
+}
+
+// resolveProjectRoot returns the absolute path of the project directory given by
+// --project, or the current directory.
+func resolveProjectRoot(projectRoot string, getwd func() (string, error)) (string, error) {
+	var err error
+	if projectRoot == "" {
+		if projectRoot, err = getwd(); err != nil {
+			return "", fmt.Errorf("カレントディレクトリの取得に失敗: %w", err)
+		}
+	}
+	if projectRoot, err = filepath.Abs(ExpandTilde(projectRoot)); err != nil {
+		return "", fmt.Errorf("プロジェクトディレクトリの解決に失敗: %w", err)
+	}
+	if info, err := os.Stat(projectRoot); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("プロジェクトディレクトリが見つかりません: %s", projectRoot)
+	}
+	return projectRoot, nil
+}
+
+// skipAnalysis reports that the analysis is skipped and why.
+func skipAnalysis(result AnalyzeResult, reason string, output io.Writer, log *slog.Logger) AnalyzeResult {
+	log.Info("analysis skipped", "reason", reason)
+	_, _ = fmt.Fprintf(output, "⚠️  %s、スキップします\n", reason) // nolint:errcheck // Output to user, error not critical
+	result.Skipped = true
+	result.SkipReason = reason
+	return result
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunAnalyzeCommand_Session(t *testing.T) {
+	tmpDir := t.TempDir()
+	projectRoot := filepath.Join(tmpDir, "my_project")
+	configDir := filepath.Join(tmpDir, "claude")
+	t.Setenv(claudeConfigDirEnvVar, configDir)
+
+	transcriptDir := filepath.Join(configDir, "projects", EncodeProjectDir(projectRoot))
+	for _, dir := range []string{projectRoot, transcriptDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+	}
+	transcript := `{"uuid":"u1","message":{"role":"user","content":"Use make lint before committing"}}`
+	if err := os.WriteFile(filepath.Join(transcriptDir, "abc123-session.jsonl"), []byte(transcript), 0o600); err != nil {
+		t.Fatalf("Failed to create transcript: %v", err)
+	}
+	promptFile := filepath.Join(tmpDir, "prompt.md")
+	if err := os.WriteFile(promptFile, []byte("CUSTOM PROMPT MARKER\n"), 0o600); err != nil {
+		t.Fatalf("Failed to create prompt: %v", err)
+	}
+
+	outputDir := filepath.Join(tmpDir, "out")
+	writeFakeClaude(t, "## Commands\n\n- make lint\n")
+	getenv := func(string) string { return "" }
+	now := func() time.Time { return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC) }
+
+	output := &bytes.Buffer{}
+	args := []string{"--session", "abc", "--project", projectRoot, "--output-dir", outputDir, "--prompt", promptFile}
+	if err := runAnalyzeCommand(args, output, func() (string, error) { return tmpDir, nil }, getenv, now); err != nil {
+		t.Fatalf("runAnalyzeCommand() error = %v\n%s", err, output.String())
+	}
+
+	for _, want := range []string{"Manual: analyze (session: abc)", "suggest-claude-md --apply"} {
+		if !strings.Contains(output.String(), want) {
+			t.Errorf("Output should contain %q, got: %s", want, output.String())
+		}
+	}
+	logData, err := os.ReadFile(filepath.Join(outputDir, "suggest-claude-md-abc123-session-20240101-000000.log"))
+	if err != nil {
+		t.Fatalf("Failed to read log: %v", err)
+	}
+	if !strings.Contains(string(logData), "CUSTOM PROMPT MARKER") || !strings.Contains(string(logData), "make lint before committing") {
+		t.Errorf("Custom prompt and transcript should be passed to claude, log: %s", logData)
+	}
+}
+
+func TestRunAnalyzeCommand_Errors(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv(claudeConfigDirEnvVar, filepath.Join(tmpDir, "claude"))
+	getwd := func() (string, error) { return tmpDir, nil }
+	getenv := func(string) string { return "" }
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"no source", nil, "使い方"},
+		{"both sources", []string{"--transcript", "a.jsonl", "--session", "abc"}, "使い方"},
+		{"unknown flag", []string{"--transcript", "a.jsonl", "--verbose"}, "使い方"},
+		{"missing project", []string{"--transcript", "a.jsonl", "--project", filepath.Join(tmpDir, "missing")}, "プロジェクトディレクトリが見つかりません"},
+		{"no transcripts", []string{"--session", "abc"}, "プロジェクトの会話履歴が見つかりません"},
+		{"missing transcript", []string{"--transcript", filepath.Join(tmpDir, "missing.jsonl")}, "ファイルが存在しません"},
+		{"invalid format", []string{"--transcript", writeTestTranscript(t, tmpDir), "--format", "yaml"}, "無効な提案形式"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := runAnalyzeCommand(tt.args, &bytes.Buffer{}, getwd, getenv, time.Now)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("runAnalyzeCommand() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// writeTestTranscript creates a transcript in dir and returns its path.
+func writeTestTranscript(t *testing.T, dir string) string {
+	t.Helper()
+	path := filepath.Join(dir, "transcript.jsonl")
+	if err := os.WriteFile(path, []byte(`{"message":{"role":"user","content":"Hello"}}`), 0o600); err != nil {
+		t.Fatalf("Failed to create transcript: %v", err)
+	}
+	return path
+}
+
+func TestAnalyzeTranscript_SkipLeavesNoFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	outputDir := filepath.Join(tmpDir, "out")
+	// claudeが起動されればテストは失敗する
+	forbidClaude(t)
+	useTempHomeDir(t)
+	getenv := func(key string) string {
+		if key == "XDG_RUNTIME_DIR" {
+			return tmpDir
+		}
+		return ""
+	}
+	empty := filepath.Join(tmpDir, "empty.jsonl")
+	if err := os.WriteFile(empty, nil, 0o600); err != nil {
+		t.Fatalf("Failed to create transcript: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		transcript string
+		setup      func()
+		wantReason string
+	}{
+		{"empty history", empty, func() {}, "会話履歴が空のため"},
+		{"session locked", writeTestTranscript(t, tmpDir), func() { writeSessionLock(t, "transcript", os.Getppid()) }, "このセッションは既に分析中のため"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup()
+			args := []string{"--transcript", tt.transcript, "--project", tmpDir, "--output-dir", outputDir}
+			output := &bytes.Buffer{}
+			if err := runAnalyzeCommand(args, output, func() (string, error) { return tmpDir, nil }, getenv, time.Now); err != nil {
+				t.Fatalf("runAnalyzeCommand() error = %v", err)
+			}
+			if !strings.Contains(output.String(), tt.wantReason) {
+				t.Errorf("Output should contain %q, got: %s", tt.wantReason, output.String())
+			}
+			// スキップした分析は空の提案ファイルやログを残さない
+			if leftovers, _ := filepath.Glob(filepath.Join(outputDir, outputFilePrefix+"*")); len(leftovers) != 0 {
+				t.Errorf("Skipped analysis left files: %v", leftovers)
+			}
+		})
+	}
+}
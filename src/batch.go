@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultBatchConcurrency is the number of sessions analyzed at the same time.
+	defaultBatchConcurrency = 2
+	// batchDateLayout is the date format of --since and --until.
+	batchDateLayout = "2006-01-02"
+)
+
+// BatchOptions selects the sessions analyzed by the batch command.
+type BatchOptions struct {
+	ProjectRoot string
+	Since       time.Time // zero: no lower bound
+	Until       time.Time // exclusive; zero: no upper bound
+	Concurrency int
+	OutputDir   string
+	PromptFile  string
+}
+
+// sessionResult is the outcome of analyzing one session in a batch.
+type sessionResult struct {
+	session SessionSummary
+	result  AnalyzeResult
+	err     error
+}
+
+// filterSessions returns the sessions with messages that started in the date range.
+func filterSessions(sessions []SessionSummary, since, until time.Time) []SessionSummary {
+	var selected []SessionSummary
+	for _, session := range sessions {
+		if session.Messages == 0 {
+			continue
+		}
+		if (!since.IsZero() || !until.IsZero()) && session.Start.IsZero() {
+			continue
+		}
+		if !since.IsZero() && session.Start.Before(since) {
+			continue
+		}
+		if !until.IsZero() && !session.Start.Before(until) {
+			continue
+		}
+		selected = append(selected, session)
+	}
+	return selected
+}
+
+// runBatch analyzes the selected sessions in parallel and consolidates their suggestions
+// into one suggestion file ranked by the number of supporting sessions.
+func runBatch(opts BatchOptions, output io.Writer, getenv func(string) string, now func() time.Time) error {
+	outputDir := ResolveOutputDir(getenv)
+	if opts.OutputDir != "" {
+		outputDir = ExpandTilde(opts.OutputDir)
+	}
+	if err := EnsurePrivateDir(outputDir); err != nil {
+		return err
+	}
+	config, err := LoadConfig(ConfigPath(getenv))
+	if err != nil {
+		return err
+	}
+
+	sessions, err := ListSessions(opts.ProjectRoot, outputDir)
+	if err != nil {
+		return err
+	}
+	sessions = filterSessions(sessions, opts.Since, opts.Until)
+	if len(sessions) == 0 {
+		_, _ = fmt.Fprintln(output, "⚠️  分析対象のセッションがありません") // nolint:errcheck // Output to user, error not critical
+		return nil
+	}
+
+	// バッチの途中で出力が削除されないよう、保持期間による削除は最初に一度だけ行う
+	if _, err := PruneOutputs(outputDir, RetentionPolicyFromEnv(getenv), now()); err != nil {
+		_, _ = fmt.Fprintf(output, "⚠️  %v\n", err) // nolint:errcheck // Output to user, error not critical
+	}
+
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	opts.OutputDir = outputDir
+	_, _ = fmt.Fprintf(output, "🤖 %d件のセッションを分析中...（同時実行数: %d）\n\n", len(sessions), opts.Concurrency) // nolint:errcheck // Output to user, error not critical
+	results := analyzeSessions(sessions, opts, output, getenv, now)
+
+	// 有効な提案を統合し、支持するセッション数の多い順に並べる
+	consolidator := NewConsolidator(config.MergeOptions())
+	if err := consolidateResults(consolidator, results); err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintln(output) // nolint:errcheck // Output to user, error not critical
+	suggestion, err := consolidator.Suggestion()
+	if err != nil {
+		return fmt.Errorf("提案の統合に失敗: %w", err)
+	}
+	if suggestion == "" {
+		_, _ = fmt.Fprintln(output, "⚠️  有効な提案がなかったため、統合した提案は作成しませんでした") // nolint:errcheck // Output to user, error not critical
+		return nil
+	}
+
+	suggestionFile := filepath.Join(outputDir, fmt.Sprintf("%sbatch-%s.md", outputFilePrefix, now().Format("20060102-150405")))
+	if err := os.WriteFile(suggestionFile, []byte(suggestion), privateFilePerm); err != nil {
+		return fmt.Errorf("統合した提案の保存に失敗: %w", err)
+	}
+
+	_, _ = fmt.Fprintf(output, "✅ %d/%d件のセッションの提案を統合しました\n", consolidator.Sessions(), len(sessions)) // nolint:errcheck // Output to user, error not critical
+	_, _ = fmt.Fprintf(output, "📄 提案ファイル: %s\n", suggestionFile)                                     // nolint:errcheck // Output to user, error not critical
+	_, _ = fmt.Fprintf(output, "\n")                                                                 // nolint:errcheck // Output to user, error not critical
+	_, _ = fmt.Fprintf(output, "以下のコマンドで提案を適用できます：\n")                                               // nolint:errcheck // Output to user, error not critical
+	_, _ = fmt.Fprintf(output, "  suggest-claude-md --apply %s\n", suggestionFile)                   // nolint:errcheck // Output to user, error not critical
+	return nil
+}
+
+// analyzeSessions analyzes the sessions with at most opts.Concurrency analyses at a time
+// and reports the progress on the output. Results are in the order of sessions.
+func analyzeSessions(sessions []SessionSummary, opts BatchOptions, output io.Writer, getenv func(string) string, now func() time.Time) []sessionResult {
+	results := make([]sessionResult, len(sessions))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, opts.Concurrency)
+	done := 0
+	for i, session := range sessions {
+		wg.Add(1)
+		go func(i int, session SessionSummary) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			// 各セッションの詳細な出力はログファイルに残る
+			result, err := analyzeTranscript(AnalyzeOptions{
+				TranscriptPath: session.Path,
+				ProjectRoot:    opts.ProjectRoot,
+				HookInfo:       fmt.Sprintf("Manual: batch (session: %s)", session.ID),
+				OutputDir:      opts.OutputDir,
+				Format:         string(FormatMarkdown),
+				PromptFile:     opts.PromptFile,
+				SkipPrune:      true,
+			}, &bytes.Buffer{}, getenv, now)
+			results[i] = sessionResult{session: session, result: result, err: err}
+
+			mu.Lock()
+			defer mu.Unlock()
+			done++
+			_, _ = fmt.Fprintf(output, "[%d/%d] %s\n", done, len(sessions), describeSessionResult(results[i])) // nolint:errcheck // Output to user, error not critical
+		}(i, session)
+	}
+	wg.Wait()
+	return results
+}
+
+// consolidateResults adds the valid suggestions of the results to the consolidator.
+func consolidateResults(consolidator *Consolidator, results []sessionResult) error {
+	for _, r := range results {
+		if r.err != nil || !r.result.Valid {
+			continue
+		}
+		content, err := os.ReadFile(r.result.SuggestionFile)
+		if err != nil {
+			return fmt.Errorf("提案ファイルの読み込みに失敗: %w", err)
+		}
+		markdown, metadata, err := SplitSuggestion(string(content))
+		if err != nil {
+			continue
+		}
+		consolidator.Add(r.session.ID, markdown, metadata)
+	}
+	return nil
+}
+
+// describeSessionResult formats a progress line for an analyzed session.
+func describeSessionResult(r sessionResult) string {
+	switch {
+	case r.err != nil:
+		return fmt.Sprintf("❌ %s: %s", r.session.ID, strings.TrimPrefix(r.err.Error(), "❌ "))
+	case r.result.Skipped:
+		return fmt.Sprintf("⏭️  %s: %sスキップ", r.session.ID, r.result.SkipReason)
+	case !r.result.Valid:
+		return fmt.Sprintf("⚠️  %s: 提案が不正なため除外（%s）", r.session.ID, r.result.LogFile)
+	default:
+		return fmt.Sprintf("✅ %s", r.session.ID)
+	}
+}
+
+// runBatchCommand runs `suggest-claude-md batch`.
+func runBatchCommand(args []string, output io.Writer, getwd func() (string, error), getenv func(string) string, now func() time.Time) error {
+	const usage = "使い方: suggest-claude-md batch [--project <dir>] [--since YYYY-MM-DD] [--until YYYY-MM-DD] [--concurrency <n>] [--output-dir <dir>] [--prompt <file>]"
+
+	opts := BatchOptions{}
+	var since, until string
+	fs := flag.NewFlagSet("batch", flag.ContinueOnError)
+	fs.StringVar(&opts.ProjectRoot, "project", "", "Project directory (default: current directory)")
+	fs.StringVar(&since, "since", "", "Analyze sessions started on or after this date")
+	fs.StringVar(&until, "until", "", "Analyze sessions started on or before this date")
+	fs.IntVar(&opts.Concurrency, "concurrency", defaultBatchConcurrency, "Number of sessions analyzed at the same time")
+	fs.StringVar(&opts.OutputDir, "output-dir", "", "Directory for the suggestion and log files")
+	fs.StringVar(&opts.PromptFile, "prompt", "", "Prompt file used instead of the built-in prompt")
+	rest, err := parseSubcommandFlags(fs, args)
+	if err != nil {
+		return fmt.Errorf("%w\n%s", err, usage)
+	}
+	if len(rest) > 0 || opts.Concurrency < 1 {
+		return fmt.Errorf("%s", usage)
+	}
+
+	if since != "" {
+		if opts.Since, err = time.ParseInLocation(batchDateLayout, since, time.Local); err != nil {
+			return fmt.Errorf("無効な日付: %s (YYYY-MM-DD形式で指定してください)", since)
+		}
+	}
+	if until != "" {
+		if opts.Until, err = time.ParseInLocation(batchDateLayout, until, time.Local); err != nil {
+			return fmt.Errorf("無効な日付: %s (YYYY-MM-DD形式で指定してください)", until)
+		}
+		// 指定日の終わりまでを含める
+		opts.Until = opts.Until.AddDate(0, 0, 1)
+	}
+	if opts.ProjectRoot, err = resolveProjectRoot(opts.ProjectRoot, getwd); err != nil {
+		return err
+	}
+	return runBatch(opts, output, getenv, now)
+}
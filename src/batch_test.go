@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFilterSessions(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2025, 1, d, 12, 0, 0, 0, time.UTC) }
+	sessions := []SessionSummary{
+		{ID: "a", Start: day(1), Messages: 3},
+		{ID: "b", Start: day(5), Messages: 3},
+		{ID: "c", Start: day(10), Messages: 3},
+		{ID: "empty", Start: day(5), Messages: 0},
+		{ID: "no-time", Messages: 3},
+	}
+
+	tests := []struct {
+		name         string
+		since, until time.Time
+		want         string
+	}{
+		{"all", time.Time{}, time.Time{}, "a,b,c,no-time"},
+		{"since", day(5), time.Time{}, "b,c"},
+		{"until (exclusive)", time.Time{}, day(10), "a,b"},
+		{"range", day(2), day(9), "b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ids []string
+			for _, s := range filterSessions(sessions, tt.since, tt.until) {
+				ids = append(ids, s.ID)
+			}
+			if got := strings.Join(ids, ","); got != tt.want {
+				t.Errorf("filterSessions() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunBatchCommand(t *testing.T) {
+	tmpDir := t.TempDir()
+	projectRoot := filepath.Join(tmpDir, "project")
+	configDir := filepath.Join(tmpDir, "claude")
+	t.Setenv(claudeConfigDirEnvVar, configDir)
+
+	transcriptDir := filepath.Join(configDir, "projects", EncodeProjectDir(projectRoot))
+	for _, dir := range []string{projectRoot, transcriptDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+	}
+	transcripts := map[string]string{
+		"s1": "2025-01-01T10:00:00Z", "s2": "2025-01-02T10:00:00Z", "s3": "2025-01-03T10:00:00Z", "old": "2024-12-01T10:00:00Z",
+	}
+	for id, ts := range transcripts {
+		content := fmt.Sprintf(`{"timestamp":%q,"message":{"role":"user","content":"session %s"}}`, ts, id)
+		if err := os.WriteFile(filepath.Join(transcriptDir, id+".jsonl"), []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to create transcript: %v", err)
+		}
+	}
+
+	// セッションごとに異なる提案を返す偽のclaude
+	script := "#!/bin/sh\nprompt=$(cat)\n" +
+		"case \"$prompt\" in\n" +
+		"  *'session s1'*) printf '## Commands\\n\\n- make lint\\n' ;;\n" +
+		"  *'session s2'*) printf '## Commands\\n\\n- make test\\n- make lint\\n\\n## Notes\\n\\nUse Go 1.22.\\n' ;;\n" +
+		"  *) printf 'no suggestion\\n' ;;\n" +
+		"esac\n"
+	writeFakeClaudeScript(t, script)
+	getenv := func(string) string { return "" }
+	outputDir := filepath.Join(tmpDir, "out")
+	now := func() time.Time { return time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC) }
+
+	output := &bytes.Buffer{}
+	args := []string{"--project", projectRoot, "--since", "2025-01-01", "--until", "2025-01-31", "--concurrency", "3", "--output-dir", outputDir}
+	if err := runBatchCommand(args, output, func() (string, error) { return tmpDir, nil }, getenv, now); err != nil {
+		t.Fatalf("runBatchCommand() error = %v\n%s", err, output.String())
+	}
+
+	outputStr := output.String()
+	for _, want := range []string{"3件のセッションを分析中", "[3/3]", "⚠️  s3: 提案が不正なため除外", "2/3件のセッションの提案を統合しました"} {
+		if !strings.Contains(outputStr, want) {
+			t.Errorf("Output should contain %q, got: %s", want, outputStr)
+		}
+	}
+	if strings.Contains(outputStr, "old") {
+		t.Errorf("Sessions outside the date range should not be analyzed: %s", outputStr)
+	}
+
+	content, err := os.ReadFile(filepath.Join(outputDir, "suggest-claude-md-batch-20250201-000000.md"))
+	if err != nil {
+		t.Fatalf("Failed to read consolidated suggestion: %v", err)
+	}
+	markdown, metadata, err := SplitSuggestion(string(content))
+	if err != nil {
+		t.Fatalf("SplitSuggestion() error = %v", err)
+	}
+	if want := "## Commands\n\n- make lint\n- make test\n\n## Notes\n\nUse Go 1.22.\n"; markdown != want {
+		t.Errorf("markdown = %q, want %q", markdown, want)
+	}
+	if item := metadata.ItemFor("Commands"); item == nil || !strings.HasPrefix(item.Rationale, "2/2件") {
+		t.Errorf("ItemFor(Commands) = %+v", item)
+	}
+}
+
+func TestRunBatchCommand_InvalidArgs(t *testing.T) {
+	getwd := func() (string, error) { return t.TempDir(), nil }
+	for _, args := range [][]string{{"--since", "2025/01/01"}, {"--concurrency", "0"}, {"extra"}} {
+		if err := runBatchCommand(args, &bytes.Buffer{}, getwd, func(string) string { return "" }, time.Now); err == nil {
+			t.Errorf("runBatchCommand(%v) should fail", args)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	configEnvVar   = "SUGGEST_CLAUDE_MD_CONFIG"
+	configFileName = "config.json"
+)
+
+// Config represents the user configuration (~/.config/suggest-claude-md/config.json)
+type Config struct {
+	// SectionAliases maps a canonical section title to titles that mean the same section.
+	// They are added to the built-in aliases.
+	SectionAliases map[string][]string `json:"section_aliases,omitempty"`
+	// TitleSimilarityThreshold is the minimum similarity (0-1) for fuzzy title matching.
+	TitleSimilarityThreshold float64 `json:"title_similarity_threshold,omitempty"`
+	// SuggestionFormat is the format requested from the model ("markdown" or "operations").
+	SuggestionFormat string `json:"suggestion_format,omitempty"`
+	// ExecutionMode selects how hooks run the analysis ("sync" or "async").
+	ExecutionMode string `json:"execution_mode,omitempty"`
+	// Notify lists the notifiers run when an async analysis finishes ("bell", "notify-send", "command").
+	Notify []string `json:"notify,omitempty"`
+	// NotifyCommand is the shell command run by the "command" notifier.
+	NotifyCommand string `json:"notify_command,omitempty"`
+	// Skip holds the rules for skipping hooks without analyzing the session.
+	Skip SkipRules `json:"skip,omitempty"`
+	// LogFile is where the structured log is written ("off" disables it).
+	LogFile string `json:"log_file,omitempty"`
+	// LogLevel is the minimum level of logged records ("debug", "info", "warn", "error").
+	LogLevel string `json:"log_level,omitempty"`
+	// LogFormat is the format of logged records ("text" or "json").
+	LogFormat string `json:"log_format,omitempty"`
+	// UsageFile is where the usage of every run is recorded (default: usage.jsonl in the state directory).
+	UsageFile string `json:"usage_file,omitempty"`
+	// MonthlyBudgetUSD is the estimated cost per month above which hooks skip the analysis (0: no budget).
+	MonthlyBudgetUSD float64 `json:"monthly_budget_usd,omitempty"`
+}
+
+// ConfigPath returns the configuration file path.
+// SUGGEST_CLAUDE_MD_CONFIG takes precedence, then $XDG_CONFIG_HOME and ~/.config.
+func ConfigPath(getenv func(string) string) string {
+	if path := getenv(configEnvVar); path != "" {
+		return ExpandTilde(path)
+	}
+	if configHome := getenv("XDG_CONFIG_HOME"); configHome != "" {
+		return filepath.Join(configHome, commandName, configFileName)
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(homeDir, ".config", commandName, configFileName)
+}
+
+// LoadConfig loads the configuration file. A missing file yields an empty configuration.
+func LoadConfig(path string) (*Config, error) {
+	config := &Config{}
+	if path == "" {
+		return config, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("設定ファイルの読み込みに失敗: %w", err)
+	}
+
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("設定ファイルの解析に失敗 (%s): %w", path, err)
+	}
+	return config, nil
+}
+
+// MergeOptions returns merge options reflecting the configuration.
+func (c *Config) MergeOptions() MergeOptions {
+	opts := DefaultMergeOptions()
+	threshold := c.TitleSimilarityThreshold
+	if threshold <= 0 {
+		threshold = defaultTitleSimilarityThreshold
+	}
+	opts.Titles = NewTitleMatcher(c.SectionAliases, threshold)
+	return opts
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigPath(t *testing.T) {
+	env := map[string]string{configEnvVar: "/etc/custom.json", "XDG_CONFIG_HOME": "/xdg"}
+	if got := ConfigPath(func(key string) string { return env[key] }); got != "/etc/custom.json" {
+		t.Errorf("ConfigPath() = %q, want %q", got, "/etc/custom.json")
+	}
+
+	delete(env, configEnvVar)
+	if got := ConfigPath(func(key string) string { return env[key] }); got != "/xdg/suggest-claude-md/config.json" {
+		t.Errorf("ConfigPath() = %q, want XDG path", got)
+	}
+
+	got := ConfigPath(func(string) string { return "" })
+	if !strings.HasSuffix(got, filepath.Join(".config", "suggest-claude-md", "config.json")) {
+		t.Errorf("ConfigPath() = %q, want ~/.config path", got)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	config, err := LoadConfig(filepath.Join(tmpDir, "missing.json"))
+	if err != nil || config == nil {
+		t.Fatalf("LoadConfig() for missing file = %v, %v", config, err)
+	}
+
+	path := filepath.Join(tmpDir, "config.json")
+	content := `{"section_aliases": {"deploy": ["デプロイ"]}, "title_similarity_threshold": 0.9}`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	config, err = LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if config.TitleSimilarityThreshold != 0.9 || len(config.SectionAliases["deploy"]) != 1 {
+		t.Errorf("Unexpected config: %+v", config)
+	}
+
+	if err := os.WriteFile(path, []byte("{invalid"), 0o600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "設定ファイルの解析に失敗") {
+		t.Errorf("LoadConfig() should fail for invalid JSON, got %v", err)
+	}
+}
+
+func TestConfig_MergeOptions(t *testing.T) {
+	config := &Config{SectionAliases: map[string][]string{"deploy": {"リリース手順"}}}
+	opts := config.MergeOptions()
+
+	if opts.Duplicates != DuplicateSkip {
+		t.Errorf("Duplicates = %s, want skip", opts.Duplicates)
+	}
+	if _, ok := opts.Titles.Match("リリース手順", "Deploy"); !ok {
+		t.Error("Configured alias should match")
+	}
+	if opts.Titles.threshold != defaultTitleSimilarityThreshold {
+		t.Errorf("Threshold = %v, want default", opts.Titles.threshold)
+	}
+}
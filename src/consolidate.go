@@ -0,0 +1,323 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// maxRationaleSessions is the number of session IDs listed in a consolidated rationale.
+const maxRationaleSessions = 5
+
+// maxHeadingLevel is the deepest ATX heading level.
+const maxHeadingLevel = 6
+
+// supportedBlock is a paragraph, list item or code block suggested by one or more sessions.
+type supportedBlock struct {
+	text     string
+	compare  string // text used for duplicate detection
+	bullet   bool
+	sessions map[string]bool
+	order    int
+}
+
+// supportedSection is a suggested section merged across sessions.
+type supportedSection struct {
+	title    string
+	sessions map[string]bool
+	evidence []Evidence
+	blocks   []*supportedBlock
+	children []*supportedSection
+	order    int
+}
+
+// supportedOperation is a replace or delete operation suggested by one or more sessions.
+type supportedOperation struct {
+	op       Operation
+	sessions map[string]bool
+	order    int
+}
+
+// Consolidator merges suggestions of many sessions into one deduplicated suggestion.
+// Sections are matched by title (with aliases and fuzzy matching) and their contents
+// by normalized text; each section and block records the sessions that suggested it.
+// Operations of the metadata are merged by kind, target and content in the same way.
+type Consolidator struct {
+	opts       MergeOptions
+	root       supportedSection
+	operations []*supportedOperation
+	sessions   []string
+	next       int
+}
+
+// NewConsolidator returns a consolidator using the title matcher and similarity
+// threshold of opts.
+func NewConsolidator(opts MergeOptions) *Consolidator {
+	return &Consolidator{opts: opts}
+}
+
+// Add merges the markdown suggestion of a session and its metadata (which may be nil).
+func (c *Consolidator) Add(sessionID, markdown string, metadata *OperationSet) {
+	if metadata == nil {
+		metadata = &OperationSet{}
+	}
+	c.sessions = append(c.sessions, sessionID)
+	for _, node := range ParseSectionTree(markdown).Children {
+		c.addNode(&c.root, node, sessionID, metadata)
+	}
+	for _, op := range metadata.Operations {
+		c.addOperation(op, sessionID)
+	}
+}
+
+// Sessions returns the number of sessions added.
+func (c *Consolidator) Sessions() int {
+	return len(c.sessions)
+}
+
+// addNode merges a suggested section and its subsections into parent.
+func (c *Consolidator) addNode(parent *supportedSection, node *SectionNode, sessionID string, metadata *OperationSet) {
+	var section *supportedSection
+	for _, child := range parent.children {
+		if _, ok := c.opts.Titles.Match(child.title, node.Title); ok {
+			section = child
+			break
+		}
+	}
+	if section == nil {
+		section = &supportedSection{title: node.Title, sessions: make(map[string]bool), order: c.nextOrder()}
+		parent.children = append(parent.children, section)
+	}
+	section.sessions[sessionID] = true
+	if item := metadata.ItemFor(node.Title); item != nil {
+		section.evidence = mergeEvidence(section.evidence, item.Evidence)
+	}
+
+	body := node.Content[node.BodyOffset-node.StartOffset:]
+	for _, block := range splitBlocks(body) {
+		c.addBlock(section, block, sessionID)
+	}
+	for _, child := range node.Children {
+		c.addNode(section, child, sessionID, metadata)
+	}
+}
+
+// addOperation adds an operation unless another session suggested the same kind of
+// operation on the same section with similar content, in which case the session is
+// counted as supporting the existing operation.
+func (c *Consolidator) addOperation(op Operation, sessionID string) {
+	for _, existing := range c.operations {
+		if existing.op.Op == op.Op && c.sameTarget(existing.op.Target, op.Target) && c.sameContent(existing.op.Content, op.Content) {
+			existing.sessions[sessionID] = true
+			existing.op.Evidence = mergeEvidence(existing.op.Evidence, op.Evidence)
+			return
+		}
+	}
+	c.operations = append(c.operations, &supportedOperation{op: op, sessions: map[string]bool{sessionID: true}, order: c.nextOrder()})
+}
+
+// sameTarget reports whether two section paths name the same section.
+func (c *Consolidator) sameTarget(a, b string) bool {
+	pa, pb := splitSectionPath(a), splitSectionPath(b)
+	if len(pa) != len(pb) {
+		return false
+	}
+	for i := range pa {
+		if _, ok := c.opts.Titles.Match(pa[i], pb[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// sameContent reports whether two operation contents are duplicates. Empty contents
+// (a whole-section delete) only match each other.
+func (c *Consolidator) sameContent(a, b string) bool {
+	if strings.TrimSpace(a) == "" || strings.TrimSpace(b) == "" {
+		return strings.TrimSpace(a) == strings.TrimSpace(b)
+	}
+	return isDuplicateText(a, b, c.opts.SimilarityThreshold)
+}
+
+// mergeEvidence appends the evidence not yet referenced by UUID.
+func mergeEvidence(evidence, more []Evidence) []Evidence {
+	for _, e := range more {
+		if !slices.ContainsFunc(evidence, func(existing Evidence) bool { return existing.UUID == e.UUID }) {
+			evidence = append(evidence, e)
+		}
+	}
+	return evidence
+}
+
+// addBlock adds a block to the section unless it duplicates an existing one,
+// in which case the session is counted as supporting the existing block.
+func (c *Consolidator) addBlock(section *supportedSection, block *supportedBlock, sessionID string) {
+	for _, existing := range section.blocks {
+		if existing.bullet == block.bullet && isDuplicateText(existing.compare, block.compare, c.opts.SimilarityThreshold) {
+			existing.sessions[sessionID] = true
+			return
+		}
+	}
+	block.sessions = map[string]bool{sessionID: true}
+	block.order = c.nextOrder()
+	section.blocks = append(section.blocks, block)
+}
+
+func (c *Consolidator) nextOrder() int {
+	c.next++
+	return c.next
+}
+
+// splitBlocks splits a section body into top-level list items (with their continuation
+// lines), paragraphs and code blocks.
+func splitBlocks(body string) []*supportedBlock {
+	var blocks []*supportedBlock
+	var current []string
+	bullet := false
+	flush := func() {
+		text := strings.TrimRight(strings.Join(current, "\n"), " \t\n")
+		if strings.TrimSpace(text) != "" {
+			compare := text
+			if bullet {
+				compare = bulletLineRegex.FindStringSubmatch(current[0])[2]
+			}
+			blocks = append(blocks, &supportedBlock{text: text, compare: compare, bullet: bullet})
+		}
+		current, bullet = nil, false
+	}
+
+	fence := ""
+	for _, line := range strings.Split(body, "\n") {
+		if fence != "" {
+			current = append(current, line)
+			trimmed := strings.TrimSpace(line)
+			if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+				fence = ""
+			}
+			continue
+		}
+		if matches := fenceOpenRegex.FindStringSubmatch(line); matches != nil {
+			fence = matches[1]
+			current = append(current, line)
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+		if matches := bulletLineRegex.FindStringSubmatch(line); matches != nil && matches[1] == "" {
+			flush()
+			bullet = true
+		}
+		current = append(current, line)
+	}
+	flush()
+	return blocks
+}
+
+// Suggestion renders the consolidated suggestion: sections, their contents and the
+// operations are ranked by the number of supporting sessions, and the support of each
+// is recorded in its rationale in the metadata block.
+func (c *Consolidator) Suggestion() (string, error) {
+	var b strings.Builder
+	var items []ItemRationale
+	c.render(&b, &items, &c.root, nil)
+	operations := c.rankedOperations()
+	if b.Len() == 0 && len(operations) == 0 {
+		return "", nil
+	}
+
+	metadata, err := json.MarshalIndent(OperationSet{Operations: operations, Items: items}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	b.WriteString("```" + metadataInfoString + "\n")
+	b.Write(metadata)
+	b.WriteString("\n```\n")
+	return b.String(), nil
+}
+
+// render writes the children of section ranked by support.
+func (c *Consolidator) render(b *strings.Builder, items *[]ItemRationale, section *supportedSection, path []string) {
+	children := append([]*supportedSection(nil), section.children...)
+	sort.SliceStable(children, func(i, j int) bool {
+		if len(children[i].sessions) != len(children[j].sessions) {
+			return len(children[i].sessions) > len(children[j].sessions)
+		}
+		return children[i].order < children[j].order
+	})
+
+	for _, child := range children {
+		childPath := append(append([]string(nil), path...), child.title)
+		// 見出しは6レベルまでなので、それより深いセクションは6レベルにそろえる
+		fmt.Fprintf(b, "%s %s\n\n", strings.Repeat("#", min(len(childPath)+1, maxHeadingLevel)), child.title)
+
+		blocks := append([]*supportedBlock(nil), child.blocks...)
+		sort.SliceStable(blocks, func(i, j int) bool {
+			if len(blocks[i].sessions) != len(blocks[j].sessions) {
+				return len(blocks[i].sessions) > len(blocks[j].sessions)
+			}
+			return blocks[i].order < blocks[j].order
+		})
+		for i, block := range blocks {
+			b.WriteString(block.text)
+			// 連続する箇条書きは1つのリストにまとめる
+			if block.bullet && i+1 < len(blocks) && blocks[i+1].bullet {
+				b.WriteString("\n")
+			} else {
+				b.WriteString("\n\n")
+			}
+		}
+
+		*items = append(*items, ItemRationale{
+			Target:    strings.Join(childPath, sectionPathSeparator),
+			Rationale: c.supportRationale(child.sessions),
+			Evidence:  child.evidence,
+		})
+		c.render(b, items, child, childPath)
+	}
+}
+
+// rankedOperations returns the operations ranked by support, each with the support
+// added to the rationale of the first session that suggested it.
+func (c *Consolidator) rankedOperations() []Operation {
+	ranked := append([]*supportedOperation(nil), c.operations...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if len(ranked[i].sessions) != len(ranked[j].sessions) {
+			return len(ranked[i].sessions) > len(ranked[j].sessions)
+		}
+		return ranked[i].order < ranked[j].order
+	})
+
+	operations := make([]Operation, 0, len(ranked))
+	for _, s := range ranked {
+		op := s.op
+		op.Rationale = fmt.Sprintf("%s（%s）", op.Rationale, c.supportRationale(s.sessions))
+		operations = append(operations, op)
+	}
+	return operations
+}
+
+// supportRationale describes how many (and which) sessions suggested an item.
+func (c *Consolidator) supportRationale(sessions map[string]bool) string {
+	var ids []string
+	for _, id := range c.sessions {
+		if sessions[id] {
+			ids = append(ids, shortSessionID(id))
+		}
+	}
+	if len(ids) > maxRationaleSessions {
+		ids = append(ids[:maxRationaleSessions], "…")
+	}
+	return fmt.Sprintf("%d/%d件のセッションで提案されました（%s）", len(sessions), len(c.sessions), strings.Join(ids, ", "))
+}
+
+// shortSessionID shortens a UUID session ID to its first group.
+func shortSessionID(id string) string {
+	if i := strings.IndexByte(id, '-'); i >= 8 {
+		return id[:i]
+	}
+	return id
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConsolidator(t *testing.T) {
+	c := NewConsolidator(DefaultMergeOptions())
+	c.Add("session-a", "## Commands\n\n- `make test` runs the tests\n- make lint\n\n## Troubleshooting\n\nRestart the daemon.\n", nil)
+	c.Add("session-b", "## コマンド\n\n- make test runs the tests\n- make build\n", nil)
+	c.Add("session-c", "## Commands\n\n- make lint\n- make test runs the tests\n\n### CI\n\n```sh\nmake ci\n```\n", nil)
+
+	suggestion, err := c.Suggestion()
+	if err != nil {
+		t.Fatalf("Suggestion() unexpected error: %v", err)
+	}
+
+	markdown, metadata, err := SplitSuggestion(suggestion)
+	if err != nil {
+		t.Fatalf("Consolidated suggestion should be valid: %v\n%s", err, suggestion)
+	}
+	want := "## Commands\n\n- `make test` runs the tests\n- make lint\n- make build\n\n### CI\n\n```sh\nmake ci\n```\n\n## Troubleshooting\n\nRestart the daemon.\n"
+	if markdown != want {
+		t.Errorf("markdown =\n%s\nwant:\n%s", markdown, want)
+	}
+
+	tests := map[string]string{
+		"Commands":        "3/3件のセッションで提案されました（session-a, session-b, session-c）",
+		"CI":              "1/3件のセッションで提案されました（session-c）",
+		"Troubleshooting": "1/3件のセッションで提案されました（session-a）",
+	}
+	for title, wantRationale := range tests {
+		item := metadata.ItemFor(title)
+		if item == nil || item.Rationale != wantRationale {
+			t.Errorf("ItemFor(%s) = %+v, want %q", title, item, wantRationale)
+		}
+	}
+	if v := ValidateSuggestion(suggestion, FormatMarkdown); !v.Valid() {
+		t.Errorf("Consolidated suggestion should pass validation: %v", v.Problems)
+	}
+}
+
+func TestConsolidator_Metadata(t *testing.T) {
+	c := NewConsolidator(DefaultMergeOptions())
+	c.Add("session-a", "## Commands\n\n- make test\n", &OperationSet{
+		Operations: []Operation{
+			{Op: OpReplace, Target: "Commands > Build", Content: "Run `make build`.", Rationale: "Makefileに移行", Evidence: []Evidence{{UUID: "u1"}}},
+			{Op: OpDelete, Target: "Legacy", Rationale: "廃止"},
+		},
+		Items: []ItemRationale{{Target: "Commands", Rationale: "r", Evidence: []Evidence{{UUID: "u0"}}}},
+	})
+	c.Add("session-b", "", &OperationSet{
+		Operations: []Operation{
+			{Op: OpDelete, Target: "Legacy", Rationale: "使われていない", Evidence: []Evidence{{UUID: "u2"}}},
+			{Op: OpReplace, Target: "コマンド > Build", Content: "Run make build.", Rationale: "ビルド方法の変更", Evidence: []Evidence{{UUID: "u3"}, {UUID: "u1"}}},
+		},
+	})
+	c.Add("session-c", "## Commands\n\n- make test\n", &OperationSet{
+		Operations: []Operation{{Op: OpReplace, Target: "Commands > Build", Content: "Use bazel.", Rationale: "別の提案"}},
+		Items:      []ItemRationale{{Target: "Commands", Rationale: "r", Evidence: []Evidence{{UUID: "u4"}, {UUID: "u0"}}}},
+	})
+
+	suggestion, err := c.Suggestion()
+	if err != nil {
+		t.Fatalf("Suggestion() unexpected error: %v", err)
+	}
+	_, metadata, err := SplitSuggestion(suggestion)
+	if err != nil {
+		t.Fatalf("Consolidated suggestion should be valid: %v\n%s", err, suggestion)
+	}
+
+	// 同じ対象・内容の操作はまとめ、支持の多い順に並べる
+	want := []struct {
+		op        OperationType
+		content   string
+		rationale string
+		evidence  int
+	}{
+		{OpReplace, "Run `make build`.", "Makefileに移行（2/3件のセッションで提案されました（session-a, session-b））", 2},
+		{OpDelete, "", "廃止（2/3件のセッションで提案されました（session-a, session-b））", 1},
+		{OpReplace, "Use bazel.", "別の提案（1/3件のセッションで提案されました（session-c））", 0},
+	}
+	if len(metadata.Operations) != len(want) {
+		t.Fatalf("Operations = %+v, want %d operations", metadata.Operations, len(want))
+	}
+	for i, w := range want {
+		op := metadata.Operations[i]
+		if op.Op != w.op || op.Content != w.content || op.Rationale != w.rationale || len(op.Evidence) != w.evidence {
+			t.Errorf("Operations[%d] = %+v, want %+v", i, op, w)
+		}
+	}
+
+	// 各セッションの根拠はセクションごとにまとめる
+	if item := metadata.ItemFor("Commands"); item == nil || len(item.Evidence) != 2 {
+		t.Errorf("ItemFor(Commands) = %+v, want 2 evidence", item)
+	}
+}
+
+func TestConsolidator_OperationsOnly(t *testing.T) {
+	c := NewConsolidator(DefaultMergeOptions())
+	c.Add("session-a", "", &OperationSet{Operations: []Operation{{Op: OpDelete, Target: "Legacy", Rationale: "廃止"}}})
+	suggestion, err := c.Suggestion()
+	if err != nil {
+		t.Fatalf("Suggestion() unexpected error: %v", err)
+	}
+	markdown, metadata, err := SplitSuggestion(suggestion)
+	if err != nil || strings.TrimSpace(markdown) != "" || len(metadata.Operations) != 1 {
+		t.Errorf("SplitSuggestion() = %q, %+v, %v", markdown, metadata, err)
+	}
+}
+
+func TestConsolidator_DeepHeadings(t *testing.T) {
+	c := NewConsolidator(DefaultMergeOptions())
+	c.Add("session-a", "# Top\n\n## A\n\n### B\n\n#### C\n\n##### D\n\n###### E\n\ntext\n", nil)
+	c.Add("session-b", "# Top\n\n## A\n\n### B\n\n#### C\n\n##### D\n\n###### E\n\n- x\n", nil)
+
+	suggestion, err := c.Suggestion()
+	if err != nil {
+		t.Fatalf("Suggestion() unexpected error: %v", err)
+	}
+	if strings.Contains(suggestion, "#######") {
+		t.Errorf("Heading levels should be at most 6:\n%s", suggestion)
+	}
+	if !strings.Contains(suggestion, "###### E\n") {
+		t.Errorf("Suggestion should contain the level 6 heading:\n%s", suggestion)
+	}
+}
+
+func TestConsolidator_Empty(t *testing.T) {
+	c := NewConsolidator(DefaultMergeOptions())
+	c.Add("session-a", "no sections\n", nil)
+	suggestion, err := c.Suggestion()
+	if err != nil || suggestion != "" {
+		t.Errorf("Suggestion() = %q, %v; want empty", suggestion, err)
+	}
+}
+
+func TestSplitBlocks(t *testing.T) {
+	body := "\nIntro line\ncontinued\n\n- item 1\n  detail\n- item 2\n\n```\ncode\n\nmore\n```\n"
+	blocks := splitBlocks(body)
+	var got []string
+	for _, block := range blocks {
+		got = append(got, block.text)
+	}
+	want := []string{"Intro line\ncontinued", "- item 1\n  detail", "- item 2", "```\ncode\n\nmore\n```"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("splitBlocks() = %q, want %q", got, want)
+	}
+	if blocks[1].compare != "item 1" || !blocks[1].bullet {
+		t.Errorf("Bullet block = %+v", blocks[1])
+	}
+}
+
+func TestShortSessionID(t *testing.T) {
+	if got := shortSessionID("602a7e81-2f30-4582-a58e-b717cfd29e1d"); got != "602a7e81" {
+		t.Errorf("shortSessionID() = %s", got)
+	}
+	if got := shortSessionID("session-a"); got != "session-a" {
+		t.Errorf("shortSessionID() = %s", got)
+	}
+}
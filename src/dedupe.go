@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// DuplicatePolicy decides what to do with a suggested subsection that already exists.
+type DuplicatePolicy string
+
+const (
+	// DuplicateSkip drops the suggested subsection
+	DuplicateSkip DuplicatePolicy = "skip"
+	// DuplicateReplace replaces the existing subsection with the suggested one
+	DuplicateReplace DuplicatePolicy = "replace"
+	// DuplicateMerge adds only the new bullet items to the existing subsection
+	DuplicateMerge DuplicatePolicy = "merge"
+)
+
+const (
+	defaultSimilarityThreshold = 0.85
+	// fuzzyMinRunes is the minimum length for similarity matching;
+	// shorter texts (e.g. "sync" and "async") must match exactly.
+	fuzzyMinRunes = 12
+)
+
+// MergeOptions controls title matching and duplicate handling in MergeSectionsWithOptions.
+type MergeOptions struct {
+	Duplicates DuplicatePolicy
+	// SimilarityThreshold is the minimum similarity (0-1) for two texts
+	// to be treated as duplicates after normalization.
+	SimilarityThreshold float64
+	// Titles matches suggested section titles to existing ones
+	Titles *TitleMatcher
+}
+
+// DefaultMergeOptions returns the default merge options.
+func DefaultMergeOptions() MergeOptions {
+	return MergeOptions{
+		Duplicates:          DuplicateSkip,
+		SimilarityThreshold: defaultSimilarityThreshold,
+		Titles:              NewTitleMatcher(nil, defaultTitleSimilarityThreshold),
+	}
+}
+
+// ParseDuplicatePolicy parses a duplicate policy name.
+func ParseDuplicatePolicy(value string) (DuplicatePolicy, error) {
+	switch policy := DuplicatePolicy(strings.ToLower(strings.TrimSpace(value))); policy {
+	case DuplicateSkip, DuplicateReplace, DuplicateMerge:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("無効な重複処理: %s (有効な値: skip, replace, merge)", value)
+	}
+}
+
+var (
+	bulletLineRegex = regexp.MustCompile(`^([ \t]*)(?:[-*+]|\d{1,9}[.)])[ \t]+(.*)$`)
+	markupRegex     = regexp.MustCompile("[*_`~]+|\\[([^\\]]*)\\]\\([^)]*\\)")
+)
+
+// normalizeForCompare normalizes text for duplicate detection:
+// Markdown markup, punctuation and case differences are ignored.
+func normalizeForCompare(text string) string {
+	text = markupRegex.ReplaceAllString(text, "$1")
+	var b strings.Builder
+	space := false
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			space = false
+			b.WriteRune(r)
+		default:
+			space = true
+		}
+	}
+	return b.String()
+}
+
+// similarity returns the Dice coefficient of the character bigrams of a and b.
+// Character bigrams work for both space-separated and Japanese text.
+func similarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) < 2 || len(rb) < 2 {
+		return 0
+	}
+
+	bigrams := make(map[string]int)
+	for i := 0; i < len(ra)-1; i++ {
+		bigrams[string(ra[i:i+2])]++
+	}
+	matches := 0
+	for i := 0; i < len(rb)-1; i++ {
+		key := string(rb[i : i+2])
+		if bigrams[key] > 0 {
+			bigrams[key]--
+			matches++
+		}
+	}
+	return float64(2*matches) / float64(len(ra)+len(rb)-2)
+}
+
+// isDuplicateText reports whether two texts are the same after normalization
+// or similar enough to be considered duplicates.
+func isDuplicateText(a, b string, threshold float64) bool {
+	na, nb := normalizeForCompare(a), normalizeForCompare(b)
+	if na == "" || nb == "" {
+		return false
+	}
+	if na == nb {
+		return true
+	}
+	if len([]rune(na)) < fuzzyMinRunes || len([]rune(nb)) < fuzzyMinRunes {
+		return false
+	}
+	return similarity(na, nb) >= threshold
+}
+
+// extractBullets returns the text of the list items in content.
+func extractBullets(content string) []string {
+	var bullets []string
+	for _, line := range strings.Split(content, "\n") {
+		if matches := bulletLineRegex.FindStringSubmatch(line); matches != nil {
+			bullets = append(bullets, matches[2])
+		}
+	}
+	return bullets
+}
+
+// isDuplicateBullet reports whether bullet duplicates one of existing.
+func isDuplicateBullet(bullet string, existing []string, threshold float64) bool {
+	for _, e := range existing {
+		if isDuplicateText(bullet, e, threshold) {
+			return true
+		}
+	}
+	return false
+}
+
+// removeDuplicateBullets removes list items of content that duplicate existing items,
+// together with their continuation lines. It returns the remaining content and
+// the number of removed items.
+func removeDuplicateBullets(content string, existing []string, threshold float64) (string, int) {
+	lines := strings.Split(content, "\n")
+	var kept []string
+	removed := 0
+	skipIndent := -1 // 削除した項目の継続行をスキップするためのインデント
+	for _, line := range lines {
+		if skipIndent >= 0 {
+			indent, rest := leadingIndent(line)
+			if rest != "" && indent > skipIndent {
+				continue
+			}
+			skipIndent = -1
+		}
+		if matches := bulletLineRegex.FindStringSubmatch(line); matches != nil &&
+			isDuplicateBullet(matches[2], existing, threshold) {
+			removed++
+			skipIndent, _ = leadingIndent(matches[1])
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return strings.Join(kept, "\n"), removed
+}
+
+// hasBody reports whether section text has content other than headings and blank lines.
+func hasBody(content string) bool {
+	headings := ScanHeadings(content)
+	lines := splitLines(content)
+	isHeadingLine := make(map[int]bool)
+	for _, h := range headings {
+		for i := h.StartLine; i <= h.EndLine; i++ {
+			isHeadingLine[i] = true
+		}
+	}
+	for i, line := range lines {
+		if !isHeadingLine[i] && strings.TrimSpace(line.text) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// lastBulletEnd returns the offset just after the last list item (and its continuation
+// lines) in content[start:end], or -1 if there is no list item.
+func lastBulletEnd(content string, start, end int) int {
+	pos := -1
+	inItem := false
+	for _, line := range splitLines(content[start:end]) {
+		if bulletLineRegex.MatchString(line.text) {
+			pos = start + line.end
+			inItem = true
+			continue
+		}
+		if inItem && strings.TrimSpace(line.text) != "" && (line.text[0] == ' ' || line.text[0] == '\t') {
+			pos = start + line.end
+			continue
+		}
+		inItem = false
+	}
+	return pos
+}
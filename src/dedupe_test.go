@@ -0,0 +1,250 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseDuplicatePolicy(t *testing.T) {
+	for _, value := range []string{"skip", "Replace", " merge "} {
+		if _, err := ParseDuplicatePolicy(value); err != nil {
+			t.Errorf("ParseDuplicatePolicy(%q) error = %v", value, err)
+		}
+	}
+	if _, err := ParseDuplicatePolicy("overwrite"); err == nil {
+		t.Error("ParseDuplicatePolicy() should reject unknown policy")
+	}
+}
+
+func TestNormalizeForCompare(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"Run `make build` to build.", "run make build to build"},
+		{"**Important**: use [mise](https://mise.jdx.dev)!", "important use mise"},
+		{"  go   test ./...  ", "go test"},
+		{"`go test`を実行する", "go testを実行する"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeForCompare(tt.input); got != tt.want {
+			t.Errorf("normalizeForCompare(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestIsDuplicateText(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want bool
+	}{
+		{"exact", "Run make build", "Run make build", true},
+		{"markup and case", "Run `make build`", "run make build.", true},
+		{"near duplicate", "Use go test ./... to run all tests", "Use go test ./... to run all the tests", true},
+		{"japanese near duplicate", "テストは make test で実行する", "テストは make test で実行します", true},
+		{"different", "Run make build", "Deploy with terraform apply", false},
+		{"empty", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isDuplicateText(tt.a, tt.b, defaultSimilarityThreshold); got != tt.want {
+				t.Errorf("isDuplicateText(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoveDuplicateBullets(t *testing.T) {
+	content := "Intro.\n\n- make build\n  builds the binary\n- make lint\n1. go test ./...\n"
+	existing := []string{"`make build`", "go test ./..."}
+
+	result, removed := removeDuplicateBullets(content, existing, defaultSimilarityThreshold)
+
+	if removed != 2 {
+		t.Errorf("Removed %d bullets, want 2", removed)
+	}
+	want := "Intro.\n\n- make lint\n"
+	if result != want {
+		t.Errorf("removeDuplicateBullets() = %q, want %q", result, want)
+	}
+}
+
+func TestLastBulletEnd(t *testing.T) {
+	content := "## A\n\n- one\n- two\n  continued\n\nText.\n"
+	pos := lastBulletEnd(content, 0, len(content))
+	if content[:pos] != "## A\n\n- one\n- two\n  continued\n" {
+		t.Errorf("lastBulletEnd() = %d (%q)", pos, content[:pos])
+	}
+
+	if pos := lastBulletEnd("Text only.\n", 0, 11); pos != -1 {
+		t.Errorf("lastBulletEnd() without bullets = %d, want -1", pos)
+	}
+}
+
+func TestMergeSectionsWithOptions_DuplicateSubsection(t *testing.T) {
+	existing := `# Project
+
+## Commands
+
+### Build
+
+- make build
+
+## Testing
+
+- go test
+`
+
+	suggestion := `## Commands
+
+### Build
+
+- make build
+- make build-linux
+
+### Lint
+
+- make lint
+`
+
+	tests := []struct {
+		name        string
+		policy      DuplicatePolicy
+		wantAction  MergeAction
+		wantContent string
+	}{
+		{
+			name:       "skip",
+			policy:     DuplicateSkip,
+			wantAction: MergeDuplicate,
+			wantContent: `## Commands
+
+### Build
+
+- make build
+
+### Lint
+
+- make lint
+
+## Testing`,
+		},
+		{
+			name:       "replace",
+			policy:     DuplicateReplace,
+			wantAction: MergeReplace,
+			wantContent: `## Commands
+
+### Build
+
+- make build
+- make build-linux
+
+### Lint
+
+- make lint
+
+## Testing`,
+		},
+		{
+			name:       "merge",
+			policy:     DuplicateMerge,
+			wantAction: MergeBullets,
+			wantContent: `## Commands
+
+### Build
+
+- make build
+- make build-linux
+
+### Lint
+
+- make lint
+
+## Testing`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultMergeOptions()
+			opts.Duplicates = tt.policy
+
+			result := MergeSectionsWithOptions(existing, suggestion, opts)
+
+			if !strings.Contains(result.Content, tt.wantContent) {
+				t.Errorf("Content =\n%s\nwant to contain:\n%s", result.Content, tt.wantContent)
+			}
+			if strings.Count(result.Content, "### Build") != 1 {
+				t.Errorf("Build subsection should appear once, got:\n%s", result.Content)
+			}
+			if len(result.Decisions) != 2 || result.Decisions[0].Action != tt.wantAction {
+				t.Errorf("Unexpected decisions: %+v", result.Decisions)
+			}
+		})
+	}
+}
+
+func TestMergeSectionsWithOptions_DuplicateBullets(t *testing.T) {
+	existing := "## Commands\n\n- Run `make build` to build\n\n## Other\n"
+	suggestion := "## Commands\n\n- run make build to build.\n- make test\n"
+
+	result := MergeSections(existing, suggestion)
+
+	want := "## Commands\n\n- Run `make build` to build\n\n- make test\n\n## Other\n"
+	if result.Content != want {
+		t.Errorf("Content = %q, want %q", result.Content, want)
+	}
+	if result.Decisions[0].RemovedBullets != 1 {
+		t.Errorf("Expected 1 removed bullet, got %+v", result.Decisions[0])
+	}
+}
+
+func TestMergeSectionsWithOptions_AllBulletsDuplicate(t *testing.T) {
+	existing := "## Commands\n\n- make build\n"
+	suggestion := "## Commands\n\n- make build\n"
+
+	result := MergeSections(existing, suggestion)
+
+	if result.Content != existing {
+		t.Errorf("Content should be unchanged, got %q", result.Content)
+	}
+	if len(result.Decisions) != 1 || result.Decisions[0].Action != MergeDuplicate {
+		t.Errorf("Unexpected decisions: %+v", result.Decisions)
+	}
+}
+
+func TestMergeSectionsWithOptions_SimilarSubsectionBody(t *testing.T) {
+	// タイトルが異なっても本文がほぼ同じサブセクションは重複とみなす
+	existing := "## Notes\n\n### Hooks\n\nHooks run on SessionEnd and PreCompact.\n"
+	suggestion := "## Notes\n\n### Hook events\n\nHooks run on SessionEnd and PreCompact events.\n"
+
+	result := MergeSections(existing, suggestion)
+
+	if result.Content != existing {
+		t.Errorf("Content should be unchanged, got %q", result.Content)
+	}
+	if result.Decisions[0].Action != MergeDuplicate || result.Decisions[0].Target != "Notes > Hooks" {
+		t.Errorf("Unexpected decisions: %+v", result.Decisions)
+	}
+}
+
+func TestMergeSectionsWithOptions_ReplaceOnce(t *testing.T) {
+	existing := "## A\n\n### Build\n\nold\n"
+	suggestion := "## A\n\n### Build\n\nnew 1\n\n## A\n\n### Build\n\nnew 2\n"
+
+	opts := DefaultMergeOptions()
+	opts.Duplicates = DuplicateReplace
+	result := MergeSectionsWithOptions(existing, suggestion, opts)
+
+	want := "## A\n\n### Build\n\nnew 1\n"
+	if result.Content != want {
+		t.Errorf("Content = %q, want %q", result.Content, want)
+	}
+	if result.Decisions[1].Reason != "既に置き換え済み" {
+		t.Errorf("Second replacement should be skipped, got %+v", result.Decisions[1])
+	}
+}
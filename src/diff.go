@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const defaultDiffContext = 3
+
+// DiffLine is a line of a diff hunk.
+type DiffLine struct {
+	Kind byte // ' ' (context), '-' (removed) or '+' (added)
+	Text string
+}
+
+// DiffHunk is a group of changed lines with surrounding context, in unified diff form.
+type DiffHunk struct {
+	OldStart, OldLines int
+	NewStart, NewLines int
+	Lines              []DiffLine
+}
+
+// String formats the hunk as in a unified diff.
+func (h DiffHunk) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@\n", h.OldStart, h.OldLines, h.NewStart, h.NewLines)
+	for _, line := range h.Lines {
+		b.WriteByte(line.Kind)
+		b.WriteString(line.Text)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// UnifiedDiff compares two texts line by line and returns the changed hunks
+// with the given number of context lines.
+func UnifiedDiff(oldText, newText string, context int) []DiffHunk {
+	lines := diffLines(splitTextLines(oldText), splitTextLines(newText))
+
+	// 変更行の前後context行をまとめてハンクにする
+	var hunks []DiffHunk
+	oldNo, newNo := 1, 1
+	i := 0
+	for i < len(lines) {
+		if lines[i].Kind == ' ' {
+			i++
+			oldNo++
+			newNo++
+			continue
+		}
+
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		hunk := DiffHunk{OldStart: oldNo - (i - start), NewStart: newNo - (i - start)}
+		hunk.Lines = append(hunk.Lines, lines[start:i]...)
+		hunk.OldLines, hunk.NewLines = i-start, i-start
+
+		unchanged := 0
+		for i < len(lines) && unchanged <= 2*context {
+			line := lines[i]
+			if line.Kind == ' ' {
+				unchanged++
+			} else {
+				unchanged = 0
+			}
+			hunk.Lines = append(hunk.Lines, line)
+			switch line.Kind {
+			case ' ':
+				hunk.OldLines++
+				hunk.NewLines++
+				oldNo++
+				newNo++
+			case '-':
+				hunk.OldLines++
+				oldNo++
+			case '+':
+				hunk.NewLines++
+				newNo++
+			}
+			i++
+		}
+
+		// 末尾の余分なコンテキスト行を取り除く
+		if extra := unchanged - context; extra > 0 {
+			hunk.Lines = hunk.Lines[:len(hunk.Lines)-extra]
+			hunk.OldLines -= extra
+			hunk.NewLines -= extra
+		}
+		hunks = append(hunks, hunk)
+	}
+	return hunks
+}
+
+// splitTextLines splits text into lines without the trailing empty line.
+func splitTextLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// diffLines computes a line diff from the longest common subsequence.
+func diffLines(a, b []string) []DiffLine {
+	// lcs[i][j] は a[i:] と b[j:] の最長共通部分列の長さ
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var lines []DiffLine
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, DiffLine{Kind: ' ', Text: a[i]})
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] > lcs[i+1][j]):
+			lines = append(lines, DiffLine{Kind: '+', Text: b[j]})
+			j++
+		default:
+			lines = append(lines, DiffLine{Kind: '-', Text: a[i]})
+			i++
+		}
+	}
+	return lines
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	oldText := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	newText := "a\nb\nc\nd\nE\nf\ng\nh\ni\nj\nk\n"
+
+	hunks := UnifiedDiff(oldText, newText, 1)
+	if len(hunks) != 2 {
+		t.Fatalf("Expected 2 hunks, got %d: %v", len(hunks), hunks)
+	}
+
+	want := "@@ -4,3 +4,3 @@\n d\n-e\n+E\n f\n"
+	if got := hunks[0].String(); got != want {
+		t.Errorf("hunk[0] =\n%s\nwant:\n%s", got, want)
+	}
+	want = "@@ -10,1 +10,2 @@\n j\n+k\n"
+	if got := hunks[1].String(); got != want {
+		t.Errorf("hunk[1] =\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestUnifiedDiff_MergesNearbyChanges(t *testing.T) {
+	oldText := "a\nb\nc\nd\ne\n"
+	newText := "A\nb\nc\nd\nE\n"
+
+	hunks := UnifiedDiff(oldText, newText, 3)
+	if len(hunks) != 1 {
+		t.Fatalf("Expected 1 hunk, got %d: %v", len(hunks), hunks)
+	}
+	if hunks[0].OldLines != 5 || hunks[0].NewLines != 5 {
+		t.Errorf("Unexpected hunk size: %+v", hunks[0])
+	}
+}
+
+func TestUnifiedDiff_NoChanges(t *testing.T) {
+	if hunks := UnifiedDiff("a\nb\n", "a\nb\n", 3); len(hunks) != 0 {
+		t.Errorf("Expected no hunks, got %v", hunks)
+	}
+}
+
+func TestUnifiedDiff_Deletion(t *testing.T) {
+	hunks := UnifiedDiff("## A\n\nold\n\n## B\n", "## B\n", 3)
+	if len(hunks) != 1 {
+		t.Fatalf("Expected 1 hunk, got %d", len(hunks))
+	}
+	got := hunks[0].String()
+	for _, want := range []string{"-## A\n", "-old\n", " ## B\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("hunk should contain %q, got:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// claudeVersionTimeout limits how long `claude --version` may take during diagnostics.
+const claudeVersionTimeout = 10 * time.Second
+
+// CheckStatus is the result of a diagnostic check.
+type CheckStatus int
+
+const (
+	// CheckPass means the check found no problem
+	CheckPass CheckStatus = iota
+	// CheckWarn means the hook works but something may not behave as expected
+	CheckWarn
+	// CheckFail means the hook cannot work until the problem is fixed
+	CheckFail
+)
+
+// Icon returns the mark printed for the status.
+func (s CheckStatus) Icon() string {
+	switch s {
+	case CheckPass:
+		return "✅"
+	case CheckWarn:
+		return "⚠️ "
+	default:
+		return "❌"
+	}
+}
+
+// DoctorCheck is the outcome of one diagnostic check with how to fix it.
+type DoctorCheck struct {
+	Name   string
+	Status CheckStatus
+	Detail string
+	Fixes  []string
+}
+
+// RunDoctorChecks diagnoses why the hook might silently do nothing.
+func RunDoctorChecks(getwd func() (string, error), getenv func(string) string) []DoctorCheck {
+	return []DoctorCheck{
+		checkClaudeCLI(),
+		checkHooks(),
+		checkSettingsFiles(),
+		checkConfigFile(getenv),
+		checkOutputDir(getenv),
+		checkLogFile(getenv),
+		checkBudget(getenv, time.Now()),
+		checkRecursionGuard(getenv),
+		checkClaudeMd(getwd),
+		checkTranscriptParsing(getwd),
+	}
+}
+
+// runDoctor prints the diagnostic results and fails if any check failed.
+func runDoctor(output io.Writer, getwd func() (string, error), getenv func(string) string) error {
+	_, _ = fmt.Fprintln(output, "🩺 suggest-claude-md の環境診断") // nolint:errcheck // Output to user, error not critical
+	_, _ = fmt.Fprintln(output)                              // nolint:errcheck // Output to user, error not critical
+
+	failed := 0
+	for _, check := range RunDoctorChecks(getwd, getenv) {
+		_, _ = fmt.Fprintf(output, "%s %s: %s\n", check.Status.Icon(), check.Name, check.Detail) // nolint:errcheck // Output to user, error not critical
+		for _, fix := range check.Fixes {
+			_, _ = fmt.Fprintf(output, "   → %s\n", fix) // nolint:errcheck // Output to user, error not critical
+		}
+		if check.Status == CheckFail {
+			failed++
+		}
+	}
+
+	_, _ = fmt.Fprintln(output) // nolint:errcheck // Output to user, error not critical
+	if failed > 0 {
+		return fmt.Errorf("%d件のチェックが失敗しました", failed)
+	}
+	_, _ = fmt.Fprintln(output, "✅ すべての必須チェックに合格しました") // nolint:errcheck // Output to user, error not critical
+	return nil
+}
+
+// checkClaudeCLI checks that the claude command run by the executor exists and responds.
+func checkClaudeCLI() DoctorCheck {
+	check := DoctorCheck{Name: "claude CLI"}
+	path, err := exec.LookPath(claudeCommand)
+	if err != nil {
+		check.Status = CheckFail
+		check.Detail = fmt.Sprintf("%s が見つかりません", claudeCommand)
+		check.Fixes = []string{"Claude Codeをインストールし、claudeコマンドをPATHに追加してください"}
+		return check
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), claudeVersionTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, "--version").Output()
+	if err != nil {
+		check.Status = CheckFail
+		check.Detail = fmt.Sprintf("%s --version の実行に失敗: %v", path, err)
+		check.Fixes = []string{"claude --version を手動で実行し、エラーを確認してください"}
+		return check
+	}
+	version := strings.TrimSpace(strings.SplitN(string(out), "\n", 2)[0])
+	check.Detail = fmt.Sprintf("%s (%s)", path, version)
+	return check
+}
+
+// doctorSettingsPaths returns the settings file of each scope.
+func doctorSettingsPaths() ([]string, map[string]string) {
+	scopes := []string{scopeUser, scopeProject, scopeLocal}
+	paths := make(map[string]string)
+	for _, scope := range scopes {
+		if path, err := settingsPathForScope(scope, false); err == nil {
+			paths[scope] = path
+		}
+	}
+	return scopes, paths
+}
+
+// checkHooks checks that hooks are installed and point to an existing binary.
+func checkHooks() DoctorCheck {
+	check := DoctorCheck{Name: "フック"}
+	scopes, paths := doctorSettingsPaths()
+	status := collectHookStatus(paths, scopes)
+
+	if len(status.Installations) == 0 {
+		check.Status = CheckFail
+		check.Detail = "suggest-claude-mdのフックが登録されていません"
+		check.Fixes = []string{"suggest-claude-md --install-hook user（またはproject / local）でインストールしてください"}
+		return check
+	}
+
+	var stale []string
+	for _, installation := range status.Installations {
+		if installation.Stale() {
+			stale = append(stale, fmt.Sprintf("%s (%s): %s", installation.Scope, installation.Event, installation.Command))
+			check.Fixes = append(check.Fixes, fmt.Sprintf("suggest-claude-md --install-hook %s で再インストールしてください", installation.Scope))
+		}
+	}
+	if len(stale) > 0 {
+		check.Status = CheckFail
+		check.Detail = "フックのコマンドが見つかりません: " + strings.Join(stale, ", ")
+		check.Fixes = uniqueStrings(check.Fixes)
+		return check
+	}
+
+	var locations []string
+	for _, installation := range status.Installations {
+		locations = append(locations, fmt.Sprintf("%s (%s)", installation.Event, installation.Scope))
+	}
+	check.Detail = strings.Join(locations, ", ")
+	for _, warning := range status.Warnings {
+		check.Status = CheckWarn
+		check.Fixes = append(check.Fixes, warning+": suggest-claude-md uninstall-hook <scope> で不要な登録を削除してください")
+	}
+	return check
+}
+
+// checkSettingsFiles checks that existing settings files are valid JSON.
+func checkSettingsFiles() DoctorCheck {
+	check := DoctorCheck{Name: "settings.json"}
+	scopes, paths := doctorSettingsPaths()
+
+	var valid, invalid []string
+	for _, scope := range scopes {
+		path, ok := paths[scope]
+		if !ok {
+			continue
+		}
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			continue
+		}
+		if _, err := loadSettings(path); err != nil {
+			invalid = append(invalid, fmt.Sprintf("%s: %v", path, err))
+			continue
+		}
+		valid = append(valid, path)
+	}
+
+	switch {
+	case len(invalid) > 0:
+		check.Status = CheckFail
+		check.Detail = "設定ファイルを読み込めません: " + strings.Join(invalid, "; ")
+		check.Fixes = []string{"JSONの構文エラーを修正してください（Claude Codeも設定を読み込めません）"}
+	case len(valid) == 0:
+		check.Status = CheckWarn
+		check.Detail = "設定ファイルがありません"
+		check.Fixes = []string{"suggest-claude-md --install-hook user で作成できます"}
+	default:
+		check.Detail = strings.Join(valid, ", ")
+	}
+	return check
+}
+
+// checkConfigFile checks the suggest-claude-md configuration file.
+func checkConfigFile(getenv func(string) string) DoctorCheck {
+	check := DoctorCheck{Name: "設定ファイル"}
+	path := ConfigPath(getenv)
+	config, err := LoadConfig(path)
+	if err == nil {
+		_, err = ParseSuggestionFormat(config.SuggestionFormat)
+	}
+	if err == nil {
+		_, err = executionModeFor(config, getenv)
+	}
+	if err == nil {
+		_, err = NewNotifiers(config)
+	}
+	if err == nil {
+		err = config.Skip.Validate()
+	}
+	if err == nil {
+		_, err = logOptionsFor(config, getenv)
+	}
+	if err == nil {
+		err = config.ValidateBudget()
+	}
+	if err != nil {
+		check.Status = CheckFail
+		check.Detail = err.Error()
+		check.Fixes = []string{fmt.Sprintf("%s を修正してください", path)}
+		return check
+	}
+	if _, statErr := os.Stat(path); os.IsNotExist(statErr) {
+		check.Detail = fmt.Sprintf("%s（なし、既定値を使用）", path)
+		return check
+	}
+	check.Detail = path
+	return check
+}
+
+// checkOutputDir checks that logs and suggestions can be written.
+func checkOutputDir(getenv func(string) string) DoctorCheck {
+	check := DoctorCheck{Name: "出力ディレクトリ"}
+	dir := ResolveOutputDir(getenv)
+	fixes := []string{"XDG_RUNTIME_DIR または TMPDIR を書き込み可能なディレクトリに設定してください"}
+
+	if err := EnsurePrivateDir(dir); err != nil {
+		check.Status = CheckFail
+		check.Detail = err.Error()
+		check.Fixes = fixes
+		return check
+	}
+	file, err := os.CreateTemp(dir, "doctor-*")
+	if err != nil {
+		check.Status = CheckFail
+		check.Detail = fmt.Sprintf("%s に書き込めません: %v", dir, err)
+		check.Fixes = fixes
+		return check
+	}
+	_ = file.Close()           // nolint:errcheck // Only checking that the file can be created
+	_ = os.Remove(file.Name()) // nolint:errcheck // Best-effort cleanup
+	check.Detail = dir
+	return check
+}
+
+// checkLogFile shows where the structured log is written, to be attached to bug reports.
+func checkLogFile(getenv func(string) string) DoctorCheck {
+	check := DoctorCheck{Name: "ログ"}
+	config, err := LoadConfig(ConfigPath(getenv))
+	var opts LogOptions
+	if err == nil {
+		opts, err = logOptionsFor(config, getenv)
+	}
+	if err != nil {
+		check.Status = CheckFail
+		check.Detail = err.Error()
+		check.Fixes = []string{fmt.Sprintf("設定ファイルの log_level / log_format か %s / %s を修正してください", logLevelEnvVar, logFormatEnvVar)}
+		return check
+	}
+	if opts.File == "off" {
+		check.Status = CheckWarn
+		check.Detail = "ログは無効です"
+		check.Fixes = []string{"不具合の調査には log_file を指定してログを有効にしてください"}
+		return check
+	}
+	check.Detail = fmt.Sprintf("%s（レベル: %s、形式: %s）", opts.File, strings.ToLower(opts.Level.String()), opts.Format)
+	return check
+}
+
+// checkBudget shows this month's estimated cost and warns when the budget makes hooks skip.
+func checkBudget(getenv func(string) string, now time.Time) DoctorCheck {
+	check := DoctorCheck{Name: "使用量"}
+	config, err := LoadConfig(ConfigPath(getenv))
+	var records []UsageRecord
+	if err == nil {
+		records, err = LoadUsage(UsagePath(config, getenv))
+	}
+	if err != nil {
+		check.Status = CheckFail
+		check.Detail = err.Error()
+		return check
+	}
+	spent := monthlySpend(records, now)
+	if config.MonthlyBudgetUSD <= 0 {
+		check.Detail = fmt.Sprintf("今月の推定コスト $%.2f（予算なし）", spent)
+		return check
+	}
+	check.Detail = fmt.Sprintf("今月の推定コスト $%.2f / 予算 $%.2f", spent, config.MonthlyBudgetUSD)
+	if spent >= config.MonthlyBudgetUSD {
+		check.Status = CheckWarn
+		check.Detail += "（予算に達したため、フックは分析をスキップします）"
+		check.Fixes = []string{"設定ファイルの monthly_budget_usd を増やすか、来月まで待ってください"}
+	}
+	return check
+}
+
+// checkRecursionGuard warns when the recursion guard is set in the current environment,
+// which makes every hook run skip.
+func checkRecursionGuard(getenv func(string) string) DoctorCheck {
+	check := DoctorCheck{Name: "再帰実行防止"}
+	if reason := environmentRecursionGuard(getenv); reason != "" {
+		check.Status = CheckWarn
+		check.Detail = fmt.Sprintf("この環境からのフック実行はスキップされます: %s", reason)
+		check.Fixes = []string{
+			fmt.Sprintf("suggest-claude-mdが起動したclaudeの中でなければ unset %s を実行してください", recursionGuardEnvVar),
+		}
+		return check
+	}
+	check.Detail = fmt.Sprintf("%s は未設定", recursionGuardEnvVar)
+	return check
+}
+
+// checkClaudeMd checks that CLAUDE.md is where suggestions are applied.
+func checkClaudeMd(getwd func() (string, error)) DoctorCheck {
+	check := DoctorCheck{Name: "CLAUDE.md"}
+	projectRoot, err := getwd()
+	if err != nil {
+		check.Status = CheckFail
+		check.Detail = fmt.Sprintf("カレントディレクトリの取得に失敗: %v", err)
+		return check
+	}
+
+	path := filepath.Join(projectRoot, "CLAUDE.md")
+	if _, err := os.Stat(path); err == nil {
+		check.Detail = path
+		return check
+	}
+	check.Status = CheckWarn
+	check.Detail = fmt.Sprintf("%s がありません（--apply で新規作成されます）", path)
+	if _, err := os.Stat(filepath.Join(projectRoot, ".claude")); os.IsNotExist(err) {
+		check.Fixes = []string{"Claude Codeを起動するプロジェクトのルートディレクトリで実行してください"}
+	}
+	return check
+}
+
+// checkTranscriptParsing checks that the newest transcript of the project is
+// extracted as conversation history.
+func checkTranscriptParsing(getwd func() (string, error)) DoctorCheck {
+	check := DoctorCheck{Name: "会話履歴の解析"}
+	projectRoot, err := getwd()
+	if err != nil {
+		check.Status = CheckFail
+		check.Detail = fmt.Sprintf("カレントディレクトリの取得に失敗: %v", err)
+		return check
+	}
+
+	path, err := newestTranscript(projectRoot)
+	if err != nil {
+		check.Status = CheckFail
+		check.Detail = err.Error()
+		return check
+	}
+	if path == "" {
+		check.Status = CheckWarn
+		check.Detail = "このプロジェクトの会話履歴がありません"
+		check.Fixes = []string{"Claude Codeを起動するプロジェクトのルートディレクトリで、セッションを一度終えてから実行してください"}
+		return check
+	}
+
+	history, err := ExtractConversationHistory(path)
+	if err != nil {
+		check.Status = CheckFail
+		check.Detail = fmt.Sprintf("%s を解析できません: %v", path, err)
+		check.Fixes = []string{"suggest-claude-mdを最新版に更新してください"}
+		return check
+	}
+	if history == "" {
+		check.Status = CheckWarn
+		check.Detail = fmt.Sprintf("%s から会話を抽出できません", path)
+		check.Fixes = []string{"会話のあるセッションで再確認し、それでも抽出できない場合はsuggest-claude-mdを最新版に更新してください"}
+		return check
+	}
+	check.Detail = fmt.Sprintf("%s（%d文字）", path, utf8.RuneCountInString(history))
+	return check
+}
+
+// uniqueStrings returns values without duplicates, keeping the first occurrence.
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	var unique []string
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckClaudeCLI(t *testing.T) {
+	writeFakeClaude(t, "1.2.3 (Claude Code)\n")
+
+	check := checkClaudeCLI()
+	if check.Status != CheckPass || !strings.Contains(check.Detail, "1.2.3 (Claude Code)") {
+		t.Errorf("checkClaudeCLI() = %+v, want pass with version", check)
+	}
+
+	t.Setenv("PATH", t.TempDir())
+	check = checkClaudeCLI()
+	if check.Status != CheckFail || len(check.Fixes) == 0 {
+		t.Errorf("checkClaudeCLI() = %+v, want fail with fixes", check)
+	}
+}
+
+func TestCheckHooksAndSettings(t *testing.T) {
+	tmpDir := t.TempDir()
+	originalWd, _ := os.Getwd()
+	defer os.Chdir(originalWd) // nolint:errcheck // Best-effort cleanup in test
+
+	os.Chdir(tmpDir)           // nolint:errcheck // Test will fail if this fails
+	os.Mkdir(".claude", 0o755) // nolint:errcheck // Test will fail if this fails
+	t.Setenv(claudeConfigDirEnvVar, filepath.Join(tmpDir, "config"))
+
+	if check := checkHooks(); check.Status != CheckFail || !strings.Contains(check.Detail, "登録されていません") {
+		t.Errorf("checkHooks() without hooks = %+v", check)
+	}
+	if check := checkSettingsFiles(); check.Status != CheckWarn {
+		t.Errorf("checkSettingsFiles() without settings = %+v", check)
+	}
+
+	stale := &ClaudeSettings{Hooks: map[string][]HookEntry{
+		"SessionEnd": {{Hooks: []HookCommand{{Type: "command", Command: "/nonexistent/suggest-claude-md"}}}},
+	}}
+	if err := saveSettings(filepath.Join(".claude", "settings.json"), stale); err != nil {
+		t.Fatalf("Failed to save settings: %v", err)
+	}
+	check := checkHooks()
+	if check.Status != CheckFail || !strings.Contains(check.Fixes[0], "--install-hook project") {
+		t.Errorf("checkHooks() with stale hook = %+v", check)
+	}
+
+	if err := os.WriteFile(filepath.Join(".claude", "settings.local.json"), []byte("{invalid"), 0o600); err != nil {
+		t.Fatalf("Failed to write settings: %v", err)
+	}
+	if check := checkSettingsFiles(); check.Status != CheckFail || !strings.Contains(check.Detail, "settings.local.json") {
+		t.Errorf("checkSettingsFiles() with invalid JSON = %+v", check)
+	}
+}
+
+func TestCheckRecursionGuard(t *testing.T) {
+	check := checkRecursionGuard(func(key string) string {
+		if key == recursionGuardEnvVar {
+			return "1"
+		}
+		return ""
+	})
+	if check.Status != CheckWarn || !strings.Contains(check.Fixes[0], "unset "+recursionGuardEnvVar) {
+		t.Errorf("checkRecursionGuard() = %+v", check)
+	}
+	if check := checkRecursionGuard(func(string) string { return "" }); check.Status != CheckPass {
+		t.Errorf("checkRecursionGuard() without guard = %+v", check)
+	}
+}
+
+func TestCheckOutputDir(t *testing.T) {
+	tmpDir := t.TempDir()
+	check := checkOutputDir(func(key string) string {
+		if key == "XDG_RUNTIME_DIR" {
+			return tmpDir
+		}
+		return ""
+	})
+	if check.Status != CheckPass {
+		t.Errorf("checkOutputDir() = %+v", check)
+	}
+	entries, _ := os.ReadDir(filepath.Join(tmpDir, commandName)) // nolint:errcheck // Checked by length below
+	if len(entries) != 0 {
+		t.Errorf("Temporary files should be removed: %v", entries)
+	}
+
+	blocker := filepath.Join(tmpDir, "file")
+	if err := os.WriteFile(blocker, nil, 0o600); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	check = checkOutputDir(func(key string) string {
+		if key == "XDG_RUNTIME_DIR" {
+			return blocker
+		}
+		return ""
+	})
+	if check.Status != CheckFail || len(check.Fixes) == 0 {
+		t.Errorf("checkOutputDir() on a file = %+v", check)
+	}
+}
+
+func TestCheckClaudeMdAndTranscript(t *testing.T) {
+	projectRoot := t.TempDir()
+	getwd := func() (string, error) { return projectRoot, nil }
+
+	if check := checkClaudeMd(getwd); check.Status != CheckWarn || len(check.Fixes) == 0 {
+		t.Errorf("checkClaudeMd() without CLAUDE.md = %+v", check)
+	}
+	// .claudeはカレントディレクトリではなくプロジェクトのルートで確認する
+	if err := os.Mkdir(filepath.Join(projectRoot, ".claude"), 0o755); err != nil {
+		t.Fatalf("Failed to create .claude: %v", err)
+	}
+	if check := checkClaudeMd(getwd); check.Status != CheckWarn || len(check.Fixes) != 0 {
+		t.Errorf("checkClaudeMd() in a Claude Code project = %+v", check)
+	}
+	if err := os.WriteFile(filepath.Join(projectRoot, "CLAUDE.md"), []byte("# Project\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write CLAUDE.md: %v", err)
+	}
+	if check := checkClaudeMd(getwd); check.Status != CheckPass {
+		t.Errorf("checkClaudeMd() = %+v", check)
+	}
+
+	configDir := t.TempDir()
+	t.Setenv(claudeConfigDirEnvVar, configDir)
+	if check := checkTranscriptParsing(getwd); check.Status != CheckWarn {
+		t.Errorf("checkTranscriptParsing() without transcripts = %+v", check)
+	}
+
+	// 最新の会話履歴を解析する
+	transcriptDir := filepath.Join(configDir, "projects", EncodeProjectDir(projectRoot))
+	if err := os.MkdirAll(transcriptDir, 0o755); err != nil {
+		t.Fatalf("Failed to create transcript directory: %v", err)
+	}
+	older := filepath.Join(transcriptDir, "older.jsonl")
+	newer := filepath.Join(transcriptDir, "newer.jsonl")
+	for path, content := range map[string]string{
+		older: "",
+		newer: `{"type":"user","message":{"role":"user","content":"doctor question"}}`,
+	} {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to create transcript: %v", err)
+		}
+	}
+	oldTime := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(older, oldTime, oldTime); err != nil {
+		t.Fatalf("Failed to set mtime: %v", err)
+	}
+	if check := checkTranscriptParsing(getwd); check.Status != CheckPass || !strings.Contains(check.Detail, newer) {
+		t.Errorf("checkTranscriptParsing() = %+v, want pass for %s", check, newer)
+	}
+
+	// 会話を抽出できない場合は警告する
+	if err := os.Chtimes(newer, oldTime.Add(-time.Hour), oldTime.Add(-time.Hour)); err != nil {
+		t.Fatalf("Failed to set mtime: %v", err)
+	}
+	if check := checkTranscriptParsing(getwd); check.Status != CheckWarn || !strings.Contains(check.Detail, older) {
+		t.Errorf("checkTranscriptParsing() of an empty transcript = %+v", check)
+	}
+}
+
+func TestRunDoctor_ReportsFailures(t *testing.T) {
+	tmpDir := t.TempDir()
+	originalWd, _ := os.Getwd()
+	defer os.Chdir(originalWd) // nolint:errcheck // Best-effort cleanup in test
+
+	os.Chdir(tmpDir) // nolint:errcheck // Test will fail if this fails
+	t.Setenv(claudeConfigDirEnvVar, filepath.Join(tmpDir, "config"))
+
+	t.Setenv("PATH", filepath.Join(tmpDir, "bin"))
+
+	env := map[string]string{
+		"XDG_RUNTIME_DIR": tmpDir,
+		"XDG_STATE_HOME":  filepath.Join(tmpDir, "state"),
+		configEnvVar:      filepath.Join(tmpDir, "config.json"),
+	}
+	var output bytes.Buffer
+	err := runDoctor(&output, func() (string, error) { return tmpDir, nil }, func(key string) string { return env[key] })
+	if err == nil || !strings.Contains(err.Error(), "2件") {
+		t.Errorf("runDoctor() error = %v, want 2 failures (claude CLI, hooks)", err)
+	}
+	for _, want := range []string{"❌ claude CLI", "❌ フック", "✅ 出力ディレクトリ", "→ "} {
+		if !strings.Contains(output.String(), want) {
+			t.Errorf("Output should contain %q:\n%s", want, output.String())
+		}
+	}
+}
+
+func TestCheckBudget(t *testing.T) {
+	tmpDir := t.TempDir()
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.Local)
+	if err := AppendUsage(filepath.Join(tmpDir, "state", commandName, usageFileName), UsageRecord{Time: now, Usage: ClaudeUsage{CostUSD: 1.5}}); err != nil {
+		t.Fatalf("AppendUsage() error = %v", err)
+	}
+
+	tests := []struct {
+		config     string
+		wantStatus CheckStatus
+		wantDetail string
+	}{
+		{`{}`, CheckPass, "今月の推定コスト $1.50（予算なし）"},
+		{`{"monthly_budget_usd":5}`, CheckPass, "今月の推定コスト $1.50 / 予算 $5.00"},
+		{`{"monthly_budget_usd":1}`, CheckWarn, "今月の推定コスト $1.50 / 予算 $1.00（予算に達したため、フックは分析をスキップします）"},
+	}
+	for _, tt := range tests {
+		check := checkBudget(usageEnv(t, tmpDir, tt.config), now)
+		if check.Status != tt.wantStatus || check.Detail != tt.wantDetail {
+			t.Errorf("checkBudget(%s) = %+v", tt.config, check)
+		}
+	}
+	if check := checkConfigFile(usageEnv(t, tmpDir, `{"monthly_budget_usd":-1}`)); check.Status != CheckFail {
+		t.Errorf("checkConfigFile() with a negative budget = %+v", check)
+	}
+}
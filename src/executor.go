@@ -1,9 +1,12 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"strings"
 )
 
 // ExecutorConfig holds configuration for background execution.
@@ -12,44 +15,103 @@ type ExecutorConfig struct {
 	TempPromptFilePath string
 	LogFile            string
 	HookInfo           string
-	SuggestionFile     string // 提案ファイルのパス
+	SuggestionFile     string    // 提案ファイルのパス
+	Output             io.Writer // claudeの応答を表示する出力（nilの場合は表示しない）
 }
 
-// ExecuteSynchronously executes Claude CLI synchronously and outputs to stdout and log file.
-func ExecuteSynchronously(config *ExecutorConfig) error {
+const (
+	// claudeCommand is the Claude CLI command, looked up in PATH.
+	claudeCommand = "claude"
+	// recursionGuardEnvVar is set for the claude process so that hooks of that session are skipped.
+	recursionGuardEnvVar = "SUGGEST_CLAUDE_MD_RUNNING"
+	// stderrTailLines is the number of the last lines of claude's stderr included in errors.
+	stderrTailLines = 5
+)
+
+// ExecuteSynchronously executes Claude CLI synchronously, shows its response on the
+// output, saves it to the suggestion and log files and returns the usage it reported.
+func ExecuteSynchronously(config *ExecutorConfig) (ClaudeUsage, error) {
 	shellScript := fmt.Sprintf(`
 		cd '%s' || exit 1
 		export SUGGEST_CLAUDE_MD_RUNNING=1
 
-		# claudeコマンドを実行し、出力を標準出力とログファイルと提案ファイルに保存
-		# teeで標準出力に表示しつつ、提案ファイルにも保存
-		claude --dangerously-skip-permissions --output-format text --print < '%s' | tee '%s' '%s'
-
-		# ログファイルにフック情報を追記
-		{
-			echo ""
-			echo "---"
-			echo ""
-			echo "## フック実行情報"
-			echo ""
-			echo "%s"
-			echo ""
-			echo "---"
-			echo ""
-			echo "## 実際に渡したプロンプト全文"
-			echo ""
-			cat '%s'
-		} >> '%s'
-
-		# 一時ファイル削除
-		rm -f '%s'
-	`, config.ProjectRoot, config.TempPromptFilePath, config.SuggestionFile, config.LogFile,
-		config.HookInfo, config.TempPromptFilePath, config.LogFile,
-		config.TempPromptFilePath)
+		# 会話全文を含むため、作成するファイルは本人のみ読み書き可能にする
+		umask 077
+
+		# claudeコマンドを実行し、応答と使用量をJSONで受け取る
+		exec %s --dangerously-skip-permissions --output-format json --print < '%s'
+	`, config.ProjectRoot, claudeCommand, config.TempPromptFilePath)
 
 	cmd := exec.Command("sh", "-c", shellScript)
-	cmd.Env = append(os.Environ(), "SUGGEST_CLAUDE_MD_RUNNING=1")
+	cmd.Env = append(os.Environ(), recursionGuardEnvVar+"=1")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
 
 	// Runで同期実行（完了を待つ）
-	return cmd.Run()
+	runErr := cmd.Run()
+	response, usage, parseErr := parseClaudeOutput(stdout.Bytes())
+	if config.Output != nil && response != "" {
+		_, _ = io.WriteString(config.Output, strings.TrimRight(response, "\n")+"\n") // nolint:errcheck // Output to user, error not critical
+	}
+
+	// 失敗した場合も、原因を調べられるよう応答をログに残す
+	if err := os.WriteFile(config.SuggestionFile, []byte(response), privateFilePerm); err != nil {
+		return usage, fmt.Errorf("提案ファイルへの書き込みに失敗: %w", err)
+	}
+	if err := writeExecutionLog(config, response, usage, stderr.String()); err != nil {
+		return usage, err
+	}
+	_ = os.Remove(config.TempPromptFilePath) // nolint:errcheck // Best-effort cleanup of the temp file
+
+	// 標準エラー出力のエラーは提案の検証では検出できないため、エラーに含める
+	tail := stderrTail(stderr.String())
+	switch {
+	case runErr != nil && tail != "":
+		return usage, fmt.Errorf("%w: %s", runErr, tail)
+	case runErr != nil:
+		return usage, runErr
+	case parseErr != nil:
+		return usage, parseErr
+	case strings.TrimSpace(response) == "" && tail != "":
+		return usage, fmt.Errorf("claudeが応答を返しませんでした: %s", tail)
+	}
+	return usage, nil
+}
+
+// stderrTail returns the last lines of the stderr of claude joined into one line.
+func stderrTail(stderr string) string {
+	lines := strings.Split(strings.TrimSpace(stderr), "\n")
+	if len(lines) > stderrTailLines {
+		lines = lines[len(lines)-stderrTailLines:]
+	}
+	return strings.TrimSpace(strings.Join(lines, " / "))
+}
+
+// writeExecutionLog writes the response of claude, the hook information, the usage,
+// the stderr of claude and the prompt to the log file.
+func writeExecutionLog(config *ExecutorConfig, response string, usage ClaudeUsage, stderr string) error {
+	prompt, err := os.ReadFile(config.TempPromptFilePath)
+	if err != nil {
+		return fmt.Errorf("プロンプトファイルの読み込みに失敗: %w", err)
+	}
+
+	var b strings.Builder
+	b.WriteString(response)
+	b.WriteString("\n---\n\n## フック実行情報\n\n")
+	b.WriteString(config.HookInfo + "\n\n")
+	if usage.Reported {
+		b.WriteString(usage.String() + "\n\n")
+	}
+	if strings.TrimSpace(stderr) != "" {
+		b.WriteString("---\n\n## 標準エラー出力\n\n")
+		b.WriteString(strings.TrimRight(stderr, "\n") + "\n\n")
+	}
+	b.WriteString("---\n\n## 実際に渡したプロンプト全文\n\n")
+	b.Write(prompt)
+
+	if err := os.WriteFile(config.LogFile, []byte(b.String()), privateFilePerm); err != nil {
+		return fmt.Errorf("ログファイルへの書き込みに失敗: %w", err)
+	}
+	return nil
 }
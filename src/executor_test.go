@@ -1,7 +1,10 @@
 package main
 
 import (
+	"bytes"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -44,9 +47,117 @@ func TestExecuteSynchronously_InvalidCommand(t *testing.T) {
 	}
 
 	// cmd.Run()は存在しないディレクトリでエラーを返す
-	err = ExecuteSynchronously(config)
+	_, err = ExecuteSynchronously(config)
 	// エラーが返ることを期待（同期実行のため）
 	if err == nil {
 		t.Errorf("ExecuteSynchronously() expected error for nonexistent directory, got nil")
 	}
 }
+
+func TestExecuteSynchronously_RecursionGuardEnvironment(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeFakeClaudeScript(t, "#!/bin/sh\ncat > /dev/null\necho \"$SUGGEST_CLAUDE_MD_RUNNING\"\n")
+	promptFile := filepath.Join(tmpDir, "prompt.md")
+	if err := os.WriteFile(promptFile, []byte("test"), 0o600); err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+
+	config := &ExecutorConfig{
+		ProjectRoot:        tmpDir,
+		TempPromptFilePath: promptFile,
+		LogFile:            filepath.Join(tmpDir, "test.log"),
+		SuggestionFile:     filepath.Join(tmpDir, "suggestion.md"),
+	}
+	if _, err := ExecuteSynchronously(config); err != nil {
+		t.Fatalf("ExecuteSynchronously() error = %v", err)
+	}
+	got, _ := os.ReadFile(config.SuggestionFile) // nolint:errcheck // Checked by content
+	if string(got) != "1\n" {
+		t.Errorf("claude environment = %q, want %q", got, "1\n")
+	}
+}
+
+func TestExecuteSynchronously_ShowsResponse(t *testing.T) {
+	tmpDir := t.TempDir()
+	promptFile := filepath.Join(tmpDir, "prompt.md")
+	if err := os.WriteFile(promptFile, []byte("test"), 0o600); err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+
+	writeFakeClaude(t, claudeJSONResult("## Testing\n\n- go test ./...\n"))
+	output := &bytes.Buffer{}
+	config := &ExecutorConfig{
+		ProjectRoot:        tmpDir,
+		TempPromptFilePath: promptFile,
+		LogFile:            filepath.Join(tmpDir, "test.log"),
+		SuggestionFile:     filepath.Join(tmpDir, "suggestion.md"),
+		Output:             output,
+	}
+	usage, err := ExecuteSynchronously(config)
+	if err != nil {
+		t.Fatalf("ExecuteSynchronously() error = %v", err)
+	}
+	// 同期モードではJSONではなく応答の本文を画面に表示する
+	if output.String() != "## Testing\n\n- go test ./...\n" {
+		t.Errorf("output = %q", output.String())
+	}
+	if got, _ := os.ReadFile(config.SuggestionFile); string(got) != output.String() { // nolint:errcheck // Checked by content
+		t.Errorf("suggestion = %q, want the response", got)
+	}
+	if !usage.Reported || usage.OutputTokens != 150 {
+		t.Errorf("usage = %+v", usage)
+	}
+}
+
+func TestExecuteSynchronously_Stderr(t *testing.T) {
+	tests := []struct {
+		name      string
+		script    string
+		wantError string
+	}{
+		{
+			name:      "failed",
+			script:    "#!/bin/sh\ncat > /dev/null\necho 'line 1' >&2\necho 'Error: Invalid API key' >&2\nexit 1\n",
+			wantError: "exit status 1: line 1 / Error: Invalid API key",
+		},
+		{
+			name:      "no response",
+			script:    "#!/bin/sh\ncat > /dev/null\necho 'Error: Invalid API key' >&2\n",
+			wantError: "claudeが応答を返しませんでした: Error: Invalid API key",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			writeFakeClaudeScript(t, tt.script)
+			promptFile := filepath.Join(tmpDir, "prompt.md")
+			if err := os.WriteFile(promptFile, []byte("test"), 0o600); err != nil {
+				t.Fatalf("Failed to create temp file: %v", err)
+			}
+
+			config := &ExecutorConfig{
+				ProjectRoot:        tmpDir,
+				TempPromptFilePath: promptFile,
+				LogFile:            filepath.Join(tmpDir, "test.log"),
+				SuggestionFile:     filepath.Join(tmpDir, "suggestion.md"),
+			}
+			_, err := ExecuteSynchronously(config)
+			if err == nil || err.Error() != tt.wantError {
+				t.Errorf("ExecuteSynchronously() error = %v, want %q", err, tt.wantError)
+			}
+			log, _ := os.ReadFile(config.LogFile) // nolint:errcheck // Checked by content
+			if !strings.Contains(string(log), "## 標準エラー出力\n\n") || !strings.Contains(string(log), "Error: Invalid API key\n") {
+				t.Errorf("Log should contain the stderr of claude:\n%s", log)
+			}
+		})
+	}
+}
+
+func TestStderrTail(t *testing.T) {
+	if got := stderrTail("1\n2\n3\n4\n5\n6\n7\n"); got != "3 / 4 / 5 / 6 / 7" {
+		t.Errorf("stderrTail() = %q", got)
+	}
+	if got := stderrTail("\n"); got != "" {
+		t.Errorf("stderrTail() = %q, want empty", got)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// analysisPromptMarker marks the prompts of analyses, so that the transcripts of
+	// the claude sessions they start can be recognised.
+	analysisPromptMarker = "<!-- suggest-claude-md: analysis prompt -->"
+
+	// sessionLockDirName is the directory of the state directory holding the PID
+	// files of the sessions being analyzed.
+	sessionLockDirName = "running"
+	// maxAncestorDepth bounds the walk up the process tree.
+	maxAncestorDepth = 64
+)
+
+// lookupHomeDir returns the home directory of the user in the user database
+// (/etc/passwd), which does not depend on the environment. Tests replace it.
+var lookupHomeDir = func(uid int) (string, error) {
+	u, err := user.LookupId(strconv.Itoa(uid))
+	if err != nil {
+		return "", err
+	}
+	return u.HomeDir, nil
+}
+
+// environmentRecursionGuard returns why the hook must be skipped according to the
+// environment inherited from an analysis, or "" if it may run.
+func environmentRecursionGuard(getenv func(string) string) string {
+	if getenv(recursionGuardEnvVar) == "1" {
+		return fmt.Sprintf("既に実行中のため、スキップします（%s=1）", recursionGuardEnvVar)
+	}
+	return ""
+}
+
+// sessionRecursionGuard returns why the hook for the transcript must be skipped even
+// though the environment does not show it, e.g. when a wrapper or Claude Code itself
+// dropped the variables: the transcript is one of an analysis, or the hook runs
+// below a process that is analyzing a session. It returns "" if the hook may run.
+func sessionRecursionGuard(transcriptPath string) string {
+	if isAnalysisTranscript(transcriptPath) {
+		return fmt.Sprintf("suggest-claude-mdの分析用のセッションのため、スキップします（プロンプトのマーカーを検出: %s）", transcriptPath)
+	}
+	if sessionID, pid, ok := analyzingAncestor(sessionLockDir()); ok {
+		return fmt.Sprintf("suggest-claude-mdが起動したclaudeからの実行のため、スキップします（セッション %s を分析中のPID %d の子孫プロセス）", sessionID, pid)
+	}
+	return ""
+}
+
+// isAnalysisTranscript reports whether the first message typed by the user in the
+// transcript is a prompt generated by suggest-claude-md.
+func isAnalysisTranscript(transcriptPath string) bool {
+	file, err := os.Open(transcriptPath)
+	if err != nil {
+		return false
+	}
+	defer file.Close() // nolint:errcheck // File is read-only, no need to check close error
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var msg Message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil || msg.IsMeta || msg.Message.Role != "user" {
+			continue
+		}
+		content := extractTextContent(msg.Message.Content)
+		if content == "" {
+			continue
+		}
+		return strings.Contains(content, analysisPromptMarker)
+	}
+	return false
+}
+
+// sessionLockDir returns the directory of the PID files of running analyses:
+// ~/.local/state/suggest-claude-md/running of the home directory in the user database.
+// It does not depend on the environment, so that a hook whose environment was
+// sanitized still finds the PID files of the analysis that started it.
+func sessionLockDir() string {
+	uid := os.Getuid()
+	home, err := lookupHomeDir(uid)
+	if err != nil || !filepath.IsAbs(home) {
+		return filepath.Join("/tmp", fmt.Sprintf("%s%d", outputFilePrefix, uid), sessionLockDirName)
+	}
+	return filepath.Join(home, ".local", "state", commandName, sessionLockDirName)
+}
+
+// acquireSessionLock records that the current process analyzes the session. When
+// another live process analyzes it, holder is its PID and release is nil. PID files
+// of processes that no longer exist are replaced.
+func acquireSessionLock(dir, sessionID string) (release func(), holder int, err error) {
+	if err := EnsurePrivateDir(dir); err != nil {
+		return nil, 0, err
+	}
+	path := filepath.Join(dir, sessionID+".pid")
+	for attempt := 0; attempt < 2; attempt++ {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, privateFilePerm)
+		if err == nil {
+			_, err = fmt.Fprintf(file, "%d\n", os.Getpid())
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				_ = os.Remove(path) // nolint:errcheck // Best-effort cleanup in error path
+				return nil, 0, fmt.Errorf("PIDファイルの作成に失敗: %w", err)
+			}
+			return func() { _ = os.Remove(path) }, 0, nil // nolint:errcheck // A leftover file is replaced as stale
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, 0, fmt.Errorf("PIDファイルの作成に失敗: %w", err)
+		}
+		if pid := readPIDFile(path); processAlive(pid) {
+			return nil, pid, nil
+		}
+		// 終了したプロセスのPIDファイルを削除して作り直す
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, 0, fmt.Errorf("古いPIDファイルの削除に失敗: %w", err)
+		}
+	}
+	return nil, 0, fmt.Errorf("PIDファイルの作成に失敗: %s", path)
+}
+
+// readPIDFile returns the PID recorded in the file, or 0 if it cannot be read.
+func readPIDFile(path string) int {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
+
+// analyzingAncestor returns the session analyzed by an ancestor of the current
+// process, as recorded by acquireSessionLock in dir.
+func analyzingAncestor(dir string) (sessionID string, pid int, ok bool) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pid"))
+	if err != nil || len(paths) == 0 {
+		return "", 0, false
+	}
+	analyzing := make(map[int]string)
+	for _, path := range paths {
+		if pid := readPIDFile(path); processAlive(pid) {
+			analyzing[pid] = strings.TrimSuffix(filepath.Base(path), ".pid")
+		}
+	}
+	if len(analyzing) == 0 {
+		return "", 0, false
+	}
+
+	pid = os.Getppid()
+	for i := 0; i < maxAncestorDepth && pid > 1; i++ {
+		if sessionID, found := analyzing[pid]; found {
+			return sessionID, pid, true
+		}
+		parent, err := parentPID(pid)
+		if err != nil {
+			break
+		}
+		pid = parent
+	}
+	return "", 0, false
+}
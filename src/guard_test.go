@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeAnalysisTranscript creates the transcript of a claude session started by an analysis.
+func writeAnalysisTranscript(t *testing.T, dir string) string {
+	t.Helper()
+	prompt := GeneratePrompt(DefaultPromptContent, "### user\n\nHello", "")
+	content := `{"type":"summary","summary":"analysis"}` + "\n" +
+		`{"type":"user","isMeta":true,"message":{"role":"user","content":"Caveat: local commands"}}` + "\n" +
+		`{"type":"user","message":{"role":"user","content":` + strconv.Quote(prompt) + `}}` + "\n"
+	path := filepath.Join(dir, "analysis.jsonl")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to create transcript: %v", err)
+	}
+	return path
+}
+
+// useTempHomeDir makes the user database report a temporary home directory, so
+// that the PID files of the test do not affect other tests.
+func useTempHomeDir(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	original := lookupHomeDir
+	lookupHomeDir = func(int) (string, error) { return home, nil }
+	t.Cleanup(func() { lookupHomeDir = original })
+	return home
+}
+
+// writeSessionLock records pid as analyzing the session.
+func writeSessionLock(t *testing.T, sessionID string, pid int) {
+	t.Helper()
+	dir := sessionLockDir()
+	if err := EnsurePrivateDir(dir); err != nil {
+		t.Fatalf("EnsurePrivateDir() error = %v", err)
+	}
+	path := filepath.Join(dir, sessionID+".pid")
+	if err := os.WriteFile(path, []byte(strconv.Itoa(pid)+"\n"), 0o600); err != nil {
+		t.Fatalf("Failed to create PID file: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Remove(path) }) // nolint:errcheck // Best-effort cleanup in test
+}
+
+func TestEnvironmentRecursionGuard(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{"no guard", nil, ""},
+		{"running", map[string]string{recursionGuardEnvVar: "1"}, "既に実行中のため、スキップします（SUGGEST_CLAUDE_MD_RUNNING=1）"},
+		{"other value", map[string]string{recursionGuardEnvVar: "0"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := environmentRecursionGuard(func(key string) string { return tt.env[key] })
+			if (tt.want == "") != (got == "") || !strings.Contains(got, tt.want) {
+				t.Errorf("environmentRecursionGuard() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsAnalysisTranscript(t *testing.T) {
+	tmpDir := t.TempDir()
+	if !isAnalysisTranscript(writeAnalysisTranscript(t, tmpDir)) {
+		t.Error("Transcript of an analysis should be detected")
+	}
+	if isAnalysisTranscript(writeTestTranscript(t, tmpDir)) {
+		t.Error("Ordinary transcript should not be detected")
+	}
+	if isAnalysisTranscript(filepath.Join(tmpDir, "missing.jsonl")) {
+		t.Error("Missing transcript should not be detected")
+	}
+
+	// initのプロンプトにもマーカーを含める
+	if !strings.Contains(GenerateInitPrompt(InitPromptContent, ProjectSummary{Name: "example"}, "# CLAUDE.md\n"), analysisPromptMarker) {
+		t.Error("Init prompt should contain the marker")
+	}
+
+	// マーカーは最初のユーザーのメッセージでのみ判定する
+	later := filepath.Join(tmpDir, "later.jsonl")
+	content := `{"type":"user","message":{"role":"user","content":"prompt.goを読んで"}}` + "\n" +
+		`{"type":"user","message":{"role":"user","content":` + strconv.Quote(analysisPromptMarker) + `}}` + "\n"
+	if err := os.WriteFile(later, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to create transcript: %v", err)
+	}
+	if isAnalysisTranscript(later) {
+		t.Error("Marker after the first user message should not be detected")
+	}
+}
+
+func TestAcquireSessionLock(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), sessionLockDirName)
+
+	release, holder, err := acquireSessionLock(dir, "session")
+	if err != nil || release == nil || holder != 0 {
+		t.Fatalf("acquireSessionLock() = %v, %d, %v", release != nil, holder, err)
+	}
+	if _, holder, err := acquireSessionLock(dir, "session"); err != nil || holder != os.Getpid() {
+		t.Errorf("acquireSessionLock() of a held session = %d, %v, want holder %d", holder, err, os.Getpid())
+	}
+	if other, _, err := acquireSessionLock(dir, "other"); err != nil || other == nil {
+		t.Errorf("acquireSessionLock() of another session = %v, %v", other != nil, err)
+	} else {
+		other()
+	}
+	release()
+	if _, err := os.Stat(filepath.Join(dir, "session.pid")); !os.IsNotExist(err) {
+		t.Errorf("release should remove the PID file: %v", err)
+	}
+
+	// 終了したプロセスのPIDファイルは置き換える
+	if err := os.WriteFile(filepath.Join(dir, "session.pid"), []byte("1073741824\n"), 0o600); err != nil {
+		t.Fatalf("Failed to create PID file: %v", err)
+	}
+	release, _, err = acquireSessionLock(dir, "session")
+	if err != nil || release == nil {
+		t.Fatalf("acquireSessionLock() over a stale PID file = %v, %v", release != nil, err)
+	}
+	if pid := readPIDFile(filepath.Join(dir, "session.pid")); pid != os.Getpid() {
+		t.Errorf("PID file = %d, want %d", pid, os.Getpid())
+	}
+	release()
+}
+
+func TestSessionRecursionGuard(t *testing.T) {
+	tmpDir := t.TempDir()
+	useTempHomeDir(t)
+	transcriptPath := writeTestTranscript(t, tmpDir)
+
+	if got := sessionRecursionGuard(transcriptPath); got != "" {
+		t.Errorf("sessionRecursionGuard() = %q, want none", got)
+	}
+	if got := sessionRecursionGuard(writeAnalysisTranscript(t, tmpDir)); !strings.Contains(got, "プロンプトのマーカーを検出") {
+		t.Errorf("sessionRecursionGuard() for an analysis transcript = %q", got)
+	}
+
+	// 別のプロセスの分析は関係しない
+	writeSessionLock(t, "unrelated", 1)
+	if got := sessionRecursionGuard(transcriptPath); got != "" {
+		t.Errorf("sessionRecursionGuard() with an unrelated analysis = %q, want none", got)
+	}
+	// 親プロセスが分析中であれば、そのclaudeから起動されたフックとみなす
+	writeSessionLock(t, "parent-session", os.Getppid())
+	if got := sessionRecursionGuard(transcriptPath); !strings.Contains(got, "セッション parent-session を分析中のPID "+strconv.Itoa(os.Getppid())) {
+		t.Errorf("sessionRecursionGuard() below an analysis = %q", got)
+	}
+}
+
+func TestSessionLockDir(t *testing.T) {
+	// 環境変数ではなくユーザーデータベースのホームディレクトリを使う
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	home := useTempHomeDir(t)
+	if got, want := sessionLockDir(), filepath.Join(home, ".local", "state", commandName, sessionLockDirName); got != want {
+		t.Errorf("sessionLockDir() = %s, want %s", got, want)
+	}
+
+	lookupHomeDir = func(int) (string, error) { return "", errors.New("unknown user") }
+	want := filepath.Join("/tmp", outputFilePrefix+strconv.Itoa(os.Getuid()), sessionLockDirName)
+	if got := sessionLockDir(); got != want {
+		t.Errorf("sessionLockDir() without a home directory = %s, want %s", got, want)
+	}
+}
+
+// sessionGuardHelperArg makes the test binary act as a hook started below an analysis.
+const sessionGuardHelperArg = "session-guard-helper"
+
+func TestSessionRecursionGuard_EmptyEnvironment(t *testing.T) {
+	if flag.NArg() == 2 && flag.Arg(0) == sessionGuardHelperArg {
+		// 環境変数を持たない子プロセスとして判定する
+		home := flag.Arg(1)
+		lookupHomeDir = func(int) (string, error) { return home, nil }
+		fmt.Printf("guard: %s\n", sessionRecursionGuard(filepath.Join(home, "missing.jsonl")))
+		return
+	}
+
+	home := useTempHomeDir(t)
+	writeSessionLock(t, "parent-session", os.Getpid())
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestSessionRecursionGuard_EmptyEnvironment$", "--", sessionGuardHelperArg, home)
+	cmd.Env = []string{}
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("Helper process failed: %v\n%s", err, output)
+	}
+	if want := "guard: suggest-claude-mdが起動したclaudeからの実行のため、スキップします（セッション parent-session を分析中のPID " + strconv.Itoa(os.Getpid()); !strings.Contains(string(output), want) {
+		t.Errorf("Hook with an empty environment should be skipped, got:\n%s", output)
+	}
+}
+
+func TestRun_RecursionGuards(t *testing.T) {
+	tmpDir := t.TempDir()
+	// claudeが起動されればテストは失敗する
+	forbidClaude(t)
+	getenv := func(key string) string {
+		if key == "XDG_RUNTIME_DIR" {
+			return tmpDir
+		}
+		return ""
+	}
+	getwd := func() (string, error) { return tmpDir, nil }
+
+	output := &bytes.Buffer{}
+	input := strings.NewReader(`{"transcript_path":"` + writeAnalysisTranscript(t, tmpDir) + `","hook_event_name":"SessionEnd"}`)
+	if err := run(input, output, getwd, getenv, time.Now); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	if !strings.Contains(output.String(), "⚠️  suggest-claude-mdの分析用のセッションのため、スキップします") {
+		t.Errorf("Output should report the analysis transcript, got: %s", output.String())
+	}
+
+	// 同じセッションの分析が実行中であれば、手動の分析もスキップする
+	useTempHomeDir(t)
+	writeSessionLock(t, "transcript", os.Getppid())
+	output.Reset()
+	result, err := analyzeTranscript(AnalyzeOptions{TranscriptPath: writeTestTranscript(t, tmpDir), ProjectRoot: tmpDir}, output, getenv, time.Now)
+	if err != nil {
+		t.Fatalf("analyzeTranscript() error = %v", err)
+	}
+	if !result.Skipped || !strings.Contains(result.SkipReason, "このセッションは既に分析中のため") {
+		t.Errorf("result = %+v", result)
+	}
+	if !strings.Contains(output.String(), "⚠️  このセッションは既に分析中のため（PID "+strconv.Itoa(os.Getppid())+"）、スキップします") {
+		t.Errorf("Output should report the running analysis, got: %s", output.String())
+	}
+}
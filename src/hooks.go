@@ -1,61 +1,285 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
 )
 
 const (
 	commandName  = "suggest-claude-md"
 	scopeUser    = "user"
 	scopeProject = "project"
+	scopeLocal   = "local"
+	// scopeCustom is used for a settings file given by --settings-path
+	scopeCustom = "custom"
+
+	// claudeConfigDirEnvVar overrides ~/.claude as Claude Code's user configuration directory
+	claudeConfigDirEnvVar = "CLAUDE_CONFIG_DIR"
 )
 
-// ClaudeSettings represents the structure of .claude/settings.json
+// hookEvents are the Claude Code hook events suggest-claude-md is registered on by default.
+var hookEvents = []string{"SessionEnd", "PreCompact"}
+
+// hookEventDescriptions lists the Claude Code hook events hooks can be installed on.
+var hookEventDescriptions = map[string]string{
+	"SessionEnd":       "通常のセッション終了時",
+	"PreCompact":       "トークン上限によるコンパクション前",
+	"Stop":             "応答の完了時",
+	"SubagentStop":     "サブエージェントの完了時",
+	"SessionStart":     "セッションの開始・再開時",
+	"UserPromptSubmit": "プロンプトの送信時",
+	"Notification":     "通知の送信時",
+	"PreToolUse":       "ツールの実行前",
+	"PostToolUse":      "ツールの実行後",
+}
+
+// scopeLabels describes each settings scope for messages.
+var scopeLabels = map[string]string{
+	scopeUser:    "ユーザー設定（全プロジェクト共通）",
+	scopeProject: "プロジェクト設定（現在のプロジェクトのみ）",
+	scopeLocal:   "ローカル設定（現在のプロジェクト・自分のみ、コミットされない）",
+	scopeCustom:  "指定された設定ファイル",
+}
+
+// HookInstallOptions controls where and how hooks are installed.
+type HookInstallOptions struct {
+	// SettingsPath overrides the settings file of the scope (--settings-path)
+	SettingsPath string
+	// Events are the hook events to install on (default: hookEvents)
+	Events []string
+	// Timeout is the hook timeout in seconds (0: Claude Code's default)
+	Timeout int
+	// Matcher limits when the hook runs, e.g. "auto" for automatic compactions only
+	Matcher string
+	// Args are passed to the installed command
+	Args []string
+}
+
+// ParseHookEvents parses a comma-separated list of hook events.
+func ParseHookEvents(value string) ([]string, error) {
+	var events []string
+	seen := make(map[string]bool)
+	for _, event := range strings.Split(value, ",") {
+		event = strings.TrimSpace(event)
+		if event == "" || seen[event] {
+			continue
+		}
+		if _, ok := hookEventDescriptions[event]; !ok {
+			names := make([]string, 0, len(hookEventDescriptions))
+			for name := range hookEventDescriptions {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("無効なフックイベント: %s (有効な値: %s)", event, strings.Join(names, ", "))
+		}
+		seen[event] = true
+		events = append(events, event)
+	}
+	if len(events) == 0 {
+		return nil, fmt.Errorf("フックイベントが指定されていません")
+	}
+	return events, nil
+}
+
+// hookCommandLine builds the hook command from the executable and its arguments,
+// quoting them for the shell where needed.
+func hookCommandLine(execPath string, args []string) string {
+	parts := []string{shellQuote(execPath)}
+	for _, arg := range args {
+		parts = append(parts, shellQuote(arg))
+	}
+	return strings.Join(parts, " ")
+}
+
+// shellQuote quotes s with single quotes unless it consists only of safe characters.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./=:,@%+~") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// splitShellWords splits a command line into words with the quoting rules of the
+// shell: single quotes, double quotes and backslash escapes. Expansions are not performed.
+func splitShellWords(line string) []string {
+	var s shellWordSplitter
+	for _, r := range line {
+		if s.quoted(r) {
+			continue
+		}
+		switch {
+		case r == '\\':
+			s.escaped, s.inWord = true, true
+		case r == '\'' || r == '"':
+			s.quote, s.inWord = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			s.flush()
+		default:
+			s.word.WriteRune(r)
+			s.inWord = true
+		}
+	}
+	s.flush()
+	return s.words
+}
+
+// shellWordSplitter is the state of splitShellWords.
+type shellWordSplitter struct {
+	words   []string
+	word    strings.Builder
+	inWord  bool
+	quote   rune
+	escaped bool
+}
+
+// quoted handles a rune after a backslash or inside quotes, and reports whether it did.
+func (s *shellWordSplitter) quoted(r rune) bool {
+	switch {
+	case s.escaped:
+		// 二重引用符の中では、\ " $ ` 以外の文字の前のバックスラッシュはそのまま残る
+		if s.quote == '"' && !strings.ContainsRune("\\\"$`", r) {
+			s.word.WriteRune('\\')
+		}
+		s.word.WriteRune(r)
+		s.escaped = false
+	case s.quote == 0 || (s.quote == '"' && r == '\\'):
+		return false
+	case r == s.quote:
+		s.quote = 0
+	default:
+		s.word.WriteRune(r)
+	}
+	return true
+}
+
+// flush ends the current word, if any.
+func (s *shellWordSplitter) flush() {
+	if s.inWord {
+		s.words = append(s.words, s.word.String())
+		s.word.Reset()
+		s.inWord = false
+	}
+}
+
+// ClaudeSettings represents the structure of .claude/settings.json.
+// Other settings (permissions, env, model, ...) are kept as-is when saving.
 type ClaudeSettings struct {
 	Hooks map[string][]HookEntry `json:"hooks,omitempty"`
+
+	fields          []jsonField // all members of the loaded file in document order
+	hookOrder       []string    // hook events in document order
+	indent          string      // indentation of the loaded file
+	trailingNewline bool
 }
 
 // HookEntry represents a hook entry in settings.json
 type HookEntry struct {
-	Hooks []HookCommand `json:"hooks"`
+	Matcher string        `json:"matcher,omitempty"`
+	Hooks   []HookCommand `json:"hooks"`
+
+	fields []jsonField // members of the loaded entry, including unknown ones
 }
 
 // HookCommand represents a hook command
 type HookCommand struct {
 	Type    string `json:"type"`
 	Command string `json:"command"`
+	Timeout int    `json:"timeout,omitempty"`
+
+	fields []jsonField // members of the loaded command, including unknown ones
 }
 
-// installHooks installs suggest-claude-md hooks to settings.json
-func installHooks(scope string) error {
-	// スコープの検証
-	var settingsPath string
+// claudeConfigDir returns Claude Code's user configuration directory:
+// $CLAUDE_CONFIG_DIR if set, otherwise ~/.claude.
+func claudeConfigDir() (string, error) {
+	if dir := os.Getenv(claudeConfigDirEnvVar); dir != "" {
+		return ExpandTilde(dir), nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("ホームディレクトリの取得に失敗: %w", err)
+	}
+	return filepath.Join(homeDir, ".claude"), nil
+}
+
+// settingsPathForScope returns the settings.json path of the scope.
+// For the user scope, the configuration directory is created when create is true.
+func settingsPathForScope(scope string, create bool) (string, error) {
 	switch scope {
 	case scopeUser:
-		homeDir, err := os.UserHomeDir()
+		claudeDir, err := claudeConfigDir()
 		if err != nil {
-			return fmt.Errorf("ホームディレクトリの取得に失敗: %w", err)
+			return "", err
 		}
-		claudeDir := filepath.Join(homeDir, ".claude")
 		// .claudeディレクトリが存在しない場合は作成
-		if _, err := os.Stat(claudeDir); os.IsNotExist(err) {
+		if _, err := os.Stat(claudeDir); create && os.IsNotExist(err) {
 			if err := os.MkdirAll(claudeDir, 0o750); err != nil {
-				return fmt.Errorf(".claudeディレクトリの作成に失敗: %w", err)
+				return "", fmt.Errorf(".claudeディレクトリの作成に失敗: %w", err)
 			}
 		}
-		settingsPath = filepath.Join(claudeDir, "settings.json")
-	case scopeProject:
+		return filepath.Join(claudeDir, "settings.json"), nil
+	case scopeProject, scopeLocal:
 		claudeDir := ".claude"
-		if _, err := os.Stat(claudeDir); os.IsNotExist(err) {
-			return fmt.Errorf(".claudeディレクトリが見つかりません。このディレクトリはClaude Codeプロジェクトではない可能性があります")
+		if _, err := os.Stat(claudeDir); create && os.IsNotExist(err) {
+			return "", fmt.Errorf(".claudeディレクトリが見つかりません。このディレクトリはClaude Codeプロジェクトではない可能性があります")
+		}
+		if scope == scopeLocal {
+			return filepath.Join(claudeDir, "settings.local.json"), nil
 		}
-		settingsPath = filepath.Join(claudeDir, "settings.json")
+		return filepath.Join(claudeDir, "settings.json"), nil
 	default:
-		return fmt.Errorf("無効なスコープ: %s (有効な値: user, project)", scope)
+		return "", fmt.Errorf("無効なスコープ: %s (有効な値: user, project, local)", scope)
+	}
+}
+
+// resolveSettingsPath returns the settings file to edit and the scope to report:
+// the explicit path if given (its directory is created when create is true),
+// otherwise the scope's file. The scope may be omitted with an explicit path.
+func resolveSettingsPath(scope, settingsPath string, create bool) (string, string, error) {
+	if settingsPath == "" {
+		path, err := settingsPathForScope(scope, create)
+		return path, scope, err
+	}
+	switch scope {
+	case "", scopeUser, scopeProject, scopeLocal:
+	default:
+		return "", "", fmt.Errorf("無効なスコープ: %s (有効な値: user, project, local)", scope)
+	}
+	settingsPath = ExpandTilde(settingsPath)
+	if create {
+		if err := os.MkdirAll(filepath.Dir(settingsPath), 0o750); err != nil {
+			return "", "", fmt.Errorf("設定ディレクトリの作成に失敗: %w", err)
+		}
+	}
+	return settingsPath, scopeCustom, nil
+}
+
+// installHooks installs suggest-claude-md hooks to settings.json
+func installHooks(scope string) error {
+	return installHooksWithOptions(scope, HookInstallOptions{})
+}
+
+// installHooksWithOptions installs suggest-claude-md hooks with custom options
+func installHooksWithOptions(scope string, opts HookInstallOptions) error {
+	// スコープの検証
+	settingsPath, scope, err := resolveSettingsPath(scope, opts.SettingsPath, true)
+	if err != nil {
+		return err
+	}
+	if opts.Timeout < 0 {
+		return fmt.Errorf("無効なタイムアウト: %d (0以上の秒数を指定してください)", opts.Timeout)
+	}
+	events := opts.Events
+	if len(events) == 0 {
+		events = hookEvents
 	}
 
 	// 実行可能ファイルのパスを取得
@@ -76,37 +300,106 @@ func installHooks(scope string) error {
 		settings.Hooks = make(map[string][]HookEntry)
 	}
 
-	// SessionEndとPreCompactにフックを追加
+	// 指定されたイベント（既定: SessionEndとPreCompact）にフックを追加
 	hookCommand := HookCommand{
 		Type:    "command",
-		Command: execPath,
+		Command: hookCommandLine(execPath, opts.Args),
+		Timeout: opts.Timeout,
 	}
-
-	// SessionEndに追加
-	settings.Hooks["SessionEnd"] = addHookIfNotExists(settings.Hooks["SessionEnd"], hookCommand)
-
-	// PreCompactに追加
-	settings.Hooks["PreCompact"] = addHookIfNotExists(settings.Hooks["PreCompact"], hookCommand)
+	for _, event := range events {
+		settings.Hooks[event] = addHookIfNotExists(settings.Hooks[event], opts.Matcher, hookCommand)
+	}
+	// 選択されなくなったイベントからは削除する
+	_, dropped := removeOwnHooksExcept(settings, events)
 
 	// 設定を保存
 	if err := saveSettings(settingsPath, settings); err != nil {
 		return fmt.Errorf("設定ファイルの保存に失敗: %w", err)
 	}
 
-	scopeLabel := map[string]string{
-		scopeUser:    "ユーザー設定（全プロジェクト共通）",
-		scopeProject: "プロジェクト設定（現在のプロジェクトのみ）",
-	}[scope]
+	installation := hookInstallation{scope: scope, settingsPath: settingsPath, command: hookCommand.Command, events: events, dropped: dropped}
+	if scope == scopeLocal {
+		installation.gitignore, installation.gitignoreErr = ensureGitIgnored(settingsPath)
+	}
+	installation.print(opts)
+	return nil
+}
+
+// hookInstallation is the outcome of installHooksWithOptions shown to the user.
+type hookInstallation struct {
+	scope        string
+	settingsPath string
+	command      string
+	events       []string // events the hook is registered for
+	dropped      []string // events the hook was removed from
+	gitignore    string   // .gitignore updated for the local scope
+	gitignoreErr error
+}
 
+// print shows the installed hook and the events it was added to and removed from.
+func (h hookInstallation) print(opts HookInstallOptions) {
 	fmt.Println("✅ フックのインストールが完了しました")
-	fmt.Printf("   スコープ: %s\n", scopeLabel)
-	fmt.Printf("   設定ファイル: %s\n", settingsPath)
-	fmt.Printf("   コマンド: %s\n", execPath)
+	fmt.Printf("   スコープ: %s\n", scopeLabels[h.scope])
+	fmt.Printf("   設定ファイル: %s\n", h.settingsPath)
+	fmt.Printf("   コマンド: %s\n", h.command)
+	if opts.Timeout > 0 {
+		fmt.Printf("   タイムアウト: %d秒\n", opts.Timeout)
+	}
+	if opts.Matcher != "" {
+		fmt.Printf("   マッチャー: %s\n", opts.Matcher)
+	}
+	if h.gitignoreErr != nil {
+		fmt.Printf("⚠️  %s がgitの無視対象か確認できません。.gitignoreに追加してください: %v\n", h.settingsPath, h.gitignoreErr)
+	} else if h.gitignore != "" {
+		fmt.Printf("   %s に %s を追加しました\n", h.gitignore, localSettingsIgnorePattern)
+	}
+	if len(h.dropped) > 0 {
+		fmt.Printf("   削除したイベント: %s\n", strings.Join(h.dropped, ", "))
+	}
 	fmt.Println("\n登録されたフック:")
-	fmt.Println("  - SessionEnd: 通常のセッション終了時")
-	fmt.Println("  - PreCompact: トークン上限によるコンパクション前")
+	for _, event := range h.events {
+		fmt.Printf("  - %s: %s\n", event, hookEventDescriptions[event])
+	}
+}
 
-	return nil
+// localSettingsIgnorePattern is the .gitignore entry for the local scope's settings file.
+const localSettingsIgnorePattern = ".claude/settings.local.json"
+
+// ensureGitIgnored adds the local settings file to the project's .gitignore
+// unless git already ignores it, and returns the .gitignore path it updated.
+// Nothing is done outside a git repository or when git is not installed.
+func ensureGitIgnored(settingsPath string) (string, error) {
+	projectDir := filepath.Dir(filepath.Dir(settingsPath))
+	// 終了コード 0 は無視対象、1 は無視対象外、それ以外はリポジトリ外など
+	err := exec.Command("git", "-C", projectDir, "check-ignore", "-q", localSettingsIgnorePattern).Run()
+	var exitErr *exec.ExitError
+	if err == nil || !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+		return "", nil
+	}
+
+	gitignore := filepath.Join(projectDir, ".gitignore")
+	content, err := os.ReadFile(gitignore)
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf(".gitignoreの読み込みに失敗: %w", err)
+	}
+	if len(content) > 0 && !bytes.HasSuffix(content, []byte("\n")) {
+		content = append(content, '\n')
+	}
+	content = append(content, localSettingsIgnorePattern+"\n"...)
+	if err := os.WriteFile(gitignore, content, 0o644); err != nil {
+		return "", fmt.Errorf(".gitignoreの更新に失敗: %w", err)
+	}
+	return gitignore, nil
+}
+
+// settingsEvents returns the hook events registered in the settings in name order.
+func settingsEvents(settings *ClaudeSettings) []string {
+	events := make([]string, 0, len(settings.Hooks))
+	for event := range settings.Hooks {
+		events = append(events, event)
+	}
+	sort.Strings(events)
+	return events
 }
 
 // loadSettings loads settings from .claude/settings.json
@@ -125,13 +418,15 @@ func loadSettings(path string) (*ClaudeSettings, error) {
 	if err := json.Unmarshal(data, &settings); err != nil {
 		return nil, err
 	}
+	settings.trailingNewline = bytes.HasSuffix(data, []byte("\n"))
 
 	return &settings, nil
 }
 
-// saveSettings saves settings to .claude/settings.json
+// saveSettings saves settings to .claude/settings.json,
+// keeping unknown keys, key order and indentation of the loaded file
 func saveSettings(path string, settings *ClaudeSettings) error {
-	data, err := json.MarshalIndent(settings, "", "  ")
+	data, err := formatSettings(settings)
 	if err != nil {
 		return err
 	}
@@ -139,32 +434,309 @@ func saveSettings(path string, settings *ClaudeSettings) error {
 	return os.WriteFile(path, data, 0o644)
 }
 
-// addHookIfNotExists adds a hook command if it doesn't already exist
-func addHookIfNotExists(entries []HookEntry, hookCmd HookCommand) []HookEntry {
+// addHookIfNotExists adds the suggest-claude-md hook to the entry with the given matcher.
+// An existing suggest-claude-md hook is updated in place when its command, timeout or
+// matcher changed; unknown keys of the entry and the command are kept.
+func addHookIfNotExists(entries []HookEntry, matcher string, hookCmd HookCommand) []HookEntry {
 	// 既存のエントリーをチェックして、suggest-claude-mdが既に存在するか確認
-	for _, entry := range entries {
-		for _, cmd := range entry.Hooks {
-			basename := filepath.Base(cmd.Command)
-			if cmd.Type == hookCmd.Type && (cmd.Command == hookCmd.Command ||
-				basename == commandName ||
-				basename == commandName+".exe") {
-				// 既に存在する場合はそのまま返す
+	for i, entry := range entries {
+		for j, cmd := range entry.Hooks {
+			if cmd.Type != hookCmd.Type || (cmd.Command != hookCmd.Command && !isOwnHookCommand(cmd)) {
+				continue
+			}
+			if entry.Matcher == matcher && cmd.Command == hookCmd.Command && cmd.Timeout == hookCmd.Timeout {
+				// 既に同じ設定で存在する場合はそのまま返す
 				fmt.Println("⚠️  suggest-claude-mdフックは既に登録されています")
 				return entries
 			}
+
+			updated := cmd
+			updated.Command = hookCmd.Command
+			updated.Timeout = hookCmd.Timeout
+			fmt.Println("🔄 登録済みのsuggest-claude-mdフックの設定を更新しました")
+			if entry.Matcher == matcher || len(entry.Hooks) == 1 {
+				// 同じエントリー内で更新（他のフックと共有していなければマッチャーも変更）
+				entries[i].Matcher = matcher
+				entries[i].Hooks[j] = updated
+				return entries
+			}
+
+			// 他のフックと共有しているエントリーからは取り出し、マッチャーの一致するエントリーへ移動
+			entries[i].Hooks = append(entry.Hooks[:j:j], entry.Hooks[j+1:]...)
+			return appendHook(entries, matcher, updated)
+		}
+	}
+
+	return appendHook(entries, matcher, hookCmd)
+}
+
+// appendHook adds a hook command to the first entry with the matcher,
+// or to a new entry if there is none.
+func appendHook(entries []HookEntry, matcher string, hookCmd HookCommand) []HookEntry {
+	for i := range entries {
+		if entries[i].Matcher == matcher {
+			entries[i].Hooks = append(entries[i].Hooks, hookCmd)
+			return entries
+		}
+	}
+
+	// 一致するエントリーがない場合は、新しいエントリーとして追加
+	return append(entries, HookEntry{Matcher: matcher, Hooks: []HookCommand{hookCmd}})
+}
+
+// isOwnHookCommand reports whether a hook command runs suggest-claude-md.
+func isOwnHookCommand(cmd HookCommand) bool {
+	basename := filepath.Base(hookExecutable(cmd))
+	return cmd.Type == "command" && (basename == commandName || basename == commandName+".exe")
+}
+
+// hookExecutable returns the executable part of a hook command line.
+func hookExecutable(cmd HookCommand) string {
+	words := splitShellWords(cmd.Command)
+	if len(words) == 0 {
+		return ""
+	}
+	return words[0]
+}
+
+// removeOwnHooksExcept removes suggest-claude-md commands from the events of the
+// settings other than keep. Events left without entries are dropped. It returns the
+// number of removed commands and the events they were removed from.
+func removeOwnHooksExcept(settings *ClaudeSettings, keep []string) (int, []string) {
+	total := 0
+	var events []string
+	for _, event := range settingsEvents(settings) {
+		if slices.Contains(keep, event) {
+			continue
+		}
+		kept, removed := removeOwnHooks(settings.Hooks[event])
+		if removed == 0 {
+			continue
+		}
+		total += removed
+		events = append(events, event)
+		if len(kept) == 0 {
+			delete(settings.Hooks, event)
+		} else {
+			settings.Hooks[event] = kept
+		}
+	}
+	return total, events
+}
+
+// removeOwnHooks removes suggest-claude-md commands from the entries.
+// Entries left without commands are dropped. It returns the remaining entries
+// and the number of removed commands.
+func removeOwnHooks(entries []HookEntry) ([]HookEntry, int) {
+	var kept []HookEntry
+	removed := 0
+	for _, entry := range entries {
+		var hooks []HookCommand
+		for _, cmd := range entry.Hooks {
+			if isOwnHookCommand(cmd) {
+				removed++
+				continue
+			}
+			hooks = append(hooks, cmd)
+		}
+		if len(hooks) == 0 {
+			continue
 		}
+		entry.Hooks = hooks
+		kept = append(kept, entry)
+	}
+	return kept, removed
+}
+
+// uninstallHooks removes suggest-claude-md hooks from settings.json of the scope.
+// Hooks of other tools are left untouched.
+func uninstallHooks(scope string) error {
+	return uninstallHooksWithOptions(scope, HookInstallOptions{})
+}
+
+// uninstallHooksWithOptions removes suggest-claude-md hooks from the settings file
+// given by the options or the scope.
+func uninstallHooksWithOptions(scope string, opts HookInstallOptions) error {
+	settingsPath, scope, err := resolveSettingsPath(scope, opts.SettingsPath, false)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(settingsPath); os.IsNotExist(err) {
+		fmt.Printf("⚠️  設定ファイルが存在しません: %s\n", settingsPath)
+		return nil
+	}
+
+	settings, err := loadSettings(settingsPath)
+	if err != nil {
+		return fmt.Errorf("設定ファイルの読み込みに失敗: %w", err)
 	}
 
-	// エントリーが既に存在する場合は、最初のエントリーに追加
-	if len(entries) > 0 {
-		entries[0].Hooks = append(entries[0].Hooks, hookCmd)
-		return entries
+	total, _ := removeOwnHooksExcept(settings, nil)
+	if total == 0 {
+		fmt.Printf("⚠️  suggest-claude-mdフックは登録されていません: %s\n", settingsPath)
+		return nil
 	}
 
-	// エントリーが空の場合は、新しいエントリーとして追加
-	newEntry := HookEntry{
-		Hooks: []HookCommand{hookCmd},
+	if err := saveSettings(settingsPath, settings); err != nil {
+		return fmt.Errorf("設定ファイルの保存に失敗: %w", err)
 	}
 
-	return append(entries, newEntry)
+	fmt.Println("✅ フックのアンインストールが完了しました")
+	fmt.Printf("   スコープ: %s\n", scopeLabels[scope])
+	fmt.Printf("   設定ファイル: %s\n", settingsPath)
+	fmt.Printf("   削除したフック: %d件\n", total)
+
+	return nil
+}
+
+// HookInstallation is a suggest-claude-md hook found in a settings file.
+type HookInstallation struct {
+	Scope        string
+	SettingsPath string
+	Event        string
+	Command      string
+	BinaryPath   string // resolved executable path, empty if not found
+}
+
+// Stale reports whether the hook points to a binary that does not exist.
+func (h HookInstallation) Stale() bool {
+	return h.BinaryPath == ""
+}
+
+// HookStatus is the result of inspecting all settings scopes.
+type HookStatus struct {
+	Installations []HookInstallation
+	Errors        map[string]error // scope -> settings load error
+	Warnings      []string
+}
+
+// collectHookStatus inspects the settings files of the scopes.
+func collectHookStatus(settingsPaths map[string]string, scopes []string) HookStatus {
+	status := HookStatus{Errors: make(map[string]error)}
+	perEvent := make(map[string][]HookInstallation)
+
+	for _, scope := range scopes {
+		path := settingsPaths[scope]
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			continue
+		}
+		settings, err := loadSettings(path)
+		if err != nil {
+			status.Errors[scope] = err
+			continue
+		}
+		for _, event := range settingsEvents(settings) {
+			for _, entry := range settings.Hooks[event] {
+				for _, cmd := range entry.Hooks {
+					if !isOwnHookCommand(cmd) {
+						continue
+					}
+					installation := HookInstallation{
+						Scope:        scope,
+						SettingsPath: path,
+						Event:        event,
+						Command:      cmd.Command,
+						BinaryPath:   resolveBinary(hookExecutable(cmd)),
+					}
+					status.Installations = append(status.Installations, installation)
+					perEvent[event] = append(perEvent[event], installation)
+				}
+			}
+		}
+	}
+
+	for _, installation := range status.Installations {
+		if installation.Stale() {
+			status.Warnings = append(status.Warnings, fmt.Sprintf(
+				"%s (%s): コマンドが見つかりません: %s", installation.Scope, installation.Event, installation.Command))
+		}
+	}
+	events := make([]string, 0, len(perEvent))
+	for event := range perEvent {
+		events = append(events, event)
+	}
+	sort.Strings(events)
+	for _, event := range events {
+		if installations := perEvent[event]; len(installations) > 1 {
+			var locations []string
+			for _, installation := range installations {
+				locations = append(locations, installation.Scope)
+			}
+			status.Warnings = append(status.Warnings, fmt.Sprintf(
+				"%s: フックが%d件登録されています（%s）。重複実行の原因になります",
+				event, len(installations), strings.Join(locations, ", ")))
+		}
+	}
+	return status
+}
+
+// resolveBinary returns the absolute path of the command, or "" if it does not exist.
+func resolveBinary(command string) string {
+	if command == "" {
+		return ""
+	}
+	if strings.ContainsRune(command, filepath.Separator) {
+		path := ExpandTilde(command)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+		return ""
+	}
+	path, err := exec.LookPath(command)
+	if err != nil {
+		return ""
+	}
+	return path
+}
+
+// printHookStatus prints where suggest-claude-md hooks are installed.
+// An explicit settings file is checked in addition to the standard scopes.
+func printHookStatus(opts HookInstallOptions) error {
+	scopes := []string{scopeUser, scopeProject, scopeLocal}
+	settingsPaths := make(map[string]string)
+	for _, scope := range scopes {
+		path, err := settingsPathForScope(scope, false)
+		if err != nil {
+			return err
+		}
+		settingsPaths[scope] = path
+	}
+	if opts.SettingsPath != "" {
+		scopes = append(scopes, scopeCustom)
+		settingsPaths[scopeCustom] = ExpandTilde(opts.SettingsPath)
+	}
+
+	status := collectHookStatus(settingsPaths, scopes)
+
+	fmt.Println("🔍 フックの登録状況")
+	for _, scope := range scopes {
+		fmt.Printf("\n%s: %s\n", scopeLabels[scope], settingsPaths[scope])
+		if err, ok := status.Errors[scope]; ok {
+			fmt.Printf("  ❌ 設定ファイルの読み込みに失敗: %v\n", err)
+			continue
+		}
+		found := false
+		for _, installation := range status.Installations {
+			if installation.Scope != scope {
+				continue
+			}
+			found = true
+			binary := installation.BinaryPath
+			if installation.Stale() {
+				binary = "見つかりません"
+			}
+			fmt.Printf("  ✅ %s: %s (実行ファイル: %s)\n", installation.Event, installation.Command, binary)
+		}
+		if !found {
+			fmt.Println("  - 未登録")
+		}
+	}
+
+	if len(status.Warnings) > 0 {
+		fmt.Println("\n⚠️  警告:")
+		for _, warning := range status.Warnings {
+			fmt.Printf("  - %s\n", warning)
+		}
+	}
+	return nil
 }
@@ -2,8 +2,11 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -179,7 +182,7 @@ func TestAddHookIfNotExists_NewHook(t *testing.T) {
 	}
 
 	newHook := HookCommand{Type: "command", Command: "new-command"}
-	result := addHookIfNotExists(existingEntries, newHook)
+	result := addHookIfNotExists(existingEntries, "", newHook)
 
 	// 既存のエントリーに追加されるので、エントリー数は1のまま
 	if len(result) != 1 {
@@ -212,7 +215,7 @@ func TestAddHookIfNotExists_ExistingHook(t *testing.T) {
 	}
 
 	newHook := HookCommand{Type: "command", Command: "suggest-claude-md"}
-	result := addHookIfNotExists(existingEntries, newHook)
+	result := addHookIfNotExists(existingEntries, "", newHook)
 
 	if len(result) != 1 {
 		t.Errorf("Expected 1 entry (no duplicate), got %d", len(result))
@@ -229,7 +232,7 @@ func TestAddHookIfNotExists_ExistingHookWithPath(t *testing.T) {
 	}
 
 	newHook := HookCommand{Type: "command", Command: "suggest-claude-md"}
-	result := addHookIfNotExists(existingEntries, newHook)
+	result := addHookIfNotExists(existingEntries, "", newHook)
 
 	// 既存のパスでsuggest-claude-mdが見つかるため、追加されない
 	if len(result) != 1 {
@@ -242,7 +245,7 @@ func TestAddHookIfNotExists_EmptyEntries(t *testing.T) {
 	existingEntries := []HookEntry{}
 
 	newHook := HookCommand{Type: "command", Command: "suggest-claude-md"}
-	result := addHookIfNotExists(existingEntries, newHook)
+	result := addHookIfNotExists(existingEntries, "", newHook)
 
 	// 新しいエントリーが作成されるはず
 	if len(result) != 1 {
@@ -476,7 +479,7 @@ func TestAddHookIfNotExists_MultipleExistingHooks(t *testing.T) {
 	}
 
 	newHook := HookCommand{Type: "command", Command: "hook4"}
-	result := addHookIfNotExists(existingEntries, newHook)
+	result := addHookIfNotExists(existingEntries, "", newHook)
 
 	if len(result) != 1 {
 		t.Errorf("Expected 1 entry, got %d", len(result))
@@ -491,3 +494,431 @@ func TestAddHookIfNotExists_MultipleExistingHooks(t *testing.T) {
 		t.Errorf("Expected 4th hook to be 'hook4', got %q", result[0].Hooks[3].Command)
 	}
 }
+
+func TestRemoveOwnHooks(t *testing.T) {
+	entries := []HookEntry{
+		{Hooks: []HookCommand{
+			{Type: "command", Command: "/usr/local/bin/suggest-claude-md"},
+			{Type: "command", Command: "other-tool"},
+		}},
+		{Hooks: []HookCommand{
+			{Type: "command", Command: "suggest-claude-md --config ~/x.json"},
+		}},
+	}
+
+	kept, removed := removeOwnHooks(entries)
+
+	if removed != 2 {
+		t.Errorf("removed = %d, want 2", removed)
+	}
+	if len(kept) != 1 || len(kept[0].Hooks) != 1 || kept[0].Hooks[0].Command != "other-tool" {
+		t.Errorf("Unexpected remaining entries: %+v", kept)
+	}
+}
+
+func TestUninstallHooks_ProjectScope(t *testing.T) {
+	tmpDir := t.TempDir()
+	originalWd, _ := os.Getwd()
+	defer os.Chdir(originalWd) // nolint:errcheck // Best-effort cleanup in test
+
+	os.Chdir(tmpDir)           // nolint:errcheck // Test will fail if this fails
+	os.Mkdir(".claude", 0o755) // nolint:errcheck // Test will fail if this fails
+
+	settingsPath := filepath.Join(".claude", "settings.json")
+	settings := &ClaudeSettings{Hooks: map[string][]HookEntry{
+		"SessionEnd": {{Hooks: []HookCommand{{Type: "command", Command: "/bin/suggest-claude-md"}}}},
+		"PreCompact": {{Hooks: []HookCommand{
+			{Type: "command", Command: "suggest-claude-md"},
+			{Type: "command", Command: "other-tool"},
+		}}},
+		"Stop": {{Hooks: []HookCommand{{Type: "command", Command: "notify"}}}},
+	}}
+	if err := saveSettings(settingsPath, settings); err != nil {
+		t.Fatalf("Failed to save settings: %v", err)
+	}
+
+	if err := uninstallHooks(scopeProject); err != nil {
+		t.Fatalf("uninstallHooks() unexpected error: %v", err)
+	}
+
+	result, err := loadSettings(settingsPath)
+	if err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+	if _, ok := result.Hooks["SessionEnd"]; ok {
+		t.Error("SessionEnd should be removed when it has no hooks left")
+	}
+	if got := result.Hooks["PreCompact"]; len(got) != 1 || len(got[0].Hooks) != 1 || got[0].Hooks[0].Command != "other-tool" {
+		t.Errorf("Other PreCompact hooks should be kept: %+v", got)
+	}
+	if len(result.Hooks["Stop"]) != 1 {
+		t.Error("Other events should be kept")
+	}
+}
+
+func TestUninstallHooks_NotInstalled(t *testing.T) {
+	tmpDir := t.TempDir()
+	originalWd, _ := os.Getwd()
+	defer os.Chdir(originalWd) // nolint:errcheck // Best-effort cleanup in test
+
+	os.Chdir(tmpDir) // nolint:errcheck // Test will fail if this fails
+
+	if err := uninstallHooks(scopeProject); err != nil {
+		t.Errorf("uninstallHooks() without settings should not fail: %v", err)
+	}
+	if err := uninstallHooks("invalid"); err == nil {
+		t.Error("uninstallHooks() should return error for invalid scope")
+	}
+}
+
+func TestCollectHookStatus(t *testing.T) {
+	tmpDir := t.TempDir()
+	binary := filepath.Join(tmpDir, "suggest-claude-md")
+	if err := os.WriteFile(binary, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatalf("Failed to create binary: %v", err)
+	}
+
+	userPath := filepath.Join(tmpDir, "user.json")
+	projectPath := filepath.Join(tmpDir, "project.json")
+	userSettings := &ClaudeSettings{Hooks: map[string][]HookEntry{
+		"SessionEnd": {{Hooks: []HookCommand{{Type: "command", Command: binary}}}},
+	}}
+	projectSettings := &ClaudeSettings{Hooks: map[string][]HookEntry{
+		"SessionEnd": {{Hooks: []HookCommand{{Type: "command", Command: filepath.Join(tmpDir, "old", "suggest-claude-md")}}}},
+	}}
+	if err := saveSettings(userPath, userSettings); err != nil {
+		t.Fatalf("Failed to save settings: %v", err)
+	}
+	if err := saveSettings(projectPath, projectSettings); err != nil {
+		t.Fatalf("Failed to save settings: %v", err)
+	}
+
+	status := collectHookStatus(map[string]string{scopeUser: userPath, scopeProject: projectPath}, []string{scopeUser, scopeProject})
+
+	if len(status.Installations) != 2 {
+		t.Fatalf("Expected 2 installations, got %+v", status.Installations)
+	}
+	if status.Installations[0].BinaryPath != binary || status.Installations[0].Stale() {
+		t.Errorf("User hook should resolve to %s: %+v", binary, status.Installations[0])
+	}
+	if !status.Installations[1].Stale() {
+		t.Errorf("Project hook should be stale: %+v", status.Installations[1])
+	}
+
+	warnings := strings.Join(status.Warnings, "\n")
+	for _, want := range []string{"コマンドが見つかりません", "重複実行"} {
+		if !strings.Contains(warnings, want) {
+			t.Errorf("Warnings should contain %q, got: %s", want, warnings)
+		}
+	}
+}
+
+func TestSettingsPathForScope(t *testing.T) {
+	tmpDir := t.TempDir()
+	originalWd, _ := os.Getwd()
+	defer os.Chdir(originalWd) // nolint:errcheck // Best-effort cleanup in test
+
+	os.Chdir(tmpDir)           // nolint:errcheck // Test will fail if this fails
+	os.Mkdir(".claude", 0o755) // nolint:errcheck // Test will fail if this fails
+
+	configDir := filepath.Join(tmpDir, "config")
+	t.Setenv(claudeConfigDirEnvVar, configDir)
+
+	tests := []struct {
+		scope string
+		want  string
+	}{
+		{scopeUser, filepath.Join(configDir, "settings.json")},
+		{scopeProject, filepath.Join(".claude", "settings.json")},
+		{scopeLocal, filepath.Join(".claude", "settings.local.json")},
+	}
+	for _, tt := range tests {
+		got, err := settingsPathForScope(tt.scope, true)
+		if err != nil {
+			t.Fatalf("settingsPathForScope(%s) unexpected error: %v", tt.scope, err)
+		}
+		if got != tt.want {
+			t.Errorf("settingsPathForScope(%s) = %s, want %s", tt.scope, got, tt.want)
+		}
+	}
+	if _, err := os.Stat(configDir); err != nil {
+		t.Errorf("CLAUDE_CONFIG_DIR should be created for the user scope: %v", err)
+	}
+}
+
+func TestInstallHooks_LocalScope(t *testing.T) {
+	tmpDir := t.TempDir()
+	originalWd, _ := os.Getwd()
+	defer os.Chdir(originalWd) // nolint:errcheck // Best-effort cleanup in test
+
+	os.Chdir(tmpDir)           // nolint:errcheck // Test will fail if this fails
+	os.Mkdir(".claude", 0o755) // nolint:errcheck // Test will fail if this fails
+
+	if err := installHooks(scopeLocal); err != nil {
+		t.Fatalf("installHooks() unexpected error: %v", err)
+	}
+
+	settings, err := loadSettings(filepath.Join(".claude", "settings.local.json"))
+	if err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+	if len(settings.Hooks["SessionEnd"]) != 1 || len(settings.Hooks["PreCompact"]) != 1 {
+		t.Errorf("Hooks should be installed to settings.local.json: %+v", settings.Hooks)
+	}
+	if _, err := os.Stat(filepath.Join(".claude", "settings.json")); !os.IsNotExist(err) {
+		t.Error("settings.json should not be created for the local scope")
+	}
+}
+
+func TestEnsureGitIgnored(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	// 利用者のグローバル設定（core.excludesFile）に影響されないようにする
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+
+	projectDir := t.TempDir()
+	if output, err := exec.Command("git", "init", "-q", projectDir).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v: %s", err, output)
+	}
+	gitignore := filepath.Join(projectDir, ".gitignore")
+	if err := os.WriteFile(gitignore, []byte("node_modules"), 0o644); err != nil {
+		t.Fatalf("Failed to write .gitignore: %v", err)
+	}
+	settingsPath := filepath.Join(projectDir, ".claude", "settings.local.json")
+
+	got, err := ensureGitIgnored(settingsPath)
+	if err != nil || got != gitignore {
+		t.Fatalf("ensureGitIgnored() = %q, %v, want %q", got, err, gitignore)
+	}
+	content, _ := os.ReadFile(gitignore) // nolint:errcheck // Checked by the comparison below
+	if want := "node_modules\n.claude/settings.local.json\n"; string(content) != want {
+		t.Errorf(".gitignore = %q, want %q", content, want)
+	}
+
+	// 既に無視対象なら追加しない
+	if got, err := ensureGitIgnored(settingsPath); err != nil || got != "" {
+		t.Errorf("ensureGitIgnored() on an ignored file = %q, %v", got, err)
+	}
+
+	// gitリポジトリの外では何もしない
+	outside := filepath.Join(t.TempDir(), ".claude", "settings.local.json")
+	t.Setenv("GIT_CEILING_DIRECTORIES", filepath.Dir(filepath.Dir(outside)))
+	if got, err := ensureGitIgnored(outside); err != nil || got != "" {
+		t.Errorf("ensureGitIgnored() outside a repository = %q, %v", got, err)
+	}
+}
+
+func TestInstallHooks_SettingsPath(t *testing.T) {
+	settingsPath := filepath.Join(t.TempDir(), "nested", "settings.json")
+	opts := HookInstallOptions{SettingsPath: settingsPath}
+
+	if err := installHooksWithOptions("", opts); err != nil {
+		t.Fatalf("installHooksWithOptions() unexpected error: %v", err)
+	}
+	settings, err := loadSettings(settingsPath)
+	if err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+	if len(settings.Hooks["SessionEnd"]) != 1 {
+		t.Errorf("Hooks should be installed to the given file: %+v", settings.Hooks)
+	}
+
+	if err := uninstallHooksWithOptions("", opts); err != nil {
+		t.Fatalf("uninstallHooksWithOptions() unexpected error: %v", err)
+	}
+	settings, err = loadSettings(settingsPath)
+	if err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+	if len(settings.Hooks) != 0 {
+		t.Errorf("Hooks should be removed from the given file: %+v", settings.Hooks)
+	}
+
+	if err := installHooksWithOptions("global", opts); err == nil {
+		t.Error("Invalid scope should be rejected even with --settings-path")
+	}
+}
+
+func TestAddHookIfNotExists_UpdatesInPlace(t *testing.T) {
+	tests := []struct {
+		name        string
+		entries     []HookEntry
+		matcher     string
+		hook        HookCommand
+		wantEntries []HookEntry
+	}{
+		{
+			name:        "timeout and args changed",
+			entries:     []HookEntry{{Hooks: []HookCommand{{Type: "command", Command: "/bin/suggest-claude-md"}}}},
+			hook:        HookCommand{Type: "command", Command: "/bin/suggest-claude-md --config a.json", Timeout: 300},
+			wantEntries: []HookEntry{{Hooks: []HookCommand{{Type: "command", Command: "/bin/suggest-claude-md --config a.json", Timeout: 300}}}},
+		},
+		{
+			name:        "matcher changed on own entry",
+			entries:     []HookEntry{{Hooks: []HookCommand{{Type: "command", Command: "suggest-claude-md"}}}},
+			matcher:     "auto",
+			hook:        HookCommand{Type: "command", Command: "suggest-claude-md"},
+			wantEntries: []HookEntry{{Matcher: "auto", Hooks: []HookCommand{{Type: "command", Command: "suggest-claude-md"}}}},
+		},
+		{
+			name: "matcher changed on shared entry",
+			entries: []HookEntry{{Hooks: []HookCommand{
+				{Type: "command", Command: "other-tool"},
+				{Type: "command", Command: "suggest-claude-md"},
+			}}},
+			matcher: "auto",
+			hook:    HookCommand{Type: "command", Command: "suggest-claude-md"},
+			wantEntries: []HookEntry{
+				{Hooks: []HookCommand{{Type: "command", Command: "other-tool"}}},
+				{Matcher: "auto", Hooks: []HookCommand{{Type: "command", Command: "suggest-claude-md"}}},
+			},
+		},
+		{
+			name:    "new hook goes to entry with matcher",
+			entries: []HookEntry{{Matcher: "manual", Hooks: []HookCommand{{Type: "command", Command: "other-tool"}}}},
+			hook:    HookCommand{Type: "command", Command: "suggest-claude-md"},
+			wantEntries: []HookEntry{
+				{Matcher: "manual", Hooks: []HookCommand{{Type: "command", Command: "other-tool"}}},
+				{Hooks: []HookCommand{{Type: "command", Command: "suggest-claude-md"}}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := addHookIfNotExists(tt.entries, tt.matcher, tt.hook)
+			if fmt.Sprintf("%+v", got) != fmt.Sprintf("%+v", tt.wantEntries) {
+				t.Errorf("addHookIfNotExists() = %+v, want %+v", got, tt.wantEntries)
+			}
+		})
+	}
+}
+
+func TestInstallHooks_Options(t *testing.T) {
+	settingsPath := filepath.Join(t.TempDir(), "settings.json")
+	initial := `{"hooks":{"PreCompact":[{"hooks":[{"type":"command","command":"suggest-claude-md","note":"kept"}]}]}}`
+	if err := os.WriteFile(settingsPath, []byte(initial), 0o600); err != nil {
+		t.Fatalf("Failed to write settings: %v", err)
+	}
+
+	opts := HookInstallOptions{
+		SettingsPath: settingsPath,
+		Events:       []string{"PreCompact"},
+		Timeout:      120,
+		Matcher:      "auto",
+		Args:         []string{"--config", "my config.json"},
+	}
+	if err := installHooksWithOptions(scopeUser, opts); err != nil {
+		t.Fatalf("installHooksWithOptions() unexpected error: %v", err)
+	}
+
+	settings, err := loadSettings(settingsPath)
+	if err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+	if _, ok := settings.Hooks["SessionEnd"]; ok {
+		t.Error("Only the given events should be installed")
+	}
+	entries := settings.Hooks["PreCompact"]
+	if len(entries) != 1 || entries[0].Matcher != "auto" || len(entries[0].Hooks) != 1 {
+		t.Fatalf("Existing entry should be updated in place: %+v", entries)
+	}
+	cmd := entries[0].Hooks[0]
+	if !strings.HasSuffix(cmd.Command, " --config 'my config.json'") || cmd.Timeout != 120 {
+		t.Errorf("Command = %q, Timeout = %d", cmd.Command, cmd.Timeout)
+	}
+	data, _ := os.ReadFile(settingsPath) // nolint:errcheck // Checked by loadSettings above
+	if !strings.Contains(string(data), `"note": "kept"`) {
+		t.Errorf("Unknown keys of the hook should be kept:\n%s", data)
+	}
+
+	if err := installHooksWithOptions(scopeUser, HookInstallOptions{SettingsPath: settingsPath, Timeout: -1}); err == nil {
+		t.Error("Negative timeout should be rejected")
+	}
+}
+
+func TestParseHookEvents(t *testing.T) {
+	events, err := ParseHookEvents("SessionEnd, Stop,SessionEnd")
+	if err != nil {
+		t.Fatalf("ParseHookEvents() unexpected error: %v", err)
+	}
+	if strings.Join(events, ",") != "SessionEnd,Stop" {
+		t.Errorf("ParseHookEvents() = %v", events)
+	}
+	for _, value := range []string{"", "SessionEnd,Shutdown"} {
+		if _, err := ParseHookEvents(value); err == nil {
+			t.Errorf("ParseHookEvents(%q) should fail", value)
+		}
+	}
+}
+
+func TestHookCommandLine(t *testing.T) {
+	got := hookCommandLine("/usr/local/bin/suggest-claude-md", []string{"--config", "~/a b.json", "it's"})
+	want := `/usr/local/bin/suggest-claude-md --config '~/a b.json' 'it'\''s'`
+	if got != want {
+		t.Errorf("hookCommandLine() = %s, want %s", got, want)
+	}
+
+	// 空白を含むインストール先も引用し、同じ規則で実行ファイルを取り出せる
+	execPath := "/Users/me/Library/Application Support/bin/suggest-claude-md"
+	got = hookCommandLine(execPath, []string{"--async"})
+	if want := `'/Users/me/Library/Application Support/bin/suggest-claude-md' --async`; got != want {
+		t.Errorf("hookCommandLine() = %s, want %s", got, want)
+	}
+	cmd := HookCommand{Type: "command", Command: got}
+	if hookExecutable(cmd) != execPath || !isOwnHookCommand(cmd) {
+		t.Errorf("hookExecutable(%s) = %q", got, hookExecutable(cmd))
+	}
+}
+
+func TestSplitShellWords(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"suggest-claude-md --async", []string{"suggest-claude-md", "--async"}},
+		{`  '/a b/suggest-claude-md'   x `, []string{"/a b/suggest-claude-md", "x"}},
+		{`"/a b/suggest-claude-md" "q\"uote" "\n"`, []string{"/a b/suggest-claude-md", `q"uote`, `\n`}},
+		{`/a\ b/suggest-claude-md 'it'\''s'`, []string{"/a b/suggest-claude-md", "it's"}},
+		{`x '' ""`, []string{"x", "", ""}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := splitShellWords(tt.line); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitShellWords(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestInstallHooks_NarrowerEvents(t *testing.T) {
+	settingsPath := filepath.Join(t.TempDir(), "settings.json")
+	initial := `{"hooks":{"Stop":[{"hooks":[{"type":"command","command":"other-tool"},{"type":"command","command":"suggest-claude-md"}]}]}}`
+	if err := os.WriteFile(settingsPath, []byte(initial), 0o600); err != nil {
+		t.Fatalf("Failed to write settings: %v", err)
+	}
+	if err := installHooksWithOptions(scopeUser, HookInstallOptions{SettingsPath: settingsPath}); err != nil {
+		t.Fatalf("installHooksWithOptions() unexpected error: %v", err)
+	}
+
+	// 選択しなくなったイベントからはこのツールのフックだけを削除する
+	if err := installHooksWithOptions(scopeUser, HookInstallOptions{SettingsPath: settingsPath, Events: []string{"SessionEnd"}}); err != nil {
+		t.Fatalf("installHooksWithOptions() unexpected error: %v", err)
+	}
+	settings, err := loadSettings(settingsPath)
+	if err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+	if _, ok := settings.Hooks["PreCompact"]; ok {
+		t.Errorf("PreCompact should be removed: %+v", settings.Hooks)
+	}
+	if len(settings.Hooks["SessionEnd"]) != 1 {
+		t.Errorf("SessionEnd should be kept: %+v", settings.Hooks)
+	}
+	stop := settings.Hooks["Stop"]
+	if len(stop) != 1 || len(stop[0].Hooks) != 1 || stop[0].Hooks[0].Command != "other-tool" {
+		t.Errorf("Other hooks of Stop should be kept: %+v", stop)
+	}
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// InitPromptContent is the prompt template for generating a starter CLAUDE.md
+const InitPromptContent = `# CLAUDE.md初期作成
+
+このコマンドは、リポジトリの内容からCLAUDE.mdの初版を作成します。
+
+## 出力形式の重要な指示
+
+**必須要件:**
+1. メタ情報（「リポジトリを調査しました」「以下がCLAUDE.mdです」など）は一切出力しないでください
+2. ` + "`" + `# CLAUDE.md` + "`" + ` から始まる、CLAUDE.mdとしてそのまま保存できるMarkdownを出力してください
+3. 雛形の ## セクションの見出しと順序を維持し、不足している標準セクション（コーディング規約、注意事項など）は末尾に追加してください
+4. 必要に応じてリポジトリのファイルを読み、雛形の各セクションを具体的に補ってください
+
+**記載する内容:**
+- プロジェクトの目的と主要なコンポーネントの役割
+- ビルド・テスト・lint・単一テストの実行など、開発で実際に使うコマンド
+- ディレクトリ構成とアーキテクチャの要点
+- リポジトリから読み取れるコーディング規約（命名、エラー処理、テストの配置など）
+
+**禁止事項:**
+- リポジトリから確認できない推測や一般論（「テストを書きましょう」など）
+- 「---」などの区切り線（セクション内の区切りは可）
+`
+
+// InitOptions describes one run of the init command.
+type InitOptions struct {
+	ProjectRoot string
+	OutputDir   string // overrides the per-user output directory
+	PromptFile  string // replaces the built-in init prompt
+	NoClaude    bool   // build CLAUDE.md from the repository contents only
+	Force       bool   // overwrite an existing CLAUDE.md
+	Yes         bool   // write CLAUDE.md without confirmation
+}
+
+// StarterClaudeMd builds a CLAUDE.md with the standard sections filled from the
+// repository summary. Sections without information are omitted.
+func StarterClaudeMd(summary ProjectSummary) string {
+	var b strings.Builder
+	b.WriteString("# CLAUDE.md\n\n")
+	b.WriteString("このファイルは、このリポジトリで作業する Claude Code へのガイダンスです。\n")
+
+	section := func(title string, lines []string) {
+		if len(lines) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n## %s\n\n", title)
+		for _, line := range lines {
+			b.WriteString(line + "\n")
+		}
+	}
+
+	overview := summary.ReadmeExcerpt
+	if overview == "" {
+		overview = summary.Description
+	}
+	if overview == "" {
+		overview = summary.Name
+	}
+	section("プロジェクト概要", []string{overview})
+
+	var lines []string
+	for _, stack := range summary.Stack {
+		lines = append(lines, "- "+stack)
+	}
+	section("技術スタック", lines)
+
+	lines = nil
+	for _, command := range summary.Commands {
+		lines = append(lines, "- "+command.String())
+	}
+	section("よく使うコマンド", lines)
+
+	lines = nil
+	for _, dir := range summary.Directories {
+		lines = append(lines, fmt.Sprintf("- `%s`", dir))
+	}
+	section("ディレクトリ構成", lines)
+
+	lines = nil
+	for _, ci := range summary.CI {
+		line := fmt.Sprintf("- `%s`", ci.Path)
+		if ci.Name != "" {
+			line += fmt.Sprintf("（%s）", ci.Name)
+		}
+		if len(ci.Commands) > 0 {
+			quoted := make([]string, len(ci.Commands))
+			for i, command := range ci.Commands {
+				quoted[i] = fmt.Sprintf("`%s`", command)
+			}
+			line += ": " + strings.Join(quoted, "、")
+		}
+		lines = append(lines, line)
+	}
+	section("CI", lines)
+	return b.String()
+}
+
+// GenerateInitPrompt generates the prompt for refining the starter CLAUDE.md.
+func GenerateInitPrompt(commandContent string, summary ProjectSummary, starter string) string {
+	var prompt strings.Builder
+	prompt.WriteString(commandContent)
+	prompt.WriteString("\n\n---\n\n")
+
+	prompt.WriteString("## リポジトリの概要\n\n")
+	prompt.WriteString("以下はマニフェスト・タスクランナー・CI設定・READMEから抽出した情報です。\n\n")
+	prompt.WriteString("<project_summary>\n")
+	prompt.WriteString(summary.Context())
+	prompt.WriteString("</project_summary>\n\n")
+
+	prompt.WriteString("## 雛形\n\n")
+	prompt.WriteString("以下の雛形をもとに、CLAUDE.mdの全体を上記のフォーマットで出力してください。\n\n")
+	prompt.WriteString("<starter_claude_md>\n")
+	prompt.WriteString(starter)
+	prompt.WriteString("</starter_claude_md>\n")
+	prompt.WriteString("\n" + analysisPromptMarker + "\n")
+	return prompt.String()
+}
+
+// runInit builds a CLAUDE.md for a project without one: the repository is
+// summarised into a starter with the standard sections, claude refines it and the
+// result is merged back into the starter so that the standard sections remain.
+func runInit(opts InitOptions, input io.Reader, output io.Writer, getenv func(string) string, now func() time.Time) error {
+	claudeMdPath := filepath.Join(opts.ProjectRoot, "CLAUDE.md")
+	if _, err := os.Stat(claudeMdPath); err == nil && !opts.Force {
+		return fmt.Errorf("CLAUDE.mdは既に存在します: %s（更新はフックの提案と --apply で行えます。作り直す場合は --force）", claudeMdPath)
+	}
+
+	config, err := LoadConfig(ConfigPath(getenv))
+	if err != nil {
+		return err
+	}
+	outputDir := ResolveOutputDir(getenv)
+	if opts.OutputDir != "" {
+		outputDir = ExpandTilde(opts.OutputDir)
+	}
+	if err := EnsurePrivateDir(outputDir); err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintln(output, "🔍 リポジトリを調査中...") // nolint:errcheck // Output to user, error not critical
+	summary, err := InspectRepository(opts.ProjectRoot)
+	if err != nil {
+		return err
+	}
+	if len(summary.Sources) > 0 {
+		_, _ = fmt.Fprintf(output, "  参照したファイル: %s\n", strings.Join(summary.Sources, ", ")) // nolint:errcheck // Output to user, error not critical
+	} else {
+		_, _ = fmt.Fprintln(output, "  ⚠️  マニフェストやREADMEが見つかりませんでした") // nolint:errcheck // Output to user, error not critical
+	}
+
+	timestamp := now().Format("20060102-150405")
+	suggestionFile := filepath.Join(outputDir, fmt.Sprintf("%sinit-%s.md", outputFilePrefix, timestamp))
+	content := StarterClaudeMd(summary)
+	if !opts.NoClaude {
+		content, err = refineStarter(opts, summary, content, suggestionFile, config, output, getenv, now)
+		if err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(suggestionFile, []byte(content), privateFilePerm); err != nil {
+		return fmt.Errorf("CLAUDE.mdの案の保存に失敗: %w", err)
+	}
+
+	_, _ = fmt.Fprintln(output)                                         // nolint:errcheck // Output to user, error not critical
+	_, _ = fmt.Fprintln(output, "="+strings.Repeat("=", 79))            // nolint:errcheck // Output to user, error not critical
+	_, _ = fmt.Fprintln(output, "✨ 作成するCLAUDE.md")                      // nolint:errcheck // Output to user, error not critical
+	_, _ = fmt.Fprintln(output, "="+strings.Repeat("=", 79))            // nolint:errcheck // Output to user, error not critical
+	_, _ = fmt.Fprintln(output, content)                                // nolint:errcheck // Output to user, error not critical
+	_, _ = fmt.Fprintf(output, "📄 CLAUDE.mdの案: %s\n\n", suggestionFile) // nolint:errcheck // Output to user, error not critical
+
+	if !opts.Yes {
+		_, _ = fmt.Fprint(output, "この内容でCLAUDE.mdを作成しますか? (yes/no): ") // nolint:errcheck // Output to user, error not critical
+		confirmed, err := readConfirmation(bufio.NewScanner(input))
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			_, _ = fmt.Fprintln(output, "❌ キャンセルしました") // nolint:errcheck // Output to user, error not critical
+			return nil
+		}
+	}
+
+	if err := os.WriteFile(claudeMdPath, []byte(content), 0o644); err != nil {
+		return fmt.Errorf("CLAUDE.mdへの書き込みに失敗: %w", err)
+	}
+	_, _ = fmt.Fprintf(output, "✅ CLAUDE.mdを作成しました: %s\n", claudeMdPath) // nolint:errcheck // Output to user, error not critical
+	return nil
+}
+
+// refineStarter runs claude on the starter and merges its CLAUDE.md into the starter.
+// If claude fails or its output is not usable, the starter is returned unchanged.
+func refineStarter(opts InitOptions, summary ProjectSummary, starter, suggestionFile string, config *Config, output io.Writer, getenv func(string) string, now func() time.Time) (string, error) {
+	promptTemplate := InitPromptContent
+	if opts.PromptFile != "" {
+		content, err := os.ReadFile(ExpandTilde(opts.PromptFile))
+		if err != nil {
+			return "", fmt.Errorf("プロンプトファイルの読み込みに失敗: %w", err)
+		}
+		promptTemplate = string(content)
+	}
+
+	logFile := strings.TrimSuffix(suggestionFile, ".md") + ".log"
+	for _, path := range []string{logFile, suggestionFile} {
+		if err := createPrivateFile(path); err != nil {
+			return "", fmt.Errorf("出力ファイルの作成に失敗: %w", err)
+		}
+	}
+	tempPromptFile, err := os.CreateTemp(filepath.Dir(suggestionFile), promptFilePattern)
+	if err != nil {
+		return "", fmt.Errorf("一時ファイルの作成に失敗: %w", err)
+	}
+	tempPromptFilePath := tempPromptFile.Name()
+	if _, err := tempPromptFile.WriteString(GenerateInitPrompt(promptTemplate, summary, starter)); err != nil {
+		_ = tempPromptFile.Close()        // nolint:errcheck // Best-effort cleanup in error path
+		_ = os.Remove(tempPromptFilePath) // nolint:errcheck // Best-effort cleanup in error path
+		return "", fmt.Errorf("一時ファイルへの書き込みに失敗: %w", err)
+	}
+	_ = tempPromptFile.Close() // nolint:errcheck // File is read-only from here
+
+	_, _ = fmt.Fprintln(output, "🤖 CLAUDE.mdを作成中...") // nolint:errcheck // Output to user, error not critical
+	start := time.Now()
+	usage, err := ExecuteSynchronously(&ExecutorConfig{
+		ProjectRoot:        opts.ProjectRoot,
+		TempPromptFilePath: tempPromptFilePath,
+		LogFile:            logFile,
+		HookInfo:           "Manual: init",
+		SuggestionFile:     suggestionFile,
+	})
+	record := UsageRecord{
+		Time:           now(),
+		ProjectRoot:    opts.ProjectRoot,
+		HookInfo:       "Manual: init",
+		Status:         JobSucceeded,
+		DurationMillis: elapsedMillis(start),
+		SuggestionFile: suggestionFile,
+		Usage:          usage,
+	}
+	if err != nil {
+		record.Status = JobFailed
+	}
+	recordUsage(config, getenv, record, output)
+	if err != nil {
+		_ = os.Remove(tempPromptFilePath)                                               // nolint:errcheck // Best-effort cleanup in error path
+		_, _ = fmt.Fprintf(output, "⚠️  claudeの実行に失敗したため、リポジトリの情報のみから作成します: %v\n", err) // nolint:errcheck // Output to user, error not critical
+		_, _ = fmt.Fprintf(output, "詳細なログ: %s\n", logFile)                              // nolint:errcheck // Output to user, error not critical
+		return starter, nil
+	}
+
+	raw, err := os.ReadFile(suggestionFile)
+	if err != nil {
+		return "", fmt.Errorf("claudeの出力の読み込みに失敗: %w", err)
+	}
+	validation := ValidateSuggestion(string(raw), FormatMarkdown)
+	if !validation.Valid() {
+		_, _ = fmt.Fprintln(output, "⚠️  claudeの出力が不正なため、リポジトリの情報のみから作成します") // nolint:errcheck // Output to user, error not critical
+		for _, problem := range validation.Problems {
+			_, _ = fmt.Fprintf(output, "  - %s\n", problem) // nolint:errcheck // Output to user, error not critical
+		}
+		_, _ = fmt.Fprintf(output, "詳細なログ: %s\n", logFile) // nolint:errcheck // Output to user, error not critical
+		return starter, nil
+	}
+	for _, fix := range validation.Fixes {
+		_, _ = fmt.Fprintf(output, "🧹 %s\n", fix) // nolint:errcheck // Output to user, error not critical
+	}
+
+	// 雛形の標準セクションを残しつつ、claudeの記述をセクション単位で統合する
+	markdown, _, err := SplitSuggestion(validation.Content)
+	if err != nil {
+		return "", fmt.Errorf("claudeの出力が不正です: %w", err)
+	}
+	return MergeSectionsWithOptions(starter, markdown, config.MergeOptions()).Content, nil
+}
+
+// runInitCommand runs `suggest-claude-md init`.
+func runInitCommand(args []string, input io.Reader, output io.Writer, getwd func() (string, error), getenv func(string) string, now func() time.Time) error {
+	const usage = "使い方: suggest-claude-md init [--project <dir>] [--output-dir <dir>] [--prompt <file>] [--no-claude] [--force] [--yes]"
+
+	var opts InitOptions
+	fs := flag.NewFlagSet("init", flag.ContinueOnError)
+	fs.StringVar(&opts.ProjectRoot, "project", "", "Project directory (default: current directory)")
+	fs.StringVar(&opts.OutputDir, "output-dir", "", "Directory for the draft and log files")
+	fs.StringVar(&opts.PromptFile, "prompt", "", "Prompt file used instead of the built-in init prompt")
+	fs.BoolVar(&opts.NoClaude, "no-claude", false, "Build CLAUDE.md from the repository contents only")
+	fs.BoolVar(&opts.Force, "force", false, "Overwrite an existing CLAUDE.md")
+	fs.BoolVar(&opts.Yes, "yes", false, "Write CLAUDE.md without confirmation")
+	rest, err := parseSubcommandFlags(fs, args)
+	if err != nil {
+		return fmt.Errorf("%w\n%s", err, usage)
+	}
+	if len(rest) > 0 {
+		return fmt.Errorf("%s", usage)
+	}
+
+	if opts.ProjectRoot, err = resolveProjectRoot(opts.ProjectRoot, getwd); err != nil {
+		return err
+	}
+	return runInit(opts, input, output, getenv, now)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStarterClaudeMd(t *testing.T) {
+	summary := ProjectSummary{
+		Name:          "tool",
+		Stack:         []string{"Go 1.22"},
+		Commands:      []ProjectCommand{{"make test", "Run tests"}, {"make build", ""}},
+		CI:            []CIConfig{{Path: ".github/workflows/ci.yml", Name: "CI", Commands: []string{"go test ./..."}}},
+		ReadmeExcerpt: "A tool.",
+	}
+
+	want := "# CLAUDE.md\n\n" +
+		"このファイルは、このリポジトリで作業する Claude Code へのガイダンスです。\n\n" +
+		"## プロジェクト概要\n\nA tool.\n\n" +
+		"## 技術スタック\n\n- Go 1.22\n\n" +
+		"## よく使うコマンド\n\n- `make test`: Run tests\n- `make build`\n\n" +
+		"## CI\n\n- `.github/workflows/ci.yml`（CI）: `go test ./...`\n"
+	if got := StarterClaudeMd(summary); got != want {
+		t.Errorf("StarterClaudeMd() =\n%s\nwant:\n%s", got, want)
+	}
+
+	// 情報がなくても概要にはプロジェクト名を記載する
+	if got := StarterClaudeMd(ProjectSummary{Name: "empty"}); !strings.HasSuffix(got, "## プロジェクト概要\n\nempty\n") {
+		t.Errorf("StarterClaudeMd() = %q", got)
+	}
+}
+
+func TestRunInitCommand(t *testing.T) {
+	projectRoot := t.TempDir()
+	writeProjectFiles(t, projectRoot, map[string]string{
+		"go.mod":   "module example.com/tool\n\ngo 1.22\n",
+		"Makefile": "test: ## Run tests\n\tgo test ./...\n",
+	})
+	writeFakeClaude(t, "以下がCLAUDE.mdです。\n\n# CLAUDE.md\n\n"+
+		"## よく使うコマンド\n\n- `make test`: Run tests\n- `go test -run TestX ./...`: 単一テストを実行\n\n"+
+		"## コーディング規約\n\n- エラーは fmt.Errorf でラップする\n")
+	getenv := func(string) string { return "" }
+	outputDir := t.TempDir()
+	now := func() time.Time { return time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC) }
+	getwd := func() (string, error) { return projectRoot, nil }
+
+	output := &bytes.Buffer{}
+	if err := runInitCommand([]string{"--output-dir", outputDir}, strings.NewReader("yes\n"), output, getwd, getenv, now); err != nil {
+		t.Fatalf("runInitCommand() error = %v\n%s", err, output.String())
+	}
+
+	content, err := os.ReadFile(filepath.Join(projectRoot, "CLAUDE.md"))
+	if err != nil {
+		t.Fatalf("CLAUDE.md should be created: %v", err)
+	}
+	want := "# CLAUDE.md\n\n" +
+		"このファイルは、このリポジトリで作業する Claude Code へのガイダンスです。\n\n" +
+		"## プロジェクト概要\n\nexample.com/tool\n\n" +
+		"## 技術スタック\n\n- Go 1.22\n\n" +
+		"## よく使うコマンド\n\n- `make test`: Run tests\n\n- `go test -run TestX ./...`: 単一テストを実行\n\n" +
+		"## コーディング規約\n\n- エラーは fmt.Errorf でラップする\n"
+	if string(content) != want {
+		t.Errorf("CLAUDE.md = %q, want %q", content, want)
+	}
+
+	draft, err := os.ReadFile(filepath.Join(outputDir, "suggest-claude-md-init-20250102-030405.md"))
+	if err != nil || string(draft) != want {
+		t.Errorf("Draft should be saved with the CLAUDE.md content: %v", err)
+	}
+	for _, msg := range []string{"参照したファイル: go.mod, Makefile", "前置き", "✅ CLAUDE.mdを作成しました"} {
+		if !strings.Contains(output.String(), msg) {
+			t.Errorf("Output should contain %q, got: %s", msg, output.String())
+		}
+	}
+
+	// 既存のCLAUDE.mdは --force なしでは上書きしない
+	err = runInitCommand([]string{"--output-dir", outputDir}, strings.NewReader("yes\n"), &bytes.Buffer{}, getwd, getenv, now)
+	if err == nil || !strings.Contains(err.Error(), "既に存在します") {
+		t.Errorf("runInitCommand() error = %v, want existing CLAUDE.md error", err)
+	}
+}
+
+func TestRunInitCommand_NoClaude(t *testing.T) {
+	projectRoot := t.TempDir()
+	writeProjectFiles(t, projectRoot, map[string]string{"CLAUDE.md": "old\n", "README.md": "# App\n\nAn app.\n"})
+	forbidClaude(t)
+	getenv := func(string) string { return "" }
+	getwd := func() (string, error) { return projectRoot, nil }
+	args := []string{"--no-claude", "--force", "--output-dir", t.TempDir()}
+
+	// 確認でnoと答えると書き込まない
+	output := &bytes.Buffer{}
+	if err := runInitCommand(args, strings.NewReader("no\n"), output, getwd, getenv, time.Now); err != nil {
+		t.Fatalf("runInitCommand() error = %v", err)
+	}
+	if content, _ := os.ReadFile(filepath.Join(projectRoot, "CLAUDE.md")); string(content) != "old\n" {
+		t.Errorf("CLAUDE.md should not change when cancelled, got %q", content)
+	}
+
+	if err := runInitCommand(append(args, "--yes"), strings.NewReader(""), &bytes.Buffer{}, getwd, getenv, time.Now); err != nil {
+		t.Fatalf("runInitCommand() error = %v", err)
+	}
+	content, _ := os.ReadFile(filepath.Join(projectRoot, "CLAUDE.md")) // nolint:errcheck // Checked by content
+	if !strings.Contains(string(content), "## プロジェクト概要\n\nAn app.\n") {
+		t.Errorf("CLAUDE.md = %q", content)
+	}
+}
+
+func TestRunInitCommand_ClaudeOutputInvalid(t *testing.T) {
+	projectRoot := t.TempDir()
+	writeProjectFiles(t, projectRoot, map[string]string{"go.mod": "module m\n"})
+	writeFakeClaude(t, "Error: credit balance is too low\n")
+	getenv := func(string) string { return "" }
+
+	output := &bytes.Buffer{}
+	args := []string{"--project", projectRoot, "--output-dir", t.TempDir(), "--yes"}
+	if err := runInitCommand(args, strings.NewReader(""), output, os.Getwd, getenv, time.Now); err != nil {
+		t.Fatalf("runInitCommand() error = %v", err)
+	}
+	if !strings.Contains(output.String(), "リポジトリの情報のみから作成します") {
+		t.Errorf("Output should explain the fallback, got: %s", output.String())
+	}
+	content, _ := os.ReadFile(filepath.Join(projectRoot, "CLAUDE.md")) // nolint:errcheck // Checked by content
+	if string(content) != StarterClaudeMd(ProjectSummary{Name: "m", Stack: []string{"Go"}}) {
+		t.Errorf("CLAUDE.md should be the starter, got %q", content)
+	}
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	// jobsDirName is the directory of the output directory holding async job records.
+	jobsDirName = "jobs"
+	// defaultJobListLimit is the number of jobs listed by default.
+	defaultJobListLimit = 20
+)
+
+// JobStatus is the state of an async analysis job.
+type JobStatus string
+
+const (
+	// JobQueued means the worker has not started the analysis yet
+	JobQueued JobStatus = "queued"
+	// JobRunning means the worker is analyzing the transcript
+	JobRunning JobStatus = "running"
+	// JobSucceeded means a valid suggestion was generated
+	JobSucceeded JobStatus = "succeeded"
+	// JobInvalid means the suggestion failed validation
+	JobInvalid JobStatus = "invalid"
+	// JobSkipped means nothing was analyzed, see SkipReason
+	JobSkipped JobStatus = "skipped"
+	// JobFailed means the analysis or the worker failed
+	JobFailed JobStatus = "failed"
+)
+
+// jobStatusLabels are the labels shown by the jobs command.
+var jobStatusLabels = map[JobStatus]string{
+	JobQueued:    "⏳ 待機中",
+	JobRunning:   "🔄 実行中",
+	JobSucceeded: "✅ 完了",
+	JobInvalid:   "⚠️  不正な提案",
+	JobSkipped:   "⏭️  スキップ",
+	JobFailed:    "❌ 失敗",
+}
+
+// Finished reports whether the job will not change any more.
+func (s JobStatus) Finished() bool {
+	return s != JobQueued && s != JobRunning
+}
+
+// Job is an analysis started by a hook in async mode and run by a detached worker.
+type Job struct {
+	ID             string    `json:"id"`
+	Status         JobStatus `json:"status"`
+	ProjectRoot    string    `json:"project_root"`
+	TranscriptPath string    `json:"transcript_path"`
+	HookInfo       string    `json:"hook_info"`
+	ConfigPath     string    `json:"config_path,omitempty"`
+	Terminal       string    `json:"terminal,omitempty"` // terminal of the hook, used by the bell notifier
+	PID            int       `json:"pid,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	StartedAt      time.Time `json:"started_at"`
+	FinishedAt     time.Time `json:"finished_at"`
+	SuggestionFile string    `json:"suggestion_file,omitempty"`
+	LogFile        string    `json:"log_file,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	SkipReason     string    `json:"skip_reason,omitempty"`
+	Applied        bool      `json:"applied,omitempty"` // the suggestion was applied with --apply
+}
+
+// Stale reports whether the job is marked running but its worker is gone.
+func (j *Job) Stale() bool {
+	return j.Status == JobRunning && !processAlive(j.PID)
+}
+
+// StatusLabel returns the status shown to the user.
+func (j *Job) StatusLabel() string {
+	if j.Stale() {
+		return "💀 中断（ワーカーが終了しています）"
+	}
+	if label, ok := jobStatusLabels[j.Status]; ok {
+		return label
+	}
+	return string(j.Status)
+}
+
+// JobStore keeps job records as JSON files in a private directory.
+type JobStore struct {
+	dir string
+}
+
+// NewJobStore returns the job store under the output directory, creating it if needed.
+func NewJobStore(outputDir string) (*JobStore, error) {
+	dir := filepath.Join(outputDir, jobsDirName)
+	if err := EnsurePrivateDir(dir); err != nil {
+		return nil, err
+	}
+	return &JobStore{dir: dir}, nil
+}
+
+// Path returns the record file of a job.
+func (s *JobStore) Path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+// WorkerLogPath returns the file receiving the output of the job's analysis.
+func (s *JobStore) WorkerLogPath(id string) string {
+	return filepath.Join(s.dir, id+".log")
+}
+
+// projectFile returns a per-project file of the store, e.g. the worker lock.
+func (s *JobStore) projectFile(projectRoot, ext string) string {
+	return filepath.Join(s.dir, "worker"+EncodeProjectDir(projectRoot)+ext)
+}
+
+// ProjectWorkerLogPath returns the file receiving the output of the project's worker.
+func (s *JobStore) ProjectWorkerLogPath(projectRoot string) string {
+	return s.projectFile(projectRoot, ".log")
+}
+
+// LockProject takes the worker lock of the project without waiting. ok is false
+// when another worker holds it. The lock is released by unlock or when the process exits.
+func (s *JobStore) LockProject(projectRoot string) (unlock func(), ok bool, err error) {
+	file, err := os.OpenFile(s.projectFile(projectRoot, ".lock"), os.O_CREATE|os.O_RDWR, privateFilePerm)
+	if err != nil {
+		return nil, false, fmt.Errorf("ワーカーのロックファイルの作成に失敗: %w", err)
+	}
+	locked, err := tryLockFile(file)
+	if err != nil || !locked {
+		_ = file.Close() // nolint:errcheck // Nothing was written
+		if err != nil {
+			return nil, false, fmt.Errorf("ワーカーのロックに失敗: %w", err)
+		}
+		return nil, false, nil
+	}
+	return func() { _ = file.Close() }, true, nil // nolint:errcheck // Closing releases the lock
+}
+
+// Queued returns the queued jobs of the project, oldest first.
+func (s *JobStore) Queued(projectRoot string) ([]*Job, error) {
+	jobs, err := s.ListProject(projectRoot)
+	if err != nil {
+		return nil, err
+	}
+	var queued []*Job
+	for i := len(jobs) - 1; i >= 0; i-- {
+		if jobs[i].Status == JobQueued {
+			queued = append(queued, jobs[i])
+		}
+	}
+	return queued, nil
+}
+
+// PendingSuggestions returns the successful jobs of the project whose suggestion
+// has not been applied yet, oldest first. Suggestions finished before the last
+// change of CLAUDE.md are considered reviewed.
+func (s *JobStore) PendingSuggestions(projectRoot string) ([]*Job, error) {
+	jobs, err := s.ListProject(projectRoot)
+	if err != nil {
+		return nil, err
+	}
+	var reviewed time.Time
+	if info, err := os.Stat(filepath.Join(projectRoot, "CLAUDE.md")); err == nil {
+		reviewed = info.ModTime()
+	}
+
+	var pending []*Job
+	for i := len(jobs) - 1; i >= 0; i-- {
+		job := jobs[i]
+		if job.Status != JobSucceeded || job.Applied || !job.FinishedAt.After(reviewed) {
+			continue
+		}
+		if _, err := os.Stat(job.SuggestionFile); err != nil {
+			continue
+		}
+		pending = append(pending, job)
+	}
+	return pending, nil
+}
+
+// markSuggestionApplied marks the jobs that generated the suggestion file as applied,
+// so that later jobs no longer treat it as pending.
+func markSuggestionApplied(outputDir, suggestionFile string) error {
+	dir := filepath.Join(outputDir, jobsDirName)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil
+	}
+	store := &JobStore{dir: dir}
+	jobs, err := store.List()
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		if job.SuggestionFile == suggestionFile && !job.Applied {
+			job.Applied = true
+			if err := store.Save(job); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Create stores a new job, assigning an ID from its creation time and session.
+func (s *JobStore) Create(job *Job) error {
+	sessionID := transcriptSessionID(job.TranscriptPath)
+	base := fmt.Sprintf("%s-%s", job.CreatedAt.Format("20060102-150405"), shortSessionID(sessionID))
+	for i := 1; ; i++ {
+		job.ID = base
+		if i > 1 {
+			job.ID = fmt.Sprintf("%s-%d", base, i)
+		}
+		file, err := os.OpenFile(s.Path(job.ID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, privateFilePerm)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("ジョブの作成に失敗: %w", err)
+		}
+		_ = file.Close() // nolint:errcheck // Empty placeholder, written by Save
+		return s.Save(job)
+	}
+}
+
+// Save writes the job record atomically so that readers never see a partial record.
+func (s *JobStore) Save(job *Job) error {
+	data, err := json.MarshalIndent(job, "", "  ")
+	if err != nil {
+		return fmt.Errorf("ジョブの保存に失敗: %w", err)
+	}
+	temp, err := os.CreateTemp(s.dir, ".job-*")
+	if err != nil {
+		return fmt.Errorf("ジョブの保存に失敗: %w", err)
+	}
+	if _, err := temp.Write(append(data, '\n')); err != nil {
+		_ = temp.Close()           // nolint:errcheck // Best-effort cleanup in error path
+		_ = os.Remove(temp.Name()) // nolint:errcheck // Best-effort cleanup in error path
+		return fmt.Errorf("ジョブの保存に失敗: %w", err)
+	}
+	if err := temp.Close(); err != nil {
+		_ = os.Remove(temp.Name()) // nolint:errcheck // Best-effort cleanup in error path
+		return fmt.Errorf("ジョブの保存に失敗: %w", err)
+	}
+	if err := os.Rename(temp.Name(), s.Path(job.ID)); err != nil {
+		_ = os.Remove(temp.Name()) // nolint:errcheck // Best-effort cleanup in error path
+		return fmt.Errorf("ジョブの保存に失敗: %w", err)
+	}
+	return nil
+}
+
+// LoadJob reads a job record.
+func LoadJob(path string) (*Job, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ジョブの読み込みに失敗: %w", err)
+	}
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("ジョブの解析に失敗 (%s): %w", path, err)
+	}
+	return &job, nil
+}
+
+// List returns the stored jobs, newest first. Unreadable records are skipped.
+func (s *JobStore) List() ([]*Job, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var jobs []*Job
+	for _, path := range paths {
+		job, err := LoadJob(path)
+		if err != nil {
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.After(jobs[j].CreatedAt)
+	})
+	return jobs, nil
+}
+
+// ListProject returns the stored jobs of the project, newest first.
+// An empty projectRoot returns the jobs of all projects.
+func (s *JobStore) ListProject(projectRoot string) ([]*Job, error) {
+	jobs, err := s.List()
+	if err != nil || projectRoot == "" {
+		return jobs, err
+	}
+	var filtered []*Job
+	for _, job := range jobs {
+		if job.ProjectRoot == projectRoot {
+			filtered = append(filtered, job)
+		}
+	}
+	return filtered, nil
+}
+
+// Prune removes the records and worker logs of finished jobs older than the
+// retention period.
+func (s *JobStore) Prune(policy RetentionPolicy, now time.Time) error {
+	if policy.MaxAgeDays <= 0 {
+		return nil
+	}
+	jobs, err := s.List()
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		if !job.Status.Finished() || now.Sub(job.CreatedAt) <= time.Duration(policy.MaxAgeDays)*24*time.Hour {
+			continue
+		}
+		for _, path := range []string{s.Path(job.ID), s.WorkerLogPath(job.ID)} {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("古いジョブの削除に失敗: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// runJobsCommand runs `suggest-claude-md jobs`, listing async analyses.
+func runJobsCommand(args []string, output io.Writer, getwd func() (string, error), getenv func(string) string) error {
+	const usage = "使い方: suggest-claude-md jobs [--project <dir>] [--limit <n>]"
+
+	var projectRoot string
+	var limit int
+	fs := flag.NewFlagSet("jobs", flag.ContinueOnError)
+	fs.StringVar(&projectRoot, "project", "", "Only list jobs of this project")
+	fs.IntVar(&limit, "limit", defaultJobListLimit, "Number of jobs to list (0: all)")
+	rest, err := parseSubcommandFlags(fs, args)
+	if err != nil {
+		return fmt.Errorf("%w\n%s", err, usage)
+	}
+	if len(rest) > 0 || limit < 0 {
+		return fmt.Errorf("%s", usage)
+	}
+	if projectRoot != "" {
+		if projectRoot, err = resolveProjectRoot(projectRoot, getwd); err != nil {
+			return err
+		}
+	}
+
+	store, err := NewJobStore(ResolveOutputDir(getenv))
+	if err != nil {
+		return err
+	}
+	jobs, err := store.ListProject(projectRoot)
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(output, "📋 ジョブ一覧: %s (%d件)\n", store.dir, len(jobs)) // nolint:errcheck // Output to user, error not critical
+	if len(jobs) == 0 {
+		return nil
+	}
+	_, _ = fmt.Fprintln(output) // nolint:errcheck // Output to user, error not critical
+	for i, job := range jobs {
+		if limit > 0 && i >= limit {
+			_, _ = fmt.Fprintf(output, "... 他%d件（--limit 0ですべて表示）\n", len(jobs)-limit) // nolint:errcheck // Output to user, error not critical
+			break
+		}
+		printJob(output, store, job)
+	}
+	_, _ = fmt.Fprintln(output)                                               // nolint:errcheck // Output to user, error not critical
+	_, _ = fmt.Fprintln(output, "適用するには: suggest-claude-md --apply <提案ファイル>") // nolint:errcheck // Output to user, error not critical
+	return nil
+}
+
+// printJob prints one job of the jobs command.
+func printJob(output io.Writer, store *JobStore, job *Job) {
+	duration := "-"
+	if !job.StartedAt.IsZero() && !job.FinishedAt.IsZero() {
+		duration = job.FinishedAt.Sub(job.StartedAt).Round(time.Second).String()
+	}
+	_, _ = fmt.Fprintf(output, "%s  %s  %s  %s\n", // nolint:errcheck // Output to user, error not critical
+		job.ID, job.StatusLabel(), job.CreatedAt.Local().Format("2006-01-02 15:04:05"), duration)
+	_, _ = fmt.Fprintf(output, "    プロジェクト: %s\n", job.ProjectRoot) // nolint:errcheck // Output to user, error not critical
+	if job.SuggestionFile != "" {
+		_, _ = fmt.Fprintf(output, "    📄 %s\n", job.SuggestionFile) // nolint:errcheck // Output to user, error not critical
+	}
+	if job.Error != "" {
+		_, _ = fmt.Fprintf(output, "    エラー: %s\n", job.Error) // nolint:errcheck // Output to user, error not critical
+	}
+	if job.SkipReason != "" {
+		_, _ = fmt.Fprintf(output, "    理由: %s\n", job.SkipReason) // nolint:errcheck // Output to user, error not critical
+	}
+	if job.Stale() || job.Status == JobFailed {
+		// 分析の出力はジョブのログに、ワーカー自体のエラーはプロジェクトのワーカーのログに残る
+		_, _ = fmt.Fprintf(output, "    ジョブのログ: %s\n", store.WorkerLogPath(job.ID))                  // nolint:errcheck // Output to user, error not critical
+		_, _ = fmt.Fprintf(output, "    ワーカーのログ: %s\n", store.ProjectWorkerLogPath(job.ProjectRoot)) // nolint:errcheck // Output to user, error not critical
+	}
+}
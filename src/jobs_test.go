@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJobStore(t *testing.T) {
+	store, err := NewJobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewJobStore() error = %v", err)
+	}
+	info, err := os.Stat(store.dir)
+	if err != nil || info.Mode().Perm() != privateDirPerm {
+		t.Fatalf("Job directory should be private: %v %v", info, err)
+	}
+
+	created := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	var jobs []*Job
+	for i := 0; i < 3; i++ {
+		job := &Job{
+			Status:         JobQueued,
+			TranscriptPath: "/t/602a7e81-2f30-4582-a58e-b717cfd29e1d.jsonl",
+			CreatedAt:      created.Add(time.Duration(i) * time.Millisecond),
+		}
+		if err := store.Create(job); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		jobs = append(jobs, job)
+	}
+	// 同じ秒・同じセッションのジョブには連番を付ける
+	for i, want := range []string{"20250102-030405-602a7e81", "20250102-030405-602a7e81-2", "20250102-030405-602a7e81-3"} {
+		if jobs[i].ID != want {
+			t.Errorf("jobs[%d].ID = %q, want %q", i, jobs[i].ID, want)
+		}
+	}
+
+	listed, err := store.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(listed) != 3 || listed[0].ID != jobs[2].ID || listed[2].ID != jobs[0].ID {
+		t.Errorf("List() should return newest first, got %d jobs", len(listed))
+	}
+
+	// 保持期間を過ぎた終了済みのジョブだけを削除する
+	jobs[0].Status = JobSucceeded
+	jobs[1].Status = JobRunning
+	for _, job := range jobs[:2] {
+		if err := store.Save(job); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+	if err := os.WriteFile(store.WorkerLogPath(jobs[0].ID), []byte("log"), 0o600); err != nil {
+		t.Fatalf("Failed to create worker log: %v", err)
+	}
+	if err := store.Prune(RetentionPolicy{MaxAgeDays: 1}, created.Add(48*time.Hour)); err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	for i, wantExists := range []bool{false, true, true} {
+		if _, err := os.Stat(store.Path(jobs[i].ID)); (err == nil) != wantExists {
+			t.Errorf("job %s exists = %v, want %v", jobs[i].ID, err == nil, wantExists)
+		}
+	}
+	if _, err := os.Stat(store.WorkerLogPath(jobs[0].ID)); !os.IsNotExist(err) {
+		t.Errorf("Worker log of a pruned job should be removed")
+	}
+}
+
+func TestJobStore_ListProject(t *testing.T) {
+	store, err := NewJobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewJobStore() error = %v", err)
+	}
+	created := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i, projectRoot := range []string{"/work/app", "/work/lib", "/work/app"} {
+		job := &Job{Status: JobQueued, ProjectRoot: projectRoot, TranscriptPath: "/t/s.jsonl", CreatedAt: created.Add(time.Duration(i) * time.Second)}
+		if err := store.Create(job); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		projectRoot string
+		want        int
+	}{
+		{projectRoot: "/work/app", want: 2},
+		{projectRoot: "/work/other", want: 0},
+		{projectRoot: "", want: 3},
+	}
+	for _, tt := range tests {
+		jobs, err := store.ListProject(tt.projectRoot)
+		if err != nil {
+			t.Fatalf("ListProject(%q) error = %v", tt.projectRoot, err)
+		}
+		if len(jobs) != tt.want {
+			t.Errorf("ListProject(%q) = %d jobs, want %d", tt.projectRoot, len(jobs), tt.want)
+		}
+		for i, job := range jobs {
+			if tt.projectRoot != "" && job.ProjectRoot != tt.projectRoot {
+				t.Errorf("ListProject(%q) returned a job of %s", tt.projectRoot, job.ProjectRoot)
+			}
+			if i > 0 && job.CreatedAt.After(jobs[i-1].CreatedAt) {
+				t.Errorf("ListProject(%q) should return newest first", tt.projectRoot)
+			}
+		}
+	}
+}
+
+func TestJobStore_LockProject(t *testing.T) {
+	store, err := NewJobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewJobStore() error = %v", err)
+	}
+	unlock, ok, err := store.LockProject("/project/a")
+	if err != nil || !ok {
+		t.Fatalf("LockProject() = %v, %v", ok, err)
+	}
+	if _, ok, err := store.LockProject("/project/a"); err != nil || ok {
+		t.Errorf("LockProject() of a locked project = %v, %v, want false", ok, err)
+	}
+	// 別のプロジェクトのワーカーは並行して動ける
+	unlockOther, ok, err := store.LockProject("/project/b")
+	if err != nil || !ok {
+		t.Fatalf("LockProject() of another project = %v, %v", ok, err)
+	}
+	unlockOther()
+
+	unlock()
+	unlock, ok, err = store.LockProject("/project/a")
+	if err != nil || !ok {
+		t.Fatalf("LockProject() after unlock = %v, %v", ok, err)
+	}
+	unlock()
+}
+
+func TestJobStore_Queued(t *testing.T) {
+	store, err := NewJobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewJobStore() error = %v", err)
+	}
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	jobs := []*Job{
+		{Status: JobQueued, ProjectRoot: "/project", TranscriptPath: "/t/b.jsonl", CreatedAt: createdAt.Add(time.Minute)},
+		{Status: JobQueued, ProjectRoot: "/project", TranscriptPath: "/t/a.jsonl", CreatedAt: createdAt},
+		{Status: JobSucceeded, ProjectRoot: "/project", TranscriptPath: "/t/c.jsonl", CreatedAt: createdAt},
+		{Status: JobQueued, ProjectRoot: "/other", TranscriptPath: "/t/d.jsonl", CreatedAt: createdAt},
+	}
+	for _, job := range jobs {
+		if err := store.Create(job); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+
+	queued, err := store.Queued("/project")
+	if err != nil {
+		t.Fatalf("Queued() error = %v", err)
+	}
+	if len(queued) != 2 || queued[0].ID != jobs[1].ID || queued[1].ID != jobs[0].ID {
+		t.Errorf("Queued() = %+v, want the queued jobs of the project oldest first", queued)
+	}
+}
+
+func TestJobStore_PendingSuggestions(t *testing.T) {
+	outputDir := t.TempDir()
+	projectRoot := t.TempDir()
+	store, err := NewJobStore(outputDir)
+	if err != nil {
+		t.Fatalf("NewJobStore() error = %v", err)
+	}
+	claudeMd := filepath.Join(projectRoot, "CLAUDE.md")
+	if err := os.WriteFile(claudeMd, []byte("# CLAUDE.md\n"), 0o644); err != nil {
+		t.Fatalf("Failed to create CLAUDE.md: %v", err)
+	}
+	reviewed := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(claudeMd, reviewed, reviewed); err != nil {
+		t.Fatalf("Chtimes() error = %v", err)
+	}
+
+	suggestion := func(name string) string {
+		path := filepath.Join(outputDir, name)
+		if err := os.WriteFile(path, []byte("## Testing\n"), 0o600); err != nil {
+			t.Fatalf("Failed to create suggestion: %v", err)
+		}
+		return path
+	}
+	jobs := []*Job{
+		{Status: JobSucceeded, SuggestionFile: suggestion("new.md"), FinishedAt: reviewed.Add(time.Minute)},
+		{Status: JobSucceeded, SuggestionFile: suggestion("reviewed.md"), FinishedAt: reviewed.Add(-time.Minute)},
+		{Status: JobSucceeded, SuggestionFile: suggestion("applied.md"), FinishedAt: reviewed.Add(time.Minute), Applied: true},
+		{Status: JobSucceeded, SuggestionFile: filepath.Join(outputDir, "deleted.md"), FinishedAt: reviewed.Add(time.Minute)},
+		{Status: JobInvalid, SuggestionFile: suggestion("invalid.md"), FinishedAt: reviewed.Add(time.Minute)},
+	}
+	for i, job := range jobs {
+		job.ProjectRoot = projectRoot
+		job.TranscriptPath = filepath.Join(projectRoot, "session.jsonl")
+		job.CreatedAt = reviewed.Add(time.Duration(i) * time.Second)
+		if err := store.Create(job); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+
+	pending, err := store.PendingSuggestions(projectRoot)
+	if err != nil {
+		t.Fatalf("PendingSuggestions() error = %v", err)
+	}
+	if len(pending) != 1 || pending[0].ID != jobs[0].ID {
+		t.Fatalf("PendingSuggestions() = %+v, want only the new suggestion", pending)
+	}
+
+	// 適用した提案は未適用として扱わない
+	if err := markSuggestionApplied(outputDir, jobs[0].SuggestionFile); err != nil {
+		t.Fatalf("markSuggestionApplied() error = %v", err)
+	}
+	if pending, _ := store.PendingSuggestions(projectRoot); len(pending) != 0 { // nolint:errcheck // Checked by length
+		t.Errorf("PendingSuggestions() after apply = %+v, want none", pending)
+	}
+	if applied, _ := LoadJob(store.Path(jobs[0].ID)); !applied.Applied { // nolint:errcheck // Checked by field
+		t.Error("Job should be marked as applied")
+	}
+
+	// ジョブを使っていなければ何もしない
+	if err := markSuggestionApplied(t.TempDir(), jobs[0].SuggestionFile); err != nil {
+		t.Errorf("markSuggestionApplied() without jobs error = %v", err)
+	}
+}
+
+func TestJobStatusLabel(t *testing.T) {
+	tests := []struct {
+		job  Job
+		want string
+	}{
+		{Job{Status: JobQueued}, "⏳ 待機中"},
+		{Job{Status: JobRunning, PID: os.Getpid()}, "🔄 実行中"},
+		{Job{Status: JobRunning, PID: 0}, "💀 中断（ワーカーが終了しています）"},
+		{Job{Status: JobSucceeded}, "✅ 完了"},
+		{Job{Status: "unknown"}, "unknown"},
+	}
+	for _, tt := range tests {
+		if got := tt.job.StatusLabel(); got != tt.want {
+			t.Errorf("StatusLabel(%+v) = %q, want %q", tt.job, got, tt.want)
+		}
+	}
+}
+
+func TestRunJobsCommand(t *testing.T) {
+	tmpDir := t.TempDir()
+	getenv := func(key string) string {
+		if key == "XDG_RUNTIME_DIR" {
+			return tmpDir
+		}
+		return ""
+	}
+	getwd := func() (string, error) { return tmpDir, nil }
+	store, err := NewJobStore(ResolveOutputDir(getenv))
+	if err != nil {
+		t.Fatalf("NewJobStore() error = %v", err)
+	}
+
+	otherProject := filepath.Join(tmpDir, "other")
+	if err := os.Mkdir(otherProject, 0o755); err != nil {
+		t.Fatalf("Failed to create project: %v", err)
+	}
+	created := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, job := range []*Job{
+		{Status: JobSucceeded, ProjectRoot: tmpDir, TranscriptPath: "a.jsonl", CreatedAt: created,
+			StartedAt: created, FinishedAt: created.Add(42 * time.Second), SuggestionFile: "/out/a.md"},
+		{Status: JobFailed, ProjectRoot: tmpDir, TranscriptPath: "b.jsonl", CreatedAt: created.Add(time.Minute), Error: "実行に失敗"},
+		{Status: JobQueued, ProjectRoot: otherProject, TranscriptPath: "c.jsonl", CreatedAt: created.Add(2 * time.Minute)},
+	} {
+		if err := store.Create(job); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+
+	output := &bytes.Buffer{}
+	if err := runJobsCommand([]string{"--project", tmpDir}, output, getwd, getenv); err != nil {
+		t.Fatalf("runJobsCommand() error = %v", err)
+	}
+	outputStr := output.String()
+	for _, want := range []string{"(2件)", "20250102-030405-a  ✅ 完了", "42s", "📄 /out/a.md", "20250102-030505-b  ❌ 失敗", "エラー: 実行に失敗",
+		"ジョブのログ: " + store.WorkerLogPath("20250102-030505-b"), "ワーカーのログ: " + store.ProjectWorkerLogPath(tmpDir)} {
+		if !strings.Contains(outputStr, want) {
+			t.Errorf("Output should contain %q, got: %s", want, outputStr)
+		}
+	}
+	if strings.Contains(outputStr, "-c  ") {
+		t.Errorf("Jobs of other projects should not be listed: %s", outputStr)
+	}
+	// 新しい順に表示する
+	if strings.Index(outputStr, "-b  ") > strings.Index(outputStr, "-a  ") {
+		t.Errorf("Jobs should be listed newest first: %s", outputStr)
+	}
+
+	output.Reset()
+	if err := runJobsCommand([]string{"--limit", "1"}, output, getwd, getenv); err != nil {
+		t.Fatalf("runJobsCommand() error = %v", err)
+	}
+	if !strings.Contains(output.String(), "(3件)") || !strings.Contains(output.String(), "他2件") {
+		t.Errorf("Output should be limited, got: %s", output.String())
+	}
+
+	if err := runJobsCommand([]string{"extra"}, &bytes.Buffer{}, getwd, getenv); err == nil {
+		t.Error("runJobsCommand() should reject positional arguments")
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// logFileEnvVar, logLevelEnvVar and logFormatEnvVar override the logging settings
+	// of the config file. The --log-* options are passed to the async worker through them.
+	logFileEnvVar   = "SUGGEST_CLAUDE_MD_LOG_FILE"
+	logLevelEnvVar  = "SUGGEST_CLAUDE_MD_LOG_LEVEL"
+	logFormatEnvVar = "SUGGEST_CLAUDE_MD_LOG_FORMAT"
+
+	// logFileName is the default log file in the output directory. It does not start
+	// with outputFilePrefix, so that PruneOutputs leaves it alone.
+	logFileName = commandName + ".log"
+	// maxLogFileSize is the size from which the log file is rotated to <file>.1.
+	maxLogFileSize = 5 << 20
+)
+
+// LogFormat is the format of the records written to the log file.
+type LogFormat string
+
+const (
+	// LogFormatText writes key=value records
+	LogFormatText LogFormat = "text"
+	// LogFormatJSON writes one JSON object per record
+	LogFormatJSON LogFormat = "json"
+)
+
+// logger records the stages of the pipeline for debugging. It discards everything
+// until main configures it with setupLogging.
+var logger = discardLogger()
+
+// discardLogger returns a logger with every level disabled.
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError + 1}))
+}
+
+// ParseLogFormat parses a log format name. An empty name selects text.
+func ParseLogFormat(name string) (LogFormat, error) {
+	switch LogFormat(strings.ToLower(strings.TrimSpace(name))) {
+	case "", LogFormatText:
+		return LogFormatText, nil
+	case LogFormatJSON:
+		return LogFormatJSON, nil
+	default:
+		return "", fmt.Errorf("無効なログ形式: %s (有効な値: text, json)", name)
+	}
+}
+
+// ParseLogLevel parses a log level name. An empty name selects info.
+func ParseLogLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "debug":
+		return slog.LevelDebug, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("無効なログレベル: %s (有効な値: debug, info, warn, error)", name)
+	}
+}
+
+// LogOptions are the resolved logging settings.
+type LogOptions struct {
+	File   string // "off" disables logging
+	Level  slog.Level
+	Format LogFormat
+}
+
+// logOptionsFor resolves the logging settings: environment variables (and so the
+// --log-* options) take precedence over the config file. The log file defaults to
+// suggest-claude-md.log in the output directory.
+func logOptionsFor(config *Config, getenv func(string) string) (LogOptions, error) {
+	pick := func(envVar, configured string) string {
+		if value := getenv(envVar); value != "" {
+			return value
+		}
+		return configured
+	}
+
+	var opts LogOptions
+	var err error
+	if opts.Level, err = ParseLogLevel(pick(logLevelEnvVar, config.LogLevel)); err != nil {
+		return opts, err
+	}
+	if opts.Format, err = ParseLogFormat(pick(logFormatEnvVar, config.LogFormat)); err != nil {
+		return opts, err
+	}
+	opts.File = ExpandTilde(pick(logFileEnvVar, config.LogFile))
+	if opts.File == "" {
+		opts.File = filepath.Join(ResolveOutputDir(getenv), logFileName)
+	}
+	return opts, nil
+}
+
+// newLogger opens the log file, rotating it when it grew too large, and returns a
+// logger writing to it with the function closing it.
+func newLogger(opts LogOptions) (*slog.Logger, func(), error) {
+	if opts.File == "off" {
+		return discardLogger(), func() {}, nil
+	}
+	if err := EnsurePrivateDir(filepath.Dir(opts.File)); err != nil {
+		return nil, nil, err
+	}
+	if info, err := os.Stat(opts.File); err == nil && info.Size() >= maxLogFileSize {
+		if err := os.Rename(opts.File, opts.File+".1"); err != nil {
+			return nil, nil, fmt.Errorf("ログファイルのローテーションに失敗: %w", err)
+		}
+	}
+	file, err := os.OpenFile(opts.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, privateFilePerm)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ログファイルを開けません: %w", err)
+	}
+
+	handlerOpts := &slog.HandlerOptions{Level: opts.Level}
+	var handler slog.Handler = slog.NewTextHandler(file, handlerOpts)
+	if opts.Format == LogFormatJSON {
+		handler = slog.NewJSONHandler(file, handlerOpts)
+	}
+	// 複数のプロセスが同じファイルに書くため、どのプロセスの記録かを残す
+	l := slog.New(handler).With("pid", os.Getpid())
+	return l, func() { _ = file.Close() }, nil // nolint:errcheck // Records are written unbuffered
+}
+
+// setupLogging configures logger from the config file and the environment. Logging
+// must never stop the tool, so problems are only reported as a warning.
+func setupLogging(getenv func(string) string, stderr io.Writer) func() {
+	config, err := LoadConfig(ConfigPath(getenv))
+	var opts LogOptions
+	if err == nil {
+		opts, err = logOptionsFor(config, getenv)
+	}
+	var l *slog.Logger
+	var closeLog func()
+	if err == nil {
+		l, closeLog, err = newLogger(opts)
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "⚠️  ログを記録できません: %v\n", err) // nolint:errcheck // Output to user, error not critical
+		return func() {}
+	}
+	logger = l
+	return closeLog
+}
+
+// loggingEnv returns the logging settings of getenv as environment variables, so
+// that processes started by this one log the same way.
+func loggingEnv(getenv func(string) string) []string {
+	var env []string
+	for _, key := range []string{logFileEnvVar, logLevelEnvVar, logFormatEnvVar} {
+		if value := getenv(key); value != "" {
+			env = append(env, key+"="+value)
+		}
+	}
+	return env
+}
+
+// sessionLogger returns logger with the attributes identifying an analysis.
+func sessionLogger(sessionID, projectRoot string) *slog.Logger {
+	return logger.With("session_id", sessionID, "project", projectRoot)
+}
+
+// elapsedMillis returns the milliseconds since start for the duration attributes.
+func elapsedMillis(start time.Time) int64 {
+	return time.Since(start).Milliseconds()
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// captureLogs makes logger write JSON records to the returned buffer for the test.
+func captureLogs(t *testing.T, level slog.Level) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	saved := logger
+	logger = slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: level}))
+	t.Cleanup(func() { logger = saved })
+	return &buf
+}
+
+// decodeLogRecords parses JSON log records, one per line.
+func decodeLogRecords(t *testing.T, data []byte) []map[string]interface{} {
+	t.Helper()
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Invalid log record %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestParseLogFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    LogFormat
+		wantErr bool
+	}{
+		{"", LogFormatText, false},
+		{"text", LogFormatText, false},
+		{" JSON ", LogFormatJSON, false},
+		{"xml", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLogFormat(tt.name)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseLogFormat(%q) = %q, %v, want %q (error: %v)", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParseLogLevel(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    slog.Level
+		wantErr bool
+	}{
+		{"", slog.LevelInfo, false},
+		{"debug", slog.LevelDebug, false},
+		{"Warning", slog.LevelWarn, false},
+		{"error", slog.LevelError, false},
+		{"trace", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLogLevel(tt.name)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseLogLevel(%q) = %v, %v, want %v (error: %v)", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestLogOptionsFor(t *testing.T) {
+	tmpDir := t.TempDir()
+	env := map[string]string{"XDG_RUNTIME_DIR": tmpDir}
+	getenv := func(key string) string { return env[key] }
+
+	opts, err := logOptionsFor(&Config{}, getenv)
+	if err != nil {
+		t.Fatalf("logOptionsFor() error = %v", err)
+	}
+	want := LogOptions{File: filepath.Join(tmpDir, commandName, logFileName), Level: slog.LevelInfo, Format: LogFormatText}
+	if opts != want {
+		t.Errorf("logOptionsFor() = %+v, want %+v", opts, want)
+	}
+
+	// 環境変数（オプション）は設定ファイルより優先する
+	config := &Config{LogFile: "/var/log/config.log", LogLevel: "debug", LogFormat: "text"}
+	env[logFormatEnvVar] = "json"
+	env[logFileEnvVar] = "/var/log/env.log"
+	opts, err = logOptionsFor(config, getenv)
+	if err != nil {
+		t.Fatalf("logOptionsFor() error = %v", err)
+	}
+	want = LogOptions{File: "/var/log/env.log", Level: slog.LevelDebug, Format: LogFormatJSON}
+	if opts != want {
+		t.Errorf("logOptionsFor() = %+v, want %+v", opts, want)
+	}
+
+	if _, err := logOptionsFor(&Config{LogLevel: "verbose"}, func(string) string { return "" }); err == nil || !strings.Contains(err.Error(), "無効なログレベル") {
+		t.Errorf("logOptionsFor() error = %v, want invalid level", err)
+	}
+}
+
+func TestNewLogger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "suggest-claude-md.log")
+	l, closeLog, err := newLogger(LogOptions{File: path, Level: slog.LevelWarn, Format: LogFormatJSON})
+	if err != nil {
+		t.Fatalf("newLogger() error = %v", err)
+	}
+	l.Info("ignored")
+	l.Warn("recorded", "session_id", "abc")
+	closeLog()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read log: %v", err)
+	}
+	records := decodeLogRecords(t, data)
+	if len(records) != 1 || records[0]["msg"] != "recorded" || records[0]["session_id"] != "abc" || records[0]["pid"] != float64(os.Getpid()) {
+		t.Errorf("records = %v", records)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != privateFilePerm {
+		t.Errorf("Log file should be private: %v %v", info, err)
+	}
+
+	// 大きくなったログは .1 に移してから書き始める
+	if err := os.WriteFile(path, bytes.Repeat([]byte("x"), maxLogFileSize), 0o600); err != nil {
+		t.Fatalf("Failed to grow log: %v", err)
+	}
+	l, closeLog, err = newLogger(LogOptions{File: path, Format: LogFormatText})
+	if err != nil {
+		t.Fatalf("newLogger() error = %v", err)
+	}
+	l.Info("after rotation")
+	closeLog()
+	data, _ = os.ReadFile(path) // nolint:errcheck // Checked by content
+	if !strings.Contains(string(data), "msg=\"after rotation\"") || len(data) >= maxLogFileSize {
+		t.Errorf("Log should be rotated, got %d bytes", len(data))
+	}
+	if info, err := os.Stat(path + ".1"); err != nil || info.Size() != maxLogFileSize {
+		t.Errorf("Rotated log = %v, %v", info, err)
+	}
+
+	// off ではファイルを開かない
+	l, closeLog, err = newLogger(LogOptions{File: "off"})
+	if err != nil {
+		t.Fatalf("newLogger(off) error = %v", err)
+	}
+	if l.Enabled(context.Background(), slog.LevelError) {
+		t.Error("off should discard every record")
+	}
+	closeLog()
+}
+
+func TestSetupLogging(t *testing.T) {
+	saved := logger
+	t.Cleanup(func() { logger = saved })
+
+	// ログの設定の誤りではツールを止めない
+	stderr := &bytes.Buffer{}
+	closeLog := setupLogging(func(key string) string {
+		if key == logLevelEnvVar {
+			return "verbose"
+		}
+		return ""
+	}, stderr)
+	closeLog()
+	if !strings.Contains(stderr.String(), "⚠️  ログを記録できません: 無効なログレベル: verbose") || logger != saved {
+		t.Errorf("setupLogging() should only warn, got: %s", stderr.String())
+	}
+
+	path := filepath.Join(t.TempDir(), "debug.log")
+	env := map[string]string{logFileEnvVar: path, logFormatEnvVar: "json", configEnvVar: filepath.Join(t.TempDir(), "missing.json")}
+	closeLog = setupLogging(func(key string) string { return env[key] }, stderr)
+	logger.Info("configured")
+	closeLog()
+	if data, _ := os.ReadFile(path); !strings.Contains(string(data), `"msg":"configured"`) { // nolint:errcheck // Checked by content
+		t.Errorf("log = %s", data)
+	}
+	if got := loggingEnv(func(key string) string { return env[key] }); len(got) != 2 || got[0] != logFileEnvVar+"="+path || got[1] != logFormatEnvVar+"=json" {
+		t.Errorf("loggingEnv() = %v", got)
+	}
+}
+
+func TestAnalyzeTranscript_Logging(t *testing.T) {
+	logs := captureLogs(t, slog.LevelDebug)
+	tmpDir := t.TempDir()
+	writeFakeClaude(t, "## Testing\n\n- go test ./...\n")
+	getenv := func(key string) string {
+		if key == "XDG_RUNTIME_DIR" {
+			return tmpDir
+		}
+		return ""
+	}
+
+	opts := AnalyzeOptions{TranscriptPath: writeTestTranscript(t, tmpDir), ProjectRoot: tmpDir, HookInfo: "Hook: SessionEnd"}
+	if _, err := analyzeTranscript(opts, &bytes.Buffer{}, getenv, time.Now); err != nil {
+		t.Fatalf("analyzeTranscript() error = %v", err)
+	}
+
+	// 各段階をセッションIDとともに記録する
+	var messages []string
+	for _, record := range decodeLogRecords(t, logs.Bytes()) {
+		if record["session_id"] != "transcript" || record["project"] != tmpDir {
+			t.Errorf("record without the session: %v", record)
+		}
+		messages = append(messages, record["msg"].(string))
+		switch record["msg"] {
+		case "prompt generated":
+			if record["prompt_bytes"].(float64) == 0 {
+				t.Errorf("prompt size should be recorded: %v", record)
+			}
+		case "claude finished":
+			if _, ok := record["duration_ms"]; !ok {
+				t.Errorf("backend latency should be recorded: %v", record)
+			}
+		case "suggestion validated":
+			if record["valid"] != true || record["output_bytes"].(float64) == 0 {
+				t.Errorf("output should be recorded: %v", record)
+			}
+		}
+	}
+	want := []string{"analysis started", "transcript parsed", "prompt generated", "running claude", "claude finished", "suggestion validated", "analysis finished"}
+	if strings.Join(messages, ",") != strings.Join(want, ",") {
+		t.Errorf("messages = %v, want %v", messages, want)
+	}
+
+	// 失敗はエラーとして記録する
+	logs.Reset()
+	opts.TranscriptPath = filepath.Join(tmpDir, "missing.jsonl")
+	if _, err := analyzeTranscript(opts, &bytes.Buffer{}, getenv, time.Now); err == nil {
+		t.Fatal("analyzeTranscript() should fail for a missing transcript")
+	}
+	records := decodeLogRecords(t, logs.Bytes())
+	last := records[len(records)-1]
+	if last["msg"] != "analysis failed" || last["level"] != "ERROR" || !strings.HasPrefix(last["error"].(string), "ファイルが存在しません") {
+		t.Errorf("last record = %v", last)
+	}
+}
@@ -20,8 +20,20 @@ func main() {
 	_ = version // version is set during build via ldflags
 
 	// フラグの定義
-	installHook := flag.String("install-hook", "", "Install hooks (user: ~/.claude/settings.json, project: .claude/settings.json)")
+	installHook := flag.String("install-hook", "", "Install hooks (user: ~/.claude/settings.json, project: .claude/settings.json, local: .claude/settings.local.json)")
+	settingsPath := flag.String("settings-path", "", "Settings file to install hooks to or remove hooks from (overrides the scope's file)")
+	hookEventList := flag.String("hook-events", strings.Join(hookEvents, ","), "Comma-separated hook events to install on")
+	hookTimeout := flag.Int("hook-timeout", 0, "Hook timeout in seconds (0: Claude Code's default)")
+	hookMatcher := flag.String("hook-matcher", "", "Hook matcher (e.g. auto: PreCompact only for automatic compactions)")
+	var hookArgs stringList
+	flag.Var(&hookArgs, "hook-arg", "Argument passed to the installed command (repeatable)")
 	applySuggestion := flag.String("apply", "", "Apply suggestion file to CLAUDE.md")
+	onDuplicate := flag.String("on-duplicate", string(DuplicateSkip), "How to handle subsections that already exist in CLAUDE.md (skip, replace, merge)")
+	configPath := flag.String("config", "", "Path to config file (default: ~/.config/suggest-claude-md/config.json)")
+	asyncMode := flag.Bool("async", false, "Run the hook analysis in a detached background worker")
+	logFile := flag.String("log-file", "", "File receiving the structured log (off: disable)")
+	logLevel := flag.String("log-level", "", "Minimum level of logged records (debug, info, warn, error)")
+	logFormat := flag.String("log-format", "", "Format of logged records (text, json)")
 	showHelp := flag.Bool("help", false, "Show help message")
 	flag.Parse()
 
@@ -31,9 +43,54 @@ func main() {
 		return
 	}
 
+	// オプションは対応する環境変数より優先する（--configと--asyncはフックの引数として渡される）
+	overrides := map[string]string{
+		configEnvVar:    *configPath,
+		logFileEnvVar:   *logFile,
+		logLevelEnvVar:  *logLevel,
+		logFormatEnvVar: *logFormat,
+	}
+	if *asyncMode {
+		overrides[executionModeEnvVar] = string(ModeAsync)
+	}
+	getenv := func(key string) string {
+		if value := overrides[key]; value != "" {
+			return value
+		}
+		return os.Getenv(key)
+	}
+	closeLog := setupLogging(getenv, os.Stderr)
+	defer closeLog()
+
+	// サブコマンドが指定された場合
+	if args := flag.Args(); len(args) > 0 {
+		if err := runSubcommand(args, HookInstallOptions{SettingsPath: *settingsPath}); err != nil {
+			// 分析処理のエラーには既に❌が付いている
+			message := err.Error()
+			if !strings.HasPrefix(message, "❌") {
+				message = "❌ " + message
+			}
+			fmt.Fprintln(os.Stderr, message)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// --install-hookが指定された場合
 	if *installHook != "" {
-		if err := installHooks(*installHook); err != nil {
+		events, err := ParseHookEvents(*hookEventList)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(1)
+		}
+		opts := HookInstallOptions{
+			SettingsPath: *settingsPath,
+			Events:       events,
+			Timeout:      *hookTimeout,
+			Matcher:      *hookMatcher,
+			Args:         hookArgs,
+		}
+		if err := installHooksWithOptions(*installHook, opts); err != nil {
 			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
 			os.Exit(1)
 		}
@@ -42,7 +99,22 @@ func main() {
 
 	// --applyが指定された場合
 	if *applySuggestion != "" {
-		if err := applySuggestionFile(*applySuggestion); err != nil {
+		policy, err := ParseDuplicatePolicy(*onDuplicate)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(1)
+		}
+		if *configPath == "" {
+			*configPath = ConfigPath(os.Getenv)
+		}
+		config, err := LoadConfig(*configPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(1)
+		}
+		opts := config.MergeOptions()
+		opts.Duplicates = policy
+		if err := applySuggestionFileWithOptions(*applySuggestion, os.Stdin, opts); err != nil {
 			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
 			os.Exit(1)
 		}
@@ -50,32 +122,204 @@ func main() {
 	}
 
 	// 通常のフック実行
-	if err := run(os.Stdin, os.Stdout, os.Getwd, os.Getenv, time.Now); err != nil {
+	if err := run(os.Stdin, os.Stdout, os.Getwd, getenv, time.Now); err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 		os.Exit(1)
 	}
 }
 
+// runSubcommand runs the subcommand given as positional arguments.
+// hookOpts holds the global options given before the subcommand.
+func runSubcommand(args []string, hookOpts HookInstallOptions) error {
+	switch args[0] {
+	case "uninstall-hook":
+		const usage = "使い方: suggest-claude-md uninstall-hook <user|project|local> [--settings-path <file>]"
+		fs := flag.NewFlagSet("uninstall-hook", flag.ContinueOnError)
+		fs.StringVar(&hookOpts.SettingsPath, "settings-path", hookOpts.SettingsPath, "Settings file to remove hooks from")
+		rest, err := parseSubcommandFlags(fs, args[1:])
+		if err != nil {
+			return fmt.Errorf("%w\n%s", err, usage)
+		}
+		switch {
+		case len(rest) == 1:
+			return uninstallHooksWithOptions(rest[0], hookOpts)
+		case len(rest) == 0 && hookOpts.SettingsPath != "":
+			return uninstallHooksWithOptions("", hookOpts)
+		default:
+			return fmt.Errorf("%s", usage)
+		}
+	case "analyze":
+		return runAnalyzeCommand(args[1:], os.Stdout, os.Getwd, os.Getenv, time.Now)
+	case "batch":
+		return runBatchCommand(args[1:], os.Stdout, os.Getwd, os.Getenv, time.Now)
+	case "init":
+		return runInitCommand(args[1:], os.Stdin, os.Stdout, os.Getwd, os.Getenv, time.Now)
+	case "sessions":
+		return runSessionsCommand(args[1:], os.Stdout, os.Getwd, os.Getenv)
+	case "jobs":
+		return runJobsCommand(args[1:], os.Stdout, os.Getwd, os.Getenv)
+	case "stats":
+		return runStatsCommand(args[1:], os.Stdout, os.Getwd, os.Getenv, time.Now)
+	case "worker":
+		// 非同期モードのフックが起動する内部コマンド
+		return runWorkerCommand(args[1:], os.Stdout, os.Getenv, time.Now)
+	case "doctor":
+		if len(args) != 1 {
+			return fmt.Errorf("使い方: suggest-claude-md doctor")
+		}
+		return runDoctor(os.Stdout, os.Getwd, os.Getenv)
+	case "hook":
+		const usage = "使い方: suggest-claude-md hook status [--settings-path <file>]"
+		fs := flag.NewFlagSet("hook", flag.ContinueOnError)
+		fs.StringVar(&hookOpts.SettingsPath, "settings-path", hookOpts.SettingsPath, "Settings file to check in addition to the scopes")
+		rest, err := parseSubcommandFlags(fs, args[1:])
+		if err != nil {
+			return fmt.Errorf("%w\n%s", err, usage)
+		}
+		if len(rest) != 1 || rest[0] != "status" {
+			return fmt.Errorf("%s", usage)
+		}
+		return printHookStatus(hookOpts)
+	default:
+		return fmt.Errorf("不明なサブコマンド: %s (--helpで使い方を表示)", args[0])
+	}
+}
+
+// stringList is a flag value that collects each occurrence of a repeatable flag.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, " ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// parseSubcommandFlags parses subcommand flags given before or after the
+// positional arguments and returns the positional arguments.
+func parseSubcommandFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	fs.SetOutput(io.Discard)
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		if args[0] == "--" {
+			return append(positional, args[1:]...), nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
 func printHelp() {
 	fmt.Println("suggest-claude-md - Claude Code CLAUDE.md update suggestion tool")
 	fmt.Printf("Version: %s\n\n", version)
 	fmt.Println("Usage:")
 	fmt.Println("  suggest-claude-md [options]")
+	fmt.Println("  suggest-claude-md <command> [arguments]")
+	fmt.Println("")
+	fmt.Println("Commands:")
+	fmt.Println("  uninstall-hook <scope> [--settings-path <file>]")
+	fmt.Println("                    Remove suggest-claude-md hooks from the scope (user, project, local)")
+	fmt.Println("                    or from the given settings file. Other hooks are kept")
+	fmt.Println("  hook status [--settings-path <file>]")
+	fmt.Println("                    Show where hooks are installed, the binary each points to,")
+	fmt.Println("                    and warn about duplicate or stale installations")
+	fmt.Println("  analyze (--transcript <file> | --session <id>) [options]")
+	fmt.Println("                    Analyze a transcript without a hook, e.g. to re-run a past session")
+	fmt.Println("                      --project <dir>     Project whose CLAUDE.md is used (default: .)")
+	fmt.Println("                      --session <id>      Session ID (or unique prefix) under")
+	fmt.Println("                                          ~/.claude/projects/<encoded project path>/")
+	fmt.Println("                      --output-dir <dir>  Directory for the suggestion and log files")
+	fmt.Println("                      --format <format>   Suggestion format (markdown, operations)")
+	fmt.Println("                      --prompt <file>     Use this prompt instead of the built-in one")
+	fmt.Println("  sessions [--project <dir>] [--limit <n>]")
+	fmt.Println("                    List the project's sessions with start time, duration, message count,")
+	fmt.Println("                    git branch, first prompt and whether they were already analyzed")
+	fmt.Println("  batch [options]   Analyze many past sessions and consolidate their suggestions into one,")
+	fmt.Println("                    ranked by how many sessions support each item")
+	fmt.Println("                      --project <dir>     Project to analyze (default: .)")
+	fmt.Println("                      --since/--until <YYYY-MM-DD>")
+	fmt.Println("                                          Only sessions started in this date range")
+	fmt.Println("                      --concurrency <n>   Sessions analyzed at the same time (default: 2)")
+	fmt.Println("                      --output-dir <dir>, --prompt <file>  As for analyze")
+	fmt.Println("  init [options]    Create a starter CLAUDE.md from go.mod, package.json, Makefile, .mise.toml,")
+	fmt.Println("                    CI config and README, refined by claude, and write it after confirmation")
+	fmt.Println("                      --project <dir>     Project to initialize (default: .)")
+	fmt.Println("                      --no-claude         Use the repository contents only")
+	fmt.Println("                      --force             Overwrite an existing CLAUDE.md")
+	fmt.Println("                      --yes               Write without confirmation")
+	fmt.Println("                      --output-dir <dir>, --prompt <file>  As for analyze")
+	fmt.Println("  jobs [--project <dir>] [--limit <n>]")
+	fmt.Println("                    List analyses run in async mode: queued, running, finished or")
+	fmt.Println("                    interrupted, with their suggestion files and errors")
+	fmt.Println("  stats [--project <dir>] [--since/--until <YYYY-MM-DD>]")
+	fmt.Println("                    Summarize token usage and estimated cost per project and per day")
+	fmt.Println("                    (default: this month) and show this month's cost against the budget")
+	fmt.Println("  doctor            Diagnose the environment (claude CLI, hooks, settings, output")
+	fmt.Println("                    directory, CLAUDE.md, transcript parsing) and show how to fix problems")
 	fmt.Println("")
 	fmt.Println("Options:")
 	fmt.Println("  --install-hook <scope>")
 	fmt.Println("                    Install hooks for SessionEnd and PreCompact events")
 	fmt.Println("                    Scope:")
 	fmt.Println("                      user    - Install to ~/.claude/settings.json (all projects)")
+	fmt.Println("                                ($CLAUDE_CONFIG_DIR/settings.json if set)")
 	fmt.Println("                      project - Install to .claude/settings.json (current project only)")
+	fmt.Println("                      local   - Install to .claude/settings.local.json (current project,")
+	fmt.Println("                                not committed)")
+	fmt.Println("  --settings-path <file>")
+	fmt.Println("                    Use this settings file instead of the scope's file")
+	fmt.Println("  --hook-events <events>")
+	fmt.Println("                    Comma-separated events to install on (default: SessionEnd,PreCompact)")
+	fmt.Println("  --hook-timeout <seconds>")
+	fmt.Println("                    Timeout of the installed hooks (default: Claude Code's default)")
+	fmt.Println("  --hook-matcher <matcher>")
+	fmt.Println("                    Matcher of the installed hooks (e.g. auto, manual for PreCompact)")
+	fmt.Println("  --hook-arg <arg>  Argument passed to the installed command (repeatable)")
+	fmt.Println("                    Re-running --install-hook updates installed hooks in place")
 	fmt.Println("  --apply <file>   Apply suggestion file to CLAUDE.md")
 	fmt.Println("                    Displays existing CLAUDE.md content and proposed changes")
 	fmt.Println("                    Prompts for confirmation before applying")
+	fmt.Println("  --on-duplicate <policy>")
+	fmt.Println("                    How --apply handles subsections that already exist (default: skip)")
+	fmt.Println("                      skip    - Drop duplicate subsections and list items")
+	fmt.Println("                      replace - Replace the existing subsection")
+	fmt.Println("                      merge   - Add only new list items to the existing subsection")
+	fmt.Println("  --config <file>  Path to config file")
+	fmt.Println("                    (default: $SUGGEST_CLAUDE_MD_CONFIG or ~/.config/suggest-claude-md/config.json)")
+	fmt.Println("  --async          Run the hook analysis in a detached background worker and return")
+	fmt.Println("                    immediately (same as execution_mode: \"async\" in the config file)")
+	fmt.Println("  --log-file <file> Structured log of each stage (parse, prompt size, claude latency, output")
+	fmt.Println("                    size, merge decisions) with the session ID")
+	fmt.Println("                    (default: <output directory>/suggest-claude-md.log, off: disable)")
+	fmt.Println("  --log-level <level>")
+	fmt.Println("                    Minimum level of logged records: debug, info, warn, error (default: info)")
+	fmt.Println("  --log-format <format>")
+	fmt.Println("                    Format of logged records: text or json (default: text)")
 	fmt.Println("  --help           Show this help message")
 	fmt.Println("")
 	fmt.Println("Normal usage:")
 	fmt.Println("  This tool is typically invoked as a Claude Code hook and reads hook input from stdin.")
-	fmt.Println("  Suggestions are saved to /tmp/suggest-claude-md-*.md")
+	fmt.Println("  Suggestions are saved to a private (0700) per-user directory:")
+	fmt.Println("    $XDG_RUNTIME_DIR/suggest-claude-md/ or ${TMPDIR:-/tmp}/suggest-claude-md-<uid>/")
+	fmt.Println("  Invalid responses (empty, errors, no ## section) are saved as *.invalid.md and cannot be applied.")
+	fmt.Println("")
+	fmt.Println("Environment:")
+	fmt.Println("  SUGGEST_CLAUDE_MD_RETENTION_DAYS   Delete logs/suggestions older than N days (default: 30, 0: disable)")
+	fmt.Println("  SUGGEST_CLAUDE_MD_RETENTION_FILES  Keep at most N logs/suggestions (default: 200, 0: disable)")
+	fmt.Println("  SUGGEST_CLAUDE_MD_EXECUTION_MODE   Hook execution mode: sync or async (overrides the config file)")
+	fmt.Println("  SUGGEST_CLAUDE_MD_LOG_FILE         Log file (overrides log_file of the config file)")
+	fmt.Println("  SUGGEST_CLAUDE_MD_LOG_LEVEL        Log level (overrides log_level of the config file)")
+	fmt.Println("  SUGGEST_CLAUDE_MD_LOG_FORMAT       Log format (overrides log_format of the config file)")
+	fmt.Println("  CLAUDE_CONFIG_DIR                  Claude Code configuration directory (default: ~/.claude)")
 	fmt.Println("")
 	fmt.Println("Examples:")
 	fmt.Println("  # Install hooks to user settings (all projects)")
@@ -84,8 +328,47 @@ func printHelp() {
 	fmt.Println("  # Install hooks to project settings (current project only)")
 	fmt.Println("  suggest-claude-md --install-hook project")
 	fmt.Println("")
+	fmt.Println("  # Install hooks only for yourself in this project (not committed)")
+	fmt.Println("  suggest-claude-md --install-hook local")
+	fmt.Println("")
+	fmt.Println("  # Install hooks to a specific settings file")
+	fmt.Println("  suggest-claude-md --install-hook user --settings-path ~/work/.claude/settings.json")
+	fmt.Println("")
+	fmt.Println("  # Run only on automatic compactions with a 5 minute timeout and a custom config")
+	fmt.Println("  suggest-claude-md --install-hook user --hook-events PreCompact --hook-matcher auto \\")
+	fmt.Println("    --hook-timeout 300 --hook-arg --config --hook-arg ~/.config/suggest-claude-md/work.json")
+	fmt.Println("")
+	fmt.Println("  # Create the first CLAUDE.md of a repository")
+	fmt.Println("  suggest-claude-md init")
+	fmt.Println("")
+	fmt.Println("  # Analyze in the background so that SessionEnd and PreCompact are not delayed")
+	fmt.Println("  suggest-claude-md --install-hook user --hook-arg --async")
+	fmt.Println("  suggest-claude-md jobs")
+	fmt.Println("")
+	fmt.Println("  # Find a past session of this project")
+	fmt.Println("  suggest-claude-md sessions")
+	fmt.Println("")
+	fmt.Println("  # Re-run the analysis of a past session with a custom prompt")
+	fmt.Println("  suggest-claude-md analyze --session 602a7e81 --prompt ./better-prompt.md")
+	fmt.Println("")
+	fmt.Println("  # Bootstrap CLAUDE.md from the sessions of the last quarter")
+	fmt.Println("  suggest-claude-md batch --since 2025-01-01 --until 2025-03-31 --concurrency 4")
+	fmt.Println("")
+	fmt.Println("  # Log every stage of the hook as JSON for a bug report")
+	fmt.Println("  suggest-claude-md --install-hook user --hook-arg --log-format --hook-arg json \\")
+	fmt.Println("    --hook-arg --log-level --hook-arg debug")
+	fmt.Println("")
+	fmt.Println("  # Find out why the hook does nothing")
+	fmt.Println("  suggest-claude-md doctor")
+	fmt.Println("")
+	fmt.Println("  # Check where hooks are installed")
+	fmt.Println("  suggest-claude-md hook status")
+	fmt.Println("")
+	fmt.Println("  # Remove hooks from project settings")
+	fmt.Println("  suggest-claude-md uninstall-hook project")
+	fmt.Println("")
 	fmt.Println("  # Apply a suggestion file to CLAUDE.md")
-	fmt.Println("  suggest-claude-md --apply /tmp/suggest-claude-md-abc123.md")
+	fmt.Println("  suggest-claude-md --apply /tmp/suggest-claude-md-1000/suggest-claude-md-abc123.md")
 	fmt.Println("")
 	fmt.Println("  # Show help")
 	fmt.Println("  suggest-claude-md --help")
@@ -93,9 +376,10 @@ func printHelp() {
 
 // run is the main logic that can be tested.
 func run(input io.Reader, output io.Writer, getwd func() (string, error), getenv func(string) string, now func() time.Time) error {
-	// 再帰実行防止
-	if getenv("SUGGEST_CLAUDE_MD_RUNNING") == "1" {
-		_, _ = fmt.Fprintln(output, "⚠️  既に実行中のため、スキップします") // nolint:errcheck // Output to user, error not critical
+	// 再帰実行防止（分析が起動したclaudeから引き継いだ環境変数）
+	if reason := environmentRecursionGuard(getenv); reason != "" {
+		logger.Info("hook skipped", "guard", "environment", "reason", reason)
+		_, _ = fmt.Fprintf(output, "⚠️  %s\n", reason) // nolint:errcheck // Output to user, error not critical
 		return nil
 	}
 
@@ -111,12 +395,15 @@ func run(input io.Reader, output io.Writer, getwd func() (string, error), getenv
 		return fmt.Errorf("❌ transcript_pathが空です")
 	}
 
-	// ~をホームディレクトリに展開
-	transcriptPath := ExpandTilde(hookInput.TranscriptPath)
+	sessionID := transcriptSessionID(hookInput.TranscriptPath)
+	logger.Info("hook received", "session_id", sessionID, "event", hookInput.HookEventName,
+		"trigger", hookInput.Trigger, "reason", hookInput.Reason, "transcript", hookInput.TranscriptPath)
 
-	// ファイルの存在確認
-	if _, err := os.Stat(transcriptPath); os.IsNotExist(err) {
-		return fmt.Errorf("❌ ファイルが存在しません: %s", transcriptPath)
+	// 環境変数が引き継がれなかった場合の再帰実行防止
+	if reason := sessionRecursionGuard(ExpandTilde(hookInput.TranscriptPath)); reason != "" {
+		logger.Info("hook skipped", "session_id", sessionID, "guard", "session", "reason", reason)
+		_, _ = fmt.Fprintf(output, "⚠️  %s\n", reason) // nolint:errcheck // Output to user, error not critical
+		return nil
 	}
 
 	// PROJECT_ROOTの取得
@@ -125,81 +412,68 @@ func run(input io.Reader, output io.Writer, getwd func() (string, error), getenv
 		return fmt.Errorf("❌ カレントディレクトリの取得に失敗: %w", err)
 	}
 
-	// CONVERSATION_IDの抽出
-	conversationID := strings.TrimSuffix(filepath.Base(transcriptPath), filepath.Ext(transcriptPath))
-
-	// TIMESTAMPの生成
-	timestamp := now().Format("20060102-150405")
-
-	// ログファイルと提案ファイルのパス
-	logFile := fmt.Sprintf("/tmp/suggest-claude-md-%s-%s.log", conversationID, timestamp)
-	suggestionFile := fmt.Sprintf("/tmp/suggest-claude-md-%s-%s.md", conversationID, timestamp)
-
-	_, _ = fmt.Fprintln(output, "🤖 会話履歴を分析中...") // nolint:errcheck // Output to user, error not critical
-	hookInfo := fmt.Sprintf("Hook: %s (trigger: %s)", hookInput.HookEventName, hookInput.Trigger)
-	_, _ = fmt.Fprintln(output, hookInfo)    // nolint:errcheck // Output to user, error not critical
-	_, _ = fmt.Fprintf(output, "📋 実行中...\n") // nolint:errcheck // Output to user, error not critical
-	_, _ = fmt.Fprintf(output, "\n")         // nolint:errcheck // Output to user, error not critical
+	opts := AnalyzeOptions{
+		TranscriptPath: hookInput.TranscriptPath,
+		ProjectRoot:    projectRoot,
+		HookInfo:       fmt.Sprintf("Hook: %s (trigger: %s)", hookInput.HookEventName, hookInput.Trigger),
+	}
 
-	// 会話履歴の抽出
-	conversationHistory, err := ExtractConversationHistory(transcriptPath)
+	config, err := LoadConfig(ConfigPath(getenv))
 	if err != nil {
-		return fmt.Errorf("❌ 会話履歴の抽出に失敗: %w", err)
+		return fmt.Errorf("❌ %w", err)
 	}
-
-	if conversationHistory == "" {
-		_, _ = fmt.Fprintln(output, "⚠️  会話履歴が空のため、スキップします") // nolint:errcheck // Output to user, error not critical
-		return nil
+	mode, err := executionModeFor(config, getenv)
+	if err != nil {
+		return fmt.Errorf("❌ %w", err)
 	}
 
-	// 既存のCLAUDE.mdを読み込む
-	claudeMdPath := filepath.Join(projectRoot, "CLAUDE.md")
-	var existingClaudeMd string
-	if content, readErr := os.ReadFile(claudeMdPath); readErr == nil {
-		existingClaudeMd = string(content)
+	// スキップルールに該当すれば分析しない
+	if err := config.Skip.Validate(); err != nil {
+		return fmt.Errorf("❌ %w", err)
 	}
-
-	// プロンプトファイルの生成
-	promptContent := GeneratePrompt(DefaultPromptContent, conversationHistory, existingClaudeMd)
-
-	// 一時ファイルの作成
-	tempPromptFile, err := os.CreateTemp("", "suggest-claude-md-prompt-*.md")
+	rule, err := config.Skip.Match(hookInput, projectRoot, ExpandTilde(hookInput.TranscriptPath))
 	if err != nil {
-		return fmt.Errorf("❌ 一時ファイルの作成に失敗: %w", err)
+		return fmt.Errorf("❌ スキップルールの判定に失敗: %w", err)
 	}
-	tempPromptFilePath := tempPromptFile.Name()
-
-	if _, err := tempPromptFile.WriteString(promptContent); err != nil {
-		_ = tempPromptFile.Close()        // nolint:errcheck // Best-effort cleanup in error path
-		_ = os.Remove(tempPromptFilePath) // nolint:errcheck // Best-effort cleanup in error path
-		return fmt.Errorf("❌ 一時ファイルへの書き込みに失敗: %w", err)
+	if rule != "" {
+		logger.Info("hook skipped", "session_id", sessionID, "rule", rule)
+		_, _ = fmt.Fprintf(output, "⏭️  スキップルールに該当するため、分析しません: %s\n", rule) // nolint:errcheck // Output to user, error not critical
+		return nil
 	}
-	_ = tempPromptFile.Close() // nolint:errcheck // File is read-only from here
 
-	// 同期実行
-	config := &ExecutorConfig{
-		ProjectRoot:        projectRoot,
-		TempPromptFilePath: tempPromptFilePath,
-		LogFile:            logFile,
-		HookInfo:           hookInfo,
-		SuggestionFile:     suggestionFile,
+	// 今月の予算に達していれば分析しない
+	budgetReason, err := budgetExceeded(config, getenv, now())
+	if err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+	if budgetReason != "" {
+		logger.Info("hook skipped", "session_id", sessionID, "budget", budgetReason)
+		_, _ = fmt.Fprintf(output, "⏭️  %s、分析しません\n", budgetReason) // nolint:errcheck // Output to user, error not critical
+		return nil
 	}
 
-	if err := ExecuteSynchronously(config); err != nil {
-		_ = os.Remove(tempPromptFilePath) // nolint:errcheck // Best-effort cleanup in error path
-		return fmt.Errorf("❌ 実行に失敗: %w", err)
+	// 非同期モードではワーカーに任せてすぐに戻る
+	if mode == ModeAsync {
+		return startAsyncAnalysis(opts, config, output, getenv, now)
 	}
 
-	_, _ = fmt.Fprintf(output, "\n")                                               // nolint:errcheck // Output to user, error not critical
-	_, _ = fmt.Fprintf(output, "✅ 分析が完了しました\n")                                    // nolint:errcheck // Output to user, error not critical
-	_, _ = fmt.Fprintf(output, "📄 提案ファイル: %s\n", suggestionFile)                   // nolint:errcheck // Output to user, error not critical
-	_, _ = fmt.Fprintf(output, "\n")                                               // nolint:errcheck // Output to user, error not critical
-	_, _ = fmt.Fprintf(output, "以下のコマンドで提案を適用できます：\n")                             // nolint:errcheck // Output to user, error not critical
-	_, _ = fmt.Fprintf(output, "  suggest-claude-md --apply %s\n", suggestionFile) // nolint:errcheck // Output to user, error not critical
-	_, _ = fmt.Fprintf(output, "\n")                                               // nolint:errcheck // Output to user, error not critical
-	_, _ = fmt.Fprintf(output, "詳細なログ: %s\n", logFile)                             // nolint:errcheck // Output to user, error not critical
+	return runSyncAnalysis(opts, output, getenv, now)
+}
 
-	return nil
+// validateSuggestionFile validates the model output saved in the suggestion file
+// and rewrites the file with the sanitised content when it is valid.
+func validateSuggestionFile(suggestionFile string, format SuggestionFormat) (SuggestionValidation, error) {
+	content, err := os.ReadFile(suggestionFile)
+	if err != nil {
+		return SuggestionValidation{}, err
+	}
+	validation := ValidateSuggestion(string(content), format)
+	if validation.Valid() && validation.Content != string(content) {
+		if err := os.WriteFile(suggestionFile, []byte(validation.Content), privateFilePerm); err != nil {
+			return SuggestionValidation{}, err
+		}
+	}
+	return validation, nil
 }
 
 // applySuggestionFile applies a suggestion file to CLAUDE.md after user confirmation
@@ -209,8 +483,16 @@ func applySuggestionFile(suggestionPath string) error {
 
 // applySuggestionFileWithInput applies a suggestion file with a custom input reader (for testing)
 func applySuggestionFileWithInput(suggestionPath string, input io.Reader) error {
+	return applySuggestionFileWithOptions(suggestionPath, input, DefaultMergeOptions())
+}
+
+// applySuggestionFileWithOptions applies a suggestion file with custom merge options
+func applySuggestionFileWithOptions(suggestionPath string, input io.Reader, opts MergeOptions) error {
 	// 提案ファイルの存在確認
 	suggestionPath = ExpandTilde(suggestionPath)
+	if IsInvalidSuggestionPath(suggestionPath) {
+		return fmt.Errorf("検証に失敗した提案ファイルは適用できません: %s", suggestionPath)
+	}
 	if _, err := os.Stat(suggestionPath); os.IsNotExist(err) {
 		return fmt.Errorf("提案ファイルが存在しません: %s", suggestionPath)
 	}
@@ -244,51 +526,179 @@ func applySuggestionFileWithInput(suggestionPath string, input io.Reader) error
 	fmt.Println("=" + strings.Repeat("=", 79))
 	if existingContent == "" {
 		fmt.Println("(ファイルは存在しません)")
+		fmt.Println("💡 suggest-claude-md init でリポジトリの内容からCLAUDE.mdの雛形を作成できます")
 	} else {
 		fmt.Println(existingContent)
 	}
 	fmt.Println()
 
-	// 提案内容を表示
+	// 追加するMarkdownと、既存の記述を変更・削除する操作に分ける
+	markdown, metadata, err := SplitSuggestion(string(suggestionContent))
+	if err != nil {
+		return fmt.Errorf("提案ファイルが不正です: %w", err)
+	}
+	operations := metadata.Operations
+
+	if strings.TrimSpace(markdown) != "" {
+		fmt.Println("=" + strings.Repeat("=", 79))
+		fmt.Println("✨ 追加する提案内容")
+		fmt.Println("=" + strings.Repeat("=", 79))
+		fmt.Println(markdown)
+		fmt.Println()
+	}
+
+	if len(operations) > 0 {
+		fmt.Println("=" + strings.Repeat("=", 79))
+		fmt.Println("✨ 提案された操作")
+		fmt.Println("=" + strings.Repeat("=", 79))
+		for _, op := range operations {
+			fmt.Printf("  - %s\n", op)
+			if op.Content != "" {
+				for _, line := range strings.Split(strings.TrimRight(op.Content, "\n"), "\n") {
+					fmt.Printf("      %s\n", line)
+				}
+			}
+		}
+		fmt.Println()
+	}
+
+	scanner := bufio.NewScanner(input)
+
+	// 既存の記述を変更・削除する操作は差分を表示して個別に確認
+	operations, err = confirmDestructiveOperations(existingContent, operations, opts, scanner)
+	if err != nil {
+		return err
+	}
+
+	merged := ApplyOperations(existingContent, operations, opts)
+	if strings.TrimSpace(markdown) != "" {
+		added := MergeSectionsWithOptions(merged.Content, markdown, opts)
+		metadata.Annotate(added.Decisions)
+		merged.Content = added.Content
+		merged.Decisions = append(merged.Decisions, added.Decisions...)
+	}
+
+	// 配置先を表示
 	fmt.Println("=" + strings.Repeat("=", 79))
-	fmt.Println("✨ 追加する提案内容")
+	fmt.Println("📍 配置先")
 	fmt.Println("=" + strings.Repeat("=", 79))
-	fmt.Println(string(suggestionContent))
+	for _, decision := range merged.Decisions {
+		fmt.Printf("  - %s\n", decision)
+		printRationale(decision.Rationale, decision.Evidence)
+		logger.Info("merge decision", "suggestion_file", suggestionPath, "action", string(decision.Action),
+			"title", decision.Title, "target", decision.Target, "match", decision.Match.String(), "removed_bullets", decision.RemovedBullets)
+	}
 	fmt.Println()
 
+	if merged.Content == existingContent {
+		fmt.Println("変更はありません")
+		recordSuggestionApplied(suggestionPath)
+		return nil
+	}
+
 	// 確認プロンプト
 	fmt.Print("この内容をCLAUDE.mdに適用しますか? (yes/no): ")
-
-	// inputから1行読み取る
-	scanner := bufio.NewScanner(input)
-	if !scanner.Scan() {
-		if err := scanner.Err(); err != nil {
-			return fmt.Errorf("入力の読み取りに失敗: %w", err)
-		}
-		return fmt.Errorf("入力がありません")
+	confirmed, err := readConfirmation(scanner)
+	if err != nil {
+		return err
 	}
-	response := scanner.Text()
-
-	response = strings.ToLower(strings.TrimSpace(response))
-	if response != "yes" && response != "y" {
+	if !confirmed {
+		logger.Info("apply cancelled", "suggestion_file", suggestionPath)
 		fmt.Println("❌ キャンセルしました")
 		return nil
 	}
 
-	// セクションベースで挿入
-	var newContent string
-	if existingContent == "" {
-		newContent = string(suggestionContent)
-	} else {
-		newContent = InsertIntoSection(existingContent, string(suggestionContent))
-	}
-
-	if err := os.WriteFile(claudeMdPath, []byte(newContent), 0o644); err != nil {
+	if err := os.WriteFile(claudeMdPath, []byte(merged.Content), 0o644); err != nil {
 		return fmt.Errorf("CLAUDE.mdへの書き込みに失敗: %w", err)
 	}
+	logger.Info("suggestion applied", "suggestion_file", suggestionPath, "claude_md", claudeMdPath,
+		"bytes_before", len(existingContent), "bytes_after", len(merged.Content))
 
 	fmt.Printf("✅ CLAUDE.mdを更新しました: %s\n", claudeMdPath)
 	fmt.Printf("   提案ファイル: %s\n", suggestionPath)
+	recordSuggestionApplied(suggestionPath)
 
 	return nil
 }
+
+// recordSuggestionApplied marks the suggestion as applied in the job store, so that
+// queued analyses no longer treat it as pending. Failures only produce a warning.
+func recordSuggestionApplied(suggestionPath string) {
+	absPath, err := filepath.Abs(suggestionPath)
+	if err == nil {
+		err = markSuggestionApplied(ResolveOutputDir(os.Getenv), absPath)
+	}
+	if err != nil {
+		fmt.Printf("⚠️  ジョブの記録の更新に失敗: %v\n", err)
+	}
+}
+
+// confirmDestructiveOperations shows the diff of each operation that changes or
+// removes existing text and keeps only the ones the user confirms. Each diff is
+// computed on the combined result of the operations accepted before it, so it
+// shows what the operation actually changes when applied together with them.
+func confirmDestructiveOperations(existingContent string, operations []Operation, opts MergeOptions, scanner *bufio.Scanner) ([]Operation, error) {
+	var accepted []Operation
+	for _, op := range operations {
+		if !op.IsDestructive() {
+			accepted = append(accepted, op)
+			continue
+		}
+
+		base := ApplyOperations(existingContent, accepted, opts)
+		preview := ApplyOperations(existingContent, append(accepted[:len(accepted):len(accepted)], op), opts)
+		hunks := UnifiedDiff(base.Content, preview.Content, defaultDiffContext)
+		if len(hunks) == 0 || preview.Decisions[len(preview.Decisions)-1].Action == MergeSkip {
+			// 適用できない操作や先の操作と重なる操作はスキップとして配置先に表示する
+			accepted = append(accepted, op)
+			continue
+		}
+
+		fmt.Println("=" + strings.Repeat("=", 79))
+		fmt.Printf("⚠️  既存の記述の変更: %s\n", op)
+		fmt.Println("=" + strings.Repeat("=", 79))
+		printRationale("", op.Evidence)
+		for _, hunk := range hunks {
+			fmt.Print(hunk)
+		}
+		fmt.Println()
+
+		fmt.Print("この変更を適用しますか? (yes/no): ")
+		confirmed, err := readConfirmation(scanner)
+		if err != nil {
+			return nil, err
+		}
+		if confirmed {
+			accepted = append(accepted, op)
+		} else {
+			fmt.Println("この変更はスキップします")
+		}
+	}
+	return accepted, nil
+}
+
+// printRationale prints why an item was suggested and the supporting transcript messages.
+func printRationale(rationale string, evidence []Evidence) {
+	if rationale != "" {
+		fmt.Printf("      理由: %s\n", rationale)
+	}
+	if len(evidence) > 0 {
+		refs := make([]string, 0, len(evidence))
+		for _, e := range evidence {
+			refs = append(refs, e.String())
+		}
+		fmt.Printf("      根拠: %s\n", strings.Join(refs, ", "))
+	}
+}
+
+// readConfirmation reads a yes/no answer from the scanner.
+func readConfirmation(scanner *bufio.Scanner) (bool, error) {
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return false, fmt.Errorf("入力の読み取りに失敗: %w", err)
+		}
+		return false, fmt.Errorf("入力がありません")
+	}
+	response := strings.ToLower(strings.TrimSpace(scanner.Text()))
+	return response == "yes" || response == "y", nil
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -10,6 +12,20 @@ import (
 	"time"
 )
 
+func TestMain(m *testing.M) {
+	// 使用量などの状態ファイルを利用者のホームディレクトリに書き込まない
+	home, err := os.MkdirTemp("", "suggest-claude-md-home-")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Setenv("HOME", home) // nolint:errcheck // Tests fail if HOME is not set
+	lookupHomeDir = func(int) (string, error) { return home, nil }
+	code := m.Run()
+	os.RemoveAll(home) // nolint:errcheck // Best-effort cleanup
+	os.Exit(code)
+}
+
 func TestRun(t *testing.T) {
 	// テスト用の一時ディレクトリとファイルを作成
 	tmpDir := t.TempDir()
@@ -372,7 +388,10 @@ func TestRun_AllOutputMessages(t *testing.T) {
 	fixedTime := time.Date(2024, 6, 15, 10, 30, 0, 0, time.UTC)
 	now := func() time.Time { return fixedTime }
 
-	err = run(input, output, func() (string, error) { return tmpDir, nil }, func(key string) string { return "" }, now)
+	writeFakeClaude(t, "## Testing\n\n- go test ./...\n")
+	getenv := func(string) string { return "" }
+
+	err = run(input, output, func() (string, error) { return tmpDir, nil }, getenv, now)
 	if err != nil {
 		t.Fatalf("run() error = %v", err)
 	}
@@ -389,7 +408,7 @@ func TestRun_AllOutputMessages(t *testing.T) {
 		"以下のコマンドで提案を適用できます：",
 		"suggest-claude-md --apply",
 		"詳細なログ:",
-		"/tmp/suggest-claude-md-comprehensive-test-20240615-103000",
+		filepath.Join(ResolveOutputDir(func(string) string { return "" }), "suggest-claude-md-comprehensive-test-20240615-103000"),
 	}
 
 	for _, expected := range expectedMessages {
@@ -427,7 +446,7 @@ func TestRun_LogFilePathFormat(t *testing.T) {
 	outputStr := output.String()
 
 	// ログファイルパスの形式を確認
-	expectedLogPath := "/tmp/suggest-claude-md-special-conversation-123-20241231-235959.log"
+	expectedLogPath := filepath.Join(ResolveOutputDir(func(string) string { return "" }), "suggest-claude-md-special-conversation-123-20241231-235959.log")
 	if !strings.Contains(outputStr, expectedLogPath) {
 		t.Errorf("Log file path should be %q, got: %s", expectedLogPath, outputStr)
 	}
@@ -956,3 +975,248 @@ New content for section 4.
 		t.Errorf("All sections should be present, got: %s", resultStr)
 	}
 }
+
+func TestApplySuggestionFileWithInput_ConfirmsDestructiveOperations(t *testing.T) {
+	existingContent := `# Project
+
+## Commands
+
+- make lint
+- make build
+`
+	suggestionContent := "## Commands\n\n- make test\n\n```json suggest-claude-md\n" +
+		`{"operations":[{"op":"delete","target":"Commands","content":"- make lint","rationale":"make lint was renamed"}]}` +
+		"\n```\n"
+
+	tests := []struct {
+		name     string
+		input    string
+		wantLint bool
+	}{
+		{name: "accept deletion", input: "yes\nyes\n", wantLint: false},
+		{name: "reject deletion", input: "no\nyes\n", wantLint: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			claudeMdPath := filepath.Join(tmpDir, "CLAUDE.md")
+			if err := os.WriteFile(claudeMdPath, []byte(existingContent), 0o644); err != nil {
+				t.Fatalf("Failed to create existing CLAUDE.md: %v", err)
+			}
+			suggestionPath := filepath.Join(tmpDir, "suggestion.md")
+			if err := os.WriteFile(suggestionPath, []byte(suggestionContent), 0o644); err != nil {
+				t.Fatalf("Failed to create suggestion file: %v", err)
+			}
+
+			originalWd, _ := os.Getwd()
+			defer os.Chdir(originalWd) // nolint:errcheck // Best-effort cleanup
+			os.Chdir(tmpDir)           // nolint:errcheck // Test will fail if this fails
+
+			if err := applySuggestionFileWithInput(suggestionPath, strings.NewReader(tt.input)); err != nil {
+				t.Fatalf("applySuggestionFileWithInput() returned error: %v", err)
+			}
+
+			content, err := os.ReadFile(claudeMdPath)
+			if err != nil {
+				t.Fatalf("Failed to read CLAUDE.md: %v", err)
+			}
+			result := string(content)
+			if strings.Contains(result, "make lint") != tt.wantLint {
+				t.Errorf("make lint presence = %v, want %v, got:\n%s", !tt.wantLint, tt.wantLint, result)
+			}
+			if !strings.Contains(result, "- make test") {
+				t.Errorf("Added content should be applied, got:\n%s", result)
+			}
+		})
+	}
+}
+
+func TestConfirmDestructiveOperations_CombinedPreview(t *testing.T) {
+	existingContent := "## Commands\n\n- make lint\n- make build\n"
+
+	tests := []struct {
+		name       string
+		operations []Operation
+		input      string
+		want       string
+	}{
+		{
+			name: "overlapping operation is not confirmed",
+			operations: []Operation{
+				{Op: OpReplace, Target: "Commands", Content: "- make test", Rationale: "r"},
+				{Op: OpDelete, Target: "Commands", Content: "- make lint", Rationale: "r"},
+			},
+			input: "yes\n",
+			want:  "## Commands\n\n- make test\n",
+		},
+		{
+			name: "each operation is confirmed on the accepted result",
+			operations: []Operation{
+				{Op: OpDelete, Target: "Commands", Content: "- make lint", Rationale: "r"},
+				{Op: OpDelete, Target: "Commands", Content: "- make build", Rationale: "r"},
+			},
+			input: "yes\nno\n",
+			want:  "## Commands\n\n- make build\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scanner := bufio.NewScanner(strings.NewReader(tt.input))
+			accepted, err := confirmDestructiveOperations(existingContent, tt.operations, DefaultMergeOptions(), scanner)
+			if err != nil {
+				t.Fatalf("confirmDestructiveOperations() returned error: %v", err)
+			}
+			if got := ApplyOperations(existingContent, accepted, DefaultMergeOptions()).Content; got != tt.want {
+				t.Errorf("Content = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// writeFakeClaude creates a script that prints output in place of the claude CLI.
+func writeFakeClaude(t *testing.T, output string) string {
+	t.Helper()
+	outputPath := filepath.Join(t.TempDir(), "output.txt")
+	if err := os.WriteFile(outputPath, []byte(output), 0o600); err != nil {
+		t.Fatalf("Failed to create fake output: %v", err)
+	}
+	return writeFakeClaudeScript(t, fmt.Sprintf("#!/bin/sh\ncat > /dev/null\ncat '%s'\n", outputPath))
+}
+
+// writeFakeClaudeScript installs script as the claude command found first in PATH during the test.
+func writeFakeClaudeScript(t *testing.T, script string) string {
+	t.Helper()
+	dir := t.TempDir()
+	scriptPath := filepath.Join(dir, claudeCommand)
+	if err := os.WriteFile(scriptPath, []byte(script), 0o700); err != nil {
+		t.Fatalf("Failed to create fake claude: %v", err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return scriptPath
+}
+
+// forbidClaude installs a claude command that fails, for tests in which claude must not run.
+func forbidClaude(t *testing.T) {
+	t.Helper()
+	writeFakeClaudeScript(t, "#!/bin/sh\necho 'claude should not run' >&2\nexit 1\n")
+}
+
+func TestRun_ValidatesSuggestion(t *testing.T) {
+	tests := []struct {
+		name         string
+		response     string
+		wantValid    bool
+		wantOutputs  []string
+		wantContent  string
+		notWantApply bool
+	}{
+		{
+			name:        "sanitised response",
+			response:    "以下が提案です：\n\n```markdown\n## Testing\n\n- go test ./...\n```\n\n---\n",
+			wantValid:   true,
+			wantOutputs: []string{"🧹 最初の見出しより前の前置きを削除", "suggest-claude-md --apply"},
+			wantContent: "## Testing\n\n- go test ./...\n",
+		},
+		{
+			name:         "empty response",
+			response:     "",
+			wantOutputs:  []string{"⚠️  提案が不正なため、適用対象から除外しました", "出力が空です", ".invalid.md"},
+			notWantApply: true,
+		},
+		{
+			name:         "error response",
+			response:     "API Error: 529 overloaded\n",
+			wantOutputs:  []string{"claudeがエラーを出力しました"},
+			notWantApply: true,
+		},
+		{
+			name:         "no section heading",
+			response:     "特に提案はありません。\n",
+			wantOutputs:  []string{"## で始まるセクション見出しがありません"},
+			notWantApply: true,
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			transcriptPath := filepath.Join(tmpDir, fmt.Sprintf("validate-%d.jsonl", i))
+			if err := os.WriteFile(transcriptPath, []byte(`{"message":{"role":"user","content":"Hello"}}`), 0o600); err != nil {
+				t.Fatalf("Failed to create transcript: %v", err)
+			}
+			outputDir := filepath.Join(tmpDir, "out")
+			writeFakeClaude(t, tt.response)
+			getenv := func(key string) string {
+				if key == "XDG_RUNTIME_DIR" {
+					return outputDir
+				}
+				return ""
+			}
+
+			input := strings.NewReader(fmt.Sprintf(`{"transcript_path": "%s", "hook_event_name": "SessionEnd"}`, transcriptPath))
+			output := &bytes.Buffer{}
+			now := func() time.Time { return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC) }
+
+			if err := run(input, output, func() (string, error) { return tmpDir, nil }, getenv, now); err != nil {
+				t.Fatalf("run() error = %v", err)
+			}
+
+			outputStr := output.String()
+			for _, want := range tt.wantOutputs {
+				if !strings.Contains(outputStr, want) {
+					t.Errorf("Output should contain %q, got: %s", want, outputStr)
+				}
+			}
+			if tt.notWantApply && strings.Contains(outputStr, "--apply") {
+				t.Errorf("Invalid suggestion should not be presented for apply, got: %s", outputStr)
+			}
+
+			suggestionFile := filepath.Join(outputDir, commandName, fmt.Sprintf("suggest-claude-md-validate-%d-20240101-000000.md", i))
+			if tt.wantValid {
+				content, err := os.ReadFile(suggestionFile)
+				if err != nil {
+					t.Fatalf("Failed to read suggestion file: %v", err)
+				}
+				if string(content) != tt.wantContent {
+					t.Errorf("Suggestion content = %q, want %q", string(content), tt.wantContent)
+				}
+				return
+			}
+			if _, err := os.Stat(InvalidSuggestionPath(suggestionFile)); err != nil {
+				t.Errorf("Invalid suggestion should be kept as %s: %v", InvalidSuggestionPath(suggestionFile), err)
+			}
+			if err := applySuggestionFileWithInput(InvalidSuggestionPath(suggestionFile), strings.NewReader("yes\n")); err == nil {
+				t.Error("Invalid suggestion file should not be applied")
+			}
+		})
+	}
+}
+
+func TestParseSubcommandFlags(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		wantArgs []string
+		wantPath string
+	}{
+		{"flag after argument", []string{"project", "--settings-path", "a.json"}, []string{"project"}, "a.json"},
+		{"flag before argument", []string{"--settings-path=b.json", "local"}, []string{"local"}, "b.json"},
+		{"no flag", []string{"user"}, []string{"user"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			path := fs.String("settings-path", "", "")
+			args, err := parseSubcommandFlags(fs, tt.args)
+			if err != nil {
+				t.Fatalf("parseSubcommandFlags() unexpected error: %v", err)
+			}
+			if strings.Join(args, " ") != strings.Join(tt.wantArgs, " ") || *path != tt.wantPath {
+				t.Errorf("parseSubcommandFlags() = %v, %q; want %v, %q", args, *path, tt.wantArgs, tt.wantPath)
+			}
+		})
+	}
+}
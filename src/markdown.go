@@ -0,0 +1,277 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Heading is a heading found by the Markdown block scanner.
+// Offsets are byte offsets into the scanned content.
+type Heading struct {
+	Level     int
+	Title     string
+	Setext    bool // true for === / --- underlined headings
+	Start     int  // offset of the first byte of the heading
+	End       int  // offset just after the heading (including its line break)
+	StartLine int
+	EndLine   int
+}
+
+// blockState is the kind of block the scanner is currently inside.
+type blockState int
+
+const (
+	blockNone blockState = iota
+	blockParagraph
+	blockFence
+	blockHTML
+)
+
+// mdLine is a single line of the document with its byte offsets.
+type mdLine struct {
+	text  string // without line terminator
+	start int
+	end   int // offset after the line terminator
+}
+
+var (
+	atxHeadingRegex       = regexp.MustCompile(`^(#{1,6})(?:[ \t]+(.*?))?[ \t]*$`)
+	atxClosingRegex       = regexp.MustCompile(`(?:^|[ \t]+)#+$`)
+	setextUnderlineRegex  = regexp.MustCompile(`^(=+|-+)[ \t]*$`)
+	thematicBreakRegex    = regexp.MustCompile(`^(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	listItemRegex         = regexp.MustCompile(`^(?:[-+*]|\d{1,9}[.)])(?:[ \t]|$)`)
+	htmlRawOpenRegex      = regexp.MustCompile(`(?i)^<(?:script|pre|style|textarea)(?:[ \t>]|$)`)
+	htmlRawCloseRegex     = regexp.MustCompile(`(?i)</(?:script|pre|style|textarea)>`)
+	htmlBlockTagRegex     = regexp.MustCompile(`(?i)^</?(?:address|article|aside|base|basefont|blockquote|body|caption|center|col|colgroup|dd|details|dialog|dir|div|dl|dt|fieldset|figcaption|figure|footer|form|frame|frameset|h[1-6]|head|header|hr|html|iframe|legend|li|link|main|menu|menuitem|nav|noframes|ol|optgroup|option|p|param|search|section|summary|table|tbody|td|tfoot|th|thead|title|tr|track|ul)(?:[ \t>]|/>|$)`)
+	htmlGenericTagRegex   = regexp.MustCompile(`^(?:<[A-Za-z][A-Za-z0-9-]*(?:[ \t]+[A-Za-z_:][A-Za-z0-9_.:-]*(?:[ \t]*=[ \t]*(?:[^ \t"'=<>` + "`" + `]+|'[^']*'|"[^"]*"))?)*[ \t]*/?>|</[A-Za-z][A-Za-z0-9-]*[ \t]*>)[ \t]*$`)
+	htmlDeclarationRegex  = regexp.MustCompile(`^<![A-Za-z]`)
+	fenceOpenRegex        = regexp.MustCompile("^(`{3,}|~{3,})(.*)$")
+	blockquoteMarkerRegex = regexp.MustCompile(`^>`)
+)
+
+// splitLines splits content into lines keeping track of byte offsets.
+func splitLines(content string) []mdLine {
+	var lines []mdLine
+	start := 0
+	for start < len(content) {
+		idx := strings.IndexByte(content[start:], '\n')
+		if idx == -1 {
+			lines = append(lines, mdLine{text: content[start:], start: start, end: len(content)})
+			break
+		}
+		end := start + idx + 1
+		text := strings.TrimSuffix(content[start:end-1], "\r")
+		lines = append(lines, mdLine{text: text, start: start, end: end})
+		start = end
+	}
+	return lines
+}
+
+// leadingIndent returns the indentation width (tabs count to the next multiple of 4)
+// and the line with the indentation removed.
+func leadingIndent(line string) (int, string) {
+	width := 0
+	for i, r := range line {
+		switch r {
+		case ' ':
+			width++
+		case '\t':
+			width += 4 - width%4
+		default:
+			return width, line[i:]
+		}
+	}
+	return width, ""
+}
+
+// ScanHeadings scans Markdown content following the CommonMark block structure
+// and returns its ATX and setext headings. Lines inside fenced code blocks,
+// indented code blocks and HTML blocks are never treated as headings.
+func ScanHeadings(content string) []Heading {
+	s := &headingScanner{
+		lines:          splitLines(content),
+		state:          blockNone,
+		paragraphStart: -1,
+		htmlEnd:        func(string) bool { return false },
+	}
+	for i := range s.lines {
+		s.scanLine(i)
+	}
+	return s.headings
+}
+
+// headingScanner is the block state of ScanHeadings.
+type headingScanner struct {
+	lines    []mdLine
+	headings []Heading
+
+	state          blockState
+	paragraphStart int // index of the first line of the current paragraph
+	setextAllowed  bool
+
+	// fence state
+	fenceChar byte
+	fenceLen  int
+
+	// HTML block state
+	htmlEnd         func(string) bool
+	htmlEndsOnBlank bool
+}
+
+// scanLine advances the block state by the i-th line.
+func (s *headingScanner) scanLine(i int) {
+	line := s.lines[i]
+	blank := strings.TrimSpace(line.text) == ""
+
+	switch s.state {
+	case blockFence:
+		s.continueFence(line.text)
+		return
+	case blockHTML:
+		s.continueHTML(line.text, blank)
+		return
+	}
+
+	if blank {
+		s.state = blockNone
+		return
+	}
+
+	indent, rest := leadingIndent(line.text)
+
+	// インデントされたコードブロック、または段落の継続行
+	if indent >= 4 {
+		return
+	}
+
+	// Setext見出し（段落の直後の === / ---）
+	if s.state == blockParagraph && s.setextAllowed && setextUnderlineRegex.MatchString(rest) {
+		s.addSetextHeading(i, rest)
+		return
+	}
+
+	if s.openFence(rest) || s.openHTML(rest) {
+		return
+	}
+
+	// ATX見出し
+	if matches := atxHeadingRegex.FindStringSubmatch(rest); matches != nil {
+		title := atxClosingRegex.ReplaceAllString(strings.TrimSpace(matches[2]), "")
+		s.headings = append(s.headings, Heading{
+			Level:     len(matches[1]),
+			Title:     strings.TrimSpace(title),
+			Start:     line.start,
+			End:       line.end,
+			StartLine: i,
+			EndLine:   i,
+		})
+		s.state = blockNone
+		return
+	}
+
+	// 区切り線
+	if thematicBreakRegex.MatchString(rest) {
+		s.state = blockNone
+		return
+	}
+
+	// 段落の継続行
+	if s.state == blockParagraph {
+		return
+	}
+
+	// 新しい段落。引用やリスト項目の中ではSetext見出しにしない
+	s.state = blockParagraph
+	s.paragraphStart = i
+	s.setextAllowed = !blockquoteMarkerRegex.MatchString(rest) && !listItemRegex.MatchString(rest)
+}
+
+// continueFence closes the fenced code block when text is its closing fence.
+func (s *headingScanner) continueFence(text string) {
+	indent, rest := leadingIndent(text)
+	if indent < 4 && len(rest) >= s.fenceLen && rest[0] == s.fenceChar {
+		run := len(rest) - len(strings.TrimLeft(rest, string(s.fenceChar)))
+		if run >= s.fenceLen && strings.TrimSpace(rest[run:]) == "" {
+			s.state = blockNone
+		}
+	}
+}
+
+// continueHTML closes the HTML block when text meets its end condition.
+func (s *headingScanner) continueHTML(text string, blank bool) {
+	if s.htmlEndsOnBlank {
+		if blank {
+			s.state = blockNone
+		}
+		return
+	}
+	if s.htmlEnd(text) {
+		s.state = blockNone
+	}
+}
+
+// addSetextHeading adds the current paragraph as a setext heading underlined by the i-th line.
+func (s *headingScanner) addSetextHeading(i int, underline string) {
+	var titleLines []string
+	for _, l := range s.lines[s.paragraphStart:i] {
+		titleLines = append(titleLines, strings.TrimSpace(l.text))
+	}
+	level := 2
+	if underline[0] == '=' {
+		level = 1
+	}
+	s.headings = append(s.headings, Heading{
+		Level:     level,
+		Title:     strings.Join(titleLines, " "),
+		Setext:    true,
+		Start:     s.lines[s.paragraphStart].start,
+		End:       s.lines[i].end,
+		StartLine: s.paragraphStart,
+		EndLine:   i,
+	})
+	s.state = blockNone
+}
+
+// openFence starts a fenced code block when rest is an opening fence.
+func (s *headingScanner) openFence(rest string) bool {
+	matches := fenceOpenRegex.FindStringSubmatch(rest)
+	if matches == nil || (matches[1][0] == '`' && strings.Contains(matches[2], "`")) {
+		return false
+	}
+	s.state = blockFence
+	s.fenceChar = matches[1][0]
+	s.fenceLen = len(matches[1])
+	return true
+}
+
+// openHTML starts an HTML block when rest matches one of its start conditions.
+func (s *headingScanner) openHTML(rest string) bool {
+	if !strings.HasPrefix(rest, "<") {
+		return false
+	}
+	switch {
+	case htmlRawOpenRegex.MatchString(rest):
+		s.htmlEnd, s.htmlEndsOnBlank = htmlRawCloseRegex.MatchString, false
+	case strings.HasPrefix(rest, "<!--"):
+		s.htmlEnd, s.htmlEndsOnBlank = func(line string) bool { return strings.Contains(line, "-->") }, false
+	case strings.HasPrefix(rest, "<?"):
+		s.htmlEnd, s.htmlEndsOnBlank = func(line string) bool { return strings.Contains(line, "?>") }, false
+	case strings.HasPrefix(rest, "<![CDATA["):
+		s.htmlEnd, s.htmlEndsOnBlank = func(line string) bool { return strings.Contains(line, "]]>") }, false
+	case htmlDeclarationRegex.MatchString(rest):
+		s.htmlEnd, s.htmlEndsOnBlank = func(line string) bool { return strings.Contains(line, ">") }, false
+	case htmlBlockTagRegex.MatchString(rest):
+		s.htmlEndsOnBlank = true
+	case s.state != blockParagraph && htmlGenericTagRegex.MatchString(rest):
+		// 任意のタグ（type 7）は段落を中断できない
+		s.htmlEndsOnBlank = true
+	default:
+		return false
+	}
+	s.state = blockHTML
+	// 開始行で終了条件を満たす場合
+	if !s.htmlEndsOnBlank && s.htmlEnd(rest[1:]) {
+		s.state = blockNone
+	}
+	return true
+}
@@ -0,0 +1,141 @@
+package main
+
+import "testing"
+
+func TestScanHeadings(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		wantTitles []string
+		wantLevels []int
+	}{
+		{
+			name:       "ATX headings",
+			content:    "# Title\n\n## Section\n\n### Sub ###\n",
+			wantTitles: []string{"Title", "Section", "Sub"},
+			wantLevels: []int{1, 2, 3},
+		},
+		{
+			name:       "comment in fenced bash block is ignored",
+			content:    "## Commands\n\n```bash\n# build the binary\ngo build ./...\n```\n\n## Next\n",
+			wantTitles: []string{"Commands", "Next"},
+			wantLevels: []int{2, 2},
+		},
+		{
+			name:       "tilde fence with longer closing fence",
+			content:    "~~~\n# not a heading\n~~~~\n# Heading\n",
+			wantTitles: []string{"Heading"},
+			wantLevels: []int{1},
+		},
+		{
+			name:       "shorter fence does not close",
+			content:    "````\n```\n# not a heading\n````\n",
+			wantTitles: nil,
+		},
+		{
+			name:       "indented code block",
+			content:    "Text\n\n    # not a heading\n",
+			wantTitles: nil,
+		},
+		{
+			name:       "HTML comment block",
+			content:    "<!--\n# not a heading\n-->\n## Visible\n",
+			wantTitles: []string{"Visible"},
+			wantLevels: []int{2},
+		},
+		{
+			name:       "HTML details block ends at blank line",
+			content:    "<details>\n# not a heading\n\n## Visible\n",
+			wantTitles: []string{"Visible"},
+			wantLevels: []int{2},
+		},
+		{
+			name:       "setext headings",
+			content:    "Project\n=======\n\nCommands\n--------\n\nText\n",
+			wantTitles: []string{"Project", "Commands"},
+			wantLevels: []int{1, 2},
+		},
+		{
+			name:       "multi-line setext heading",
+			content:    "First line\nsecond line\n===\n",
+			wantTitles: []string{"First line second line"},
+			wantLevels: []int{1},
+		},
+		{
+			name:       "thematic break is not a setext heading",
+			content:    "Text\n\n---\n\n## Section\n",
+			wantTitles: []string{"Section"},
+			wantLevels: []int{2},
+		},
+		{
+			name:       "list item followed by dashes",
+			content:    "- item\n---\n",
+			wantTitles: nil,
+		},
+		{
+			name:       "hash without space is not a heading",
+			content:    "#hashtag\n#5\n",
+			wantTitles: nil,
+		},
+		{
+			name:       "empty ATX heading",
+			content:    "##\n",
+			wantTitles: []string{""},
+			wantLevels: []int{2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headings := ScanHeadings(tt.content)
+			if len(headings) != len(tt.wantTitles) {
+				t.Fatalf("Expected %d headings, got %d: %+v", len(tt.wantTitles), len(headings), headings)
+			}
+			for i, h := range headings {
+				if h.Title != tt.wantTitles[i] {
+					t.Errorf("headings[%d].Title = %q, want %q", i, h.Title, tt.wantTitles[i])
+				}
+				if h.Level != tt.wantLevels[i] {
+					t.Errorf("headings[%d].Level = %d, want %d", i, h.Level, tt.wantLevels[i])
+				}
+			}
+		})
+	}
+}
+
+func TestScanHeadings_Offsets(t *testing.T) {
+	content := "intro\n\n## A\ntext\n\nTitle\n---\nbody"
+
+	headings := ScanHeadings(content)
+	if len(headings) != 2 {
+		t.Fatalf("Expected 2 headings, got %d", len(headings))
+	}
+
+	if got := content[headings[0].Start:headings[0].End]; got != "## A\n" {
+		t.Errorf("First heading span = %q, want %q", got, "## A\n")
+	}
+	if got := content[headings[1].Start:headings[1].End]; got != "Title\n---\n" {
+		t.Errorf("Setext heading span = %q, want %q", got, "Title\n---\n")
+	}
+	if headings[1].StartLine != 5 || headings[1].EndLine != 6 {
+		t.Errorf("Setext heading lines = %d-%d, want 5-6", headings[1].StartLine, headings[1].EndLine)
+	}
+}
+
+func TestSplitLines(t *testing.T) {
+	lines := splitLines("a\r\nb\n\nc")
+	want := []mdLine{
+		{text: "a", start: 0, end: 3},
+		{text: "b", start: 3, end: 5},
+		{text: "", start: 5, end: 6},
+		{text: "c", start: 6, end: 7},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("Expected %d lines, got %d", len(want), len(lines))
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("lines[%d] = %+v, want %+v", i, lines[i], want[i])
+		}
+	}
+}
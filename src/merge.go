@@ -0,0 +1,412 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MergeAction is the kind of placement chosen for a suggested section.
+type MergeAction string
+
+const (
+	// MergeInsert inserts the suggested content at the end of an existing section
+	MergeInsert MergeAction = "insert"
+	// MergeAppendSection appends the suggested section at the end of the document
+	MergeAppendSection MergeAction = "append-section"
+	// MergeAppendAll appends the whole suggestion because it has no usable sections
+	MergeAppendAll MergeAction = "append-all"
+	// MergeSkip drops the suggested section (e.g. it has no content)
+	MergeSkip MergeAction = "skip"
+	// MergeDuplicate drops a suggested subsection that duplicates an existing one
+	MergeDuplicate MergeAction = "duplicate"
+	// MergeReplace replaces an existing subsection with the suggested one
+	MergeReplace MergeAction = "replace"
+	// MergeBullets adds the new list items to an existing subsection
+	MergeBullets MergeAction = "merge-bullets"
+	// MergeDelete deletes an existing section or text (structured operations only)
+	MergeDelete MergeAction = "delete"
+)
+
+// MergeDecision records where a suggested section was placed and why.
+type MergeDecision struct {
+	Action MergeAction
+	Title  string // title of the suggested section
+	Target string // title of the existing section it was merged into
+	Offset int    // byte offset in the existing content where text was inserted
+	Reason string
+	// Match describes how the suggested title matched the target
+	Match TitleMatch
+	// RemovedBullets is the number of list items dropped as duplicates
+	RemovedBullets int
+	// Rationale and Evidence explain why the item was suggested (from the suggestion metadata)
+	Rationale string
+	Evidence  []Evidence
+}
+
+// String returns a human-readable description of the decision.
+func (d MergeDecision) String() string {
+	var description string
+	switch d.Action {
+	case MergeInsert:
+		description = fmt.Sprintf("「%s」→ 既存セクション「%s」の末尾に挿入", d.Title, d.Target)
+	case MergeAppendSection:
+		description = fmt.Sprintf("「%s」→ 新規セクションとして末尾に追加", d.Title)
+	case MergeAppendAll:
+		description = fmt.Sprintf("提案全体 → 末尾に追加（%s）", d.Reason)
+	case MergeSkip:
+		if d.Title == "" {
+			description = fmt.Sprintf("提案の一部 → スキップ（%s）", d.Reason)
+			break
+		}
+		description = fmt.Sprintf("「%s」→ スキップ（%s）", d.Title, d.Reason)
+	case MergeDuplicate:
+		description = fmt.Sprintf("「%s」→ 既存の「%s」と重複のためスキップ", d.Title, d.Target)
+	case MergeReplace:
+		description = fmt.Sprintf("「%s」→ 既存の「%s」を置き換え", d.Title, d.Target)
+	case MergeBullets:
+		description = fmt.Sprintf("「%s」→ 既存の「%s」に箇条書きを統合", d.Title, d.Target)
+	case MergeDelete:
+		description = fmt.Sprintf("「%s」→ 既存の「%s」から削除", d.Title, d.Target)
+	default:
+		description = fmt.Sprintf("「%s」→ %s", d.Title, d.Action)
+	}
+	if match := d.Match.String(); match != "" {
+		description += fmt.Sprintf("（%s）", match)
+	}
+	if d.RemovedBullets > 0 {
+		description += fmt.Sprintf("（重複する箇条書き%d件を除外）", d.RemovedBullets)
+	}
+	return description
+}
+
+// MergeResult is the merged document and the decisions that produced it.
+type MergeResult struct {
+	Content   string
+	Decisions []MergeDecision
+}
+
+// mergeEdit is a pending change of the existing content.
+// Text replaces content[offset:end]; end equals offset for insertions.
+// Block edits are separated from the surrounding text by blank lines,
+// raw edits are inserted as-is. Decision is the index of the decision that
+// made the edit.
+type mergeEdit struct {
+	offset   int
+	end      int
+	text     string
+	raw      bool
+	decision int
+}
+
+// MergeSections merges suggestion content into the existing CLAUDE.md
+// with the default options.
+func MergeSections(existingContent, suggestionContent string) MergeResult {
+	return MergeSectionsWithOptions(existingContent, suggestionContent, DefaultMergeOptions())
+}
+
+// MergeSectionsWithOptions merges suggestion content into the existing CLAUDE.md.
+// Both documents are parsed into section trees and insertions are computed
+// from byte offsets of the existing document, so the result does not depend on
+// section text being unique. Suggested sections are processed in document order.
+// Subsections and list items that already exist are handled according to opts.
+func MergeSectionsWithOptions(existingContent, suggestionContent string, opts MergeOptions) MergeResult {
+	if existingContent == "" {
+		return MergeResult{
+			Content:   suggestionContent,
+			Decisions: []MergeDecision{{Action: MergeAppendAll, Reason: "既存のCLAUDE.mdが空"}},
+		}
+	}
+
+	suggestionRoot := ParseSectionTree(suggestionContent)
+	if len(suggestionRoot.Children) == 0 {
+		return MergeResult{
+			Content:   appendContent(existingContent, suggestionContent),
+			Decisions: []MergeDecision{{Action: MergeAppendAll, Reason: "セクションがない"}},
+		}
+	}
+
+	m := &merger{
+		existing:   existingContent,
+		suggestion: suggestionContent,
+		opts:       opts,
+		replaced:   make(map[*SectionNode]bool),
+	}
+	if hasBody(suggestionRoot.Content) {
+		m.decisions = append(m.decisions, MergeDecision{
+			Action: MergeSkip,
+			Reason: "最初の見出しより前のテキスト",
+		})
+	}
+
+	// 提案の最上位セクションは、既存の任意の階層の同名セクションに対応付ける
+	existingRoot := ParseSectionTree(existingContent)
+	var newSections []string
+	for _, node := range suggestionRoot.Children {
+		target, match := m.findTarget(existingRoot, node.Title, node.Level)
+		if target == nil {
+			newSections = append(newSections, suggestionContent[node.StartOffset:node.SubtreeEnd()])
+			m.decisions = append(m.decisions, MergeDecision{
+				Action: MergeAppendSection,
+				Title:  node.Title,
+				Offset: len(existingContent),
+			})
+			continue
+		}
+
+		if !hasBody(suggestionContent[node.BodyOffset:node.SubtreeEnd()]) {
+			m.decisions = append(m.decisions, MergeDecision{
+				Action: MergeSkip,
+				Title:  node.Title,
+				Target: target.Path(),
+				Reason: "追加する内容がない",
+				Match:  match,
+			})
+			continue
+		}
+
+		m.mergeInto(target, node, match)
+	}
+	result, ignored := applyEdits(existingContent, m.edits)
+	m.skipIgnored(ignored)
+
+	// 既存にないセクションは末尾に追加
+	if len(newSections) > 0 {
+		if !strings.HasSuffix(result, "\n") {
+			result += "\n"
+		}
+		result += "\n" + strings.Join(newSections, "\n")
+	}
+
+	return MergeResult{Content: result, Decisions: m.decisions}
+}
+
+// merger accumulates edits and decisions for MergeSectionsWithOptions.
+type merger struct {
+	existing   string
+	suggestion string
+	opts       MergeOptions
+	edits      []mergeEdit
+	decisions  []MergeDecision
+	replaced   map[*SectionNode]bool
+}
+
+// mergeInto merges the suggested node into the matching existing section.
+// The text under the suggested heading is inserted at the end of the target's own
+// body and new subsections at the end of the target subtree. Existing sections
+// matched by title are merged recursively when they are top-level sections (# or ##)
+// or the suggestion has subsections for them; otherwise the duplicate policy applies.
+func (m *merger) mergeInto(target, node *SectionNode, match TitleMatch) {
+	existingBullets := extractBullets(m.existing[target.StartOffset:target.SubtreeEnd()])
+
+	// 見出し直後の本文
+	intro, removed := removeDuplicateBullets(m.suggestion[node.BodyOffset:node.EndOffset], existingBullets, m.opts.SimilarityThreshold)
+	if hasBody(intro) {
+		m.insertBlock(target.EndOffset, intro, MergeDecision{
+			Action:         MergeInsert,
+			Title:          node.Title,
+			Target:         target.Path(),
+			Match:          match,
+			RemovedBullets: removed,
+		})
+	} else if removed > 0 {
+		m.decisions = append(m.decisions, MergeDecision{
+			Action:         MergeDuplicate,
+			Title:          node.Title,
+			Target:         target.Path(),
+			Match:          match,
+			RemovedBullets: removed,
+		})
+	}
+
+	for _, child := range node.Children {
+		text := m.suggestion[child.StartOffset:child.SubtreeEnd()]
+
+		existing, titleMatch := m.findTarget(target, child.Title, child.Level)
+		if existing != nil && (existing.Level <= 2 || len(child.Children) > 0) && !m.replaced[existing] {
+			m.mergeInto(existing, child, titleMatch)
+			continue
+		}
+		if existing == nil {
+			existing = m.findSimilar(target, child)
+		}
+		if existing != nil {
+			m.resolveDuplicate(existing, child, text, titleMatch)
+			continue
+		}
+
+		text, removed = removeDuplicateBullets(text, existingBullets, m.opts.SimilarityThreshold)
+		if !hasBody(text) {
+			m.decisions = append(m.decisions, MergeDecision{
+				Action:         MergeDuplicate,
+				Title:          child.Title,
+				Target:         target.Path(),
+				RemovedBullets: removed,
+			})
+			continue
+		}
+		m.insertBlock(target.SubtreeEnd(), text, MergeDecision{
+			Action:         MergeInsert,
+			Title:          child.Title,
+			Target:         target.Path(),
+			RemovedBullets: removed,
+		})
+	}
+}
+
+// findTarget finds the existing section under scope that matches a suggested
+// title whose heading has the given level (0 when unknown).
+func (m *merger) findTarget(scope *SectionNode, title string, level int) (*SectionNode, TitleMatch) {
+	if m.opts.Titles == nil {
+		if node := FindSection(scope, title); node != nil {
+			return node, TitleMatch{Kind: TitleMatchExact, Score: 1}
+		}
+		return nil, TitleMatch{}
+	}
+	return m.opts.Titles.FindBest(scope, title, level)
+}
+
+// findSimilar returns the existing subsection of target whose body is
+// near-identical to the body of the suggested subsection.
+func (m *merger) findSimilar(target, child *SectionNode) *SectionNode {
+	childBody := m.suggestion[child.BodyOffset:child.SubtreeEnd()]
+	var found *SectionNode
+	target.Walk(func(existing *SectionNode) bool {
+		if existing != target && isDuplicateText(m.existing[existing.BodyOffset:existing.SubtreeEnd()], childBody, m.opts.SimilarityThreshold) {
+			found = existing
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// resolveDuplicate applies the duplicate policy to a suggested subsection.
+func (m *merger) resolveDuplicate(existing, child *SectionNode, text string, match TitleMatch) {
+	decision := MergeDecision{
+		Action: MergeDuplicate,
+		Title:  child.Title,
+		Target: existing.Path(),
+		Match:  match,
+	}
+	if m.replaced[existing] {
+		decision.Reason = "既に置き換え済み"
+		m.decisions = append(m.decisions, decision)
+		return
+	}
+
+	switch m.opts.Duplicates {
+	case DuplicateReplace:
+		m.replaced[existing] = true
+		decision.Action = MergeReplace
+		decision.Offset = existing.StartOffset
+		m.addEdit(mergeEdit{
+			offset: existing.StartOffset,
+			end:    existing.SubtreeEnd(),
+			text:   strings.Trim(text, "\n"),
+		})
+	case DuplicateMerge:
+		existingBullets := extractBullets(m.existing[existing.StartOffset:existing.SubtreeEnd()])
+		var newBullets []string
+		for _, line := range strings.Split(m.suggestion[child.BodyOffset:child.SubtreeEnd()], "\n") {
+			matches := bulletLineRegex.FindStringSubmatch(line)
+			if matches == nil {
+				continue
+			}
+			if isDuplicateBullet(matches[2], existingBullets, m.opts.SimilarityThreshold) {
+				decision.RemovedBullets++
+				continue
+			}
+			newBullets = append(newBullets, strings.TrimRight(line, " \t"))
+		}
+		if len(newBullets) == 0 {
+			break
+		}
+
+		decision.Action = MergeBullets
+		text := strings.Join(newBullets, "\n")
+		if pos := lastBulletEnd(m.existing, existing.BodyOffset, existing.EndOffset); pos != -1 {
+			if !strings.HasSuffix(m.existing[:pos], "\n") {
+				text = "\n" + text
+			}
+			decision.Offset = pos
+			m.addEdit(mergeEdit{offset: pos, end: pos, text: text + "\n", raw: true})
+		} else {
+			decision.Offset = existing.EndOffset
+			m.addEdit(mergeEdit{offset: existing.EndOffset, end: existing.EndOffset, text: text})
+		}
+	}
+
+	m.decisions = append(m.decisions, decision)
+}
+
+// insertBlock records a block insertion and its decision.
+func (m *merger) insertBlock(offset int, text string, decision MergeDecision) {
+	decision.Offset = offset
+	m.addEdit(mergeEdit{offset: offset, end: offset, text: strings.Trim(text, "\n")})
+	m.decisions = append(m.decisions, decision)
+}
+
+// addEdit records an edit made by the decision appended next.
+func (m *merger) addEdit(edit mergeEdit) {
+	edit.decision = len(m.decisions)
+	m.edits = append(m.edits, edit)
+}
+
+// skipIgnored marks the decisions of edits ignored by applyEdits as skipped, so that
+// the preview does not report text that is not in the result.
+func (m *merger) skipIgnored(ignored []mergeEdit) {
+	for _, edit := range ignored {
+		decision := &m.decisions[edit.decision]
+		decision.Action = MergeSkip
+		decision.Reason = "置き換える範囲と重なる"
+		decision.RemovedBullets = 0
+	}
+}
+
+// applyEdits applies edits to content. Edits at the same offset keep their order,
+// and edits overlapping an earlier replacement are ignored and returned.
+func applyEdits(content string, edits []mergeEdit) (string, []mergeEdit) {
+	sort.SliceStable(edits, func(i, j int) bool {
+		return edits[i].offset < edits[j].offset
+	})
+
+	var result strings.Builder
+	var ignored []mergeEdit
+	prev := 0
+	for _, edit := range edits {
+		if edit.offset < prev {
+			ignored = append(ignored, edit)
+			continue
+		}
+		result.WriteString(content[prev:edit.offset])
+		prev = edit.end
+		if edit.raw {
+			result.WriteString(edit.text)
+			continue
+		}
+
+		// 挿入位置の前後に空行を確保
+		written := result.String()
+		switch {
+		case strings.HasSuffix(written, "\n\n") || written == "":
+		case strings.HasSuffix(written, "\n"):
+			result.WriteString("\n")
+		default:
+			result.WriteString("\n\n")
+		}
+		result.WriteString(edit.text)
+		result.WriteString("\n")
+		if edit.end < len(content) {
+			result.WriteString("\n")
+		}
+	}
+	result.WriteString(content[prev:])
+
+	return result.String(), ignored
+}
+
+// normalizeTitle normalizes a section title for comparison.
+func normalizeTitle(title string) string {
+	return strings.ToLower(strings.TrimSpace(title))
+}
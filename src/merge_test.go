@@ -0,0 +1,351 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMergeSections_IdenticalSectionContent(t *testing.T) {
+	// 同じ本文を持つセクションが複数あっても、タイトルが一致するセクションに挿入される
+	existing := `# Project
+
+## Alpha
+
+TBD
+
+## Beta
+
+TBD
+`
+
+	suggestion := `## Beta
+
+### Added
+
+Only for Beta.
+`
+
+	result := MergeSections(existing, suggestion)
+
+	want := `# Project
+
+## Alpha
+
+TBD
+
+## Beta
+
+TBD
+
+### Added
+
+Only for Beta.
+`
+	if result.Content != want {
+		t.Errorf("MergeSections() content =\n%s\nwant:\n%s", result.Content, want)
+	}
+}
+
+func TestMergeSections_DeterministicOrder(t *testing.T) {
+	existing := `## A
+
+a
+
+## B
+
+b
+`
+
+	suggestion := `## B
+
+### B1
+
+b1
+
+## A
+
+### A1
+
+a1
+
+## A
+
+### A2
+
+a2
+`
+
+	want := `## A
+
+a
+
+### A1
+
+a1
+
+### A2
+
+a2
+
+## B
+
+b
+
+### B1
+
+b1
+`
+
+	for i := 0; i < 20; i++ {
+		result := MergeSections(existing, suggestion)
+		if result.Content != want {
+			t.Fatalf("MergeSections() content =\n%s\nwant:\n%s", result.Content, want)
+		}
+	}
+}
+
+func TestMergeSections_LastSectionWithoutTrailingNewline(t *testing.T) {
+	existing := "## Commands\n\n- make build"
+	suggestion := "## Commands\n\n- make test\n"
+
+	result := MergeSections(existing, suggestion)
+
+	want := "## Commands\n\n- make build\n\n- make test\n"
+	if result.Content != want {
+		t.Errorf("MergeSections() content = %q, want %q", result.Content, want)
+	}
+}
+
+func TestMergeSections_Decisions(t *testing.T) {
+	existing := `# Project
+
+## Commands
+
+- make build
+
+## Testing
+
+- go test
+`
+
+	suggestion := `## Commands
+
+### Lint
+
+- make lint
+
+## Testing
+
+## Troubleshooting
+
+Restart the daemon.
+`
+
+	result := MergeSections(existing, suggestion)
+
+	if len(result.Decisions) != 3 {
+		t.Fatalf("Expected 3 decisions, got %d: %+v", len(result.Decisions), result.Decisions)
+	}
+
+	insert := result.Decisions[0]
+	if insert.Action != MergeInsert || insert.Target != "Project > Commands" {
+		t.Errorf("Unexpected first decision: %+v", insert)
+	}
+	if !strings.HasPrefix(existing[insert.Offset:], "## Testing") {
+		t.Errorf("Insert offset should point at the next section, got %q", existing[insert.Offset:])
+	}
+
+	if result.Decisions[1].Action != MergeSkip || result.Decisions[1].Title != "Testing" {
+		t.Errorf("Unexpected second decision: %+v", result.Decisions[1])
+	}
+	if result.Decisions[2].Action != MergeAppendSection || result.Decisions[2].Title != "Troubleshooting" {
+		t.Errorf("Unexpected third decision: %+v", result.Decisions[2])
+	}
+
+	if !strings.Contains(result.Content, "- make build\n\n### Lint\n\n- make lint\n\n## Testing") {
+		t.Errorf("Lint should be inserted at the end of Commands, got:\n%s", result.Content)
+	}
+	if !strings.HasSuffix(result.Content, "## Troubleshooting\n\nRestart the daemon.\n") {
+		t.Errorf("Troubleshooting should be appended, got:\n%s", result.Content)
+	}
+}
+
+func TestMergeSections_AddOverlappingReplace(t *testing.T) {
+	existing := "# Project\n\n## Commands\n\n### Build\n\nmake build\n\n#### Flags\n\n-v\n\n## Testing\n\n- go test\n"
+	// 「Build」の本文への追加と、サブセクションを含む「Build」の置き換えが同じセクションを対象にする
+	suggestion := "## Commands\n\n### Build\n\nbazel build //...\n\n## Build\n\n- make release\n"
+
+	opts := DefaultMergeOptions()
+	opts.Duplicates = DuplicateReplace
+	result := MergeSectionsWithOptions(existing, suggestion, opts)
+
+	want := "# Project\n\n## Commands\n\n### Build\n\nbazel build //...\n\n## Testing\n\n- go test\n"
+	if result.Content != want {
+		t.Errorf("Content =\n%s\nwant:\n%s", result.Content, want)
+	}
+	if len(result.Decisions) != 2 {
+		t.Fatalf("Expected 2 decisions, got %d: %+v", len(result.Decisions), result.Decisions)
+	}
+	if result.Decisions[0].Action != MergeReplace {
+		t.Errorf("Unexpected first decision: %+v", result.Decisions[0])
+	}
+	// 反映されなかった追加は、確認画面でもスキップとして表示する
+	added := result.Decisions[1]
+	if added.Action != MergeSkip || added.Reason != "置き換える範囲と重なる" {
+		t.Errorf("Overlapping insert should be skipped: %+v", added)
+	}
+	if got := added.String(); !strings.Contains(got, "スキップ（置き換える範囲と重なる）") {
+		t.Errorf("String() = %s", got)
+	}
+}
+
+func TestMergeSections_AppendAll(t *testing.T) {
+	tests := []struct {
+		name       string
+		existing   string
+		suggestion string
+		wantReason string
+	}{
+		{
+			name:       "empty existing",
+			existing:   "",
+			suggestion: "## New\n",
+			wantReason: "既存のCLAUDE.mdが空",
+		},
+		{
+			name:       "no sections",
+			existing:   "# Project\n",
+			suggestion: "Plain text.",
+			wantReason: "セクションがない",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := MergeSections(tt.existing, tt.suggestion)
+			if len(result.Decisions) != 1 || result.Decisions[0].Action != MergeAppendAll {
+				t.Fatalf("Expected a single append-all decision, got %+v", result.Decisions)
+			}
+			if result.Decisions[0].Reason != tt.wantReason {
+				t.Errorf("Reason = %q, want %q", result.Decisions[0].Reason, tt.wantReason)
+			}
+			if !strings.Contains(result.Content, tt.suggestion) {
+				t.Errorf("Suggestion should be appended, got %q", result.Content)
+			}
+		})
+	}
+}
+
+func TestMergeSections_CodeFenceInExisting(t *testing.T) {
+	// コードフェンス内の # 行でセクションが分割されない
+	existing := "## Commands\n\n```bash\n# build\nmake\n```\n\n## Other\n\nx\n"
+	suggestion := "## Commands\n\n- make test\n"
+
+	result := MergeSections(existing, suggestion)
+
+	want := "## Commands\n\n```bash\n# build\nmake\n```\n\n- make test\n\n## Other\n\nx\n"
+	if result.Content != want {
+		t.Errorf("MergeSections() content = %q, want %q", result.Content, want)
+	}
+}
+
+func TestMergeDecision_String(t *testing.T) {
+	tests := []struct {
+		decision MergeDecision
+		want     string
+	}{
+		{MergeDecision{Action: MergeInsert, Title: "A", Target: "A"}, "既存セクション「A」"},
+		{MergeDecision{Action: MergeAppendSection, Title: "B"}, "新規セクション"},
+		{MergeDecision{Action: MergeAppendAll, Reason: "理由"}, "提案全体"},
+		{MergeDecision{Action: MergeSkip, Title: "C", Reason: "理由"}, "スキップ"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.decision.String(); !strings.Contains(got, tt.want) {
+			t.Errorf("String() = %q, want to contain %q", got, tt.want)
+		}
+	}
+}
+
+func TestMergeSections_NestedDocument(t *testing.T) {
+	// 全体が # Project の配下にある CLAUDE.md
+	existing := `# Project
+
+Intro.
+
+## Architecture
+
+### Execution modes
+
+- sync
+
+## Testing
+
+- go test
+`
+
+	tests := []struct {
+		name       string
+		suggestion string
+		want       string
+		wantTarget string
+	}{
+		{
+			name:       "level-1 title matches",
+			suggestion: "# Project\n\n## Testing\n\n- make test\n",
+			want:       "## Testing\n\n- go test\n\n- make test\n",
+			wantTarget: "Project > Testing",
+		},
+		{
+			name:       "level-3 only suggestion",
+			suggestion: "### Execution modes\n\n- async\n",
+			want:       "### Execution modes\n\n- sync\n\n- async\n\n## Testing",
+			wantTarget: "Project > Architecture > Execution modes",
+		},
+		{
+			name:       "level-4 under matched level-3",
+			suggestion: "## Architecture\n\n### Execution modes\n\n#### Async\n\nDetached worker.\n",
+			want:       "- sync\n\n#### Async\n\nDetached worker.\n\n## Testing",
+			wantTarget: "Project > Architecture > Execution modes",
+		},
+		{
+			name:       "intro of level-1 section goes before subsections",
+			suggestion: "# Project\n\nMore intro.\n",
+			want:       "Intro.\n\nMore intro.\n\n## Architecture",
+			wantTarget: "Project",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := MergeSections(existing, tt.suggestion)
+			if !strings.Contains(result.Content, tt.want) {
+				t.Errorf("Content =\n%s\nwant to contain:\n%s", result.Content, tt.want)
+			}
+			if strings.Count(result.Content, "# Project") != 1 {
+				t.Errorf("Project title should appear once, got:\n%s", result.Content)
+			}
+			last := result.Decisions[len(result.Decisions)-1]
+			if last.Action != MergeInsert || last.Target != tt.wantTarget {
+				t.Errorf("Unexpected decision: %+v", last)
+			}
+		})
+	}
+}
+
+func TestMergeSections_UnmatchedLevel1Appended(t *testing.T) {
+	existing := "# Project\n\n## A\n\na\n"
+	suggestion := "Preamble.\n\n# Other\n\nText.\n"
+
+	result := MergeSections(existing, suggestion)
+
+	if !strings.HasSuffix(result.Content, "\n\n# Other\n\nText.\n") {
+		t.Errorf("Unmatched level-1 section should be appended, got %q", result.Content)
+	}
+	if len(result.Decisions) != 2 || result.Decisions[0].Action != MergeSkip || result.Decisions[1].Action != MergeAppendSection {
+		t.Errorf("Unexpected decisions: %+v", result.Decisions)
+	}
+	if got := result.Decisions[0].String(); !strings.Contains(got, "提案の一部") {
+		t.Errorf("Preamble decision = %q", got)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	notifierBell       = "bell"
+	notifierNotifySend = "notify-send"
+	notifierCommand    = "command"
+
+	// notifyTimeout bounds how long a notifier may run.
+	notifyTimeout = 30 * time.Second
+)
+
+// Notifier tells the user that an async analysis finished.
+type Notifier interface {
+	Name() string
+	Notify(job *Job) error
+}
+
+// NewNotifiers returns the notifiers configured by notify and notify_command.
+// The terminal bell is used when none is configured.
+func NewNotifiers(config *Config) ([]Notifier, error) {
+	names := config.Notify
+	if len(names) == 0 {
+		names = []string{notifierBell}
+	}
+
+	var notifiers []Notifier
+	for _, name := range names {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case notifierBell:
+			notifiers = append(notifiers, bellNotifier{})
+		case notifierNotifySend:
+			notifiers = append(notifiers, notifySendNotifier{})
+		case notifierCommand:
+			if strings.TrimSpace(config.NotifyCommand) == "" {
+				return nil, fmt.Errorf("通知方法 command には notify_command の指定が必要です")
+			}
+			notifiers = append(notifiers, commandNotifier{command: config.NotifyCommand})
+		case "none":
+		default:
+			return nil, fmt.Errorf("無効な通知方法: %s (有効な値: bell, notify-send, command, none)", name)
+		}
+	}
+	return notifiers, nil
+}
+
+// notificationMessage describes the outcome of a job in one line.
+func notificationMessage(job *Job) string {
+	project := filepath.Base(job.ProjectRoot)
+	switch job.Status {
+	case JobSucceeded:
+		return fmt.Sprintf("CLAUDE.md更新提案が完了しました（%s）: %s", project, job.SuggestionFile)
+	case JobInvalid:
+		return fmt.Sprintf("提案が不正なため除外しました（%s）: %s", project, job.LogFile)
+	case JobSkipped:
+		reason := job.SkipReason
+		if reason == "" {
+			reason = "会話履歴が空のため"
+		}
+		return fmt.Sprintf("%sスキップしました（%s）", reason, project)
+	default:
+		return fmt.Sprintf("分析に失敗しました（%s）: %s", project, job.Error)
+	}
+}
+
+// bellNotifier rings the bell of the terminal the hook was started from.
+type bellNotifier struct{}
+
+func (bellNotifier) Name() string { return notifierBell }
+
+func (bellNotifier) Notify(job *Job) error {
+	if job.Terminal == "" {
+		return fmt.Errorf("フックの実行元の端末がわかりません")
+	}
+	tty, err := os.OpenFile(job.Terminal, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer tty.Close() // nolint:errcheck // Nothing to flush
+	_, err = tty.WriteString("\a")
+	return err
+}
+
+// notifySendNotifier shows a desktop notification with notify-send.
+type notifySendNotifier struct{}
+
+func (notifySendNotifier) Name() string { return notifierNotifySend }
+
+func (notifySendNotifier) Notify(job *Job) error {
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, "notify-send", "--app-name="+commandName, commandName, notificationMessage(job)).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// commandNotifier runs a shell command with the job described in environment variables.
+type commandNotifier struct {
+	command string
+}
+
+func (commandNotifier) Name() string { return notifierCommand }
+
+func (n commandNotifier) Notify(job *Job) error {
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", n.command)
+	cmd.Dir = job.ProjectRoot
+	cmd.Env = append(os.Environ(),
+		"SUGGEST_CLAUDE_MD_JOB_ID="+job.ID,
+		"SUGGEST_CLAUDE_MD_JOB_STATUS="+string(job.Status),
+		"SUGGEST_CLAUDE_MD_PROJECT="+job.ProjectRoot,
+		"SUGGEST_CLAUDE_MD_SUGGESTION_FILE="+job.SuggestionFile,
+		"SUGGEST_CLAUDE_MD_LOG_FILE="+job.LogFile,
+		"SUGGEST_CLAUDE_MD_MESSAGE="+notificationMessage(job),
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// terminalName returns the controlling terminal of the current process, or "" if there is none.
+func terminalName() string {
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return ""
+	}
+	defer tty.Close() // nolint:errcheck // File is read-only, no need to check close error
+
+	cmd := exec.Command("tty")
+	cmd.Stdin = tty
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewNotifiers(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		want    []string
+		wantErr string
+	}{
+		{"default is bell", Config{}, []string{"bell"}, ""},
+		{"multiple", Config{Notify: []string{"notify-send", "Command"}, NotifyCommand: "true"}, []string{"notify-send", "command"}, ""},
+		{"none", Config{Notify: []string{"none"}}, nil, ""},
+		{"command without notify_command", Config{Notify: []string{"command"}}, nil, "notify_command"},
+		{"unknown", Config{Notify: []string{"slack"}}, nil, "無効な通知方法: slack"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notifiers, err := NewNotifiers(&tt.config)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("NewNotifiers() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewNotifiers() error = %v", err)
+			}
+			var names []string
+			for _, n := range notifiers {
+				names = append(names, n.Name())
+			}
+			if strings.Join(names, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("NewNotifiers() = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandNotifier(t *testing.T) {
+	tmpDir := t.TempDir()
+	outputFile := filepath.Join(tmpDir, "notification")
+	job := &Job{ID: "job-1", Status: JobInvalid, ProjectRoot: tmpDir, LogFile: "/out/a.log"}
+
+	notifier := commandNotifier{command: `printf '%s|%s|%s|%s' "$SUGGEST_CLAUDE_MD_JOB_ID" "$SUGGEST_CLAUDE_MD_JOB_STATUS" "$PWD" "$SUGGEST_CLAUDE_MD_MESSAGE" > notification`}
+	if err := notifier.Notify(job); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Notification command should run in the project: %v", err)
+	}
+	want := "job-1|invalid|" + tmpDir + "|" + notificationMessage(job)
+	if string(content) != want {
+		t.Errorf("notification = %q, want %q", content, want)
+	}
+
+	failing := commandNotifier{command: "echo boom >&2; exit 3"}
+	if err := failing.Notify(job); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("Notify() error = %v, want command output", err)
+	}
+}
+
+func TestBellNotifier_NoTerminal(t *testing.T) {
+	if err := (bellNotifier{}).Notify(&Job{}); err == nil {
+		t.Error("Notify() should fail without a terminal")
+	}
+}
@@ -0,0 +1,545 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// SuggestionFormat is the output format requested from the model.
+type SuggestionFormat string
+
+const (
+	// FormatMarkdown asks for free Markdown merged by MergeSections
+	FormatMarkdown SuggestionFormat = "markdown"
+	// FormatOperations asks for a JSON list of operations applied by ApplyOperations
+	FormatOperations SuggestionFormat = "operations"
+)
+
+// ParseSuggestionFormat parses a suggestion format name. An empty value means Markdown.
+func ParseSuggestionFormat(value string) (SuggestionFormat, error) {
+	switch format := SuggestionFormat(strings.ToLower(strings.TrimSpace(value))); format {
+	case "", FormatMarkdown:
+		return FormatMarkdown, nil
+	case FormatOperations:
+		return FormatOperations, nil
+	default:
+		return "", fmt.Errorf("無効な提案形式: %s (有効な値: markdown, operations)", value)
+	}
+}
+
+// OperationType is the kind of change an operation makes to CLAUDE.md.
+type OperationType string
+
+const (
+	// OpAdd adds content to a section, creating the section if needed
+	OpAdd OperationType = "add"
+	// OpReplace replaces the body of an existing section
+	OpReplace OperationType = "replace"
+	// OpDelete deletes a section, or only the given text within it
+	OpDelete OperationType = "delete"
+)
+
+// Evidence refers to a transcript message that supports a suggestion.
+type Evidence struct {
+	UUID      string `json:"uuid"`
+	Timestamp string `json:"timestamp,omitempty"`
+}
+
+// String returns the message reference as "uuid (timestamp)".
+func (e Evidence) String() string {
+	if e.Timestamp == "" {
+		return e.UUID
+	}
+	return fmt.Sprintf("%s (%s)", e.UUID, e.Timestamp)
+}
+
+// Operation is a single structured change proposed by the model.
+type Operation struct {
+	Op        OperationType `json:"op"`
+	Target    string        `json:"target"` // section path, e.g. "Architecture > Execution modes"
+	Content   string        `json:"content,omitempty"`
+	Rationale string        `json:"rationale"`
+	Evidence  []Evidence    `json:"evidence,omitempty"`
+}
+
+// ItemRationale explains why a section of a Markdown suggestion was proposed.
+// It is kept in the suggestion file and never written to CLAUDE.md.
+type ItemRationale struct {
+	Target    string     `json:"target"` // section title or path in the suggestion
+	Rationale string     `json:"rationale"`
+	Evidence  []Evidence `json:"evidence,omitempty"`
+}
+
+// OperationSet is the structured suggestion document. In a Markdown suggestion
+// it is embedded as a metadata block carrying corrections and per-item rationales.
+type OperationSet struct {
+	Operations []Operation     `json:"operations,omitempty"`
+	Items      []ItemRationale `json:"items,omitempty"`
+}
+
+// Annotate attaches the item rationales to the decisions of a Markdown merge.
+func (s *OperationSet) Annotate(decisions []MergeDecision) {
+	for i := range decisions {
+		if item := s.ItemFor(decisions[i].Title); item != nil {
+			decisions[i].Rationale = item.Rationale
+			decisions[i].Evidence = item.Evidence
+		}
+	}
+}
+
+// ItemFor returns the rationale for a suggested section title, or nil.
+func (s *OperationSet) ItemFor(title string) *ItemRationale {
+	want := normalizeSectionTitle(title)
+	for i, item := range s.Items {
+		path := splitSectionPath(item.Target)
+		if len(path) > 0 && normalizeSectionTitle(path[len(path)-1]) == want {
+			return &s.Items[i]
+		}
+	}
+	return nil
+}
+
+// OperationsSchema is the JSON Schema of OperationSet, included in the prompt.
+const OperationsSchema = `{
+  "type": "object",
+  "required": ["operations"],
+  "additionalProperties": false,
+  "properties": {
+    "operations": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "required": ["op", "target", "rationale"],
+        "additionalProperties": false,
+        "properties": {
+          "op": {"enum": ["add", "replace", "delete"]},
+          "target": {"type": "string", "minLength": 1, "description": "セクションのパス（例: アーキテクチャ > 実行モード）"},
+          "content": {"type": "string", "description": "add/replaceでは必須。deleteでは削除する文（省略時はセクション全体を削除）"},
+          "rationale": {"type": "string", "minLength": 1},
+          "evidence": {"$ref": "#/$defs/evidence"}
+        }
+      }
+    }
+  },
+  "$defs": {
+    "evidence": {
+      "type": "array",
+      "description": "根拠となった会話履歴のメッセージ",
+      "items": {
+        "type": "object",
+        "required": ["uuid"],
+        "additionalProperties": false,
+        "properties": {
+          "uuid": {"type": "string", "minLength": 1},
+          "timestamp": {"type": "string"}
+        }
+      }
+    }
+  }
+}`
+
+// Validate checks an operation against the schema rules.
+func (o Operation) Validate() error {
+	switch o.Op {
+	case OpAdd, OpReplace:
+		if strings.TrimSpace(o.Content) == "" {
+			return fmt.Errorf("%sにはcontentが必要です", o.Op)
+		}
+	case OpDelete:
+	default:
+		return fmt.Errorf("無効なop: %q (有効な値: add, replace, delete)", o.Op)
+	}
+	if len(splitSectionPath(o.Target)) == 0 {
+		return fmt.Errorf("targetが空です")
+	}
+	if strings.TrimSpace(o.Rationale) == "" {
+		return fmt.Errorf("rationaleが空です")
+	}
+	return validateEvidence(o.Evidence)
+}
+
+// Validate checks an item rationale against the schema rules.
+func (r ItemRationale) Validate() error {
+	if len(splitSectionPath(r.Target)) == 0 {
+		return fmt.Errorf("targetが空です")
+	}
+	if strings.TrimSpace(r.Rationale) == "" {
+		return fmt.Errorf("rationaleが空です")
+	}
+	return validateEvidence(r.Evidence)
+}
+
+// validateEvidence checks that every evidence refers to a message.
+func validateEvidence(evidence []Evidence) error {
+	for _, e := range evidence {
+		if strings.TrimSpace(e.UUID) == "" {
+			return fmt.Errorf("evidenceのuuidが空です")
+		}
+	}
+	return nil
+}
+
+// IsStructuredSuggestion reports whether a suggestion looks like a JSON operation set.
+func IsStructuredSuggestion(content string) bool {
+	trimmed := strings.TrimSpace(stripCodeFence(content))
+	return strings.HasPrefix(trimmed, "{")
+}
+
+// ParseOperations parses and validates a JSON operation set.
+// A surrounding ```json code fence is tolerated.
+func ParseOperations(content string) (*OperationSet, error) {
+	decoder := json.NewDecoder(bytes.NewReader([]byte(stripCodeFence(content))))
+	decoder.DisallowUnknownFields()
+
+	var set OperationSet
+	if err := decoder.Decode(&set); err != nil {
+		return nil, fmt.Errorf("操作リストの解析に失敗: %w", err)
+	}
+	if set.Operations == nil && set.Items == nil {
+		return nil, fmt.Errorf("操作リストにoperationsがありません")
+	}
+	for i, op := range set.Operations {
+		if err := op.Validate(); err != nil {
+			return nil, fmt.Errorf("操作%d: %w", i+1, err)
+		}
+	}
+	for i, item := range set.Items {
+		if err := item.Validate(); err != nil {
+			return nil, fmt.Errorf("項目%d: %w", i+1, err)
+		}
+	}
+	return &set, nil
+}
+
+// stripCodeFence removes a code fence wrapping the whole content.
+func stripCodeFence(content string) string {
+	trimmed := strings.TrimSpace(content)
+	if !strings.HasPrefix(trimmed, "```") && !strings.HasPrefix(trimmed, "~~~") {
+		return content
+	}
+	fence := trimmed[:3]
+	firstLineEnd := strings.IndexByte(trimmed, '\n')
+	if firstLineEnd == -1 || !strings.HasSuffix(trimmed, fence) {
+		return content
+	}
+	return strings.TrimSpace(strings.TrimSuffix(trimmed[firstLineEnd+1:], fence))
+}
+
+// IsDestructive reports whether the operation changes or removes existing text.
+func (o Operation) IsDestructive() bool {
+	return o.Op == OpReplace || o.Op == OpDelete
+}
+
+// SplitSuggestion separates a suggestion into Markdown to merge and its metadata
+// (operations to apply and per-item rationales). A structured suggestion consists of
+// operations only; a Markdown suggestion may carry metadata in code blocks whose
+// info string is metadataInfoString. Other ```json blocks are kept as Markdown.
+func SplitSuggestion(content string) (string, *OperationSet, error) {
+	if IsStructuredSuggestion(content) {
+		set, err := ParseOperations(content)
+		if err != nil {
+			return "", nil, err
+		}
+		return "", set, nil
+	}
+
+	metadata := &OperationSet{}
+	found := false
+	var markdown strings.Builder
+	prev := 0
+	for _, block := range findMetadataBlocks(content) {
+		set, err := ParseOperations(content[block.bodyStart:block.bodyEnd])
+		if err != nil {
+			return "", nil, err
+		}
+		metadata.Operations = append(metadata.Operations, set.Operations...)
+		metadata.Items = append(metadata.Items, set.Items...)
+		markdown.WriteString(content[prev:block.start])
+		prev = block.end
+		found = true
+	}
+	if !found {
+		return content, metadata, nil
+	}
+	markdown.WriteString(content[prev:])
+
+	return strings.TrimSpace(markdown.String()) + "\n", metadata, nil
+}
+
+// metadataInfoString is the info string of the code block holding the metadata
+// of a Markdown suggestion, e.g. ```json suggest-claude-md.
+const metadataInfoString = "json " + commandName
+
+// metadataBlock is the byte range of a metadata code block and its body.
+type metadataBlock struct {
+	start, end         int
+	bodyStart, bodyEnd int
+}
+
+// isMetadataInfo reports whether the info string of a code fence marks a metadata block.
+func isMetadataInfo(info string) bool {
+	return strings.Join(strings.Fields(info), " ") == metadataInfoString
+}
+
+// findMetadataBlocks returns the unindented fenced code blocks marked as metadata.
+func findMetadataBlocks(content string) []metadataBlock {
+	var blocks []metadataBlock
+	var current *metadataBlock
+	fence := ""
+	for _, line := range splitLines(content) {
+		if fence != "" {
+			// 開始と同じ文字で同じ長さ以上のフェンスで閉じる
+			trimmed := strings.TrimSpace(line.text)
+			if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+				if current != nil {
+					current.bodyEnd = line.start
+					current.end = line.end
+					blocks = append(blocks, *current)
+				}
+				fence, current = "", nil
+			}
+			continue
+		}
+		if matches := fenceOpenRegex.FindStringSubmatch(line.text); matches != nil {
+			fence = matches[1]
+			if isMetadataInfo(matches[2]) {
+				current = &metadataBlock{start: line.start, bodyStart: line.end}
+			}
+		}
+	}
+	return blocks
+}
+
+// String returns a human-readable summary of the operation.
+func (o Operation) String() string {
+	label := map[OperationType]string{OpAdd: "追加", OpReplace: "置換", OpDelete: "削除"}[o.Op]
+	return fmt.Sprintf("[%s] %s — %s", label, o.Target, o.Rationale)
+}
+
+// ApplyOperations applies structured operations to the existing CLAUDE.md.
+// Targets are resolved on the original document, so operations do not affect
+// each other's positions. Operations that cannot be applied, including those
+// overlapping an earlier operation, are reported as skipped.
+func ApplyOperations(existingContent string, operations []Operation, opts MergeOptions) MergeResult {
+	a := &operationApplier{
+		merger: merger{existing: existingContent, opts: opts},
+		root:   ParseSectionTree(existingContent),
+	}
+	for _, op := range operations {
+		a.apply(op)
+	}
+
+	result, ignored := applyEdits(existingContent, a.edits)
+	a.skipIgnored(ignored)
+	if len(a.newSections) > 0 {
+		if result == "" {
+			result = strings.Join(a.newSections, "\n")
+		} else {
+			result = appendContent(result, strings.Join(a.newSections, "\n"))
+		}
+	}
+
+	return MergeResult{Content: result, Decisions: a.decisions}
+}
+
+// operationApplier accumulates edits and decisions for ApplyOperations.
+type operationApplier struct {
+	merger
+	root        *SectionNode
+	changes     []operationChange
+	newSections []string
+}
+
+// operationChange is the change of the existing document planned for an operation.
+// A whole change replaces or deletes the subtree of node; other changes only
+// cover edit.offset..edit.end. A new section has no node and is appended to the document.
+type operationChange struct {
+	action     MergeAction
+	node       *SectionNode
+	edit       mergeEdit
+	whole      bool
+	newSection bool
+}
+
+// overlaps reports whether two changes touch the same text, including a change
+// within a section that the other replaces or deletes.
+func (c operationChange) overlaps(other operationChange) bool {
+	if (c.whole && c.node.Contains(other.node)) || (other.whole && other.node.Contains(c.node)) {
+		return true
+	}
+	return c.edit.offset < other.edit.end && other.edit.offset < c.edit.end
+}
+
+// apply plans one operation and records its decision.
+func (a *operationApplier) apply(op Operation) {
+	path := splitSectionPath(op.Target)
+	target, match := a.findPath(a.root, path)
+	decision := MergeDecision{Title: op.Target, Match: match, Rationale: op.Rationale, Evidence: op.Evidence}
+	if target != nil {
+		decision.Target = target.Path()
+	}
+
+	var change *operationChange
+	var reason string
+	switch {
+	case op.Op == OpAdd:
+		change = a.planAdd(op, path, target, &decision)
+	case target == nil:
+		reason = "対象のセクションが見つからない"
+	case match.Kind == TitleMatchFuzzy:
+		// 置換・削除は既存の内容を失うため、似ているだけの見出しは対象にしない
+		reason = "見出しが完全一致またはエイリアス一致するセクションがない"
+	default:
+		change, reason = a.planRemoval(op, target)
+	}
+	if change != nil {
+		reason = a.conflict(*change)
+	}
+	if reason != "" {
+		decision.Action = MergeSkip
+		decision.Reason = reason
+		a.decisions = append(a.decisions, decision)
+		return
+	}
+
+	decision.Action = change.action
+	decision.Offset = change.edit.offset
+	a.changes = append(a.changes, *change)
+	if change.newSection {
+		a.newSections = append(a.newSections, change.edit.text+"\n")
+	} else {
+		a.addEdit(change.edit)
+	}
+	a.decisions = append(a.decisions, decision)
+}
+
+// planAdd plans an add operation: the content is inserted at the end of the
+// target, or a new section is created under the parent or at the end of the document.
+func (a *operationApplier) planAdd(op Operation, path []string, target *SectionNode, decision *MergeDecision) *operationChange {
+	content := strings.Trim(op.Content, "\n")
+	if target != nil {
+		offset := target.SubtreeEnd()
+		return &operationChange{action: MergeInsert, node: target, edit: mergeEdit{offset: offset, end: offset, text: content}}
+	}
+
+	// 親セクションがあればその末尾に、なければ文書の末尾に新規セクションを作成
+	parent, _ := a.findPath(a.root, path[:len(path)-1])
+	level, offset := 2, len(a.existing)
+	if parent != nil {
+		level, offset = parent.Level+1, parent.SubtreeEnd()
+		decision.Target = parent.Path()
+	}
+	text := strings.Repeat("#", level) + " " + path[len(path)-1] + "\n\n" + content
+	return &operationChange{
+		action:     MergeAppendSection,
+		node:       parent,
+		edit:       mergeEdit{offset: offset, end: offset, text: text},
+		newSection: parent == nil,
+	}
+}
+
+// planRemoval plans a replace or delete operation on target.
+// It returns the reason when the operation cannot be applied.
+func (a *operationApplier) planRemoval(op Operation, target *SectionNode) (*operationChange, string) {
+	end := target.SubtreeEnd()
+	switch {
+	case op.Op == OpReplace:
+		text := "\n" + strings.Trim(op.Content, "\n") + "\n" + trailingBlankLine(a.existing, end)
+		return &operationChange{
+			action: MergeReplace,
+			node:   target,
+			edit:   mergeEdit{offset: target.BodyOffset, end: end, text: text, raw: true},
+			whole:  true,
+		}, ""
+	case strings.TrimSpace(op.Content) == "":
+		return &operationChange{
+			action: MergeDelete,
+			node:   target,
+			edit:   mergeEdit{offset: target.StartOffset, end: end, raw: true},
+			whole:  true,
+		}, ""
+	}
+
+	start, end := findTextInSection(a.existing, target, op.Content)
+	if start == -1 {
+		return nil, "削除対象の文が見つからない"
+	}
+	return &operationChange{action: MergeDelete, node: target, edit: mergeEdit{offset: start, end: end, raw: true}}, ""
+}
+
+// conflict returns why change cannot be applied together with the changes
+// accepted so far, or "" when it overlaps none of them.
+func (a *operationApplier) conflict(change operationChange) string {
+	for _, prior := range a.changes {
+		if !prior.overlaps(change) {
+			continue
+		}
+		if prior.node == change.node {
+			return "同じセクションへの変更が既にある"
+		}
+		return fmt.Sprintf("「%s」への変更と重なる", prior.node.Path())
+	}
+	return ""
+}
+
+// findPath resolves a section path with the title matcher. Each element is
+// searched below the previous match. A fuzzy match of any element is returned
+// instead of the last match, so callers can tell the path was not matched exactly.
+// Path elements have no heading level, so fuzzy matches are limited to direct children.
+func (m *merger) findPath(root *SectionNode, path []string) (*SectionNode, TitleMatch) {
+	if len(path) == 0 {
+		return nil, TitleMatch{}
+	}
+	node := root
+	var match TitleMatch
+	for _, title := range path {
+		var current TitleMatch
+		node, current = m.findTarget(node, title, 0)
+		if node == nil {
+			return nil, TitleMatch{}
+		}
+		if match.Kind != TitleMatchFuzzy {
+			match = current
+		}
+	}
+	return node, match
+}
+
+// trailingBlankLine returns "\n" when the text at offset is another section,
+// to keep a blank line before it after a replacement.
+func trailingBlankLine(content string, offset int) string {
+	if offset < len(content) {
+		return "\n"
+	}
+	return ""
+}
+
+// findTextInSection finds whole lines of text within the section subtree and
+// returns their byte range including the line break, or -1 if not found.
+func findTextInSection(content string, section *SectionNode, text string) (int, int) {
+	text = strings.Trim(text, "\n")
+	if text == "" {
+		return -1, -1
+	}
+	body := content[section.BodyOffset:section.SubtreeEnd()]
+	idx := strings.Index(body, text)
+	for idx != -1 {
+		start := section.BodyOffset + idx
+		end := start + len(text)
+		// 行単位で一致する場合のみ
+		if (start == 0 || content[start-1] == '\n') && (end == len(content) || content[end] == '\n') {
+			if end < len(content) {
+				end++
+			}
+			return start, end
+		}
+		next := strings.Index(body[idx+1:], text)
+		if next == -1 {
+			break
+		}
+		idx += 1 + next
+	}
+	return -1, -1
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseOperations(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+		wantLen int
+	}{
+		{
+			name:    "valid",
+			content: `{"operations":[{"op":"add","target":"Commands","content":"- make lint","rationale":"lint was added"}]}`,
+			wantLen: 1,
+		},
+		{
+			name:    "code fence",
+			content: "```json\n{\"operations\":[{\"op\":\"delete\",\"target\":\"Old\",\"rationale\":\"removed\"}]}\n```\n",
+			wantLen: 1,
+		},
+		{
+			name:    "empty list",
+			content: `{"operations":[]}`,
+			wantLen: 0,
+		},
+		{
+			name:    "invalid op",
+			content: `{"operations":[{"op":"move","target":"A","rationale":"r"}]}`,
+			wantErr: "無効なop",
+		},
+		{
+			name:    "missing content",
+			content: `{"operations":[{"op":"replace","target":"A","rationale":"r"}]}`,
+			wantErr: "contentが必要",
+		},
+		{
+			name:    "missing rationale",
+			content: `{"operations":[{"op":"add","target":"A","content":"x"}]}`,
+			wantErr: "rationaleが空",
+		},
+		{
+			name:    "empty target",
+			content: `{"operations":[{"op":"delete","target":" > ","rationale":"r"}]}`,
+			wantErr: "targetが空",
+		},
+		{
+			name:    "unknown field",
+			content: `{"operations":[{"op":"add","target":"A","content":"x","rationale":"r","extra":1}]}`,
+			wantErr: "解析に失敗",
+		},
+		{
+			name:    "missing operations",
+			content: `{}`,
+			wantErr: "operationsがありません",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, err := ParseOperations(tt.content)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseOperations() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseOperations() unexpected error: %v", err)
+			}
+			if len(set.Operations) != tt.wantLen {
+				t.Errorf("len(Operations) = %d, want %d", len(set.Operations), tt.wantLen)
+			}
+		})
+	}
+}
+
+func TestIsStructuredSuggestion(t *testing.T) {
+	tests := []struct {
+		content string
+		want    bool
+	}{
+		{`{"operations":[]}`, true},
+		{"```json\n{\"operations\":[]}\n```", true},
+		{"## Commands\n\n- make build\n", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsStructuredSuggestion(tt.content); got != tt.want {
+			t.Errorf("IsStructuredSuggestion(%q) = %v, want %v", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestApplyOperations(t *testing.T) {
+	existing := `# Project
+
+## Architecture
+
+### Execution modes
+
+- sync
+- background with notification
+
+## Commands
+
+- make build
+`
+
+	tests := []struct {
+		name       string
+		op         Operation
+		want       string
+		wantAction MergeAction
+	}{
+		{
+			name:       "add to existing section",
+			op:         Operation{Op: OpAdd, Target: "Commands", Content: "- make test", Rationale: "r"},
+			want:       "## Commands\n\n- make build\n\n- make test\n",
+			wantAction: MergeInsert,
+		},
+		{
+			name:       "add new subsection",
+			op:         Operation{Op: OpAdd, Target: "Architecture > Hooks", Content: "SessionEnd only.", Rationale: "r"},
+			want:       "- background with notification\n\n### Hooks\n\nSessionEnd only.\n\n## Commands",
+			wantAction: MergeAppendSection,
+		},
+		{
+			name:       "add new top-level section",
+			op:         Operation{Op: OpAdd, Target: "Troubleshooting", Content: "Restart.", Rationale: "r"},
+			want:       "- make build\n\n## Troubleshooting\n\nRestart.\n",
+			wantAction: MergeAppendSection,
+		},
+		{
+			name:       "replace section body",
+			op:         Operation{Op: OpReplace, Target: "Architecture > Execution modes", Content: "- sync only", Rationale: "r"},
+			want:       "### Execution modes\n\n- sync only\n\n## Commands",
+			wantAction: MergeReplace,
+		},
+		{
+			name:       "delete section",
+			op:         Operation{Op: OpDelete, Target: "Architecture", Rationale: "r"},
+			want:       "# Project\n\n## Commands",
+			wantAction: MergeDelete,
+		},
+		{
+			name:       "delete line",
+			op:         Operation{Op: OpDelete, Target: "Execution modes", Content: "- background with notification", Rationale: "r"},
+			want:       "- sync\n\n## Commands",
+			wantAction: MergeDelete,
+		},
+		{
+			name:       "delete missing line",
+			op:         Operation{Op: OpDelete, Target: "Execution modes", Content: "- async", Rationale: "r"},
+			want:       existing,
+			wantAction: MergeSkip,
+		},
+		{
+			name:       "missing target",
+			op:         Operation{Op: OpReplace, Target: "Deployment", Content: "x", Rationale: "r"},
+			want:       existing,
+			wantAction: MergeSkip,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ApplyOperations(existing, []Operation{tt.op}, DefaultMergeOptions())
+			if !strings.Contains(result.Content, tt.want) {
+				t.Errorf("Content =\n%s\nwant to contain:\n%s", result.Content, tt.want)
+			}
+			if len(result.Decisions) != 1 || result.Decisions[0].Action != tt.wantAction {
+				t.Errorf("Decisions = %+v, want action %s", result.Decisions, tt.wantAction)
+			}
+		})
+	}
+}
+
+func TestApplyOperations_ConflictingOperations(t *testing.T) {
+	existing := "## A\n\na\n\n## B\n\nb\n"
+	ops := []Operation{
+		{Op: OpDelete, Target: "A", Rationale: "obsolete"},
+		{Op: OpAdd, Target: "A", Content: "more", Rationale: "r"},
+		{Op: OpReplace, Target: "B", Content: "b2", Rationale: "r"},
+	}
+
+	result := ApplyOperations(existing, ops, DefaultMergeOptions())
+
+	if result.Content != "## B\n\nb2\n" {
+		t.Errorf("Content = %q", result.Content)
+	}
+	if result.Decisions[1].Action != MergeSkip {
+		t.Errorf("Operation on a deleted section should be skipped: %+v", result.Decisions[1])
+	}
+	if result.Decisions[0].Rationale != "obsolete" {
+		t.Errorf("Rationale should be kept as the reason: %+v", result.Decisions[0])
+	}
+}
+
+func TestParseSuggestionFormat(t *testing.T) {
+	for _, value := range []string{"", "markdown", "Operations"} {
+		if _, err := ParseSuggestionFormat(value); err != nil {
+			t.Errorf("ParseSuggestionFormat(%q) unexpected error: %v", value, err)
+		}
+	}
+	if _, err := ParseSuggestionFormat("yaml"); err == nil {
+		t.Error("ParseSuggestionFormat(yaml) should fail")
+	}
+}
+
+func TestSplitSuggestion(t *testing.T) {
+	tests := []struct {
+		name         string
+		content      string
+		wantMarkdown string
+		wantOps      int
+		wantErr      bool
+	}{
+		{
+			name:         "markdown only",
+			content:      "## Commands\n\n- make test\n",
+			wantMarkdown: "## Commands\n\n- make test\n",
+		},
+		{
+			name:         "markdown with corrections",
+			content:      "## Commands\n\n- make test\n\n```json suggest-claude-md\n{\"operations\":[{\"op\":\"delete\",\"target\":\"Commands\",\"content\":\"- make lint\",\"rationale\":\"renamed\"}]}\n```\n",
+			wantMarkdown: "## Commands\n\n- make test\n",
+			wantOps:      1,
+		},
+		{
+			name:         "other json block is kept",
+			content:      "## Settings\n\n```json\n{\"hooks\":{}}\n```\n",
+			wantMarkdown: "## Settings\n\n```json\n{\"hooks\":{}}\n```\n",
+		},
+		{
+			name:         "json example with metadata keys is kept",
+			content:      "## API\n\nレスポンス例:\n\n```json\n{\"items\":[{\"id\":1}],\"operations\":[]}\n```\n",
+			wantMarkdown: "## API\n\nレスポンス例:\n\n```json\n{\"items\":[{\"id\":1}],\"operations\":[]}\n```\n",
+		},
+		{
+			name:         "marker with extra spaces",
+			content:      "## Commands\n\n- make test\n\n```  json   suggest-claude-md \n{\"operations\":[{\"op\":\"delete\",\"target\":\"Commands\",\"rationale\":\"r\"}]}\n```\n",
+			wantMarkdown: "## Commands\n\n- make test\n",
+			wantOps:      1,
+		},
+		{
+			name:    "invalid corrections",
+			content: "## A\n\n```json suggest-claude-md\n{\"operations\":[{\"op\":\"move\",\"target\":\"A\",\"rationale\":\"r\"}]}\n```\n",
+			wantErr: true,
+		},
+		{
+			name:    "structured",
+			content: `{"operations":[{"op":"replace","target":"A","content":"x","rationale":"r"}]}`,
+			wantOps: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			markdown, metadata, err := SplitSuggestion(tt.content)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("SplitSuggestion() should fail")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SplitSuggestion() unexpected error: %v", err)
+			}
+			if markdown != tt.wantMarkdown {
+				t.Errorf("markdown = %q, want %q", markdown, tt.wantMarkdown)
+			}
+			if len(metadata.Operations) != tt.wantOps {
+				t.Errorf("len(Operations) = %d, want %d", len(metadata.Operations), tt.wantOps)
+			}
+		})
+	}
+}
+
+func TestSplitSuggestion_ItemRationales(t *testing.T) {
+	content := "## Troubleshooting\n\nRestart the daemon.\n\n```json suggest-claude-md\n" +
+		`{"items":[{"target":"Troubleshooting","rationale":"the daemon hung twice","evidence":[{"uuid":"msg-1","timestamp":"2025-01-01T00:00:00Z"}]}]}` +
+		"\n```\n"
+
+	markdown, metadata, err := SplitSuggestion(content)
+	if err != nil {
+		t.Fatalf("SplitSuggestion() unexpected error: %v", err)
+	}
+	if strings.Contains(markdown, "rationale") {
+		t.Errorf("Metadata should not be merged into CLAUDE.md, got %q", markdown)
+	}
+
+	result := MergeSections("## Commands\n\n- make\n", markdown)
+	metadata.Annotate(result.Decisions)
+
+	decision := result.Decisions[0]
+	if decision.Rationale != "the daemon hung twice" {
+		t.Errorf("Rationale = %q", decision.Rationale)
+	}
+	if len(decision.Evidence) != 1 || decision.Evidence[0].String() != "msg-1 (2025-01-01T00:00:00Z)" {
+		t.Errorf("Evidence = %+v", decision.Evidence)
+	}
+}
+
+func TestParseOperations_Evidence(t *testing.T) {
+	valid := `{"operations":[{"op":"delete","target":"A","rationale":"r","evidence":[{"uuid":"u1"}]}]}`
+	if _, err := ParseOperations(valid); err != nil {
+		t.Errorf("ParseOperations() unexpected error: %v", err)
+	}
+
+	invalid := `{"items":[{"target":"A","rationale":"r","evidence":[{"timestamp":"t"}]}]}`
+	if _, err := ParseOperations(invalid); err == nil || !strings.Contains(err.Error(), "uuid") {
+		t.Errorf("ParseOperations() error = %v, want uuid error", err)
+	}
+}
+
+func TestApplyOperations_OverlappingOperations(t *testing.T) {
+	existing := "## A\n\na\n\n### A1\n\n- one\n- two\n\n## B\n\nb\n"
+
+	tests := []struct {
+		name        string
+		ops         []Operation
+		want        string
+		wantActions []MergeAction
+	}{
+		{
+			name: "add into a replaced section",
+			ops: []Operation{
+				{Op: OpReplace, Target: "A", Content: "new a", Rationale: "r"},
+				{Op: OpAdd, Target: "A > A1", Content: "- three", Rationale: "r"},
+			},
+			want:        "## A\n\nnew a\n\n## B\n\nb\n",
+			wantActions: []MergeAction{MergeReplace, MergeSkip},
+		},
+		{
+			name: "replace a section after adding into its subsection",
+			ops: []Operation{
+				{Op: OpAdd, Target: "A1", Content: "- three", Rationale: "r"},
+				{Op: OpReplace, Target: "A", Content: "new a", Rationale: "r"},
+			},
+			want:        "## A\n\na\n\n### A1\n\n- one\n- two\n\n- three\n\n## B\n\nb\n",
+			wantActions: []MergeAction{MergeInsert, MergeSkip},
+		},
+		{
+			name: "delete a line of a deleted section",
+			ops: []Operation{
+				{Op: OpDelete, Target: "A1", Content: "- one", Rationale: "r"},
+				{Op: OpDelete, Target: "A", Rationale: "r"},
+			},
+			want:        "## A\n\na\n\n### A1\n\n- two\n\n## B\n\nb\n",
+			wantActions: []MergeAction{MergeDelete, MergeSkip},
+		},
+		{
+			name: "delete a line of the parent inside a replaced subsection",
+			ops: []Operation{
+				{Op: OpReplace, Target: "A1", Content: "- new", Rationale: "r"},
+				{Op: OpDelete, Target: "A", Content: "- two", Rationale: "r"},
+			},
+			want:        "## A\n\na\n\n### A1\n\n- new\n\n## B\n\nb\n",
+			wantActions: []MergeAction{MergeReplace, MergeSkip},
+		},
+		{
+			name: "independent changes",
+			ops: []Operation{
+				{Op: OpDelete, Target: "A1", Content: "- one", Rationale: "r"},
+				{Op: OpDelete, Target: "A1", Content: "- two", Rationale: "r"},
+				{Op: OpAdd, Target: "A", Content: "more a", Rationale: "r"},
+				{Op: OpReplace, Target: "B", Content: "b2", Rationale: "r"},
+			},
+			want:        "## A\n\na\n\n### A1\n\n\nmore a\n\n## B\n\nb2\n",
+			wantActions: []MergeAction{MergeDelete, MergeDelete, MergeInsert, MergeReplace},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ApplyOperations(existing, tt.ops, DefaultMergeOptions())
+			if result.Content != tt.want {
+				t.Errorf("Content =\n%q\nwant:\n%q", result.Content, tt.want)
+			}
+			if len(result.Decisions) != len(tt.wantActions) {
+				t.Fatalf("Decisions = %+v", result.Decisions)
+			}
+			for i, want := range tt.wantActions {
+				if result.Decisions[i].Action != want {
+					t.Errorf("Decisions[%d] = %+v, want action %s", i, result.Decisions[i], want)
+				}
+				if want == MergeSkip && result.Decisions[i].Reason == "" {
+					t.Errorf("Decisions[%d] has no reason", i)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyOperations_DestructiveRequiresExactTitle(t *testing.T) {
+	existing := "## Testing guide\n\n- go test\n\n## Build\n\n- make\n"
+
+	tests := []struct {
+		name       string
+		op         Operation
+		wantAction MergeAction
+	}{
+		{
+			name:       "add to a similar title",
+			op:         Operation{Op: OpAdd, Target: "Testing", Content: "- make test", Rationale: "r"},
+			wantAction: MergeInsert,
+		},
+		{
+			name:       "replace a similar title",
+			op:         Operation{Op: OpReplace, Target: "Testing", Content: "- make test", Rationale: "r"},
+			wantAction: MergeSkip,
+		},
+		{
+			name:       "delete a similar title",
+			op:         Operation{Op: OpDelete, Target: "Testing", Rationale: "r"},
+			wantAction: MergeSkip,
+		},
+		{
+			name:       "replace an alias",
+			op:         Operation{Op: OpReplace, Target: "ビルド", Content: "- make build", Rationale: "r"},
+			wantAction: MergeReplace,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ApplyOperations(existing, []Operation{tt.op}, DefaultMergeOptions())
+			if len(result.Decisions) != 1 || result.Decisions[0].Action != tt.wantAction {
+				t.Errorf("Decisions = %+v, want action %s", result.Decisions, tt.wantAction)
+			}
+			if tt.wantAction == MergeSkip && result.Content != existing {
+				t.Errorf("Content = %q, want unchanged", result.Content)
+			}
+		})
+	}
+}
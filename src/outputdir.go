@@ -0,0 +1,245 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	outputFilePrefix = "suggest-claude-md-"
+	// promptFilePattern is the name of the temporary prompt files passed to claude
+	promptFilePattern = "prompt-*.md"
+
+	// privateDirPerm is the permission for the per-user output directory
+	privateDirPerm os.FileMode = 0o700
+	// privateFilePerm is the permission for logs, suggestions and prompts
+	privateFilePerm os.FileMode = 0o600
+
+	defaultRetentionDays  = 30
+	defaultRetentionFiles = 200
+)
+
+// RetentionPolicy controls pruning of old logs and suggestion files.
+// Zero values disable the corresponding limit.
+type RetentionPolicy struct {
+	MaxAgeDays int
+	MaxFiles   int
+}
+
+// ResolveOutputDir returns the per-user private directory for logs and suggestions.
+// XDG_RUNTIME_DIR is preferred because it is already private to the user,
+// otherwise a uid-suffixed directory under TMPDIR (or /tmp) is used.
+func ResolveOutputDir(getenv func(string) string) string {
+	if runtimeDir := getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		return filepath.Join(runtimeDir, commandName)
+	}
+
+	tmpDir := getenv("TMPDIR")
+	if tmpDir == "" {
+		tmpDir = "/tmp"
+	}
+	return filepath.Join(tmpDir, fmt.Sprintf("%s%d", outputFilePrefix, os.Getuid()))
+}
+
+// ResolveStateDir returns the per-user directory for data that must survive reboots
+// and logouts, such as the usage records: $XDG_STATE_HOME/suggest-claude-md, or
+// ~/.local/state/suggest-claude-md. The output directory is used only when the
+// home directory is unknown.
+func ResolveStateDir(getenv func(string) string) string {
+	if stateDir := getenv("XDG_STATE_HOME"); filepath.IsAbs(stateDir) {
+		return filepath.Join(stateDir, commandName)
+	}
+	home := getenv("HOME")
+	if home == "" {
+		var err error
+		if home, err = os.UserHomeDir(); err != nil {
+			return ResolveOutputDir(getenv)
+		}
+	}
+	return filepath.Join(home, ".local", "state", commandName)
+}
+
+// EnsurePrivateDir creates dir with 0700 permission, or tightens an existing one.
+// Symlinks and directories owned by another user are rejected so that another user
+// cannot redirect or read our output.
+func EnsurePrivateDir(dir string) error {
+	if err := os.MkdirAll(dir, privateDirPerm); err != nil {
+		return fmt.Errorf("出力ディレクトリの作成に失敗: %w", err)
+	}
+
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return fmt.Errorf("出力ディレクトリの確認に失敗: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("出力ディレクトリがディレクトリではありません: %s", dir)
+	}
+	if uid, ok := fileOwner(info); ok && uid != os.Getuid() {
+		return fmt.Errorf("出力ディレクトリの所有者が異なります: %s (uid: %d)", dir, uid)
+	}
+
+	if info.Mode().Perm() != privateDirPerm {
+		if err := os.Chmod(dir, privateDirPerm); err != nil {
+			return fmt.Errorf("出力ディレクトリの権限変更に失敗: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// createPrivateFile creates (or truncates) a file readable only by the current user.
+func createPrivateFile(path string) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, privateFilePerm)
+	if err != nil {
+		return err
+	}
+	if err := file.Chmod(privateFilePerm); err != nil {
+		_ = file.Close() // nolint:errcheck // Best-effort cleanup in error path
+		return err
+	}
+	return file.Close()
+}
+
+// RetentionPolicyFromEnv builds a retention policy from environment variables.
+// SUGGEST_CLAUDE_MD_RETENTION_DAYS and SUGGEST_CLAUDE_MD_RETENTION_FILES override the defaults;
+// 0 disables the limit.
+func RetentionPolicyFromEnv(getenv func(string) string) RetentionPolicy {
+	policy := RetentionPolicy{
+		MaxAgeDays: defaultRetentionDays,
+		MaxFiles:   defaultRetentionFiles,
+	}
+	if days, err := strconv.Atoi(getenv("SUGGEST_CLAUDE_MD_RETENTION_DAYS")); err == nil && days >= 0 {
+		policy.MaxAgeDays = days
+	}
+	if files, err := strconv.Atoi(getenv("SUGGEST_CLAUDE_MD_RETENTION_FILES")); err == nil && files >= 0 {
+		policy.MaxFiles = files
+	}
+	return policy
+}
+
+// PruneOutputs removes logs, suggestions and prompt files left by interrupted runs in
+// dir that violate the retention policy.
+// Files older than MaxAgeDays are removed first, then the oldest files beyond MaxFiles.
+// It returns the paths that were removed.
+func PruneOutputs(dir string, policy RetentionPolicy, now time.Time) ([]string, error) {
+	files, err := listOutputFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	kept := 0
+	for _, f := range files {
+		expired := policy.MaxAgeDays > 0 && now.Sub(f.modTime) > time.Duration(policy.MaxAgeDays)*24*time.Hour
+		overflow := policy.MaxFiles > 0 && kept >= policy.MaxFiles
+		if !expired && !overflow {
+			kept++
+			continue
+		}
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			return removed, fmt.Errorf("古いファイルの削除に失敗: %w", err)
+		}
+		removed = append(removed, f.path)
+	}
+
+	return removed, nil
+}
+
+// outputFile is a log, suggestion or prompt file subject to the retention policy.
+type outputFile struct {
+	path    string
+	modTime time.Time
+}
+
+// listOutputFiles returns the logs, suggestions and prompt files in dir, newest first.
+func listOutputFiles(dir string) ([]outputFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("出力ディレクトリの読み込みに失敗: %w", err)
+	}
+
+	var files []outputFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !isOutputFileName(name) {
+			continue
+		}
+		info, infoErr := entry.Info()
+		if infoErr != nil {
+			continue
+		}
+		files = append(files, outputFile{path: filepath.Join(dir, name), modTime: info.ModTime()})
+	}
+
+	// 新しい順に並べる
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.After(files[j].modTime)
+	})
+	return files, nil
+}
+
+// isOutputFileName reports whether a file name is a log, suggestion or prompt file.
+func isOutputFileName(name string) bool {
+	if prompt, _ := filepath.Match(promptFilePattern, name); prompt {
+		return true
+	}
+	ext := filepath.Ext(name)
+	return strings.HasPrefix(name, outputFilePrefix) && (ext == ".log" || ext == ".md" || ext == ".json")
+}
+
+// suggestionTimestampSuffixLen is the length of the "-20060102-150405" suffix of output files.
+const suggestionTimestampSuffixLen = len("-20060102-150405")
+
+// AnalyzedConversations returns the conversation IDs that have a valid suggestion file
+// in dir. Suggestions that failed validation and empty files of interrupted runs do not
+// count. A missing directory yields an empty set.
+func AnalyzedConversations(dir string) (map[string]bool, error) {
+	analyzed := make(map[string]bool)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return analyzed, nil
+		}
+		return nil, fmt.Errorf("出力ディレクトリの読み込みに失敗: %w", err)
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, outputFilePrefix) || IsInvalidSuggestionPath(name) {
+			continue
+		}
+		id, format, ok := suggestionFileConversation(name)
+		if !ok || analyzed[id] {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || !ValidateSuggestion(string(content), format).Valid() {
+			continue
+		}
+		analyzed[id] = true
+	}
+	return analyzed, nil
+}
+
+// suggestionFileConversation returns the conversation ID and the format of a
+// suggestion file name such as suggest-claude-md-<id>-20060102-150405.md.
+func suggestionFileConversation(name string) (string, SuggestionFormat, bool) {
+	format := FormatMarkdown
+	switch filepath.Ext(name) {
+	case ".md":
+	case ".json":
+		format = FormatOperations
+	default:
+		return "", "", false
+	}
+	base := strings.TrimPrefix(strings.TrimSuffix(name, filepath.Ext(name)), outputFilePrefix)
+	if len(base) <= suggestionTimestampSuffixLen {
+		return "", "", false
+	}
+	return base[:len(base)-suggestionTimestampSuffixLen], format, true
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolveOutputDir(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{
+			name: "XDG_RUNTIME_DIR takes precedence",
+			env:  map[string]string{"XDG_RUNTIME_DIR": "/run/user/1000", "TMPDIR": "/var/tmp"},
+			want: "/run/user/1000/suggest-claude-md",
+		},
+		{
+			name: "TMPDIR with uid suffix",
+			env:  map[string]string{"TMPDIR": "/var/tmp"},
+			want: filepath.Join("/var/tmp", "suggest-claude-md-"+strconv.Itoa(os.Getuid())),
+		},
+		{
+			name: "fallback to /tmp",
+			env:  map[string]string{},
+			want: filepath.Join("/tmp", "suggest-claude-md-"+strconv.Itoa(os.Getuid())),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ResolveOutputDir(func(key string) string { return tt.env[key] })
+			if got != tt.want {
+				t.Errorf("ResolveOutputDir() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveStateDir(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{
+			name: "XDG_STATE_HOME takes precedence",
+			env:  map[string]string{"XDG_STATE_HOME": "/home/user/state", "HOME": "/home/user", "XDG_RUNTIME_DIR": "/run/user/1000"},
+			want: "/home/user/state/suggest-claude-md",
+		},
+		{
+			name: "relative XDG_STATE_HOME is ignored",
+			env:  map[string]string{"XDG_STATE_HOME": "state", "HOME": "/home/user"},
+			want: "/home/user/.local/state/suggest-claude-md",
+		},
+		{
+			name: "not under XDG_RUNTIME_DIR",
+			env:  map[string]string{"HOME": "/home/user", "XDG_RUNTIME_DIR": "/run/user/1000"},
+			want: "/home/user/.local/state/suggest-claude-md",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ResolveStateDir(func(key string) string { return tt.env[key] })
+			if got != tt.want {
+				t.Errorf("ResolveStateDir() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnsurePrivateDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "out")
+
+	if err := EnsurePrivateDir(dir); err != nil {
+		t.Fatalf("EnsurePrivateDir() error = %v", err)
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatalf("Failed to stat dir: %v", err)
+	}
+	if info.Mode().Perm() != 0o700 {
+		t.Errorf("Permission = %o, want 700", info.Mode().Perm())
+	}
+
+	// 既存ディレクトリの権限が緩い場合は0700に変更される
+	if err := os.Chmod(dir, 0o755); err != nil {
+		t.Fatalf("Failed to chmod: %v", err)
+	}
+	if err := EnsurePrivateDir(dir); err != nil {
+		t.Fatalf("EnsurePrivateDir() error = %v", err)
+	}
+	info, _ = os.Stat(dir) // nolint:errcheck // Checked above
+	if info.Mode().Perm() != 0o700 {
+		t.Errorf("Permission after tighten = %o, want 700", info.Mode().Perm())
+	}
+}
+
+func TestEnsurePrivateDir_RejectsSymlink(t *testing.T) {
+	tmpDir := t.TempDir()
+	target := filepath.Join(tmpDir, "target")
+	if err := os.Mkdir(target, 0o700); err != nil {
+		t.Fatalf("Failed to create target: %v", err)
+	}
+	link := filepath.Join(tmpDir, "link")
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("Cannot create symlink: %v", err)
+	}
+
+	err := EnsurePrivateDir(link)
+	if err == nil || !strings.Contains(err.Error(), "ディレクトリではありません") {
+		t.Errorf("EnsurePrivateDir() should reject symlink, got: %v", err)
+	}
+}
+
+func TestEnsurePrivateDir_RejectsOtherOwner(t *testing.T) {
+	// rootでなければ / は他のユーザーが所有している
+	dir := "/"
+	if os.Getuid() == 0 {
+		dir = filepath.Join(t.TempDir(), "out")
+		if err := os.Mkdir(dir, 0o700); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.Chown(dir, 65534, 65534); err != nil {
+			t.Skipf("Cannot change owner: %v", err)
+		}
+	}
+
+	err := EnsurePrivateDir(dir)
+	if err == nil || !strings.Contains(err.Error(), "所有者が異なります") {
+		t.Errorf("EnsurePrivateDir() should reject a directory of another user, got: %v", err)
+	}
+}
+
+func TestCreatePrivateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	if err := createPrivateFile(path); err != nil {
+		t.Fatalf("createPrivateFile() error = %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat file: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("Permission = %o, want 600", info.Mode().Perm())
+	}
+}
+
+func TestRetentionPolicyFromEnv(t *testing.T) {
+	policy := RetentionPolicyFromEnv(func(string) string { return "" })
+	if policy.MaxAgeDays != defaultRetentionDays || policy.MaxFiles != defaultRetentionFiles {
+		t.Errorf("Default policy = %+v", policy)
+	}
+
+	env := map[string]string{
+		"SUGGEST_CLAUDE_MD_RETENTION_DAYS":  "7",
+		"SUGGEST_CLAUDE_MD_RETENTION_FILES": "0",
+	}
+	policy = RetentionPolicyFromEnv(func(key string) string { return env[key] })
+	if policy.MaxAgeDays != 7 || policy.MaxFiles != 0 {
+		t.Errorf("Policy from env = %+v, want {7 0}", policy)
+	}
+
+	policy = RetentionPolicyFromEnv(func(string) string { return "invalid" })
+	if policy.MaxAgeDays != defaultRetentionDays {
+		t.Errorf("Invalid value should keep default, got %+v", policy)
+	}
+}
+
+func TestPruneOutputs(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	files := map[string]time.Time{
+		"suggest-claude-md-a-1.md":  now.Add(-1 * time.Hour),
+		"suggest-claude-md-a-1.log": now.Add(-2 * time.Hour),
+		"suggest-claude-md-b-1.md":  now.Add(-3 * time.Hour),
+		"suggest-claude-md-old.log": now.Add(-40 * 24 * time.Hour),
+		"prompt-123.md":             now.Add(-40 * 24 * time.Hour),
+		"unrelated.md":              now.Add(-40 * 24 * time.Hour),
+	}
+	for name, modTime := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("x"), 0o600); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("Failed to set mtime: %v", err)
+		}
+	}
+
+	removed, err := PruneOutputs(dir, RetentionPolicy{MaxAgeDays: 30, MaxFiles: 2}, now)
+	if err != nil {
+		t.Fatalf("PruneOutputs() error = %v", err)
+	}
+	if len(removed) != 3 {
+		t.Errorf("Removed %d files, want 3: %v", len(removed), removed)
+	}
+
+	for _, name := range []string{"suggest-claude-md-a-1.md", "suggest-claude-md-a-1.log", "unrelated.md"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s should be kept: %v", name, err)
+		}
+	}
+	for _, name := range []string{"suggest-claude-md-b-1.md", "suggest-claude-md-old.log", "prompt-123.md"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s should be removed", name)
+		}
+	}
+}
+
+func TestPruneOutputs_Disabled(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "suggest-claude-md-old.md")
+	if err := os.WriteFile(path, []byte("x"), 0o600); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	old := time.Now().Add(-365 * 24 * time.Hour)
+	_ = os.Chtimes(path, old, old) // nolint:errcheck // Best-effort in test
+
+	removed, err := PruneOutputs(dir, RetentionPolicy{}, time.Now())
+	if err != nil {
+		t.Fatalf("PruneOutputs() error = %v", err)
+	}
+	if len(removed) != 0 {
+		t.Errorf("No files should be removed when policy is disabled, got %v", removed)
+	}
+}
+
+func TestPruneOutputs_MissingDir(t *testing.T) {
+	_, err := PruneOutputs("/nonexistent/dir", RetentionPolicy{MaxFiles: 1}, time.Now())
+	if err == nil {
+		t.Error("PruneOutputs() should return error for missing directory")
+	}
+}
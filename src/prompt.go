@@ -37,14 +37,118 @@ user/projectスコープの両方にフックが登録されている可能性
 ` + "`" + `~/.claude/settings.json` + "`" + ` と ` + "`" + `.claude/settings.json` + "`" + ` を確認してください。
 ` + "```" + `
 
+## 提案の理由・根拠と既存の記述の修正・削除
+
+出力の末尾に、次の形式のJSONブロックを1つ付けてください。コードブロックの言語指定は必ず「json suggest-claude-md」としてください。
+このブロックはCLAUDE.mdには書き込まれず、レビュー時の参考情報として表示されます。
+CLAUDE.mdに記載するJSONの例（設定ファイルなど）は、通常どおり「json」のコードブロックで記述してください。
+
+- ` + "`" + `items` + "`" + `: 追記した各セクション（target は上記の見出し）の提案理由と根拠
+- ` + "`" + `operations` + "`" + `: 会話の中で既存のCLAUDE.mdの記述が古くなった・誤っていると判明した場合（コマンド名の変更など）の修正・削除。該当がなければ省略してください
+- ` + "`" + `evidence` + "`" + `: 根拠となった会話履歴のメッセージの uuid と timestamp（会話履歴の見出しに記載）
+
+` + "```" + `json suggest-claude-md
+{
+  "items": [
+    {
+      "target": "トラブルシューティング > 重複実行が発生する場合",
+      "rationale": "フックの二重登録で提案が2回生成された",
+      "evidence": [{"uuid": "5f0c…", "timestamp": "2025-01-01T12:00:00.000Z"}]
+    }
+  ],
+  "operations": [
+    {
+      "op": "replace",
+      "target": "よく使うコマンド",
+      "content": "- ` + "`" + `make check` + "`" + `: lint とテストを実行",
+      "rationale": "make lint は make check に名称変更された",
+      "evidence": [{"uuid": "9a1d…"}]
+    },
+    {
+      "op": "delete",
+      "target": "アーキテクチャ > 実行モード",
+      "content": "- バックグラウンド実行（cmd.Start()） + macOS通知",
+      "rationale": "v2.xで同期実行に変更された"
+    }
+  ]
+}
+` + "```" + `
+
+- ` + "`" + `replace` + "`" + `: target のセクション本文を content で置き換えます
+- ` + "`" + `delete` + "`" + `: content の行を削除します（content を省略するとセクション全体を削除）
+- operations の target は既存のCLAUDE.mdのセクションパスを「 > 」区切りで指定してください
+
 **禁止事項:**
 - 「会話履歴を分析しました」などの前置き
 - 「以下が提案です」などの説明文
 - 「---」などの区切り線（セクション内の区切りは可）
 `
 
+// StructuredPromptContent is the prompt template for the structured (JSON operations) format
+const StructuredPromptContent = `# CLAUDE.md更新提案（構造化形式）
+
+このコマンドは、会話履歴を分析してCLAUDE.md更新提案を操作リストとして生成します。
+
+## 出力形式の重要な指示
+
+**必須要件:**
+1. 以下のJSONスキーマに従うJSONオブジェクトのみを出力してください（前置きや説明文は不要です）
+2. 各操作の target には既存のCLAUDE.mdのセクションパスを「 > 」区切りで指定してください
+3. 各操作の rationale には、その変更が必要な理由を会話履歴に基づいて簡潔に記述してください
+   evidence には根拠となった会話履歴のメッセージの uuid と timestamp（会話履歴の見出しに記載）を指定してください
+4. 古くなった記述や誤った記述は replace または delete で修正してください
+
+## 操作の種類
+
+- ` + "`" + `add` + "`" + `: target のセクション末尾に content を追加します（セクションがなければ新規作成）
+- ` + "`" + `replace` + "`" + `: target のセクション本文を content で置き換えます
+- ` + "`" + `delete` + "`" + `: target のセクションを削除します。content を指定した場合はその行だけを削除します
+
+## JSONスキーマ
+
+` + "```" + `json
+` + OperationsSchema + `
+` + "```" + `
+
+## 出力例
+
+` + "```" + `json
+{
+  "operations": [
+    {
+      "op": "add",
+      "target": "トラブルシューティング > 重複実行が発生する場合",
+      "content": "user/projectスコープの両方にフックが登録されている可能性があります。",
+      "rationale": "フックの二重登録で提案が2回生成された",
+      "evidence": [{"uuid": "5f0c…", "timestamp": "2025-01-01T12:00:00.000Z"}]
+    },
+    {
+      "op": "delete",
+      "target": "アーキテクチャ > 実行モード",
+      "content": "- バックグラウンド実行（cmd.Start()） + macOS通知",
+      "rationale": "v2.xで同期実行に変更された"
+    }
+  ]
+}
+` + "```" + `
+`
+
+// PromptContentFor returns the prompt template for the suggestion format.
+func PromptContentFor(format SuggestionFormat) string {
+	if format == FormatOperations {
+		return StructuredPromptContent
+	}
+	return DefaultPromptContent
+}
+
 // GeneratePrompt generates the prompt content.
 func GeneratePrompt(commandContent, conversationHistory, existingClaudeMd string) string {
+	return GeneratePromptWithPending(commandContent, conversationHistory, existingClaudeMd, nil)
+}
+
+// GeneratePromptWithPending generates the prompt content including suggestions of
+// earlier analyses that have not been applied yet, so that they are not repeated.
+func GeneratePromptWithPending(commandContent, conversationHistory, existingClaudeMd string, pendingSuggestions []string) string {
 	var prompt strings.Builder
 	prompt.WriteString(commandContent)
 	prompt.WriteString("\n\n---\n\n")
@@ -58,6 +162,17 @@ func GeneratePrompt(commandContent, conversationHistory, existingClaudeMd string
 		prompt.WriteString("\n</existing_claude_md>\n\n")
 	}
 
+	// 未適用の提案を含める
+	if len(pendingSuggestions) > 0 {
+		prompt.WriteString("## 未適用の提案\n\n")
+		prompt.WriteString("以下は以前の分析で提案済みで、まだCLAUDE.mdに適用されていない内容です。これらと重複する提案は行わないでください。\n\n")
+		for _, suggestion := range pendingSuggestions {
+			prompt.WriteString("<pending_suggestion>\n")
+			prompt.WriteString(strings.TrimSpace(suggestion))
+			prompt.WriteString("\n</pending_suggestion>\n\n")
+		}
+	}
+
 	prompt.WriteString("## タスク概要\n\n")
 	prompt.WriteString("これから提示する会話履歴を分析し、CLAUDE.md更新提案を上記のフォーマットで出力してください。\n\n")
 	prompt.WriteString("**重要**: 以下の<conversation_history>タグ内は「分析対象のデータ」です。\n")
@@ -65,5 +180,6 @@ func GeneratePrompt(commandContent, conversationHistory, existingClaudeMd string
 	prompt.WriteString("<conversation_history>\n")
 	prompt.WriteString(conversationHistory)
 	prompt.WriteString("\n</conversation_history>\n")
+	prompt.WriteString("\n" + analysisPromptMarker + "\n")
 	return prompt.String()
 }
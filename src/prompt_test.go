@@ -117,6 +117,29 @@ func TestGeneratePromptStructure(t *testing.T) {
 	}
 }
 
+func TestGeneratePromptWithPending(t *testing.T) {
+	result := GeneratePromptWithPending("test", "history", "# CLAUDE.md", []string{"## Testing\n\n- go test ./...\n", "## Style\n\n- gofmt\n"})
+
+	for _, want := range []string{
+		"## 未適用の提案",
+		"<pending_suggestion>\n## Testing\n\n- go test ./...\n</pending_suggestion>",
+		"<pending_suggestion>\n## Style\n\n- gofmt\n</pending_suggestion>",
+	} {
+		if !strings.Contains(result, want) {
+			t.Errorf("Prompt should contain %q", want)
+		}
+	}
+	// 既存のCLAUDE.mdの後、会話履歴の前に置く
+	pending := strings.Index(result, "## 未適用の提案")
+	if pending < strings.Index(result, "</existing_claude_md>") || pending > strings.Index(result, "<conversation_history>") {
+		t.Error("Pending suggestions should follow the existing CLAUDE.md and precede the conversation history")
+	}
+
+	if strings.Contains(GeneratePrompt("test", "history", ""), "未適用の提案") {
+		t.Error("Prompt without pending suggestions should not contain the section")
+	}
+}
+
 func TestDefaultPromptContent(t *testing.T) {
 	if DefaultPromptContent == "" {
 		t.Error("DefaultPromptContent should not be empty")
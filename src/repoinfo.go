@@ -0,0 +1,566 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// maxSummaryCommands is the number of commands of each source included in a project summary.
+	maxSummaryCommands = 20
+	// maxCICommands is the number of CI steps included in a project summary.
+	maxCICommands = 15
+	// maxReadmeExcerpt is the number of characters of the README introduction included.
+	maxReadmeExcerpt = 600
+	// maxSummaryDirectories is the number of top-level directories included.
+	maxSummaryDirectories = 20
+)
+
+// ProjectCommand is a command found in the repository, such as a make target or npm script.
+type ProjectCommand struct {
+	Command     string
+	Description string
+}
+
+// CIConfig is a CI configuration file and the commands it runs.
+type CIConfig struct {
+	Path     string // relative to the project root
+	Name     string
+	Commands []string
+}
+
+// ProjectSummary describes a repository from its manifests, task runners, CI config and README.
+type ProjectSummary struct {
+	Name          string
+	Description   string
+	Stack         []string // languages, runtimes and tools with versions
+	Commands      []ProjectCommand
+	CI            []CIConfig
+	Directories   []string
+	ReadmeTitle   string
+	ReadmeExcerpt string
+	Sources       []string // files the summary was built from
+}
+
+var (
+	goModuleRegex     = regexp.MustCompile(`^module\s+(\S+)`)
+	goVersionRegex    = regexp.MustCompile(`^go\s+(\S+)`)
+	makeTargetRegex   = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9_./ -]*?)\s*:([^=].*)?$`)
+	makeHelpRegex     = regexp.MustCompile(`##\s*(.+)$`)
+	tomlTableRegex    = regexp.MustCompile(`^\[\s*([^\[\]]+?)\s*\]$`)
+	tomlKeyValueRegex = regexp.MustCompile(`^("?[A-Za-z0-9_.:/-]+"?)\s*=\s*(.+)$`)
+	tomlStringRegex   = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"|'([^']*)'`)
+	tomlInlineRegex   = regexp.MustCompile(`\b(run|description)\s*=\s*("(?:[^"\\]|\\.)*"|'[^']*')`)
+	ciRunRegex        = regexp.MustCompile(`^(\s*)(?:-\s+)?(run|script|before_script|command):\s*(.*)$`)
+	ciNameRegex       = regexp.MustCompile(`^name:\s*(.+)$`)
+)
+
+// ignoredSummaryDirs are top-level directories that do not describe the project layout.
+var ignoredSummaryDirs = map[string]bool{
+	"node_modules": true, "vendor": true, "dist": true, "build": true, "tmp": true, "coverage": true,
+}
+
+// InspectRepository builds a summary of the repository at projectRoot.
+// Missing files are skipped; only unreadable ones are reported as errors.
+func InspectRepository(projectRoot string) (ProjectSummary, error) {
+	summary := ProjectSummary{Name: filepath.Base(projectRoot)}
+	inspectors := []func(string, *ProjectSummary) error{
+		inspectGoMod,
+		inspectPackageJSON,
+		inspectMakefile,
+		inspectMiseToml,
+		inspectCI,
+		inspectReadme,
+		inspectDirectories,
+	}
+	for _, inspect := range inspectors {
+		if err := inspect(projectRoot, &summary); err != nil {
+			return summary, err
+		}
+	}
+	return summary, nil
+}
+
+// readProjectFile reads a file of the project; ok is false when it does not exist.
+func readProjectFile(projectRoot, name string) (content string, ok bool, err error) {
+	data, err := os.ReadFile(filepath.Join(projectRoot, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("%sの読み込みに失敗: %w", name, err)
+	}
+	return string(data), true, nil
+}
+
+func inspectGoMod(projectRoot string, summary *ProjectSummary) error {
+	content, ok, err := readProjectFile(projectRoot, "go.mod")
+	if !ok {
+		return err
+	}
+	summary.Sources = append(summary.Sources, "go.mod")
+
+	stack := "Go"
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if matches := goModuleRegex.FindStringSubmatch(line); matches != nil {
+			summary.Name = matches[1]
+		}
+		if matches := goVersionRegex.FindStringSubmatch(line); matches != nil {
+			stack = "Go " + matches[1]
+		}
+	}
+	summary.Stack = append(summary.Stack, stack)
+	return nil
+}
+
+func inspectPackageJSON(projectRoot string, summary *ProjectSummary) error {
+	content, ok, err := readProjectFile(projectRoot, "package.json")
+	if !ok {
+		return err
+	}
+	summary.Sources = append(summary.Sources, "package.json")
+
+	var pkg struct {
+		Name           string            `json:"name"`
+		Description    string            `json:"description"`
+		Scripts        map[string]string `json:"scripts"`
+		PackageManager string            `json:"packageManager"`
+		Engines        map[string]string `json:"engines"`
+	}
+	if err := json.Unmarshal([]byte(content), &pkg); err != nil {
+		return fmt.Errorf("package.jsonの解析に失敗: %w", err)
+	}
+	if pkg.Name != "" {
+		summary.Name = pkg.Name
+	}
+	if summary.Description == "" {
+		summary.Description = pkg.Description
+	}
+
+	stack := "Node.js"
+	if version := pkg.Engines["node"]; version != "" {
+		stack += " " + version
+	}
+	manager := packageManager(projectRoot, pkg.PackageManager)
+	summary.Stack = append(summary.Stack, fmt.Sprintf("%s（%s）", stack, manager))
+
+	names := make([]string, 0, len(pkg.Scripts))
+	for name := range pkg.Scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		if i >= maxSummaryCommands {
+			break
+		}
+		summary.Commands = append(summary.Commands, ProjectCommand{
+			Command:     fmt.Sprintf("%s run %s", manager, name),
+			Description: pkg.Scripts[name],
+		})
+	}
+	return nil
+}
+
+// packageManager returns the Node.js package manager used by the project.
+func packageManager(projectRoot, declared string) string {
+	if name, _, _ := strings.Cut(declared, "@"); name != "" {
+		return name
+	}
+	lockFiles := []struct{ file, manager string }{
+		{"pnpm-lock.yaml", "pnpm"},
+		{"yarn.lock", "yarn"},
+		{"bun.lockb", "bun"},
+		{"bun.lock", "bun"},
+	}
+	for _, lock := range lockFiles {
+		if _, err := os.Stat(filepath.Join(projectRoot, lock.file)); err == nil {
+			return lock.manager
+		}
+	}
+	return "npm"
+}
+
+func inspectMakefile(projectRoot string, summary *ProjectSummary) error {
+	for _, name := range []string{"Makefile", "makefile", "GNUmakefile"} {
+		content, ok, err := readProjectFile(projectRoot, name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		summary.Sources = append(summary.Sources, name)
+		summary.Commands = append(summary.Commands, makeTargets(content)...)
+		return nil
+	}
+	return nil
+}
+
+// makeTargets returns the explicit targets of a Makefile. The description is taken
+// from a trailing "## ..." comment or the comment line just above the target.
+func makeTargets(content string) []ProjectCommand {
+	var commands []ProjectCommand
+	seen := make(map[string]bool)
+	comment := ""
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(line, "#") {
+			comment = strings.TrimSpace(strings.TrimLeft(line, "#"))
+			continue
+		}
+		matches := makeTargetRegex.FindStringSubmatch(line)
+		if matches == nil || strings.HasPrefix(line, "\t") {
+			comment = ""
+			continue
+		}
+		description := comment
+		if help := makeHelpRegex.FindStringSubmatch(matches[2]); help != nil {
+			description = strings.TrimSpace(help[1])
+		}
+		comment = ""
+
+		for _, target := range strings.Fields(matches[1]) {
+			if strings.HasPrefix(target, ".") || seen[target] || len(commands) >= maxSummaryCommands {
+				continue
+			}
+			seen[target] = true
+			commands = append(commands, ProjectCommand{Command: "make " + target, Description: description})
+		}
+	}
+	return commands
+}
+
+func inspectMiseToml(projectRoot string, summary *ProjectSummary) error {
+	for _, name := range []string{".mise.toml", "mise.toml"} {
+		content, ok, err := readProjectFile(projectRoot, name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		summary.Sources = append(summary.Sources, name)
+		tools, tasks := parseMiseToml(content)
+		if len(tools) > 0 {
+			summary.Stack = append(summary.Stack, "mise: "+strings.Join(tools, ", "))
+		}
+		summary.Commands = append(summary.Commands, tasks...)
+		return nil
+	}
+	return nil
+}
+
+// parseMiseToml extracts the tools and tasks of a mise configuration. Only the
+// subset of TOML used by mise configurations is understood.
+func parseMiseToml(content string) (tools []string, tasks []ProjectCommand) {
+	table := ""
+	taskIndex := make(map[string]int)
+	task := func(name string) *ProjectCommand {
+		name = strings.Trim(name, `"'`)
+		if i, ok := taskIndex[name]; ok {
+			return &tasks[i]
+		}
+		taskIndex[name] = len(tasks)
+		tasks = append(tasks, ProjectCommand{Command: "mise run " + name})
+		return &tasks[len(tasks)-1]
+	}
+
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if matches := tomlTableRegex.FindStringSubmatch(line); matches != nil {
+			table = matches[1]
+			if name, ok := strings.CutPrefix(table, "tasks."); ok {
+				task(name)
+			}
+			continue
+		}
+		matches := tomlKeyValueRegex.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		key, value := strings.Trim(matches[1], `"`), matches[2]
+
+		switch {
+		case table == "tools":
+			tools = append(tools, strings.TrimSpace(key+" "+strings.Join(tomlStrings(value), " ")))
+		case table == "tasks":
+			t := task(key)
+			if strings.HasPrefix(value, "{") {
+				for _, field := range tomlInlineRegex.FindAllStringSubmatch(value, -1) {
+					setTaskField(t, field[1], tomlStrings(field[2]))
+				}
+			} else {
+				setTaskField(t, "run", tomlStrings(value))
+			}
+		case strings.HasPrefix(table, "tasks."):
+			setTaskField(task(strings.TrimPrefix(table, "tasks.")), key, tomlStrings(value))
+		}
+	}
+	if len(tasks) > maxSummaryCommands {
+		tasks = tasks[:maxSummaryCommands]
+	}
+	return tools, tasks
+}
+
+// setTaskField sets the description of a mise task, falling back to its run command.
+func setTaskField(task *ProjectCommand, key string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+	switch key {
+	case "description":
+		task.Description = values[0]
+	case "run":
+		if task.Description == "" {
+			task.Description = strings.Join(values, " && ")
+		}
+	}
+}
+
+// tomlStrings returns the string literals of a TOML value (a string or an array of strings).
+func tomlStrings(value string) []string {
+	var values []string
+	for _, matches := range tomlStringRegex.FindAllStringSubmatch(value, -1) {
+		if matches[1] != "" {
+			values = append(values, strings.ReplaceAll(matches[1], `\"`, `"`))
+		} else {
+			values = append(values, matches[2])
+		}
+	}
+	return values
+}
+
+func inspectCI(projectRoot string, summary *ProjectSummary) error {
+	paths, err := filepath.Glob(filepath.Join(projectRoot, ".github", "workflows", "*.y*ml"))
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{".gitlab-ci.yml", filepath.Join(".circleci", "config.yml")} {
+		paths = append(paths, filepath.Join(projectRoot, name))
+	}
+
+	for _, path := range paths {
+		rel, _ := filepath.Rel(projectRoot, path) // nolint:errcheck // path is always under projectRoot
+		content, ok, err := readProjectFile(projectRoot, rel)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		summary.Sources = append(summary.Sources, rel)
+		name, commands := ciCommands(content)
+		summary.CI = append(summary.CI, CIConfig{Path: rel, Name: name, Commands: commands})
+	}
+	return nil
+}
+
+// ciCommands returns the workflow name and the shell commands of a CI configuration:
+// run (GitHub Actions), script (GitLab CI) and command (CircleCI) steps,
+// including block scalars and lists.
+func ciCommands(content string) (string, []string) {
+	name := ""
+	var commands []string
+	seen := make(map[string]bool)
+	add := func(command string) {
+		command = strings.Trim(strings.TrimSpace(command), `"'`)
+		if command == "" || seen[command] || len(commands) >= maxCICommands {
+			return
+		}
+		seen[command] = true
+		commands = append(commands, command)
+	}
+
+	lines := strings.Split(content, "\n")
+	for i := 0; i < len(lines); i++ {
+		if matches := ciNameRegex.FindStringSubmatch(lines[i]); matches != nil && name == "" {
+			name = strings.Trim(strings.TrimSpace(matches[1]), `"'`)
+			continue
+		}
+		matches := ciRunRegex.FindStringSubmatch(lines[i])
+		if matches == nil {
+			continue
+		}
+		value := strings.TrimSpace(matches[3])
+		if value != "" && !strings.HasPrefix(value, "|") && !strings.HasPrefix(value, ">") {
+			add(value)
+			continue
+		}
+
+		// ブロックスカラーまたはリストは、キーより深いインデントの行が続く間を読む
+		indent := len(matches[1])
+		for i+1 < len(lines) {
+			next := lines[i+1]
+			if strings.TrimSpace(next) == "" {
+				i++
+				continue
+			}
+			if len(next)-len(strings.TrimLeft(next, " ")) <= indent {
+				break
+			}
+			i++
+			add(strings.TrimPrefix(strings.TrimSpace(next), "- "))
+		}
+	}
+	return name, commands
+}
+
+func inspectReadme(projectRoot string, summary *ProjectSummary) error {
+	for _, name := range []string{"README.md", "README", "readme.md", "README.markdown"} {
+		content, ok, err := readProjectFile(projectRoot, name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		summary.Sources = append(summary.Sources, name)
+		summary.ReadmeTitle, summary.ReadmeExcerpt = readmeIntroduction(content)
+		return nil
+	}
+	return nil
+}
+
+// readmeIntroduction returns the title and the first paragraph of a README,
+// skipping badges, images and HTML.
+func readmeIntroduction(content string) (title, excerpt string) {
+	headings := ScanHeadings(content)
+	body := content
+	if len(headings) > 0 && headings[0].Level == 1 {
+		title = headings[0].Title
+		body = content[headings[0].End:]
+	}
+
+	var paragraph []string
+	inFence := false
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~") {
+			inFence = !inFence
+			continue
+		}
+		switch {
+		case inFence:
+			continue
+		case line == "":
+			if len(paragraph) > 0 {
+				return title, truncateRunes(strings.Join(paragraph, " "), maxReadmeExcerpt)
+			}
+		case strings.HasPrefix(line, "#"):
+			if len(paragraph) > 0 {
+				return title, truncateRunes(strings.Join(paragraph, " "), maxReadmeExcerpt)
+			}
+		case strings.HasPrefix(line, "[!["), strings.HasPrefix(line, "!["), strings.HasPrefix(line, "<"),
+			strings.HasPrefix(line, "---"), strings.HasPrefix(line, "==="):
+			continue
+		default:
+			paragraph = append(paragraph, line)
+		}
+	}
+	return title, truncateRunes(strings.Join(paragraph, " "), maxReadmeExcerpt)
+}
+
+// truncateRunes shortens text to maxRunes characters.
+func truncateRunes(text string, maxRunes int) string {
+	if utf8.RuneCountInString(text) <= maxRunes {
+		return text
+	}
+	return string([]rune(text)[:maxRunes]) + "…"
+}
+
+func inspectDirectories(projectRoot string, summary *ProjectSummary) error {
+	entries, err := os.ReadDir(projectRoot)
+	if err != nil {
+		return fmt.Errorf("プロジェクトディレクトリの読み込みに失敗: %w", err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || strings.HasPrefix(name, ".") || ignoredSummaryDirs[name] {
+			continue
+		}
+		if len(summary.Directories) >= maxSummaryDirectories {
+			break
+		}
+		summary.Directories = append(summary.Directories, name+"/")
+	}
+	return nil
+}
+
+// Context renders the summary as Markdown for the init prompt.
+func (s ProjectSummary) Context() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "- 名前: %s\n", s.Name)
+	if s.Description != "" {
+		fmt.Fprintf(&b, "- 説明: %s\n", s.Description)
+	}
+	if len(s.Sources) > 0 {
+		fmt.Fprintf(&b, "- 参照したファイル: %s\n", strings.Join(s.Sources, ", "))
+	}
+	if len(s.Stack) > 0 {
+		b.WriteString("\n### 技術スタック\n\n")
+		for _, stack := range s.Stack {
+			fmt.Fprintf(&b, "- %s\n", stack)
+		}
+	}
+	if len(s.Commands) > 0 {
+		b.WriteString("\n### コマンド\n\n")
+		for _, command := range s.Commands {
+			fmt.Fprintf(&b, "- %s\n", command)
+		}
+	}
+	s.writeCI(&b)
+	if len(s.Directories) > 0 {
+		b.WriteString("\n### ディレクトリ\n\n")
+		for _, dir := range s.Directories {
+			fmt.Fprintf(&b, "- `%s`\n", dir)
+		}
+	}
+	s.writeReadme(&b)
+	return b.String()
+}
+
+// writeCI writes the CI workflows of the context.
+func (s ProjectSummary) writeCI(b *strings.Builder) {
+	for _, ci := range s.CI {
+		fmt.Fprintf(b, "\n### CI: %s\n\n", ci.Path)
+		if ci.Name != "" {
+			fmt.Fprintf(b, "- ワークフロー名: %s\n", ci.Name)
+		}
+		for _, command := range ci.Commands {
+			fmt.Fprintf(b, "- `%s`\n", command)
+		}
+	}
+}
+
+// writeReadme writes the README introduction of the context.
+func (s ProjectSummary) writeReadme(b *strings.Builder) {
+	if s.ReadmeTitle == "" && s.ReadmeExcerpt == "" {
+		return
+	}
+	b.WriteString("\n### README\n\n")
+	if s.ReadmeTitle != "" {
+		fmt.Fprintf(b, "タイトル: %s\n\n", s.ReadmeTitle)
+	}
+	if s.ReadmeExcerpt != "" {
+		fmt.Fprintf(b, "%s\n", s.ReadmeExcerpt)
+	}
+}
+
+// String formats the command as a Markdown list item body.
+func (c ProjectCommand) String() string {
+	if c.Description == "" {
+		return fmt.Sprintf("`%s`", c.Command)
+	}
+	return fmt.Sprintf("`%s`: %s", c.Command, c.Description)
+}
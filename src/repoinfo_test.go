@@ -0,0 +1,133 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeProjectFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+}
+
+func TestInspectRepository(t *testing.T) {
+	root := t.TempDir()
+	writeProjectFiles(t, root, map[string]string{
+		"go.mod":   "module github.com/example/tool\n\ngo 1.22\n",
+		"Makefile": ".PHONY: build test\n\n# Build the binary\nbuild:\n\tgo build ./...\n\ntest: build ## Run tests\n\tgo test ./...\n\nVERSION := 1.0\n",
+		".mise.toml": "[tools]\ngo = \"1.22\"\nnode = [\"20\", \"18\"]\n\n[tasks]\nlint = \"golangci-lint run\"\n" +
+			"fmt = { run = \"gofmt -w .\", description = \"Format code\" }\n\n[tasks.release]\ndescription = \"Release\"\nrun = \"goreleaser\"\n",
+		".github/workflows/ci.yml": "name: CI\non: push\njobs:\n  test:\n    steps:\n      - uses: actions/checkout@v4\n" +
+			"      - run: go vet ./...\n      - name: Test\n        run: |\n          go test ./...\n          go build ./...\n",
+		"README.md":                "# Tool\n\n[![CI](badge.svg)](ci)\n\nA tool that does things.\nIt is fast.\n\n## Install\n\nRun make.\n",
+		"src/main.go":              "package main\n",
+		"node_modules/x/index.js":  "",
+		".claude/settings.json":    "{}",
+		"docs/index.md":            "",
+		"package-lock-free/a.txt":  "",
+		"vendor/example.com/a.txt": "",
+	})
+
+	summary, err := InspectRepository(root)
+	if err != nil {
+		t.Fatalf("InspectRepository() error = %v", err)
+	}
+
+	if summary.Name != "github.com/example/tool" {
+		t.Errorf("Name = %q", summary.Name)
+	}
+	if want := []string{"Go 1.22", "mise: go 1.22, node 20 18"}; !reflect.DeepEqual(summary.Stack, want) {
+		t.Errorf("Stack = %q, want %q", summary.Stack, want)
+	}
+	wantCommands := []ProjectCommand{
+		{"make build", "Build the binary"},
+		{"make test", "Run tests"},
+		{"mise run lint", "golangci-lint run"},
+		{"mise run fmt", "Format code"},
+		{"mise run release", "Release"},
+	}
+	if !reflect.DeepEqual(summary.Commands, wantCommands) {
+		t.Errorf("Commands = %+v, want %+v", summary.Commands, wantCommands)
+	}
+	wantCI := []CIConfig{{Path: ".github/workflows/ci.yml", Name: "CI", Commands: []string{"go vet ./...", "go test ./...", "go build ./..."}}}
+	if !reflect.DeepEqual(summary.CI, wantCI) {
+		t.Errorf("CI = %+v, want %+v", summary.CI, wantCI)
+	}
+	if want := []string{"docs/", "package-lock-free/", "src/"}; !reflect.DeepEqual(summary.Directories, want) {
+		t.Errorf("Directories = %q, want %q", summary.Directories, want)
+	}
+	if summary.ReadmeTitle != "Tool" || summary.ReadmeExcerpt != "A tool that does things. It is fast." {
+		t.Errorf("README = %q / %q", summary.ReadmeTitle, summary.ReadmeExcerpt)
+	}
+	if want := []string{"go.mod", "Makefile", ".mise.toml", ".github/workflows/ci.yml", "README.md"}; !reflect.DeepEqual(summary.Sources, want) {
+		t.Errorf("Sources = %q, want %q", summary.Sources, want)
+	}
+}
+
+func TestInspectRepository_PackageJSON(t *testing.T) {
+	root := t.TempDir()
+	writeProjectFiles(t, root, map[string]string{
+		"package.json":   `{"name":"web","description":"Web app","engines":{"node":">=20"},"scripts":{"test":"vitest","build":"vite build"}}`,
+		"pnpm-lock.yaml": "",
+		".gitlab-ci.yml": "test:\n  script:\n    - pnpm install\n    - pnpm test\n",
+	})
+
+	summary, err := InspectRepository(root)
+	if err != nil {
+		t.Fatalf("InspectRepository() error = %v", err)
+	}
+	if summary.Name != "web" || summary.Description != "Web app" {
+		t.Errorf("Name/Description = %q / %q", summary.Name, summary.Description)
+	}
+	if want := []string{"Node.js >=20（pnpm）"}; !reflect.DeepEqual(summary.Stack, want) {
+		t.Errorf("Stack = %q, want %q", summary.Stack, want)
+	}
+	wantCommands := []ProjectCommand{{"pnpm run build", "vite build"}, {"pnpm run test", "vitest"}}
+	if !reflect.DeepEqual(summary.Commands, wantCommands) {
+		t.Errorf("Commands = %+v, want %+v", summary.Commands, wantCommands)
+	}
+	if len(summary.CI) != 1 || !reflect.DeepEqual(summary.CI[0].Commands, []string{"pnpm install", "pnpm test"}) {
+		t.Errorf("CI = %+v", summary.CI)
+	}
+}
+
+func TestInspectRepository_InvalidPackageJSON(t *testing.T) {
+	root := t.TempDir()
+	writeProjectFiles(t, root, map[string]string{"package.json": "{"})
+	if _, err := InspectRepository(root); err == nil || !strings.Contains(err.Error(), "package.json") {
+		t.Errorf("InspectRepository() error = %v, want package.json error", err)
+	}
+}
+
+func TestReadmeIntroduction(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		wantTitle   string
+		wantExcerpt string
+	}{
+		{"title and paragraph", "# App\n\nFirst paragraph.\n\nSecond.\n", "App", "First paragraph."},
+		{"no title", "Just text\nmore text\n", "", "Just text more text"},
+		{"skips html and code", "# App\n\n<p align=\"center\">\n\n```sh\nmake\n```\n\nIntro.\n", "App", "Intro."},
+		{"stops at heading", "# App\n## Usage\nRun it.\n", "App", "Run it."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			title, excerpt := readmeIntroduction(tt.content)
+			if title != tt.wantTitle || excerpt != tt.wantExcerpt {
+				t.Errorf("readmeIntroduction() = %q, %q, want %q, %q", title, excerpt, tt.wantTitle, tt.wantExcerpt)
+			}
+		})
+	}
+}
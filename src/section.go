@@ -1,202 +1,214 @@
 package main
 
-import (
-	"regexp"
-	"strings"
-)
+import "strings"
 
 // Section represents a markdown section with its level and content
 type Section struct {
-	Level     int // 1 for #, 2 for ##, etc.
-	Title     string
-	Content   string
-	StartLine int
-	EndLine   int
+	Level       int // 1 for #, 2 for ##, etc.
+	Title       string
+	Content     string // heading and body up to the next heading
+	StartLine   int
+	EndLine     int
+	StartOffset int // byte offset of the heading
+	BodyOffset  int // byte offset just after the heading
+	EndOffset   int // byte offset of the next heading (or end of content)
 }
 
-// ParseSections parses markdown content and extracts sections
-func ParseSections(content string) []Section {
-	lines := strings.Split(content, "\n")
-	var sections []Section
-	var currentSection *Section
-
-	headerRegex := regexp.MustCompile(`^(#{1,6})\s+(.+)$`)
-
-	for i, line := range lines {
-		if matches := headerRegex.FindStringSubmatch(line); matches != nil {
-			// Save previous section
-			if currentSection != nil {
-				currentSection.EndLine = i - 1
-				sections = append(sections, *currentSection)
-			}
+// sectionPathSeparator separates titles in a section path
+const sectionPathSeparator = " > "
 
-			// Start new section
-			level := len(matches[1])
-			title := strings.TrimSpace(matches[2])
-			currentSection = &Section{
-				Level:     level,
-				Title:     title,
-				Content:   line + "\n",
-				StartLine: i,
-			}
-		} else if currentSection != nil {
-			currentSection.Content += line + "\n"
-		}
-	}
+// SectionNode is a section in the document tree.
+// Children are the following sections with a deeper level, up to the next
+// heading of the same or a higher level.
+type SectionNode struct {
+	Section
+	Parent   *SectionNode
+	Children []*SectionNode
+}
 
-	// Save last section
-	if currentSection != nil {
-		currentSection.EndLine = len(lines) - 1
-		sections = append(sections, *currentSection)
+// SubtreeEnd returns the byte offset where the node and all its descendants end.
+func (n *SectionNode) SubtreeEnd() int {
+	if len(n.Children) == 0 {
+		return n.EndOffset
 	}
+	return n.Children[len(n.Children)-1].SubtreeEnd()
+}
 
-	return sections
+// Path returns the titles from the top-level section down to n, joined by " > ".
+func (n *SectionNode) Path() string {
+	var titles []string
+	for node := n; node != nil && node.Parent != nil; node = node.Parent {
+		titles = append([]string{node.Title}, titles...)
+	}
+	return strings.Join(titles, sectionPathSeparator)
 }
 
-// FindSectionByTitle finds a section by its title (case-insensitive, level 2)
-func FindSectionByTitle(sections []Section, title string) *Section {
-	normalizedTitle := strings.ToLower(strings.TrimSpace(title))
-	for i := range sections {
-		if sections[i].Level == 2 && strings.ToLower(strings.TrimSpace(sections[i].Title)) == normalizedTitle {
-			return &sections[i]
+// Contains reports whether other is n or one of its descendants.
+func (n *SectionNode) Contains(other *SectionNode) bool {
+	for ; other != nil; other = other.Parent {
+		if other == n {
+			return true
 		}
 	}
-	return nil
+	return false
 }
 
-// InsertIntoSection inserts new content into existing CLAUDE.md at appropriate sections
-// nolint:gocyclo,gocritic // Complex logic for section-based insertion is necessary
-func InsertIntoSection(existingContent, suggestionContent string) string {
-	// If existing content is empty, return suggestion as-is
-	if existingContent == "" {
-		return suggestionContent
+// Walk calls fn for n and its descendants in document order until fn returns false.
+func (n *SectionNode) Walk(fn func(node *SectionNode) bool) bool {
+	if !fn(n) {
+		return false
 	}
-
-	// Parse existing CLAUDE.md sections
-	existingSections := ParseSections(existingContent)
-
-	// Parse suggestion sections
-	suggestionSections := ParseSections(suggestionContent)
-
-	if len(suggestionSections) == 0 {
-		// No sections found, append to end
-		return appendContent(existingContent, suggestionContent)
+	for _, child := range n.Children {
+		if !child.Walk(fn) {
+			return false
+		}
 	}
+	return true
+}
 
-	// Build a map of level-2 sections and collect their subsections
-	type SectionGroup struct {
-		Section     *Section
-		Subsections []Section
-		EndLine     int
+// FindSection finds a descendant of scope by title or by a path such as
+// "Architecture > Execution modes". Each path element matches a section at any
+// depth below the previous one; shallower sections are preferred, then document order.
+func FindSection(scope *SectionNode, path string) *SectionNode {
+	node := scope
+	for _, title := range splitSectionPath(path) {
+		node = findDescendant(node, title)
+		if node == nil {
+			return nil
+		}
+	}
+	if node == scope {
+		return nil
 	}
+	return node
+}
 
-	existingGroups := make(map[string]*SectionGroup)
-	for i := 0; i < len(existingSections); i++ {
-		if existingSections[i].Level == 2 {
-			key := strings.ToLower(strings.TrimSpace(existingSections[i].Title))
-			group := &SectionGroup{
-				Section: &existingSections[i],
-			}
-			// Collect subsections
-			endLine := existingSections[i].EndLine
-			for j := i + 1; j < len(existingSections); j++ {
-				if existingSections[j].Level <= 2 {
-					break
-				}
-				group.Subsections = append(group.Subsections, existingSections[j])
-				endLine = existingSections[j].EndLine
-			}
-			group.EndLine = endLine
-			existingGroups[key] = group
+// findDescendant searches the descendants of scope breadth-first for a title.
+func findDescendant(scope *SectionNode, title string) *SectionNode {
+	key := normalizeTitle(title)
+	queue := append([]*SectionNode(nil), scope.Children...)
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		if normalizeTitle(node.Title) == key {
+			return node
 		}
+		queue = append(queue, node.Children...)
 	}
+	return nil
+}
 
-	// Group suggestion sections similarly
-	suggestionGroups := make(map[string][]Section)
-	var newLevel2Sections []string
-	hasLevel2Sections := false
-	for i := 0; i < len(suggestionSections); i++ {
-		if suggestionSections[i].Level == 2 {
-			hasLevel2Sections = true
-			key := strings.ToLower(strings.TrimSpace(suggestionSections[i].Title))
-			var subsections []Section
-			// Collect subsections
-			for j := i + 1; j < len(suggestionSections); j++ {
-				if suggestionSections[j].Level <= 2 {
-					break
-				}
-				subsections = append(subsections, suggestionSections[j])
-			}
-			suggestionGroups[key] = subsections
-
-			// If section doesn't exist in original, collect for appending
-			if _, exists := existingGroups[key]; !exists {
-				// Build the full section content
-				sectionContent := suggestionSections[i].Content
-				for _, sub := range subsections {
-					sectionContent += sub.Content
-				}
-				newLevel2Sections = append(newLevel2Sections, sectionContent)
-			}
+// splitSectionPath splits a section path into its titles.
+// Only " > " separates titles, so headings such as "a->b" or "x>y" are kept whole.
+func splitSectionPath(path string) []string {
+	var titles []string
+	for _, title := range strings.Split(path, sectionPathSeparator) {
+		if title = strings.TrimSpace(title); title != "" {
+			titles = append(titles, title)
 		}
 	}
+	return titles
+}
 
-	// If no level-2 sections found, just append to end
-	if !hasLevel2Sections {
-		return appendContent(existingContent, suggestionContent)
+// ParseSections parses markdown content and extracts sections.
+// Headings inside code fences and HTML blocks are ignored, and setext headings are supported.
+func ParseSections(content string) []Section {
+	headings := ScanHeadings(content)
+	if len(headings) == 0 {
+		return nil
 	}
 
-	// Insert subsections into existing sections
-	result := existingContent
-	for key, subsections := range suggestionGroups {
-		if group, exists := existingGroups[key]; exists {
-			// Build subsection content to insert
-			var subsectionContent string
-			for _, sub := range subsections {
-				subsectionContent += sub.Content
-			}
+	lineCount := strings.Count(content, "\n")
+	sections := make([]Section, 0, len(headings))
+	for i, h := range headings {
+		endOffset := len(content)
+		endLine := lineCount
+		if i+1 < len(headings) {
+			endOffset = headings[i+1].Start
+			endLine = headings[i+1].StartLine - 1
+		}
+		sections = append(sections, Section{
+			Level:       h.Level,
+			Title:       h.Title,
+			Content:     content[h.Start:endOffset],
+			StartLine:   h.StartLine,
+			EndLine:     endLine,
+			StartOffset: h.Start,
+			BodyOffset:  h.End,
+			EndOffset:   endOffset,
+		})
+	}
 
-			if subsectionContent == "" {
-				continue
-			}
+	return sections
+}
 
-			// Find the end of the existing section group in result
-			// Use the section content to find insertion point
-			fullSectionContent := group.Section.Content
-			for _, sub := range group.Subsections {
-				fullSectionContent += sub.Content
-			}
+// ParseSectionTree parses markdown content into a section tree.
+// The root node has level 0 and holds the content before the first heading.
+func ParseSectionTree(content string) *SectionNode {
+	sections := ParseSections(content)
 
-			insertPos := strings.Index(result, fullSectionContent)
-			if insertPos != -1 {
-				endPos := insertPos + len(fullSectionContent)
+	preambleEnd := len(content)
+	if len(sections) > 0 {
+		preambleEnd = sections[0].StartOffset
+	}
+	root := &SectionNode{Section: Section{
+		Content:   content[:preambleEnd],
+		EndOffset: preambleEnd,
+	}}
+
+	current := root
+	for _, section := range sections {
+		for current != root && current.Level >= section.Level {
+			current = current.Parent
+		}
+		node := &SectionNode{Section: section, Parent: current}
+		current.Children = append(current.Children, node)
+		current = node
+	}
 
-				before := result[:endPos]
-				after := result[endPos:]
+	return root
+}
 
-				// Ensure proper spacing
-				if !strings.HasSuffix(before, "\n\n") {
-					if strings.HasSuffix(before, "\n") {
-						before += "\n"
-					} else {
-						before += "\n\n"
-					}
-				}
+// FindSectionByTitle finds a section by its title or path (case-insensitive, any level).
+// A path such as "Architecture > Execution modes" matches a section nested at any
+// depth below the previous element.
+func FindSectionByTitle(sections []Section, title string) *Section {
+	titles := splitSectionPath(title)
+	if len(titles) == 0 {
+		return nil
+	}
 
-				result = before + subsectionContent + after
+	// [lo, hi) と scopeLevel で直前に一致したセクションの配下に検索範囲を絞る
+	lo, hi, scopeLevel := 0, len(sections), 0
+	found := -1
+	for _, t := range titles {
+		key := normalizeTitle(t)
+		found = -1
+		// 浅い階層を優先する
+		for i := lo; i < hi; i++ {
+			if sections[i].Level > scopeLevel && normalizeTitle(sections[i].Title) == key &&
+				(found == -1 || sections[i].Level < sections[found].Level) {
+				found = i
 			}
 		}
-	}
+		if found == -1 {
+			return nil
+		}
 
-	// Append new level-2 sections at the end
-	if len(newLevel2Sections) > 0 {
-		if !strings.HasSuffix(result, "\n") {
-			result += "\n"
+		lo, hi, scopeLevel = found+1, len(sections), sections[found].Level
+		for i := lo; i < len(sections); i++ {
+			if sections[i].Level <= scopeLevel {
+				hi = i
+				break
+			}
 		}
-		result += "\n" + strings.Join(newLevel2Sections, "\n")
 	}
+	return &sections[found]
+}
 
-	return result
+// InsertIntoSection inserts new content into existing CLAUDE.md at appropriate sections
+func InsertIntoSection(existingContent, suggestionContent string) string {
+	return MergeSections(existingContent, suggestionContent).Content
 }
 
 // extractSubsectionContent extracts content after the first line (section header)
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -447,22 +448,107 @@ Existing content.
 }
 
 func TestFindSectionByTitle_Level3Section(t *testing.T) {
-	// Level 3セクションは検索対象外であることを確認
+	// Level 3以下のセクションも検索対象
 	sections := []Section{
 		{Level: 2, Title: "Level 2 Section", Content: "## Level 2 Section\n\nContent"},
 		{Level: 3, Title: "Level 3 Section", Content: "### Level 3 Section\n\nContent"},
 	}
 
-	// Level 2は見つかる
 	section := FindSectionByTitle(sections, "Level 2 Section")
 	if section == nil {
 		t.Error("Expected to find Level 2 section")
 	}
 
-	// Level 3は見つからない（関数はLevel 2のみを検索）
 	section = FindSectionByTitle(sections, "Level 3 Section")
-	if section != nil {
-		t.Error("Should not find Level 3 section (function only searches Level 2)")
+	if section == nil || section.Level != 3 {
+		t.Errorf("Expected to find Level 3 section, got %+v", section)
+	}
+}
+
+func TestFindSectionByTitle_Path(t *testing.T) {
+	sections := ParseSections(`# Project
+
+## Testing
+
+### Execution modes
+
+Test modes.
+
+## Architecture
+
+### Components
+
+#### Execution modes
+
+Sync and async.
+`)
+
+	section := FindSectionByTitle(sections, "Architecture > Execution modes")
+	if section == nil || !strings.Contains(section.Content, "Sync and async") {
+		t.Errorf("Expected nested Execution modes under Architecture, got %+v", section)
+	}
+
+	// 浅い階層が優先される
+	section = FindSectionByTitle(sections, "execution modes")
+	if section == nil || !strings.Contains(section.Content, "Test modes") {
+		t.Errorf("Expected Execution modes under Testing, got %+v", section)
+	}
+
+	if FindSectionByTitle(sections, "Testing > Components") != nil {
+		t.Error("Components is not under Testing")
+	}
+	if FindSectionByTitle(sections, " > ") != nil {
+		t.Error("Empty path should not match")
+	}
+}
+
+func TestFindSection(t *testing.T) {
+	root := ParseSectionTree(`# Project
+
+## Architecture
+
+### Execution modes
+
+## Testing
+`)
+
+	node := FindSection(root, "Project > Architecture > Execution modes")
+	if node == nil || node.Path() != "Project > Architecture > Execution modes" {
+		t.Fatalf("Unexpected node: %+v", node)
+	}
+	if FindSection(root, "Architecture > Execution modes") != node {
+		t.Error("Path elements should match at any depth")
+	}
+	if FindSection(root, "Testing > Execution modes") != nil {
+		t.Error("Execution modes is not under Testing")
+	}
+	if FindSection(root, "") != nil {
+		t.Error("Empty path should not match")
+	}
+}
+
+func TestSplitSectionPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"Architecture > Execution modes", []string{"Architecture", "Execution modes"}},
+		{" Architecture  >  Execution modes ", []string{"Architecture", "Execution modes"}},
+		{"Input -> Output", []string{"Input -> Output"}},
+		{"a>b > c", []string{"a>b", "c"}},
+		{" > Testing > ", []string{"Testing"}},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		if got := splitSectionPath(tt.path); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitSectionPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+
+	root := ParseSectionTree("# Project\n\n## Input -> Output\n\n### a>b\n")
+	if node := FindSection(root, "Input -> Output > a>b"); node == nil || node.Title != "a>b" {
+		t.Errorf("Headings containing > should be found, got %+v", node)
 	}
 }
 
@@ -632,3 +718,80 @@ New section content.
 		t.Logf("Result:\n%s", result)
 	}
 }
+
+func TestParseSections_IgnoresHeadingsInCodeFence(t *testing.T) {
+	content := "## Commands\n\n```bash\n# Run tests\ngo test ./...\n```\n\n## Testing\n\nNotes.\n"
+
+	sections := ParseSections(content)
+
+	if len(sections) != 2 {
+		t.Fatalf("Expected 2 sections, got %d", len(sections))
+	}
+	if !strings.Contains(sections[0].Content, "# Run tests") {
+		t.Errorf("Code fence should stay in Commands section, got %q", sections[0].Content)
+	}
+	if sections[1].Title != "Testing" {
+		t.Errorf("Expected 'Testing', got '%s'", sections[1].Title)
+	}
+}
+
+func TestParseSections_Offsets(t *testing.T) {
+	content := "# Project\n\n## A\n\nText A.\n\n## B\n\nText B.\n"
+
+	sections := ParseSections(content)
+
+	for _, section := range sections {
+		if content[section.StartOffset:section.EndOffset] != section.Content {
+			t.Errorf("Content of %q does not match its offsets", section.Title)
+		}
+	}
+	if got := content[sections[1].StartOffset:sections[1].BodyOffset]; got != "## A\n" {
+		t.Errorf("Heading span = %q, want %q", got, "## A\n")
+	}
+	if sections[2].EndOffset != len(content) {
+		t.Errorf("Last section should end at %d, got %d", len(content), sections[2].EndOffset)
+	}
+}
+
+func TestParseSectionTree(t *testing.T) {
+	content := `Preamble.
+
+# Project
+
+## Architecture
+
+### Execution modes
+
+#### Sync
+
+## Testing
+`
+
+	root := ParseSectionTree(content)
+
+	if root.Level != 0 || root.Content != "Preamble.\n\n" {
+		t.Errorf("Unexpected root: level=%d content=%q", root.Level, root.Content)
+	}
+	if len(root.Children) != 1 || root.Children[0].Title != "Project" {
+		t.Fatalf("Expected single top-level 'Project' node, got %+v", root.Children)
+	}
+
+	project := root.Children[0]
+	if len(project.Children) != 2 {
+		t.Fatalf("Expected 2 children under Project, got %d", len(project.Children))
+	}
+	architecture := project.Children[0]
+	if architecture.Title != "Architecture" || len(architecture.Children) != 1 {
+		t.Errorf("Unexpected Architecture node: %+v", architecture)
+	}
+	sync := architecture.Children[0].Children[0]
+	if sync.Title != "Sync" || sync.Parent.Title != "Execution modes" {
+		t.Errorf("Unexpected nested node: %q (parent %q)", sync.Title, sync.Parent.Title)
+	}
+	if architecture.SubtreeEnd() != project.Children[1].StartOffset {
+		t.Errorf("Architecture subtree should end where Testing starts")
+	}
+	if root.SubtreeEnd() != len(content) {
+		t.Errorf("Root subtree should end at %d, got %d", len(content), root.SubtreeEnd())
+	}
+}
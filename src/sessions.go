@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// transcriptExt is the extension of Claude Code transcript files.
+	transcriptExt = ".jsonl"
+	// maxPromptPreview is the number of characters of the first prompt shown in session lists.
+	maxPromptPreview = 60
+	// defaultSessionListLimit is the number of sessions listed by default.
+	defaultSessionListLimit = 20
+)
+
+// projectDirNameRegex matches the characters Claude Code replaces with '-'
+// when naming a project's transcript directory after its path.
+var projectDirNameRegex = regexp.MustCompile(`[^a-zA-Z0-9]`)
+
+// EncodeProjectDir returns the directory name Claude Code uses for a project path,
+// e.g. /Users/me/my_app → -Users-me-my-app.
+func EncodeProjectDir(projectRoot string) string {
+	return projectDirNameRegex.ReplaceAllString(projectRoot, "-")
+}
+
+// ProjectTranscriptDir returns the directory holding the transcripts of a project:
+// <Claude config dir>/projects/<encoded project path>.
+func ProjectTranscriptDir(projectRoot string) (string, error) {
+	configDir, err := claudeConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "projects", EncodeProjectDir(projectRoot)), nil
+}
+
+// projectTranscripts returns the transcript files of a project in name order.
+func projectTranscripts(projectRoot string) ([]string, error) {
+	dir, err := ProjectTranscriptDir(projectRoot)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("プロジェクトの会話履歴が見つかりません: %s", dir)
+		}
+		return nil, fmt.Errorf("会話履歴ディレクトリの読み込みに失敗: %w", err)
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		if !entry.IsDir() && filepath.Ext(entry.Name()) == transcriptExt {
+			paths = append(paths, filepath.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// newestTranscript returns the most recently modified transcript of the project,
+// or "" when the project has none.
+func newestTranscript(projectRoot string) (string, error) {
+	dir, err := ProjectTranscriptDir(projectRoot)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return "", nil
+	}
+	paths, err := projectTranscripts(projectRoot)
+	if err != nil {
+		return "", err
+	}
+
+	newest := ""
+	var newestTime time.Time
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if newest == "" || info.ModTime().After(newestTime) {
+			newest, newestTime = path, info.ModTime()
+		}
+	}
+	return newest, nil
+}
+
+// FindSessionTranscript returns the transcript of the session in the project.
+// A unique prefix of the session ID is accepted.
+func FindSessionTranscript(projectRoot, sessionID string) (string, error) {
+	paths, err := projectTranscripts(projectRoot)
+	if err != nil {
+		return "", err
+	}
+
+	var matches []string
+	for _, path := range paths {
+		id := strings.TrimSuffix(filepath.Base(path), transcriptExt)
+		if id == sessionID {
+			return path, nil
+		}
+		if strings.HasPrefix(id, sessionID) {
+			matches = append(matches, path)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("セッションが見つかりません: %s", sessionID)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("セッションIDが複数の会話履歴に一致します: %s (%d件)", sessionID, len(matches))
+	}
+}
+
+// SessionSummary describes a Claude Code session from its transcript.
+type SessionSummary struct {
+	ID          string
+	Path        string
+	Start, End  time.Time
+	Messages    int    // messages with text, as included in the conversation history
+	UserTurns   int    // prompts typed by the user
+	Characters  int    // characters typed by the user and the assistant
+	ToolUses    int    // tools called by the assistant
+	FirstPrompt string // first prompt typed by the user
+	GitBranch   string
+	Analyzed    bool // a suggestion was generated for the session
+}
+
+// Duration returns the time between the first and the last message.
+func (s SessionSummary) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// SummarizeTranscript reads a transcript and summarizes the session.
+func SummarizeTranscript(path string) (SessionSummary, error) {
+	summary := SessionSummary{ID: strings.TrimSuffix(filepath.Base(path), transcriptExt), Path: path}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return summary, fmt.Errorf("ファイルを開けません: %w", err)
+	}
+	defer file.Close() // nolint:errcheck // File is read-only, no need to check close error
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var msg Message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			// JSONパースエラーはスキップ
+			continue
+		}
+		summary.add(msg)
+	}
+	if err := scanner.Err(); err != nil {
+		return summary, fmt.Errorf("ファイルの読み込みエラー: %w", err)
+	}
+	return summary, nil
+}
+
+// add counts one transcript message into the summary.
+func (s *SessionSummary) add(msg Message) {
+	if ts, err := time.Parse(time.RFC3339Nano, msg.Timestamp); err == nil {
+		if s.Start.IsZero() || ts.Before(s.Start) {
+			s.Start = ts
+		}
+		if ts.After(s.End) {
+			s.End = ts
+		}
+	}
+	if msg.GitBranch != "" && s.GitBranch == "" {
+		s.GitBranch = msg.GitBranch
+	}
+
+	s.ToolUses += countToolUses(msg.Message.Content)
+	text := extractTextContent(msg.Message.Content)
+	if text == "" {
+		return
+	}
+	s.Messages++
+	if msg.IsMeta || isCommandMessage(text) {
+		return
+	}
+	s.Characters += utf8.RuneCountInString(text)
+	if msg.Message.Role == "user" {
+		s.UserTurns++
+		if s.FirstPrompt == "" {
+			s.FirstPrompt = text
+		}
+	}
+}
+
+// countToolUses counts the tool calls in the content of a message.
+func countToolUses(content interface{}) int {
+	items, ok := content.([]interface{})
+	if !ok {
+		return 0
+	}
+	count := 0
+	for _, item := range items {
+		if itemMap, ok := item.(map[string]interface{}); ok && itemMap["type"] == "tool_use" {
+			count++
+		}
+	}
+	return count
+}
+
+// isCommandMessage reports whether a user message was recorded for a slash command.
+func isCommandMessage(text string) bool {
+	return strings.HasPrefix(text, "<command-") || strings.HasPrefix(text, "<local-command-")
+}
+
+// ListSessions summarizes the sessions of a project, newest first. Sessions with a
+// valid suggestion file in outputDir are marked as analyzed.
+func ListSessions(projectRoot, outputDir string) ([]SessionSummary, error) {
+	paths, err := projectTranscripts(projectRoot)
+	if err != nil {
+		return nil, err
+	}
+	analyzed, err := AnalyzedConversations(outputDir)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]SessionSummary, 0, len(paths))
+	for _, path := range paths {
+		summary, err := SummarizeTranscript(path)
+		if err != nil {
+			return nil, err
+		}
+		summary.Analyzed = analyzed[summary.ID]
+		sessions = append(sessions, summary)
+	}
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].Start.After(sessions[j].Start)
+	})
+	return sessions, nil
+}
+
+// previewText returns the first line of text shortened to maxRunes characters.
+func previewText(text string, maxRunes int) string {
+	line := strings.TrimSpace(strings.SplitN(strings.TrimSpace(text), "\n", 2)[0])
+	if utf8.RuneCountInString(line) <= maxRunes {
+		return line
+	}
+	return string([]rune(line)[:maxRunes]) + "…"
+}
+
+// formatDuration formats a session duration such as 1h05m or 12m.
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	if d >= time.Hour {
+		return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return fmt.Sprintf("%dm", int(d.Minutes()))
+}
+
+// runSessionsCommand runs `suggest-claude-md sessions`, listing the project's sessions.
+func runSessionsCommand(args []string, output io.Writer, getwd func() (string, error), getenv func(string) string) error {
+	const usage = "使い方: suggest-claude-md sessions [--project <dir>] [--limit <n>]"
+
+	var projectRoot string
+	var limit int
+	fs := flag.NewFlagSet("sessions", flag.ContinueOnError)
+	fs.StringVar(&projectRoot, "project", "", "Project directory (default: current directory)")
+	fs.IntVar(&limit, "limit", defaultSessionListLimit, "Number of sessions to list (0: all)")
+	rest, err := parseSubcommandFlags(fs, args)
+	if err != nil {
+		return fmt.Errorf("%w\n%s", err, usage)
+	}
+	if len(rest) > 0 || limit < 0 {
+		return fmt.Errorf("%s", usage)
+	}
+
+	if projectRoot, err = resolveProjectRoot(projectRoot, getwd); err != nil {
+		return err
+	}
+	sessions, err := ListSessions(projectRoot, ResolveOutputDir(getenv))
+	if err != nil {
+		return err
+	}
+	dir, _ := ProjectTranscriptDir(projectRoot) // nolint:errcheck // Already resolved by ListSessions
+
+	_, _ = fmt.Fprintf(output, "📚 セッション一覧: %s (%d件)\n", dir, len(sessions)) // nolint:errcheck // Output to user, error not critical
+	if len(sessions) == 0 {
+		return nil
+	}
+	_, _ = fmt.Fprintln(output) // nolint:errcheck // Output to user, error not critical
+	for i, session := range sessions {
+		if limit > 0 && i >= limit {
+			_, _ = fmt.Fprintf(output, "\n... 他%d件（--limit 0ですべて表示）\n", len(sessions)-limit) // nolint:errcheck // Output to user, error not critical
+			break
+		}
+		start := "-"
+		if !session.Start.IsZero() {
+			start = session.Start.Local().Format("2006-01-02 15:04")
+		}
+		status := "未分析"
+		if session.Analyzed {
+			status = "分析済み"
+		}
+		branch := session.GitBranch
+		if branch == "" {
+			branch = "-"
+		}
+		_, _ = fmt.Fprintf(output, "%s  %s  %6s  %4d件  %s  %s\n", // nolint:errcheck // Output to user, error not critical
+			session.ID, start, formatDuration(session.Duration()), session.Messages, status, branch)
+		if session.FirstPrompt != "" {
+			_, _ = fmt.Fprintf(output, "    %s\n", previewText(session.FirstPrompt, maxPromptPreview)) // nolint:errcheck // Output to user, error not critical
+		}
+	}
+	_, _ = fmt.Fprintln(output)                                                     // nolint:errcheck // Output to user, error not critical
+	_, _ = fmt.Fprintln(output, "分析するには: suggest-claude-md analyze --session <id>") // nolint:errcheck // Output to user, error not critical
+	return nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncodeProjectDir(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/root/module", "-root-module"},
+		{"/Users/me/my_app.v2", "-Users-me-my-app-v2"},
+	}
+	for _, tt := range tests {
+		if got := EncodeProjectDir(tt.path); got != tt.want {
+			t.Errorf("EncodeProjectDir(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestFindSessionTranscript(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv(claudeConfigDirEnvVar, configDir)
+	projectRoot := "/work/app"
+
+	dir := filepath.Join(configDir, "projects", "-work-app")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	for _, name := range []string{"aaa111.jsonl", "aaa222.jsonl", "bbb333.jsonl", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	tests := []struct {
+		id      string
+		want    string
+		wantErr string
+	}{
+		{id: "aaa111", want: "aaa111.jsonl"},
+		{id: "bbb", want: "bbb333.jsonl"},
+		{id: "aaa", wantErr: "複数"},
+		{id: "notes", wantErr: "見つかりません"},
+	}
+	for _, tt := range tests {
+		got, err := FindSessionTranscript(projectRoot, tt.id)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("FindSessionTranscript(%q) error = %v, want %q", tt.id, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != filepath.Join(dir, tt.want) {
+			t.Errorf("FindSessionTranscript(%q) = %q, %v; want %s", tt.id, got, err, tt.want)
+		}
+	}
+}
+
+func TestSummarizeTranscript(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session-1.jsonl")
+	transcript := `{"type":"summary","summary":"ignored"}
+{"type":"user","isMeta":true,"timestamp":"2025-01-02T10:00:00Z","gitBranch":"feature/x","message":{"role":"user","content":"Caveat: meta message"}}
+{"type":"user","timestamp":"2025-01-02T10:00:05Z","message":{"role":"user","content":"<command-name>/clear</command-name>"}}
+{"type":"user","timestamp":"2025-01-02T10:01:00Z","gitBranch":"feature/x","message":{"role":"user","content":"Fix the flaky test\nin detail"}}
+{"type":"assistant","message":{"role":"assistant","content":[{"type":"tool_use","name":"Bash","input":{"command":"go test"}}]}}
+{"type":"assistant","timestamp":"2025-01-02T10:45:00Z","message":{"role":"assistant","content":[{"type":"text","text":"Done"}]}}
+{"type":"user","timestamp":"2025-01-02T10:46:00Z","message":{"role":"user","content":[{"type":"tool_result","content":"ok"}]}}
+`
+	if err := os.WriteFile(path, []byte(transcript), 0o600); err != nil {
+		t.Fatalf("Failed to create transcript: %v", err)
+	}
+
+	summary, err := SummarizeTranscript(path)
+	if err != nil {
+		t.Fatalf("SummarizeTranscript() unexpected error: %v", err)
+	}
+	if summary.ID != "session-1" || summary.GitBranch != "feature/x" {
+		t.Errorf("ID = %q, GitBranch = %q", summary.ID, summary.GitBranch)
+	}
+	if summary.FirstPrompt != "Fix the flaky test\nin detail" {
+		t.Errorf("FirstPrompt = %q, meta and command messages should be skipped", summary.FirstPrompt)
+	}
+	if summary.Messages != 4 {
+		t.Errorf("Messages = %d, want 4", summary.Messages)
+	}
+	// メタ情報とコマンドのメッセージは発言・文字数に含めない
+	if summary.UserTurns != 1 || summary.Characters != 32 || summary.ToolUses != 1 {
+		t.Errorf("UserTurns = %d, Characters = %d, ToolUses = %d, want 1, 32, 1", summary.UserTurns, summary.Characters, summary.ToolUses)
+	}
+	if got := formatDuration(summary.Duration()); got != "46m" {
+		t.Errorf("Duration = %s, want 46m", got)
+	}
+}
+
+func TestListSessions_Analyzed(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv(claudeConfigDirEnvVar, configDir)
+	outputDir := t.TempDir()
+
+	dir := filepath.Join(configDir, "projects", "-work-app")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	files := map[string]string{
+		filepath.Join(dir, "old.jsonl"):                                                `{"timestamp":"2025-01-01T00:00:00Z","message":{"role":"user","content":"old"}}`,
+		filepath.Join(dir, "new.jsonl"):                                                `{"timestamp":"2025-02-01T00:00:00Z","message":{"role":"user","content":"new"}}`,
+		filepath.Join(dir, "other.jsonl"):                                              `{"timestamp":"2025-01-15T00:00:00Z","message":{"role":"user","content":"other"}}`,
+		filepath.Join(outputDir, "suggest-claude-md-old-20250101-000000.md"):           "## A\n",
+		filepath.Join(outputDir, "suggest-claude-md-other-20250115-000000.invalid.md"): "## A\n",
+		filepath.Join(outputDir, "suggest-claude-md-new-20250201-000000.log"):          "",
+		filepath.Join(outputDir, "suggest-claude-md-new-20250201-000000.md"):           "",
+		filepath.Join(outputDir, "suggest-claude-md-new-20250202-000000.md"):           "Error: Credit balance is too low\n",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	sessions, err := ListSessions("/work/app", outputDir)
+	if err != nil {
+		t.Fatalf("ListSessions() unexpected error: %v", err)
+	}
+	var got []string
+	for _, s := range sessions {
+		got = append(got, fmt.Sprintf("%s:%v", s.ID, s.Analyzed))
+	}
+	// 不正な提案・空のファイル・エラーの応答は分析済みとしない
+	if want := "new:false other:false old:true"; strings.Join(got, " ") != want {
+		t.Errorf("ListSessions() = %v, want %s (newest first, analyzed by valid suggestion files)", got, want)
+	}
+}
+
+func TestPreviewText(t *testing.T) {
+	if got := previewText("  最初の行です\n次の行", 4); got != "最初の行…" {
+		t.Errorf("previewText() = %q", got)
+	}
+	if got := previewText("short", 10); got != "short" {
+		t.Errorf("previewText() = %q", got)
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// defaultSettingsIndent is the indentation of settings files we create.
+const defaultSettingsIndent = "  "
+
+// jsonField is a member of a JSON object, kept in document order so that
+// settings.json can be rewritten without dropping or reordering unknown keys.
+type jsonField struct {
+	Key   string
+	Value json.RawMessage
+}
+
+// decodeObjectFields decodes a JSON object into its members in document order.
+func decodeObjectFields(data []byte) ([]jsonField, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return nil, fmt.Errorf("JSONオブジェクトではありません")
+	}
+
+	var fields []jsonField
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := token.(string)
+		if !ok {
+			return nil, fmt.Errorf("不正なキー: %v", token)
+		}
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+		fields = append(fields, jsonField{Key: key, Value: value})
+	}
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// encodeObjectFields encodes members as a compact JSON object.
+// Values are compacted as-is, so escapes in the original document are kept.
+func encodeObjectFields(fields []jsonField) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := marshalNoEscape(field.Key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		if err := json.Compact(&buf, field.Value); err != nil {
+			return nil, err
+		}
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// marshalNoEscape marshals v without escaping <, > and & (common in shell commands).
+func marshalNoEscape(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// mergeFields returns the original members with the known keys replaced by their
+// current values, followed by the known keys that were not in the original.
+// A known key with a nil value is omitted.
+func mergeFields(original, known []jsonField) []jsonField {
+	values := make(map[string]json.RawMessage, len(known))
+	for _, field := range known {
+		values[field.Key] = field.Value
+	}
+
+	var merged []jsonField
+	used := make(map[string]bool)
+	for _, field := range original {
+		value, ok := values[field.Key]
+		if !ok {
+			merged = append(merged, field)
+			continue
+		}
+		used[field.Key] = true
+		if value != nil {
+			merged = append(merged, jsonField{Key: field.Key, Value: value})
+		}
+	}
+	for _, field := range known {
+		if !used[field.Key] && field.Value != nil {
+			merged = append(merged, field)
+		}
+	}
+	return merged
+}
+
+// knownField marshals a known member. Zero values yield nil when omitEmpty is set.
+func knownField(key string, value any, omitEmpty bool) (jsonField, error) {
+	if omitEmpty {
+		switch v := value.(type) {
+		case string:
+			if v == "" {
+				return jsonField{Key: key}, nil
+			}
+		case int:
+			if v == 0 {
+				return jsonField{Key: key}, nil
+			}
+		}
+	}
+	data, err := marshalNoEscape(value)
+	if err != nil {
+		return jsonField{}, err
+	}
+	return jsonField{Key: key, Value: data}, nil
+}
+
+// UnmarshalJSON decodes settings and keeps all members for round-tripping.
+func (s *ClaudeSettings) UnmarshalJSON(data []byte) error {
+	fields, err := decodeObjectFields(data)
+	if err != nil {
+		return err
+	}
+	s.fields = fields
+	s.Hooks = nil
+	s.hookOrder = nil
+	s.indent = detectIndent(data)
+
+	for _, field := range fields {
+		if field.Key != "hooks" || string(bytes.TrimSpace(field.Value)) == "null" {
+			continue
+		}
+		events, err := decodeObjectFields(field.Value)
+		if err != nil {
+			return fmt.Errorf("hooks: %w", err)
+		}
+		s.Hooks = make(map[string][]HookEntry, len(events))
+		for _, event := range events {
+			var entries []HookEntry
+			if err := json.Unmarshal(event.Value, &entries); err != nil {
+				return fmt.Errorf("hooks.%s: %w", event.Key, err)
+			}
+			s.Hooks[event.Key] = entries
+			s.hookOrder = append(s.hookOrder, event.Key)
+		}
+	}
+	return nil
+}
+
+// MarshalJSON encodes settings, keeping unknown members and their order.
+// New hook events are added after the existing ones in name order.
+func (s ClaudeSettings) MarshalJSON() ([]byte, error) {
+	hooks := jsonField{Key: "hooks"}
+	if len(s.Hooks) > 0 {
+		events := make([]string, 0, len(s.Hooks))
+		seen := make(map[string]bool)
+		for _, event := range s.hookOrder {
+			if _, ok := s.Hooks[event]; ok && !seen[event] {
+				events = append(events, event)
+				seen[event] = true
+			}
+		}
+		var added []string
+		for event := range s.Hooks {
+			if !seen[event] {
+				added = append(added, event)
+			}
+		}
+		sort.Strings(added)
+		events = append(events, added...)
+
+		eventFields := make([]jsonField, 0, len(events))
+		for _, event := range events {
+			data, err := marshalNoEscape(s.Hooks[event])
+			if err != nil {
+				return nil, err
+			}
+			eventFields = append(eventFields, jsonField{Key: event, Value: data})
+		}
+		data, err := encodeObjectFields(eventFields)
+		if err != nil {
+			return nil, err
+		}
+		hooks.Value = data
+	}
+	return encodeObjectFields(mergeFields(s.fields, []jsonField{hooks}))
+}
+
+// UnmarshalJSON decodes a hook entry and keeps unknown members.
+func (e *HookEntry) UnmarshalJSON(data []byte) error {
+	type plain HookEntry
+	var decoded plain
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	fields, err := decodeObjectFields(data)
+	if err != nil {
+		return err
+	}
+	*e = HookEntry(decoded)
+	e.fields = fields
+	return nil
+}
+
+// MarshalJSON encodes a hook entry, keeping unknown members and their order.
+func (e HookEntry) MarshalJSON() ([]byte, error) {
+	matcher, err := knownField("matcher", e.Matcher, true)
+	if err != nil {
+		return nil, err
+	}
+	hooks := e.Hooks
+	if hooks == nil {
+		hooks = []HookCommand{}
+	}
+	hookList, err := marshalNoEscape(hooks)
+	if err != nil {
+		return nil, err
+	}
+	return encodeObjectFields(mergeFields(e.fields, []jsonField{matcher, {Key: "hooks", Value: hookList}}))
+}
+
+// UnmarshalJSON decodes a hook command and keeps unknown members.
+func (c *HookCommand) UnmarshalJSON(data []byte) error {
+	type plain HookCommand
+	var decoded plain
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	fields, err := decodeObjectFields(data)
+	if err != nil {
+		return err
+	}
+	*c = HookCommand(decoded)
+	c.fields = fields
+	return nil
+}
+
+// MarshalJSON encodes a hook command, keeping unknown members and their order.
+func (c HookCommand) MarshalJSON() ([]byte, error) {
+	known := make([]jsonField, 0, 3)
+	for _, field := range []struct {
+		key       string
+		value     any
+		omitEmpty bool
+	}{
+		{"type", c.Type, false},
+		{"command", c.Command, false},
+		{"timeout", c.Timeout, true},
+	} {
+		f, err := knownField(field.key, field.value, field.omitEmpty)
+		if err != nil {
+			return nil, err
+		}
+		known = append(known, f)
+	}
+	return encodeObjectFields(mergeFields(c.fields, known))
+}
+
+// detectIndent returns the indentation unit of a JSON document
+// (the leading whitespace of its first indented line).
+func detectIndent(data []byte) string {
+	for _, line := range strings.Split(string(data), "\n")[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if indent := line[:len(line)-len(trimmed)]; indent != "" && trimmed != "" {
+			return indent
+		}
+	}
+	return defaultSettingsIndent
+}
+
+// formatSettings encodes settings with the indentation of the original file.
+func formatSettings(settings *ClaudeSettings) ([]byte, error) {
+	data, err := settings.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	indent := settings.indent
+	if indent == "" {
+		indent = defaultSettingsIndent
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, data, "", indent); err != nil {
+		return nil, err
+	}
+	if settings.trailingNewline {
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveSettings_PreservesUnknownFields(t *testing.T) {
+	original := `{
+    "model": "opus",
+    "permissions": {
+        "allow": ["Bash(make test)", "Read(./src/**)"],
+        "deny": []
+    },
+    "hooks": {
+        "Stop": [
+            {
+                "matcher": "",
+                "hooks": [{"type": "command", "command": "notify && echo done", "timeout": 30}]
+            }
+        ],
+        "PreCompact": [
+            {
+                "matcher": "auto",
+                "hooks": [{"type": "command", "command": "backup", "timeout": 10, "custom": true}]
+            }
+        ]
+    },
+    "env": {"FOO": "bar"}
+}
+`
+	tmpDir := t.TempDir()
+	settingsPath := filepath.Join(tmpDir, "settings.json")
+	if err := os.WriteFile(settingsPath, []byte(original), 0o644); err != nil {
+		t.Fatalf("Failed to create settings: %v", err)
+	}
+
+	settings, err := loadSettings(settingsPath)
+	if err != nil {
+		t.Fatalf("loadSettings() error = %v", err)
+	}
+	for _, event := range hookEvents {
+		settings.Hooks[event] = addHookIfNotExists(settings.Hooks[event], "", HookCommand{Type: "command", Command: "suggest-claude-md"})
+	}
+	if err := saveSettings(settingsPath, settings); err != nil {
+		t.Fatalf("saveSettings() error = %v", err)
+	}
+
+	data, err := os.ReadFile(settingsPath)
+	if err != nil {
+		t.Fatalf("Failed to read settings: %v", err)
+	}
+	saved := string(data)
+
+	for _, want := range []string{
+		`"model": "opus"`,
+		`"Bash(make test)"`,
+		`"deny": []`,
+		`"env": {`,
+		`"FOO": "bar"`,
+		`"matcher": "auto"`,
+		`"timeout": 10`,
+		`"custom": true`,
+		`"notify && echo done"`,
+		"\n    \"model\"",
+	} {
+		if !strings.Contains(saved, want) {
+			t.Errorf("Saved settings should contain %q, got:\n%s", want, saved)
+		}
+	}
+
+	// キーの順序が保持され、新しいイベントは既存のイベントの後に追加される
+	order := []string{`"model"`, `"permissions"`, `"hooks"`, `"Stop"`, `"PreCompact"`, `"SessionEnd"`, `"env"`}
+	last := -1
+	for _, key := range order {
+		idx := strings.Index(saved, key)
+		if idx < last {
+			t.Errorf("Key %s is out of order in:\n%s", key, saved)
+		}
+		last = idx
+	}
+
+	if !strings.HasSuffix(saved, "}\n") {
+		t.Errorf("Trailing newline should be kept, got %q", saved[len(saved)-3:])
+	}
+	if strings.Count(saved, "suggest-claude-md") != 2 {
+		t.Errorf("Hook should be added to both events, got:\n%s", saved)
+	}
+}
+
+func TestSaveSettings_RoundTripUnchanged(t *testing.T) {
+	original := "{\n\t\"hooks\": {\n\t\t\"SessionEnd\": [\n\t\t\t{\n\t\t\t\t\"hooks\": [\n\t\t\t\t\t{\n\t\t\t\t\t\t\"type\": \"command\",\n\t\t\t\t\t\t\"command\": \"x\"\n\t\t\t\t\t}\n\t\t\t\t]\n\t\t\t}\n\t\t]\n\t},\n\t\"includeCoAuthoredBy\": false\n}"
+	tmpDir := t.TempDir()
+	settingsPath := filepath.Join(tmpDir, "settings.json")
+	if err := os.WriteFile(settingsPath, []byte(original), 0o644); err != nil {
+		t.Fatalf("Failed to create settings: %v", err)
+	}
+
+	settings, err := loadSettings(settingsPath)
+	if err != nil {
+		t.Fatalf("loadSettings() error = %v", err)
+	}
+	if err := saveSettings(settingsPath, settings); err != nil {
+		t.Fatalf("saveSettings() error = %v", err)
+	}
+
+	data, _ := os.ReadFile(settingsPath)
+	if string(data) != original {
+		t.Errorf("Round trip changed the file:\n%s\nwant:\n%s", data, original)
+	}
+}
+
+func TestMergeFields(t *testing.T) {
+	original := []jsonField{
+		{Key: "a", Value: []byte(`1`)},
+		{Key: "b", Value: []byte(`2`)},
+		{Key: "c", Value: []byte(`3`)},
+	}
+	known := []jsonField{
+		{Key: "b", Value: []byte(`20`)},
+		{Key: "c"},
+		{Key: "d", Value: []byte(`4`)},
+	}
+
+	merged := mergeFields(original, known)
+
+	var got []string
+	for _, field := range merged {
+		got = append(got, field.Key+"="+string(field.Value))
+	}
+	if strings.Join(got, ",") != "a=1,b=20,d=4" {
+		t.Errorf("mergeFields() = %v", got)
+	}
+}
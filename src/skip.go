@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// SkipRules are the conditions under which hooks skip the analysis, so that sessions
+// too small to teach anything do not cost a model call (config.json "skip").
+type SkipRules struct {
+	// MinUserTurns is the minimum number of prompts typed by the user.
+	MinUserTurns int `json:"min_user_turns,omitempty"`
+	// MinCharacters is the minimum number of characters of the conversation.
+	MinCharacters int `json:"min_characters,omitempty"`
+	// IgnoreProjects are glob patterns of project paths whose sessions are never
+	// analyzed. A pattern matching a parent directory ignores the projects below it.
+	IgnoreProjects []string `json:"ignore_projects,omitempty"`
+	// Triggers are the hook invocations to skip: "PreCompact", "PreCompact:manual",
+	// "SessionEnd:clear" etc. The part after ':' is matched against the trigger or
+	// the reason of the hook input.
+	Triggers []string `json:"triggers,omitempty"`
+	// RequireToolUse skips sessions in which the assistant called no tool.
+	RequireToolUse bool `json:"require_tool_use,omitempty"`
+}
+
+// Validate checks the rules for mistakes that would otherwise only show when a hook runs.
+func (r SkipRules) Validate() error {
+	if r.MinUserTurns < 0 || r.MinCharacters < 0 {
+		return fmt.Errorf("skip.min_user_turns と skip.min_characters には0以上の値を指定してください")
+	}
+	for _, pattern := range r.IgnoreProjects {
+		if _, err := filepath.Match(ExpandTilde(pattern), ""); err != nil {
+			return fmt.Errorf("skip.ignore_projects のパターンが不正です: %s", pattern)
+		}
+	}
+	for _, trigger := range r.Triggers {
+		event, _, _ := strings.Cut(trigger, ":")
+		if strings.TrimSpace(event) == "" {
+			return fmt.Errorf("skip.triggers にはフックのイベント名を指定してください（例: PreCompact:auto）: %q", trigger)
+		}
+	}
+	return nil
+}
+
+// needsTranscript reports whether any rule depends on the content of the session.
+func (r SkipRules) needsTranscript() bool {
+	return r.MinUserTurns > 0 || r.MinCharacters > 0 || r.RequireToolUse
+}
+
+// Match returns the rule that skips the hook and why, or "" if the session is analyzed.
+// The rules that need no transcript are checked first.
+func (r SkipRules) Match(input HookInput, projectRoot, transcriptPath string) (string, error) {
+	for _, pattern := range r.IgnoreProjects {
+		if dir, ok := matchProjectPattern(ExpandTilde(pattern), projectRoot); ok {
+			return fmt.Sprintf("skip.ignore_projects（%s が %s に一致）", dir, pattern), nil
+		}
+	}
+	for _, trigger := range r.Triggers {
+		if matchTrigger(trigger, input) {
+			return fmt.Sprintf("skip.triggers（%s）", trigger), nil
+		}
+	}
+	if !r.needsTranscript() {
+		return "", nil
+	}
+
+	summary, err := SummarizeTranscript(transcriptPath)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case summary.UserTurns < r.MinUserTurns:
+		return fmt.Sprintf("skip.min_user_turns（ユーザーの発言 %d回 / 最小 %d回）", summary.UserTurns, r.MinUserTurns), nil
+	case summary.Characters < r.MinCharacters:
+		return fmt.Sprintf("skip.min_characters（会話 %d文字 / 最小 %d文字）", summary.Characters, r.MinCharacters), nil
+	case r.RequireToolUse && summary.ToolUses == 0:
+		return "skip.require_tool_use（ツールの呼び出しがありません）", nil
+	}
+	return "", nil
+}
+
+// matchProjectPattern reports whether the pattern matches the project path or one
+// of its parent directories, and returns the matching directory.
+func matchProjectPattern(pattern, projectRoot string) (string, bool) {
+	for dir := filepath.Clean(projectRoot); ; dir = filepath.Dir(dir) {
+		if ok, err := filepath.Match(filepath.Clean(pattern), dir); err == nil && ok {
+			return dir, true
+		}
+		if parent := filepath.Dir(dir); parent == dir {
+			return "", false
+		}
+	}
+}
+
+// matchTrigger reports whether the hook input matches a trigger of skip.triggers.
+func matchTrigger(trigger string, input HookInput) bool {
+	event, value, hasValue := strings.Cut(trigger, ":")
+	if !strings.EqualFold(strings.TrimSpace(event), input.HookEventName) {
+		return false
+	}
+	if !hasValue {
+		return true
+	}
+	value = strings.TrimSpace(value)
+	return (input.Trigger != "" && strings.EqualFold(value, input.Trigger)) ||
+		(input.Reason != "" && strings.EqualFold(value, input.Reason))
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSkipRules_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   SkipRules
+		wantErr string
+	}{
+		{"empty", SkipRules{}, ""},
+		{"valid", SkipRules{MinUserTurns: 2, IgnoreProjects: []string{"~/scratch/*"}, Triggers: []string{"PreCompact:auto", "SessionEnd"}}, ""},
+		{"negative", SkipRules{MinCharacters: -1}, "0以上の値"},
+		{"bad pattern", SkipRules{IgnoreProjects: []string{"/work/[a"}}, "skip.ignore_projects のパターンが不正です: /work/[a"},
+		{"no event", SkipRules{Triggers: []string{":manual"}}, "フックのイベント名を指定してください"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rules.Validate()
+			if (err == nil) != (tt.wantErr == "") || (err != nil && !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSkipRules_Match(t *testing.T) {
+	dir := t.TempDir()
+	transcriptPath := filepath.Join(dir, "session.jsonl")
+	transcript := `{"type":"user","message":{"role":"user","content":"今何時？"}}
+{"type":"assistant","message":{"role":"assistant","content":[{"type":"text","text":"わかりません"}]}}
+`
+	if err := os.WriteFile(transcriptPath, []byte(transcript), 0o600); err != nil {
+		t.Fatalf("Failed to create transcript: %v", err)
+	}
+	preCompact := HookInput{HookEventName: "PreCompact", Trigger: "auto"}
+	sessionEnd := HookInput{HookEventName: "SessionEnd", Reason: "clear"}
+
+	tests := []struct {
+		name  string
+		rules SkipRules
+		input HookInput
+		root  string
+		want  string
+	}{
+		{"no rules", SkipRules{}, sessionEnd, "/work/app", ""},
+		{"ignored project", SkipRules{IgnoreProjects: []string{"/work/*"}}, sessionEnd, "/work/app", "skip.ignore_projects（/work/app が /work/* に一致）"},
+		{"ignored parent", SkipRules{IgnoreProjects: []string{"/tmp"}}, sessionEnd, "/tmp/scratch/app", "skip.ignore_projects（/tmp が /tmp に一致）"},
+		{"other project", SkipRules{IgnoreProjects: []string{"/tmp/*"}}, sessionEnd, "/work/app", ""},
+		{"event", SkipRules{Triggers: []string{"precompact"}}, preCompact, "/work/app", "skip.triggers（precompact）"},
+		{"trigger", SkipRules{Triggers: []string{"PreCompact:auto"}}, preCompact, "/work/app", "skip.triggers（PreCompact:auto）"},
+		{"other trigger", SkipRules{Triggers: []string{"PreCompact:manual"}}, preCompact, "/work/app", ""},
+		{"reason", SkipRules{Triggers: []string{"SessionEnd:clear"}}, sessionEnd, "/work/app", "skip.triggers（SessionEnd:clear）"},
+		{"other event", SkipRules{Triggers: []string{"PreCompact:clear"}}, sessionEnd, "/work/app", ""},
+		{"user turns", SkipRules{MinUserTurns: 2}, sessionEnd, "/work/app", "skip.min_user_turns（ユーザーの発言 1回 / 最小 2回）"},
+		{"characters", SkipRules{MinCharacters: 100}, sessionEnd, "/work/app", "skip.min_characters（会話 10文字 / 最小 100文字）"},
+		{"enough", SkipRules{MinUserTurns: 1, MinCharacters: 10}, sessionEnd, "/work/app", ""},
+		{"no tool use", SkipRules{RequireToolUse: true}, sessionEnd, "/work/app", "skip.require_tool_use（ツールの呼び出しがありません）"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.rules.Match(tt.input, tt.root, transcriptPath)
+			if err != nil {
+				t.Fatalf("Match() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Match() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// 会話の内容を使うルールがなければ会話履歴を読まない
+	if got, err := (SkipRules{Triggers: []string{"PreCompact"}}).Match(sessionEnd, "/work/app", filepath.Join(dir, "missing.jsonl")); err != nil || got != "" {
+		t.Errorf("Match() without transcript rules = %q, %v", got, err)
+	}
+	if _, err := (SkipRules{RequireToolUse: true}).Match(sessionEnd, "/work/app", filepath.Join(dir, "missing.jsonl")); err == nil {
+		t.Error("Match() should fail when the transcript cannot be read")
+	}
+}
+
+func TestRun_SkipRules(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.json")
+	if err := os.WriteFile(configPath, []byte(`{"skip":{"min_user_turns":3}}`), 0o600); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+	// claudeが起動されればテストは失敗する
+	forbidClaude(t)
+	getenv := func(key string) string {
+		switch key {
+		case configEnvVar:
+			return configPath
+		case "XDG_RUNTIME_DIR":
+			return tmpDir
+		}
+		return ""
+	}
+
+	output := &bytes.Buffer{}
+	input := strings.NewReader(`{"transcript_path":"` + writeTestTranscript(t, tmpDir) + `","hook_event_name":"SessionEnd","reason":"other"}`)
+	if err := run(input, output, func() (string, error) { return tmpDir, nil }, getenv, time.Now); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	if want := "⏭️  スキップルールに該当するため、分析しません: skip.min_user_turns（ユーザーの発言 1回 / 最小 3回）"; !strings.Contains(output.String(), want) {
+		t.Errorf("Output should contain %q, got: %s", want, output.String())
+	}
+	if strings.Contains(output.String(), "会話履歴を分析中") {
+		t.Error("Skipped session should not be analyzed")
+	}
+}
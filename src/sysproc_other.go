@@ -0,0 +1,38 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// detachedSysProcAttr returns no attributes; sessions are a Unix concept.
+func detachedSysProcAttr() *syscall.SysProcAttr {
+	return nil
+}
+
+// processAlive reports whether a process with the PID exists.
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	_, err := os.FindProcess(pid)
+	return err == nil
+}
+
+// tryLockFile always succeeds; advisory locks are not available.
+func tryLockFile(file *os.File) (bool, error) {
+	return true, nil
+}
+
+// fileOwner is not supported; the owner is not checked.
+func fileOwner(info os.FileInfo) (int, bool) {
+	return 0, false
+}
+
+// parentPID is not supported; the process tree is not walked.
+func parentPID(pid int) (int, error) {
+	return 0, errors.New("親プロセスの取得に対応していません")
+}
@@ -0,0 +1,71 @@
+//go:build unix
+
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// detachedSysProcAttr starts a process in a new session, and so in its own process
+// group without a controlling terminal.
+func detachedSysProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
+
+// processAlive reports whether a process with the PID exists.
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// tryLockFile takes an exclusive advisory lock on the file without waiting.
+func tryLockFile(file *os.File) (bool, error) {
+	err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// fileOwner returns the uid of the owner of a file.
+func fileOwner(info os.FileInfo) (int, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return int(stat.Uid), true
+}
+
+// psPaths are where ps is looked for when PATH does not have it, e.g. in a
+// sanitized environment.
+var psPaths = []string{"/bin/ps", "/usr/bin/ps"}
+
+// parentPID returns the parent of a process. ps is used rather than /proc so that
+// it works on macOS as well.
+func parentPID(pid int) (int, error) {
+	ps, err := exec.LookPath("ps")
+	for _, path := range psPaths {
+		if err == nil {
+			break
+		}
+		if _, statErr := os.Stat(path); statErr == nil {
+			ps, err = path, nil
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	output, err := exec.Command(ps, "-o", "ppid=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(output)))
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const defaultTitleSimilarityThreshold = 0.7
+
+// defaultSectionAliases are the built-in aliases for common CLAUDE.md sections.
+var defaultSectionAliases = map[string][]string{
+	"overview":        {"概要", "プロジェクト概要", "about"},
+	"architecture":    {"アーキテクチャ", "構成", "設計"},
+	"structure":       {"ディレクトリ構成", "directory structure", "project structure"},
+	"tech stack":      {"技術スタック", "stack"},
+	"ci":              {"ci/cd", "継続的インテグレーション"},
+	"commands":        {"コマンド", "よく使うコマンド", "common commands"},
+	"development":     {"開発", "開発環境", "開発ガイド"},
+	"testing":         {"テスト", "tests"},
+	"build":           {"ビルド"},
+	"troubleshooting": {"トラブルシューティング", "トラブルシュート", "既知の問題", "known issues"},
+	"conventions":     {"規約", "コーディング規約", "開発ルール", "coding conventions", "code style"},
+	"notes":           {"注意事項", "メモ", "備考"},
+}
+
+// TitleMatchKind describes how a suggested title matched an existing one.
+type TitleMatchKind string
+
+const (
+	// TitleMatchExact means the normalized titles are equal
+	TitleMatchExact TitleMatchKind = "exact"
+	// TitleMatchAlias means the titles belong to the same alias group
+	TitleMatchAlias TitleMatchKind = "alias"
+	// TitleMatchFuzzy means the titles are similar above the threshold
+	TitleMatchFuzzy TitleMatchKind = "fuzzy"
+)
+
+// TitleMatch is the result of matching a suggested title against an existing section.
+type TitleMatch struct {
+	Kind  TitleMatchKind
+	Score float64
+}
+
+// String returns a human-readable description of the match.
+func (m TitleMatch) String() string {
+	switch m.Kind {
+	case TitleMatchAlias:
+		return "エイリアス一致"
+	case TitleMatchFuzzy:
+		return fmt.Sprintf("類似度 %.2f", m.Score)
+	default:
+		return ""
+	}
+}
+
+// TitleMatcher matches section titles by normalized form, aliases and similarity.
+type TitleMatcher struct {
+	aliases   map[string]string // normalized title -> canonical title
+	threshold float64
+}
+
+var (
+	titleNumberingRegex = regexp.MustCompile(`^(?:\d+(?:\.\d+)*\.?|[IVXivx]+\.)\s+`)
+	digitsRegex         = regexp.MustCompile(`\d+`)
+)
+
+// NewTitleMatcher builds a matcher from the built-in aliases plus extra aliases.
+// Each key of aliases is a canonical title and its values are alternative titles.
+func NewTitleMatcher(aliases map[string][]string, threshold float64) *TitleMatcher {
+	tm := &TitleMatcher{
+		aliases:   make(map[string]string),
+		threshold: threshold,
+	}
+	// マップの反復順に依存しないよう、正規のタイトルの順に登録する
+	for _, table := range []map[string][]string{defaultSectionAliases, aliases} {
+		canonicals := make([]string, 0, len(table))
+		for canonical := range table {
+			canonicals = append(canonicals, canonical)
+		}
+		sort.Strings(canonicals)
+		for _, canonical := range canonicals {
+			names := table[canonical]
+			key := tm.canonical(normalizeSectionTitle(canonical))
+			tm.aliases[normalizeSectionTitle(canonical)] = key
+			for _, name := range names {
+				tm.aliases[normalizeSectionTitle(name)] = key
+			}
+		}
+	}
+	return tm
+}
+
+// normalizeSectionTitle normalizes a title for matching: numbering, emoji,
+// Markdown markup and punctuation are removed and the case is folded.
+func normalizeSectionTitle(title string) string {
+	title = markupRegex.ReplaceAllString(strings.TrimSpace(title), "$1")
+	title = titleNumberingRegex.ReplaceAllString(title, "")
+	var b strings.Builder
+	space := false
+	for _, r := range strings.ToLower(title) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			space = false
+			b.WriteRune(r)
+		default:
+			space = true
+		}
+	}
+	return b.String()
+}
+
+// canonical returns the canonical form of a normalized title.
+func (tm *TitleMatcher) canonical(normalized string) string {
+	if key, ok := tm.aliases[normalized]; ok {
+		return key
+	}
+	return normalized
+}
+
+// Match compares an existing title with a suggested title.
+// It reports false when the titles do not match.
+func (tm *TitleMatcher) Match(existing, suggested string) (TitleMatch, bool) {
+	a, b := normalizeSectionTitle(existing), normalizeSectionTitle(suggested)
+	if a == "" || b == "" {
+		return TitleMatch{}, false
+	}
+	if a == b {
+		return TitleMatch{Kind: TitleMatchExact, Score: 1}, true
+	}
+	if tm.canonical(a) == tm.canonical(b) {
+		return TitleMatch{Kind: TitleMatchAlias, Score: 1}, true
+	}
+
+	// 番号が異なるタイトル（Section 1 / Section 2 など）は別のセクション
+	if strings.Join(digitsRegex.FindAllString(a, -1), ",") != strings.Join(digitsRegex.FindAllString(b, -1), ",") {
+		return TitleMatch{}, false
+	}
+
+	score := titleSimilarity(a, b)
+	if score >= tm.threshold {
+		return TitleMatch{Kind: TitleMatchFuzzy, Score: score}, true
+	}
+	return TitleMatch{}, false
+}
+
+// titleSimilarity scores an existing title against a suggested title (both normalized).
+// A suggested title whose words all appear in the existing one (e.g. "testing" for
+// "testing guide") scores high and grows with the length ratio; a suggested title
+// contained in the existing one without word boundaries (common in Japanese) scores
+// by the length ratio. The reverse is not treated as containment, so a more specific
+// suggestion such as "Another Main Title" does not merge into "Main Title".
+// Otherwise the bigram similarity is used for titles long enough to compare.
+func titleSimilarity(existing, suggested string) float64 {
+	existingLen, suggestedLen := len([]rune(existing)), len([]rune(suggested))
+
+	score := 0.0
+	if suggestedLen < existingLen {
+		ratio := float64(suggestedLen) / float64(existingLen)
+		switch {
+		case containsWords(existing, suggested):
+			score = 0.75 + 0.25*ratio
+		case strings.Contains(existing, suggested):
+			score = ratio
+		}
+	}
+	if existingLen >= fuzzyMinRunes && suggestedLen >= fuzzyMinRunes {
+		if s := similarity(existing, suggested); s > score {
+			score = s
+		}
+	}
+	return score
+}
+
+// containsWords reports whether every word of sub is a word of text.
+func containsWords(text, sub string) bool {
+	words := make(map[string]bool)
+	for _, word := range strings.Fields(text) {
+		words[word] = true
+	}
+	for _, word := range strings.Fields(sub) {
+		if !words[word] {
+			return false
+		}
+	}
+	return true
+}
+
+// FindBest returns the descendant of scope that best matches a suggested title
+// whose heading has the given level. Exact matches win over alias matches, which
+// win over fuzzy matches with a higher score; ties are broken by whether the level
+// is the same, then depth and document order. Fuzzy matches are only accepted for
+// direct children of scope or sections of the same level, so that "## Build" does
+// not merge into a nested "### Build errors".
+func (tm *TitleMatcher) FindBest(scope *SectionNode, title string, level int) (*SectionNode, TitleMatch) {
+	rank := map[TitleMatchKind]int{TitleMatchExact: 3, TitleMatchAlias: 2, TitleMatchFuzzy: 1}
+
+	var best *SectionNode
+	var bestMatch TitleMatch
+	bestSameLevel, bestDepth := false, 0
+
+	var walk func(node *SectionNode, depth int)
+	walk = func(node *SectionNode, depth int) {
+		for _, child := range node.Children {
+			sameLevel := child.Level == level
+			match, ok := tm.Match(child.Title, title)
+			if ok && match.Kind == TitleMatchFuzzy && depth > 1 && !sameLevel {
+				ok = false
+			}
+			if ok {
+				better := best == nil || rank[match.Kind] > rank[bestMatch.Kind]
+				if !better && rank[match.Kind] == rank[bestMatch.Kind] {
+					switch {
+					case match.Score != bestMatch.Score:
+						better = match.Score > bestMatch.Score
+					case sameLevel != bestSameLevel:
+						better = sameLevel
+					default:
+						better = depth < bestDepth
+					}
+				}
+				if better {
+					best, bestMatch, bestSameLevel, bestDepth = child, match, sameLevel, depth
+				}
+			}
+			walk(child, depth+1)
+		}
+	}
+	walk(scope, 1)
+
+	return best, bestMatch
+}
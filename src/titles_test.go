@@ -0,0 +1,197 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeSectionTitle(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"Troubleshooting", "troubleshooting"},
+		{"1. Getting Started", "getting started"},
+		{"2.3 **Build** `commands`", "build commands"},
+		{"🔧 トラブルシューティング", "トラブルシューティング"},
+		{"Q&A", "q a"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeSectionTitle(tt.input); got != tt.want {
+			t.Errorf("normalizeSectionTitle(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestTitleMatcher_Match(t *testing.T) {
+	tm := NewTitleMatcher(map[string][]string{"deploy": {"デプロイ手順"}}, defaultTitleSimilarityThreshold)
+
+	tests := []struct {
+		name      string
+		existing  string
+		suggested string
+		wantOK    bool
+		wantKind  TitleMatchKind
+	}{
+		{"exact", "Commands", "commands", true, TitleMatchExact},
+		{"numbering ignored", "1. Commands", "Commands", true, TitleMatchExact},
+		{"built-in alias", "トラブルシューティング", "Troubleshooting", true, TitleMatchAlias},
+		{"alias to alias", "Known Issues", "トラブルシュート", true, TitleMatchAlias},
+		{"configured alias", "デプロイ手順", "Deploy", true, TitleMatchAlias},
+		{"contained title", "Troubleshooting Guide", "Troubleshooting", true, TitleMatchFuzzy},
+		{"japanese contained title", "トラブルシューティングガイド", "トラブルシューティング", true, TitleMatchFuzzy},
+		{"typo", "Architecture Overview", "Architecure Overview", true, TitleMatchFuzzy},
+		{"different numbers", "Section 1", "Section 3", false, ""},
+		{"short different titles", "Section A", "Section C", false, ""},
+		{"prefix of a word", "Testing Framework", "Test", false, ""},
+		{"unrelated", "Commands", "Architecture", false, ""},
+		{"empty", "", "Commands", false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, ok := tm.Match(tt.existing, tt.suggested)
+			if ok != tt.wantOK {
+				t.Fatalf("Match(%q, %q) ok = %v, want %v (score %.2f)", tt.existing, tt.suggested, ok, tt.wantOK, match.Score)
+			}
+			if ok && match.Kind != tt.wantKind {
+				t.Errorf("Match(%q, %q) kind = %s, want %s", tt.existing, tt.suggested, match.Kind, tt.wantKind)
+			}
+		})
+	}
+}
+
+func TestTitleMatcher_FindBest(t *testing.T) {
+	root := ParseSectionTree(`# Project
+
+## Troubleshooting Guide
+
+## Notes
+
+### Troubleshooting
+`)
+	tm := NewTitleMatcher(nil, defaultTitleSimilarityThreshold)
+
+	// 完全一致は深い階層でも類似一致より優先される
+	node, match := tm.FindBest(root, "Troubleshooting", 2)
+	if node == nil || node.Path() != "Project > Notes > Troubleshooting" || match.Kind != TitleMatchExact {
+		t.Errorf("Unexpected best match: %v (%+v)", node, match)
+	}
+
+	node, match = tm.FindBest(root, "Troubleshooting guides", 2)
+	if node == nil || node.Title != "Troubleshooting Guide" || match.Kind != TitleMatchFuzzy {
+		t.Errorf("Unexpected fuzzy match: %v (%+v)", node, match)
+	}
+
+	if node, _ := tm.FindBest(root, "Deployment", 2); node != nil {
+		t.Errorf("Expected no match, got %q", node.Title)
+	}
+}
+
+func TestTitleMatcher_FindBestLevels(t *testing.T) {
+	root := ParseSectionTree(`# Project
+
+## Development
+
+### Build errors
+
+### Testing
+
+## Tests
+`)
+	tm := NewTitleMatcher(nil, defaultTitleSimilarityThreshold)
+
+	// 深い階層の類似一致には統合しない
+	if node, match := tm.FindBest(root, "Build", 2); node != nil {
+		t.Errorf("Build should not match a nested section, got %q (%+v)", node.Path(), match)
+	}
+	if node, _ := tm.FindBest(root, "Build", 3); node == nil || node.Title != "Build errors" {
+		t.Errorf("Build at the same level should match Build errors, got %v", node)
+	}
+
+	// 同じ階層のエイリアス一致を深い階層のエイリアス一致より優先する
+	node, match := tm.FindBest(root, "テスト", 2)
+	if node == nil || node.Title != "Tests" || match.Kind != TitleMatchAlias {
+		t.Errorf("Unexpected alias match: %v (%+v)", node, match)
+	}
+}
+
+func TestNewTitleMatcher_Deterministic(t *testing.T) {
+	// 複数の正規タイトルに同じ別名があっても、結果は反復順に依存しない
+	aliases := map[string][]string{
+		"alpha": {"shared"},
+		"beta":  {"shared"},
+		"gamma": {"alpha"},
+		"delta": {"beta"},
+	}
+	want := NewTitleMatcher(aliases, defaultTitleSimilarityThreshold).aliases
+	for i := 0; i < 20; i++ {
+		got := NewTitleMatcher(aliases, defaultTitleSimilarityThreshold).aliases
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("NewTitleMatcher() aliases = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestTitleMatch_String(t *testing.T) {
+	if got := (TitleMatch{Kind: TitleMatchExact, Score: 1}).String(); got != "" {
+		t.Errorf("Exact match should have no description, got %q", got)
+	}
+	if got := (TitleMatch{Kind: TitleMatchAlias, Score: 1}).String(); got != "エイリアス一致" {
+		t.Errorf("Alias match = %q", got)
+	}
+	if got := (TitleMatch{Kind: TitleMatchFuzzy, Score: 0.8123}).String(); got != "類似度 0.81" {
+		t.Errorf("Fuzzy match = %q", got)
+	}
+}
+
+func TestMergeSections_AliasAndFuzzyTitles(t *testing.T) {
+	existing := `# Project
+
+## トラブルシューティング
+
+- 既存の項目
+
+## Testing Guide
+
+- go test ./...
+`
+
+	suggestion := `## Troubleshooting
+
+- New item
+
+## Testing
+
+- make test
+`
+
+	result := MergeSections(existing, suggestion)
+
+	if strings.Contains(result.Content, "## Troubleshooting") || strings.Count(result.Content, "## Testing") != 1 {
+		t.Errorf("Sections should be merged into existing ones, got:\n%s", result.Content)
+	}
+	if !strings.Contains(result.Content, "- 既存の項目\n\n- New item\n\n## Testing Guide") {
+		t.Errorf("New item should be merged into トラブルシューティング, got:\n%s", result.Content)
+	}
+
+	if len(result.Decisions) != 2 {
+		t.Fatalf("Expected 2 decisions, got %+v", result.Decisions)
+	}
+	if got := result.Decisions[0].String(); !strings.Contains(got, "トラブルシューティング") || !strings.Contains(got, "エイリアス一致") {
+		t.Errorf("Alias decision = %q", got)
+	}
+	if got := result.Decisions[1].String(); !strings.Contains(got, "Testing Guide") || !strings.Contains(got, "類似度") {
+		t.Errorf("Fuzzy decision = %q", got)
+	}
+}
+
+func TestTitleMatcher_MoreSpecificSuggestion(t *testing.T) {
+	// 既存より具体的な提案タイトルは別のセクションとして扱う
+	tm := NewTitleMatcher(nil, defaultTitleSimilarityThreshold)
+	if _, ok := tm.Match("Main Title", "Another Main Title"); ok {
+		t.Error("More specific suggested title should not match")
+	}
+}
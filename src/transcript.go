@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 )
 
@@ -12,6 +13,11 @@ const (
 	contentTypeText = "text"
 )
 
+// transcriptSessionID returns the session ID of a transcript, its file name without extension.
+func transcriptSessionID(transcriptPath string) string {
+	return strings.TrimSuffix(filepath.Base(transcriptPath), filepath.Ext(transcriptPath))
+}
+
 // ExtractConversationHistory extracts conversation history from transcript file.
 func ExtractConversationHistory(transcriptPath string) (string, error) {
 	file, err := os.Open(transcriptPath)
@@ -45,7 +51,16 @@ func ExtractConversationHistory(transcriptPath string) (string, error) {
 		}
 
 		// フォーマット: ### {role}\n\n{content}\n
-		history.WriteString(fmt.Sprintf("### %s\n\n%s\n\n", role, content))
+		// 提案の根拠として参照できるよう、UUIDとタイムスタンプがあれば見出しに含める
+		heading := role
+		if msg.UUID != "" {
+			heading = fmt.Sprintf("%s (uuid: %s", role, msg.UUID)
+			if msg.Timestamp != "" {
+				heading += ", " + msg.Timestamp
+			}
+			heading += ")"
+		}
+		history.WriteString(fmt.Sprintf("### %s\n\n%s\n\n", heading, content))
 	}
 
 	if err := scanner.Err(); err != nil {
@@ -251,3 +251,23 @@ func TestExtractConversationHistory_Formatting(t *testing.T) {
 		t.Errorf("Expected format:\n%q\nGot:\n%q", expected, result)
 	}
 }
+
+func TestExtractConversationHistory_MessageReferences(t *testing.T) {
+	tmpDir := t.TempDir()
+	tmpFile := filepath.Join(tmpDir, "refs.jsonl")
+	content := `{"uuid":"u1","timestamp":"2025-01-01T00:00:00Z","message":{"role":"user","content":"Question"}}
+{"uuid":"u2","message":{"role":"assistant","content":"Answer"}}`
+	if err := os.WriteFile(tmpFile, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+
+	result, err := ExtractConversationHistory(tmpFile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := "### user (uuid: u1, 2025-01-01T00:00:00Z)\n\nQuestion\n\n### assistant (uuid: u2)\n\nAnswer"
+	if result != expected {
+		t.Errorf("Expected format:\n%q\nGot:\n%q", expected, result)
+	}
+}
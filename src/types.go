@@ -6,12 +6,17 @@ package main
 type HookInput struct {
 	TranscriptPath string `json:"transcript_path"`
 	HookEventName  string `json:"hook_event_name"`
-	Trigger        string `json:"trigger"`
+	Trigger        string `json:"trigger"` // PreCompact: manual or auto
+	Reason         string `json:"reason"`  // SessionEnd: clear, logout, prompt_input_exit, other
 }
 
 // Message represents a single message in the conversation.
 type Message struct {
-	Message MessageContent `json:"message"`
+	UUID      string         `json:"uuid"`
+	Timestamp string         `json:"timestamp"`
+	GitBranch string         `json:"gitBranch"`
+	IsMeta    bool           `json:"isMeta"` // messages inserted by Claude Code, not typed by the user
+	Message   MessageContent `json:"message"`
 }
 
 // MessageContent contains the role and content of a message.
@@ -0,0 +1,418 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// usageFileName is the file of the output directory recording the usage of every run.
+// It does not start with outputFilePrefix, so that PruneOutputs leaves it alone.
+const usageFileName = "usage.jsonl"
+
+// ClaudeUsage is the token usage and the estimated cost reported by
+// claude --output-format json for one run.
+type ClaudeUsage struct {
+	InputTokens              int     `json:"input_tokens"`
+	OutputTokens             int     `json:"output_tokens"`
+	CacheCreationInputTokens int     `json:"cache_creation_input_tokens,omitempty"`
+	CacheReadInputTokens     int     `json:"cache_read_input_tokens,omitempty"`
+	CostUSD                  float64 `json:"cost_usd"`
+	APIDurationMillis        int64   `json:"api_duration_ms,omitempty"`
+	// Reported is false when claude printed no usage, e.g. a wrapper printing text.
+	Reported bool `json:"reported"`
+}
+
+// TotalInputTokens returns the input tokens including those read from or written to the cache.
+func (u ClaudeUsage) TotalInputTokens() int {
+	return u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+}
+
+// String returns the usage as shown to the user.
+func (u ClaudeUsage) String() string {
+	return fmt.Sprintf("入力 %dトークン / 出力 %dトークン / 推定コスト $%.4f", u.TotalInputTokens(), u.OutputTokens, u.CostUSD)
+}
+
+// claudeResult is the result message printed by claude --output-format json.
+type claudeResult struct {
+	Type          string  `json:"type"`
+	Subtype       string  `json:"subtype"`
+	IsError       bool    `json:"is_error"`
+	Result        string  `json:"result"`
+	DurationAPIMs int64   `json:"duration_api_ms"`
+	TotalCostUSD  float64 `json:"total_cost_usd"`
+	Usage         struct {
+		InputTokens              int `json:"input_tokens"`
+		OutputTokens             int `json:"output_tokens"`
+		CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+		CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+	} `json:"usage"`
+}
+
+// parseClaudeOutput returns the response and the usage in the output of claude.
+// Output that is not a JSON result, e.g. of a wrapper script printing text, is the
+// response itself without usage. An error result is returned as an error.
+func parseClaudeOutput(data []byte) (string, ClaudeUsage, error) {
+	result, ok := findClaudeResult(bytes.TrimSpace(data))
+	if !ok {
+		return string(data), ClaudeUsage{}, nil
+	}
+
+	usage := ClaudeUsage{
+		InputTokens:              result.Usage.InputTokens,
+		OutputTokens:             result.Usage.OutputTokens,
+		CacheCreationInputTokens: result.Usage.CacheCreationInputTokens,
+		CacheReadInputTokens:     result.Usage.CacheReadInputTokens,
+		CostUSD:                  result.TotalCostUSD,
+		APIDurationMillis:        result.DurationAPIMs,
+		Reported:                 true,
+	}
+	if result.IsError {
+		return result.Result, usage, fmt.Errorf("claudeがエラーを返しました (%s): %s", result.Subtype, strings.TrimSpace(result.Result))
+	}
+	return result.Result, usage, nil
+}
+
+// findClaudeResult decodes the result message, given alone or as the last result
+// of the message list printed with --verbose.
+func findClaudeResult(data []byte) (claudeResult, bool) {
+	var result claudeResult
+	if bytes.HasPrefix(data, []byte("{")) {
+		if err := json.Unmarshal(data, &result); err == nil && result.Type == "result" {
+			return result, true
+		}
+		return result, false
+	}
+
+	var messages []json.RawMessage
+	if !bytes.HasPrefix(data, []byte("[")) || json.Unmarshal(data, &messages) != nil {
+		return result, false
+	}
+	for i := len(messages) - 1; i >= 0; i-- {
+		if err := json.Unmarshal(messages[i], &result); err == nil && result.Type == "result" {
+			return result, true
+		}
+	}
+	return claudeResult{}, false
+}
+
+// UsageRecord is the usage of one run of claude, appended to the usage file.
+type UsageRecord struct {
+	Time           time.Time   `json:"time"`
+	SessionID      string      `json:"session_id,omitempty"`
+	ProjectRoot    string      `json:"project_root"`
+	HookInfo       string      `json:"hook_info"`
+	Status         JobStatus   `json:"status"` // succeeded, invalid or failed
+	DurationMillis int64       `json:"duration_ms"`
+	SuggestionFile string      `json:"suggestion_file,omitempty"`
+	Usage          ClaudeUsage `json:"usage"`
+}
+
+// UsagePath returns the usage file: usage_file of the config file, or usage.jsonl
+// in the state directory. The output directory is not used because it is cleared
+// on reboot, which would reset the monthly budget.
+func UsagePath(config *Config, getenv func(string) string) string {
+	if config.UsageFile != "" {
+		return ExpandTilde(config.UsageFile)
+	}
+	return filepath.Join(ResolveStateDir(getenv), usageFileName)
+}
+
+// AppendUsage appends a record to the usage file. Each record is a single write of
+// one line, so that concurrent runs do not interleave.
+func AppendUsage(path string, record UsageRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("使用量の記録に失敗: %w", err)
+	}
+	if err := EnsurePrivateDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, privateFilePerm)
+	if err != nil {
+		return fmt.Errorf("使用量の記録に失敗: %w", err)
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		_ = file.Close() // nolint:errcheck // Best-effort cleanup in error path
+		return fmt.Errorf("使用量の記録に失敗: %w", err)
+	}
+	return file.Close()
+}
+
+// LoadUsage reads the usage file, oldest first. A missing file yields no records and
+// unreadable lines are skipped.
+func LoadUsage(path string) ([]UsageRecord, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("使用量の読み込みに失敗: %w", err)
+	}
+	defer file.Close() // nolint:errcheck // Read-only file
+
+	var records []UsageRecord
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record UsageRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("使用量の読み込みに失敗: %w", err)
+	}
+	return records, nil
+}
+
+// recordUsage appends the usage of a run and shows it. Failing to record never fails
+// the analysis.
+func recordUsage(config *Config, getenv func(string) string, record UsageRecord, output io.Writer) {
+	if record.Usage.Reported {
+		_, _ = fmt.Fprintf(output, "💰 %s\n", record.Usage) // nolint:errcheck // Output to user, error not critical
+	}
+	if err := AppendUsage(UsagePath(config, getenv), record); err != nil {
+		_, _ = fmt.Fprintf(output, "⚠️  %v\n", err) // nolint:errcheck // Output to user, error not critical
+	}
+}
+
+// monthStart returns the first moment of the month of t in its location.
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+// ValidateBudget checks monthly_budget_usd.
+func (c *Config) ValidateBudget() error {
+	if c.MonthlyBudgetUSD < 0 {
+		return fmt.Errorf("monthly_budget_usd には0以上の値を指定してください: %g", c.MonthlyBudgetUSD)
+	}
+	return nil
+}
+
+// monthlySpend returns the estimated cost of the runs in the month of now.
+func monthlySpend(records []UsageRecord, now time.Time) float64 {
+	start := monthStart(now)
+	var spent float64
+	for _, record := range records {
+		if !record.Time.Before(start) {
+			spent += record.Usage.CostUSD
+		}
+	}
+	return spent
+}
+
+// budgetExceeded returns why hooks stop analyzing because the monthly budget is
+// used up, or "" if there is no budget or it is not reached yet.
+func budgetExceeded(config *Config, getenv func(string) string, now time.Time) (string, error) {
+	if err := config.ValidateBudget(); err != nil {
+		return "", err
+	}
+	if config.MonthlyBudgetUSD == 0 {
+		return "", nil
+	}
+	records, err := LoadUsage(UsagePath(config, getenv))
+	if err != nil {
+		return "", err
+	}
+	if spent := monthlySpend(records, now); spent >= config.MonthlyBudgetUSD {
+		return fmt.Sprintf("今月の推定コスト $%.2f が予算 $%.2f に達したため", spent, config.MonthlyBudgetUSD), nil
+	}
+	return "", nil
+}
+
+// UsageSummary totals the usage of several runs.
+type UsageSummary struct {
+	Runs         int
+	InputTokens  int
+	OutputTokens int
+	CostUSD      float64
+	Unreported   int // runs without usage
+}
+
+// Add adds a run to the summary.
+func (s *UsageSummary) Add(record UsageRecord) {
+	s.Runs++
+	s.InputTokens += record.Usage.TotalInputTokens()
+	s.OutputTokens += record.Usage.OutputTokens
+	s.CostUSD += record.Usage.CostUSD
+	if !record.Usage.Reported {
+		s.Unreported++
+	}
+}
+
+// SummarizeUsage totals the records by the key, returning the keys in sorted order.
+func SummarizeUsage(records []UsageRecord, key func(UsageRecord) string) ([]string, map[string]*UsageSummary) {
+	summaries := make(map[string]*UsageSummary)
+	var keys []string
+	for _, record := range records {
+		k := key(record)
+		summary, ok := summaries[k]
+		if !ok {
+			summary = &UsageSummary{}
+			summaries[k] = summary
+			keys = append(keys, k)
+		}
+		summary.Add(record)
+	}
+	sort.Strings(keys)
+	return keys, summaries
+}
+
+// runStatsCommand runs `suggest-claude-md stats`, summarizing the usage per project and per day.
+func runStatsCommand(args []string, output io.Writer, getwd func() (string, error), getenv func(string) string, now func() time.Time) error {
+	current := now().Local()
+	filter, err := parseStatsFlags(args, getwd, current)
+	if err != nil {
+		return err
+	}
+
+	config, err := LoadConfig(ConfigPath(getenv))
+	if err != nil {
+		return err
+	}
+	path := UsagePath(config, getenv)
+	records, err := LoadUsage(path)
+	if err != nil {
+		return err
+	}
+
+	last := current
+	if !filter.until.IsZero() {
+		last = filter.until.AddDate(0, 0, -1)
+	}
+	period := filter.since.Format(batchDateLayout) + " 〜 " + last.Format(batchDateLayout)
+	_, _ = fmt.Fprintf(output, "📊 使用量: %s\n", path) // nolint:errcheck // Output to user, error not critical
+	_, _ = fmt.Fprintf(output, "期間: %s\n", period)  // nolint:errcheck // Output to user, error not critical
+	if filter.projectRoot != "" {
+		_, _ = fmt.Fprintf(output, "プロジェクト: %s\n", filter.projectRoot) // nolint:errcheck // Output to user, error not critical
+	}
+	printUsageReport(output, filter.Select(records))
+
+	// 予算は期間の指定にかかわらず今月の全プロジェクトで判定する
+	spent := monthlySpend(records, current)
+	if config.MonthlyBudgetUSD > 0 {
+		_, _ = fmt.Fprintf(output, "\n今月の推定コスト: $%.2f / 予算 $%.2f（%.0f%%）\n", spent, config.MonthlyBudgetUSD, spent/config.MonthlyBudgetUSD*100) // nolint:errcheck // Output to user, error not critical
+		if spent >= config.MonthlyBudgetUSD {
+			_, _ = fmt.Fprintln(output, "⏭️  予算に達したため、フックは分析をスキップします") // nolint:errcheck // Output to user, error not critical
+		}
+	} else {
+		_, _ = fmt.Fprintf(output, "\n今月の推定コスト: $%.2f（予算なし）\n", spent) // nolint:errcheck // Output to user, error not critical
+	}
+	return nil
+}
+
+// usageFilter selects the usage records summarized by the stats command.
+type usageFilter struct {
+	projectRoot string    // empty for all projects
+	since       time.Time // inclusive
+	until       time.Time // exclusive, zero for no upper bound
+}
+
+// parseStatsFlags parses the arguments of the stats command.
+// The period starts at the first day of the current month unless --since is given.
+func parseStatsFlags(args []string, getwd func() (string, error), current time.Time) (usageFilter, error) {
+	const usage = "使い方: suggest-claude-md stats [--project <dir>] [--since YYYY-MM-DD] [--until YYYY-MM-DD]"
+
+	var projectRoot, sinceArg, untilArg string
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	fs.StringVar(&projectRoot, "project", "", "Only summarize runs of this project")
+	fs.StringVar(&sinceArg, "since", "", "Summarize runs on or after this date (default: first day of this month)")
+	fs.StringVar(&untilArg, "until", "", "Summarize runs on or before this date")
+	rest, err := parseSubcommandFlags(fs, args)
+	if err != nil {
+		return usageFilter{}, fmt.Errorf("%w\n%s", err, usage)
+	}
+	if len(rest) > 0 {
+		return usageFilter{}, fmt.Errorf("%s", usage)
+	}
+
+	filter := usageFilter{since: monthStart(current)}
+	if sinceArg != "" {
+		if filter.since, err = parseStatsDate(sinceArg); err != nil {
+			return usageFilter{}, err
+		}
+	}
+	if untilArg != "" {
+		if filter.until, err = parseStatsDate(untilArg); err != nil {
+			return usageFilter{}, err
+		}
+		// 指定日の終わりまでを含める
+		filter.until = filter.until.AddDate(0, 0, 1)
+	}
+	if projectRoot != "" {
+		if filter.projectRoot, err = resolveProjectRoot(projectRoot, getwd); err != nil {
+			return usageFilter{}, err
+		}
+	}
+	return filter, nil
+}
+
+// parseStatsDate parses a YYYY-MM-DD date in the local time zone.
+func parseStatsDate(value string) (time.Time, error) {
+	date, err := time.ParseInLocation(batchDateLayout, value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("無効な日付: %s (YYYY-MM-DD形式で指定してください)", value)
+	}
+	return date, nil
+}
+
+// Select returns the records within the period and of the project of the filter.
+func (f usageFilter) Select(records []UsageRecord) []UsageRecord {
+	var selected []UsageRecord
+	for _, record := range records {
+		if record.Time.Before(f.since) || (!f.until.IsZero() && !record.Time.Before(f.until)) {
+			continue
+		}
+		if f.projectRoot != "" && record.ProjectRoot != f.projectRoot {
+			continue
+		}
+		selected = append(selected, record)
+	}
+	return selected
+}
+
+// printUsageReport prints the records totaled per project, per day and overall.
+func printUsageReport(output io.Writer, records []UsageRecord) {
+	if len(records) == 0 {
+		_, _ = fmt.Fprintln(output, "\n記録された実行はありません") // nolint:errcheck // Output to user, error not critical
+		return
+	}
+
+	byProject := func(record UsageRecord) string { return record.ProjectRoot }
+	byDay := func(record UsageRecord) string { return record.Time.Local().Format(batchDateLayout) }
+	printUsageSummaries(output, "プロジェクト別", records, byProject)
+	printUsageSummaries(output, "日別", records, byDay)
+
+	var total UsageSummary
+	for _, record := range records {
+		total.Add(record)
+	}
+	_, _ = fmt.Fprintf(output, "\n合計: %s\n", formatUsageSummary(&total)) // nolint:errcheck // Output to user, error not critical
+	if total.Unreported > 0 {
+		_, _ = fmt.Fprintf(output, "⚠️  %d回の実行は使用量が報告されていません（claudeがJSONを出力しなかった場合など）\n", total.Unreported) // nolint:errcheck // Output to user, error not critical
+	}
+}
+
+// printUsageSummaries prints the records totaled by the key under the title.
+func printUsageSummaries(output io.Writer, title string, records []UsageRecord, key func(UsageRecord) string) {
+	keys, summaries := SummarizeUsage(records, key)
+	_, _ = fmt.Fprintf(output, "\n%s:\n", title) // nolint:errcheck // Output to user, error not critical
+	for _, k := range keys {
+		_, _ = fmt.Fprintf(output, "  %s\n    %s\n", k, formatUsageSummary(summaries[k])) // nolint:errcheck // Output to user, error not critical
+	}
+}
+
+// formatUsageSummary returns a summary line of the stats command.
+func formatUsageSummary(s *UsageSummary) string {
+	return fmt.Sprintf("%d回  入力 %dトークン  出力 %dトークン  推定コスト $%.4f", s.Runs, s.InputTokens, s.OutputTokens, s.CostUSD)
+}
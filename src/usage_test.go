@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// claudeJSONResult is the output of claude --output-format json for the response.
+func claudeJSONResult(response string) string {
+	return `{"type":"result","subtype":"success","is_error":false,"duration_ms":2100,"duration_api_ms":1800,` +
+		`"result":` + strconv.Quote(response) + `,"total_cost_usd":0.0125,` +
+		`"usage":{"input_tokens":40,"cache_creation_input_tokens":1000,"cache_read_input_tokens":2000,"output_tokens":150}}`
+}
+
+// usageEnv returns a getenv with the output directory and the config file in dir.
+// claude fails unless the test installs a fake one.
+func usageEnv(t *testing.T, dir, config string) func(string) string {
+	t.Helper()
+	// claudeが起動されればテストは失敗する
+	forbidClaude(t)
+	configPath := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(configPath, []byte(config), 0o600); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+	return func(key string) string {
+		switch key {
+		case "XDG_RUNTIME_DIR":
+			return dir
+		case "XDG_STATE_HOME":
+			return filepath.Join(dir, "state")
+		case configEnvVar:
+			return configPath
+		}
+		return ""
+	}
+}
+
+func TestParseClaudeOutput(t *testing.T) {
+	want := ClaudeUsage{InputTokens: 40, OutputTokens: 150, CacheCreationInputTokens: 1000, CacheReadInputTokens: 2000, CostUSD: 0.0125, APIDurationMillis: 1800, Reported: true}
+	tests := []struct {
+		name      string
+		output    string
+		wantText  string
+		wantUsage ClaudeUsage
+		wantErr   string
+	}{
+		{"result", claudeJSONResult("## Testing\n\n- go test\n"), "## Testing\n\n- go test\n", want, ""},
+		{"verbose", `[{"type":"system","subtype":"init"},` + claudeJSONResult("## A\n") + "]\n", "## A\n", want, ""},
+		{"text", "## Testing\n\n- go test\n", "## Testing\n\n- go test\n", ClaudeUsage{}, ""},
+		{"other json", `{"type":"assistant"}`, `{"type":"assistant"}`, ClaudeUsage{}, ""},
+		{"error", `{"type":"result","subtype":"error_max_turns","is_error":true,"result":"Credit balance is too low","total_cost_usd":0.5}`,
+			"Credit balance is too low", ClaudeUsage{CostUSD: 0.5, Reported: true}, "claudeがエラーを返しました (error_max_turns): Credit balance is too low"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, usage, err := parseClaudeOutput([]byte(tt.output))
+			if (err == nil) != (tt.wantErr == "") || (err != nil && err.Error() != tt.wantErr) {
+				t.Errorf("parseClaudeOutput() error = %v, want %q", err, tt.wantErr)
+			}
+			if text != tt.wantText || usage != tt.wantUsage {
+				t.Errorf("parseClaudeOutput() = %q, %+v, want %q, %+v", text, usage, tt.wantText, tt.wantUsage)
+			}
+		})
+	}
+	if got := want.TotalInputTokens(); got != 3040 {
+		t.Errorf("TotalInputTokens() = %d, want 3040", got)
+	}
+}
+
+func TestAppendUsage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", usageFileName)
+	first := UsageRecord{Time: time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC), ProjectRoot: "/work/app", Status: JobSucceeded, Usage: ClaudeUsage{OutputTokens: 10, CostUSD: 0.01, Reported: true}}
+	second := UsageRecord{Time: time.Date(2026, 10, 2, 9, 0, 0, 0, time.UTC), ProjectRoot: "/work/lib", Status: JobFailed}
+	for _, record := range []UsageRecord{first, second} {
+		if err := AppendUsage(path, record); err != nil {
+			t.Fatalf("AppendUsage() error = %v", err)
+		}
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != privateFilePerm {
+		t.Errorf("Usage file should be private: %v %v", info, err)
+	}
+
+	// 壊れた行は読み飛ばす
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		t.Fatalf("Failed to open usage file: %v", err)
+	}
+	_, _ = file.WriteString("{broken\n") // nolint:errcheck // Checked by LoadUsage
+	_ = file.Close()                     // nolint:errcheck // Checked by LoadUsage
+
+	records, err := LoadUsage(path)
+	if err != nil {
+		t.Fatalf("LoadUsage() error = %v", err)
+	}
+	if len(records) != 2 || !records[0].Time.Equal(first.Time) || records[0].Usage != first.Usage || records[1].ProjectRoot != "/work/lib" {
+		t.Errorf("LoadUsage() = %+v", records)
+	}
+	if records, err := LoadUsage(filepath.Join(t.TempDir(), "missing.jsonl")); err != nil || records != nil {
+		t.Errorf("LoadUsage() of a missing file = %v, %v", records, err)
+	}
+}
+
+func TestBudgetExceeded(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.Local)
+	tmpDir := t.TempDir()
+	getenv := func(key string) string {
+		if key == "HOME" {
+			return tmpDir
+		}
+		return ""
+	}
+	path := UsagePath(&Config{}, getenv)
+	if want := filepath.Join(tmpDir, ".local", "state", commandName, usageFileName); path != want {
+		t.Errorf("UsagePath() = %s, want %s", path, want)
+	}
+	// 先月の実行は今月の予算に含めない
+	for _, record := range []UsageRecord{
+		{Time: time.Date(2026, 9, 30, 23, 0, 0, 0, time.Local), Usage: ClaudeUsage{CostUSD: 5}},
+		{Time: time.Date(2026, 10, 1, 0, 0, 0, 0, time.Local), Usage: ClaudeUsage{CostUSD: 1.5}},
+		{Time: time.Date(2026, 10, 17, 8, 0, 0, 0, time.Local), Usage: ClaudeUsage{CostUSD: 0.5}},
+	} {
+		if err := AppendUsage(path, record); err != nil {
+			t.Fatalf("AppendUsage() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		budget  float64
+		want    string
+		wantErr bool
+	}{
+		{"no budget", 0, "", false},
+		{"under", 2.5, "", false},
+		{"reached", 2, "今月の推定コスト $2.00 が予算 $2.00 に達したため", false},
+		{"negative", -1, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := budgetExceeded(&Config{MonthlyBudgetUSD: tt.budget}, getenv, now)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("budgetExceeded() = %q, %v, want %q (error: %v)", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBudgetExceeded_SurvivesRuntimeDirChange(t *testing.T) {
+	home := t.TempDir()
+	// 再起動やログアウトで XDG_RUNTIME_DIR が変わっても使用量は失われない
+	envWithRuntimeDir := func(runtimeDir string) func(string) string {
+		return func(key string) string {
+			switch key {
+			case "HOME":
+				return home
+			case "XDG_RUNTIME_DIR":
+				return runtimeDir
+			}
+			return ""
+		}
+	}
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.Local)
+	before := envWithRuntimeDir(t.TempDir())
+	if err := AppendUsage(UsagePath(&Config{}, before), UsageRecord{Time: now, Usage: ClaudeUsage{CostUSD: 3}}); err != nil {
+		t.Fatalf("AppendUsage() error = %v", err)
+	}
+
+	after := envWithRuntimeDir(t.TempDir())
+	got, err := budgetExceeded(&Config{MonthlyBudgetUSD: 2}, after, now)
+	if err != nil || got == "" {
+		t.Errorf("budgetExceeded() after the runtime directory changed = %q, %v", got, err)
+	}
+}
+
+func TestRun_MonthlyBudget(t *testing.T) {
+	tmpDir := t.TempDir()
+	getenv := usageEnv(t, tmpDir, `{"monthly_budget_usd":1}`)
+	if err := AppendUsage(filepath.Join(tmpDir, "state", commandName, usageFileName), UsageRecord{Time: time.Now(), Usage: ClaudeUsage{CostUSD: 1.25}}); err != nil {
+		t.Fatalf("AppendUsage() error = %v", err)
+	}
+
+	output := &bytes.Buffer{}
+	input := strings.NewReader(`{"transcript_path":"` + writeTestTranscript(t, tmpDir) + `","hook_event_name":"SessionEnd"}`)
+	if err := run(input, output, func() (string, error) { return tmpDir, nil }, getenv, time.Now); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	if want := "⏭️  今月の推定コスト $1.25 が予算 $1.00 に達したため、分析しません"; !strings.Contains(output.String(), want) {
+		t.Errorf("Output should contain %q, got: %s", want, output.String())
+	}
+	if strings.Contains(output.String(), "会話履歴を分析中") {
+		t.Error("Analysis should be skipped over the budget")
+	}
+}
+
+func TestAnalyzeTranscript_RecordsUsage(t *testing.T) {
+	tmpDir := t.TempDir()
+	getenv := usageEnv(t, tmpDir, `{}`)
+	writeFakeClaude(t, claudeJSONResult("## Testing\n\n- go test ./...\n"))
+
+	output := &bytes.Buffer{}
+	opts := AnalyzeOptions{TranscriptPath: writeTestTranscript(t, tmpDir), ProjectRoot: tmpDir, HookInfo: "Hook: SessionEnd"}
+	result, err := analyzeTranscript(opts, output, getenv, time.Now)
+	if err != nil {
+		t.Fatalf("analyzeTranscript() error = %v", err)
+	}
+
+	// 提案ファイルにはJSONではなく応答の本文を保存する
+	if content, _ := os.ReadFile(result.SuggestionFile); string(content) != "## Testing\n\n- go test ./...\n" { // nolint:errcheck // Checked by content
+		t.Errorf("Suggestion = %q", content)
+	}
+	if !result.Valid || result.Usage.OutputTokens != 150 {
+		t.Errorf("result = %+v", result)
+	}
+	// 応答の本文は画面にも表示する
+	if want := "## Testing\n\n- go test ./...\n"; !strings.Contains(output.String(), want) || strings.Contains(output.String(), `"type":"result"`) {
+		t.Errorf("Output should contain the response %q, got: %s", want, output.String())
+	}
+	if want := "💰 入力 3040トークン / 出力 150トークン / 推定コスト $0.0125"; !strings.Contains(output.String(), want) {
+		t.Errorf("Output should contain %q, got: %s", want, output.String())
+	}
+	if log, _ := os.ReadFile(result.LogFile); !strings.Contains(string(log), "## フック実行情報\n\nHook: SessionEnd\n\n入力 3040トークン / 出力 150トークン") { // nolint:errcheck // Checked by content
+		t.Errorf("Log should contain the hook information and the usage: %s", log)
+	}
+
+	records, err := LoadUsage(filepath.Join(tmpDir, "state", commandName, usageFileName))
+	if err != nil || len(records) != 1 {
+		t.Fatalf("LoadUsage() = %v, %v", records, err)
+	}
+	record := records[0]
+	if record.SessionID != "transcript" || record.ProjectRoot != tmpDir || record.Status != JobSucceeded ||
+		record.SuggestionFile != result.SuggestionFile || record.Usage.CostUSD != 0.0125 || record.HookInfo != "Hook: SessionEnd" {
+		t.Errorf("record = %+v", record)
+	}
+
+	// claudeが失敗しても使用量を記録する
+	writeFakeClaude(t, `{"type":"result","subtype":"error_during_execution","is_error":true,"result":"overloaded","total_cost_usd":0.002}`)
+	_, err = analyzeTranscript(opts, &bytes.Buffer{}, getenv, time.Now)
+	if err == nil || !strings.Contains(err.Error(), "overloaded") {
+		t.Errorf("analyzeTranscript() error = %v, want the error of claude", err)
+	}
+	records, _ = LoadUsage(filepath.Join(tmpDir, "state", commandName, usageFileName)) // nolint:errcheck // Checked by length
+	if len(records) != 2 || records[1].Status != JobFailed || records[1].Usage.CostUSD != 0.002 {
+		t.Errorf("records = %+v", records)
+	}
+}
+
+func TestRunStatsCommand(t *testing.T) {
+	tmpDir := t.TempDir()
+	getenv := usageEnv(t, tmpDir, `{"monthly_budget_usd":2}`)
+	now := func() time.Time { return time.Date(2026, 10, 18, 12, 0, 0, 0, time.Local) }
+	path := filepath.Join(tmpDir, "state", commandName, usageFileName)
+	for _, record := range []UsageRecord{
+		{Time: time.Date(2026, 9, 30, 9, 0, 0, 0, time.Local), ProjectRoot: "/work/app", Usage: ClaudeUsage{InputTokens: 1000, OutputTokens: 100, CostUSD: 3, Reported: true}},
+		{Time: time.Date(2026, 10, 2, 9, 0, 0, 0, time.Local), ProjectRoot: "/work/app", Usage: ClaudeUsage{InputTokens: 100, CacheReadInputTokens: 50, OutputTokens: 10, CostUSD: 0.5, Reported: true}},
+		{Time: time.Date(2026, 10, 2, 18, 0, 0, 0, time.Local), ProjectRoot: "/work/lib", Usage: ClaudeUsage{InputTokens: 200, OutputTokens: 20, CostUSD: 0.25, Reported: true}},
+		{Time: time.Date(2026, 10, 17, 9, 0, 0, 0, time.Local), ProjectRoot: "/work/app"},
+	} {
+		if err := AppendUsage(path, record); err != nil {
+			t.Fatalf("AppendUsage() error = %v", err)
+		}
+	}
+	getwd := func() (string, error) { return tmpDir, nil }
+
+	output := &bytes.Buffer{}
+	if err := runStatsCommand(nil, output, getwd, getenv, now); err != nil {
+		t.Fatalf("runStatsCommand() error = %v", err)
+	}
+	for _, want := range []string{
+		"期間: 2026-10-01 〜 2026-10-18\n",
+		"プロジェクト別:\n  /work/app\n    2回  入力 150トークン  出力 10トークン  推定コスト $0.5000\n  /work/lib\n    1回  入力 200トークン  出力 20トークン  推定コスト $0.2500\n",
+		"日別:\n  2026-10-02\n    2回  入力 350トークン  出力 30トークン  推定コスト $0.7500\n  2026-10-17\n    1回",
+		"合計: 3回  入力 350トークン  出力 30トークン  推定コスト $0.7500\n",
+		"⚠️  1回の実行は使用量が報告されていません",
+		"今月の推定コスト: $0.75 / 予算 $2.00（38%）",
+	} {
+		if !strings.Contains(output.String(), want) {
+			t.Errorf("Output should contain %q, got:\n%s", want, output.String())
+		}
+	}
+
+	// 期間の指定は集計のみに影響し、予算は今月で判定する
+	output.Reset()
+	if err := runStatsCommand([]string{"--since", "2026-09-01", "--until", "2026-09-30"}, output, getwd, getenv, now); err != nil {
+		t.Fatalf("runStatsCommand() error = %v", err)
+	}
+	if !strings.Contains(output.String(), "期間: 2026-09-01 〜 2026-09-30\n") || !strings.Contains(output.String(), "合計: 1回  入力 1000トークン  出力 100トークン  推定コスト $3.0000") ||
+		!strings.Contains(output.String(), "今月の推定コスト: $0.75") {
+		t.Errorf("Output = %s", output.String())
+	}
+
+	if err := runStatsCommand([]string{"--since", "10/01"}, output, getwd, getenv, now); err == nil || !strings.Contains(err.Error(), "無効な日付") {
+		t.Errorf("runStatsCommand() error = %v, want invalid date", err)
+	}
+}
+
+func TestUsageFilter_Select(t *testing.T) {
+	records := []UsageRecord{
+		{Time: time.Date(2026, 9, 30, 23, 59, 0, 0, time.Local), ProjectRoot: "/work/app"},
+		{Time: time.Date(2026, 10, 1, 0, 0, 0, 0, time.Local), ProjectRoot: "/work/app"},
+		{Time: time.Date(2026, 10, 5, 12, 0, 0, 0, time.Local), ProjectRoot: "/work/lib"},
+		{Time: time.Date(2026, 10, 6, 0, 0, 0, 0, time.Local), ProjectRoot: "/work/app"},
+	}
+	since := time.Date(2026, 10, 1, 0, 0, 0, 0, time.Local)
+	until := time.Date(2026, 10, 6, 0, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name   string
+		filter usageFilter
+		want   []int
+	}{
+		{name: "since only", filter: usageFilter{since: since}, want: []int{1, 2, 3}},
+		{name: "until is exclusive", filter: usageFilter{since: since, until: until}, want: []int{1, 2}},
+		{name: "project", filter: usageFilter{since: since, projectRoot: "/work/app"}, want: []int{1, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected := tt.filter.Select(records)
+			if len(selected) != len(tt.want) {
+				t.Fatalf("Select() = %+v, want records %v", selected, tt.want)
+			}
+			for i, index := range tt.want {
+				if !selected[i].Time.Equal(records[index].Time) {
+					t.Errorf("Select()[%d] = %+v, want %+v", i, selected[i], records[index])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// invalidSuggestionMarker is inserted before the extension of suggestion files
+// that failed validation, e.g. suggest-claude-md-abc-20240101-120000.invalid.md.
+const invalidSuggestionMarker = ".invalid"
+
+// errorOutputRegex matches the first line of error messages printed by the claude CLI.
+var errorOutputRegex = regexp.MustCompile(`(?i)^(?:error\b|api error\b|execution error\b|credit balance is too low|invalid api key|claude ai usage limit reached)`)
+
+// SuggestionValidation is the result of validating and sanitising a model response.
+type SuggestionValidation struct {
+	Content  string   // sanitised content
+	Fixes    []string // what was removed from the response
+	Problems []string // why the suggestion cannot be applied
+}
+
+// Valid reports whether the suggestion can be presented for apply.
+func (v SuggestionValidation) Valid() bool {
+	return len(v.Problems) == 0
+}
+
+// ValidateSuggestion sanitises a model response and checks that it is a usable suggestion.
+// Wrapping code fences, meta-chatter before the first heading and separators between
+// sections are removed; empty or error outputs and responses without a ## heading are invalid.
+func ValidateSuggestion(content string, format SuggestionFormat) SuggestionValidation {
+	v := SuggestionValidation{Content: content}
+
+	trimmed := strings.TrimSpace(content)
+	if trimmed == "" {
+		v.Problems = append(v.Problems, "出力が空です")
+		return v
+	}
+	if firstLine := strings.SplitN(trimmed, "\n", 2)[0]; errorOutputRegex.MatchString(firstLine) {
+		v.Problems = append(v.Problems, fmt.Sprintf("claudeがエラーを出力しました: %s", firstLine))
+		return v
+	}
+
+	if format == FormatOperations {
+		return validateOperations(v)
+	}
+
+	v.Content = v.unwrapFence(v.Content)
+	v.Content = v.stripPreamble(v.Content)
+	v.Content = v.stripSeparators(v.Content)
+	v.Content = strings.TrimSpace(v.Content) + "\n"
+
+	hasSection := false
+	for _, h := range ScanHeadings(v.Content) {
+		if h.Level == 2 {
+			hasSection = true
+			break
+		}
+	}
+	if !hasSection {
+		v.Problems = append(v.Problems, "## で始まるセクション見出しがありません")
+	}
+	if _, _, err := SplitSuggestion(v.Content); err != nil {
+		v.Problems = append(v.Problems, fmt.Sprintf("メタデータが不正です: %v", err))
+	}
+	return v
+}
+
+// validateOperations extracts and validates the JSON of a structured suggestion.
+func validateOperations(v SuggestionValidation) SuggestionValidation {
+	content := strings.TrimSpace(stripCodeFence(v.Content))
+	if idx := strings.IndexByte(content, '{'); idx > 0 {
+		// JSONより前の前置きを削除
+		content = content[idx:]
+		v.Fixes = append(v.Fixes, "JSONより前の前置きを削除")
+	}
+	if end := strings.LastIndexByte(content, '}'); end != -1 && end < len(content)-1 {
+		content = content[:end+1]
+		v.Fixes = append(v.Fixes, "JSONより後の説明文を削除")
+	}
+	if _, err := ParseOperations(content); err != nil {
+		v.Problems = append(v.Problems, err.Error())
+		return v
+	}
+	v.Content = content + "\n"
+	return v
+}
+
+// unwrapFence removes a ```markdown code fence around the suggestion. Only a fence
+// preceded by no heading (at most meta-chatter) is treated as wrapping the response.
+func (v *SuggestionValidation) unwrapFence(content string) string {
+	lines := splitLines(content)
+	first := -1
+	for i, line := range lines {
+		if matches := fenceOpenRegex.FindStringSubmatch(line.text); matches != nil {
+			switch strings.ToLower(strings.TrimSpace(matches[2])) {
+			case "", "markdown", "md":
+				first = i
+			}
+			break
+		}
+	}
+	if first == -1 || len(ScanHeadings(content[:lines[first].start])) > 0 {
+		return content
+	}
+
+	fence := fenceOpenRegex.FindStringSubmatch(lines[first].text)[1]
+	for i := first + 1; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i].text)
+		if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+			v.Fixes = append(v.Fixes, "全体を囲むコードフェンスを削除")
+			return content[:lines[first].start] + content[lines[first].end:lines[i].start] + content[lines[i].end:]
+		}
+	}
+	return content
+}
+
+// stripPreamble removes text before the first heading, such as "以下が提案です".
+func (v *SuggestionValidation) stripPreamble(content string) string {
+	headings := ScanHeadings(content)
+	if len(headings) == 0 || strings.TrimSpace(content[:headings[0].Start]) == "" {
+		return content
+	}
+	v.Fixes = append(v.Fixes, "最初の見出しより前の前置きを削除")
+	return content[headings[0].Start:]
+}
+
+// stripSeparators removes thematic breaks at section boundaries. Breaks inside a
+// section body are kept, as are setext underlines and lines in code blocks.
+func (v *SuggestionValidation) stripSeparators(content string) string {
+	lines := splitLines(content)
+	remove := sectionSeparatorLines(content, lines)
+	if len(remove) == 0 {
+		return content
+	}
+	v.Fixes = append(v.Fixes, "セクション間の区切り線を削除")
+	return removeLines(content, lines, remove)
+}
+
+// sectionSeparatorLines returns the indexes of the thematic breaks followed only by
+// blank lines before a heading, the metadata block or the end of the document.
+func sectionSeparatorLines(content string, lines []mdLine) map[int]bool {
+	headingLine := headingLines(content)
+	remove := make(map[int]bool)
+	fence := ""
+	for i, line := range lines {
+		if fence != "" {
+			trimmed := strings.TrimSpace(line.text)
+			if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+				fence = ""
+			}
+			continue
+		}
+		if matches := fenceOpenRegex.FindStringSubmatch(line.text); matches != nil {
+			fence = matches[1]
+			continue
+		}
+		if headingLine[i] || !thematicBreakRegex.MatchString(strings.TrimSpace(line.text)) {
+			continue
+		}
+
+		// 次の空行でない行が見出し、または文書の末尾であれば区切り線
+		next := i + 1
+		for next < len(lines) && (strings.TrimSpace(lines[next].text) == "" || remove[next]) {
+			next++
+		}
+		if next == len(lines) || headingLine[next] || isMetadataFence(lines[next].text) {
+			remove[i] = true
+		}
+	}
+	return remove
+}
+
+// headingLines returns the indexes of the lines that belong to a heading,
+// including setext underlines.
+func headingLines(content string) map[int]bool {
+	headingLine := make(map[int]bool)
+	for _, h := range ScanHeadings(content) {
+		for i := h.StartLine; i <= h.EndLine; i++ {
+			headingLine[i] = true
+		}
+	}
+	return headingLine
+}
+
+// removeLines returns content without the removed lines. Blank lines left
+// next to each other by the removal are collapsed into one.
+func removeLines(content string, lines []mdLine, remove map[int]bool) string {
+	var b strings.Builder
+	dropBlank, lastBlank := false, false
+	for i, line := range lines {
+		if remove[i] {
+			dropBlank = true
+			continue
+		}
+		blank := strings.TrimSpace(line.text) == ""
+		if blank && dropBlank && lastBlank {
+			continue
+		}
+		if !blank {
+			dropBlank = false
+		}
+		b.WriteString(content[line.start:line.end])
+		lastBlank = blank
+	}
+	return b.String()
+}
+
+// isMetadataFence reports whether the line opens a metadata block.
+func isMetadataFence(line string) bool {
+	matches := fenceOpenRegex.FindStringSubmatch(line)
+	return matches != nil && isMetadataInfo(matches[2])
+}
+
+// InvalidSuggestionPath returns the path a suggestion file is renamed to when it is invalid.
+func InvalidSuggestionPath(path string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + invalidSuggestionMarker + ext
+}
+
+// IsInvalidSuggestionPath reports whether the suggestion file was marked invalid.
+func IsInvalidSuggestionPath(path string) bool {
+	ext := filepath.Ext(path)
+	return strings.HasSuffix(strings.TrimSuffix(path, ext), invalidSuggestionMarker)
+}
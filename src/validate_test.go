@@ -0,0 +1,123 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateSuggestion(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		wantContent string
+		wantFixes   int
+		wantProblem string
+	}{
+		{
+			name:        "clean suggestion",
+			content:     "## Commands\n\n- make build\n",
+			wantContent: "## Commands\n\n- make build\n",
+		},
+		{
+			name:        "wrapping markdown fence",
+			content:     "```markdown\n## Commands\n\n- make build\n```\n",
+			wantContent: "## Commands\n\n- make build\n",
+			wantFixes:   1,
+		},
+		{
+			name:        "fence followed by metadata",
+			content:     "```md\n## Commands\n\n- make build\n```\n\n```json suggest-claude-md\n{\"items\":[{\"target\":\"Commands\",\"rationale\":\"r\"}]}\n```\n",
+			wantContent: "## Commands\n\n- make build\n\n```json suggest-claude-md\n{\"items\":[{\"target\":\"Commands\",\"rationale\":\"r\"}]}\n```\n",
+			wantFixes:   1,
+		},
+		{
+			name:        "preamble",
+			content:     "会話履歴を分析しました。以下が提案です。\n\n## Commands\n\n- make build\n",
+			wantContent: "## Commands\n\n- make build\n",
+			wantFixes:   1,
+		},
+		{
+			name:        "separators between sections",
+			content:     "## A\n\na\n\n---\n\n## B\n\nb\n\n---\n",
+			wantContent: "## A\n\na\n\n## B\n\nb\n",
+			wantFixes:   1,
+		},
+		{
+			name:        "separator inside section is kept",
+			content:     "## A\n\na\n\n---\n\nmore a\n",
+			wantContent: "## A\n\na\n\n---\n\nmore a\n",
+		},
+		{
+			name:        "separator in code block is kept",
+			content:     "## A\n\n```yaml\n---\n```\n\n## B\n\nb\n",
+			wantContent: "## A\n\n```yaml\n---\n```\n\n## B\n\nb\n",
+		},
+		{
+			name:        "empty",
+			content:     "  \n",
+			wantProblem: "出力が空です",
+		},
+		{
+			name:        "error output",
+			content:     "Error: Invalid API key\n",
+			wantProblem: "エラー",
+		},
+		{
+			name:        "no level-2 heading",
+			content:     "# Title\n\nText.\n",
+			wantProblem: "セクション見出しがありません",
+		},
+		{
+			name:        "invalid metadata",
+			content:     "## A\n\na\n\n```json suggest-claude-md\n{\"operations\":[{\"op\":\"move\",\"target\":\"A\",\"rationale\":\"r\"}]}\n```\n",
+			wantProblem: "メタデータが不正です",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := ValidateSuggestion(tt.content, FormatMarkdown)
+			if tt.wantProblem != "" {
+				if v.Valid() || !strings.Contains(strings.Join(v.Problems, "\n"), tt.wantProblem) {
+					t.Fatalf("Problems = %v, want %q", v.Problems, tt.wantProblem)
+				}
+				return
+			}
+			if !v.Valid() {
+				t.Fatalf("Unexpected problems: %v", v.Problems)
+			}
+			if v.Content != tt.wantContent {
+				t.Errorf("Content = %q, want %q", v.Content, tt.wantContent)
+			}
+			if len(v.Fixes) != tt.wantFixes {
+				t.Errorf("Fixes = %v, want %d", v.Fixes, tt.wantFixes)
+			}
+		})
+	}
+}
+
+func TestValidateSuggestion_Operations(t *testing.T) {
+	valid := "以下が提案です。\n```json\n{\"operations\":[{\"op\":\"delete\",\"target\":\"A\",\"rationale\":\"r\"}]}\n```\n"
+	v := ValidateSuggestion(valid, FormatOperations)
+	if !v.Valid() {
+		t.Fatalf("Unexpected problems: %v", v.Problems)
+	}
+	if !strings.HasPrefix(v.Content, "{") || !strings.HasSuffix(v.Content, "}\n") {
+		t.Errorf("Content should be the bare JSON, got %q", v.Content)
+	}
+
+	if v := ValidateSuggestion("## A\n\na\n", FormatOperations); v.Valid() {
+		t.Error("Markdown response should be invalid in operations format")
+	}
+}
+
+func TestInvalidSuggestionPath(t *testing.T) {
+	path := "/tmp/suggest-claude-md-abc-20240101-000000.md"
+	invalid := InvalidSuggestionPath(path)
+	if invalid != "/tmp/suggest-claude-md-abc-20240101-000000.invalid.md" {
+		t.Errorf("InvalidSuggestionPath() = %q", invalid)
+	}
+	if IsInvalidSuggestionPath(path) || !IsInvalidSuggestionPath(invalid) {
+		t.Error("IsInvalidSuggestionPath() should only match invalid paths")
+	}
+}
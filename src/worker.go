@@ -0,0 +1,422 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// executionModeEnvVar overrides execution_mode of the config file (set by --async).
+	executionModeEnvVar = "SUGGEST_CLAUDE_MD_EXECUTION_MODE"
+	// workerCommandEnvVar overrides the command started as the async worker.
+	workerCommandEnvVar = "SUGGEST_CLAUDE_MD_WORKER_COMMAND"
+)
+
+// ExecutionMode selects whether a hook waits for the analysis.
+type ExecutionMode string
+
+const (
+	// ModeSync runs the analysis in the hook so the result is shown in Claude Code
+	ModeSync ExecutionMode = "sync"
+	// ModeAsync hands the analysis to a detached worker and returns immediately
+	ModeAsync ExecutionMode = "async"
+)
+
+// ParseExecutionMode parses an execution mode name. An empty value means sync.
+func ParseExecutionMode(value string) (ExecutionMode, error) {
+	switch mode := ExecutionMode(strings.ToLower(strings.TrimSpace(value))); mode {
+	case "", ModeSync:
+		return ModeSync, nil
+	case ModeAsync:
+		return ModeAsync, nil
+	default:
+		return "", fmt.Errorf("無効な実行モード: %s (有効な値: sync, async)", value)
+	}
+}
+
+// executionModeFor returns the execution mode of hooks: the environment variable
+// (or --async) takes precedence over the config file.
+func executionModeFor(config *Config, getenv func(string) string) (ExecutionMode, error) {
+	if value := getenv(executionModeEnvVar); value != "" {
+		return ParseExecutionMode(value)
+	}
+	return ParseExecutionMode(config.ExecutionMode)
+}
+
+// workerCommand returns the executable started as the async worker.
+func workerCommand(getenv func(string) string) (string, error) {
+	if command := getenv(workerCommandEnvVar); command != "" {
+		return command, nil
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("実行ファイルのパスの取得に失敗: %w", err)
+	}
+	return executable, nil
+}
+
+// startAsyncAnalysis queues the analysis as a job and starts a detached worker for
+// the project. The worker is double-forked: the first child starts a new session and
+// starts the worker, so the worker is neither a child of the hook nor a session leader.
+// If a worker is already processing the project's queue, the new one exits at once.
+func startAsyncAnalysis(opts AnalyzeOptions, config *Config, output io.Writer, getenv func(string) string, now func() time.Time) error {
+	// 設定の誤りは通知できないため、フックの時点で報告する
+	if _, err := NewNotifiers(config); err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+	store, job, err := queueAnalysis(opts, getenv, now)
+	if err != nil {
+		return err
+	}
+
+	executable, err := workerCommand(getenv)
+	if err == nil {
+		cmd := exec.Command(executable, "worker", "--detach", store.Path(job.ID))
+		cmd.SysProcAttr = detachedSysProcAttr()
+		// ログの設定はオプションで指定されることがあるため、ワーカーに引き継ぐ
+		cmd.Env = append(os.Environ(), loggingEnv(getenv)...)
+		var out []byte
+		if out, err = cmd.CombinedOutput(); err != nil {
+			err = fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+		}
+	}
+	if err != nil {
+		job.Status = JobFailed
+		job.Error = fmt.Sprintf("ワーカーの起動に失敗: %v", err)
+		_ = store.Save(job) // nolint:errcheck // The error below is what matters
+		return fmt.Errorf("❌ ワーカーの起動に失敗: %w", err)
+	}
+
+	_, _ = fmt.Fprintf(output, "🕒 分析をキューに追加しました（ジョブ: %s）\n", job.ID) // nolint:errcheck // Output to user, error not critical
+	if queued, err := store.Queued(job.ProjectRoot); err == nil && len(queued) > 1 {
+		_, _ = fmt.Fprintf(output, "先に%d件のジョブが待機しています。順番に分析します\n", len(queued)-1) // nolint:errcheck // Output to user, error not critical
+	}
+	_, _ = fmt.Fprintln(output, "状態の確認: suggest-claude-md jobs")                             // nolint:errcheck // Output to user, error not critical
+	_, _ = fmt.Fprintf(output, "ジョブのログ: %s\n", store.WorkerLogPath(job.ID))                  // nolint:errcheck // Output to user, error not critical
+	_, _ = fmt.Fprintf(output, "ワーカーのログ: %s\n", store.ProjectWorkerLogPath(job.ProjectRoot)) // nolint:errcheck // Output to user, error not critical
+	return nil
+}
+
+// runSyncAnalysis queues the analysis like an async hook, then processes the
+// project's queue in the hook and shows the analysis on the output. When a worker
+// or another hook is already processing the queue, the job is left to it, so that
+// concurrent hooks of a project never run claude in parallel.
+func runSyncAnalysis(opts AnalyzeOptions, output io.Writer, getenv func(string) string, now func() time.Time) error {
+	store, job, err := queueAnalysis(opts, getenv, now)
+	if err != nil {
+		return err
+	}
+	if err := runQueue(store.Path(job.ID), output, &foregroundJob{id: job.ID, output: output}, getenv, now); err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+
+	job, err = LoadJob(store.Path(job.ID))
+	if err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+	switch {
+	case !job.Status.Finished():
+		_, _ = fmt.Fprintln(output, "状態の確認: suggest-claude-md jobs")            // nolint:errcheck // Output to user, error not critical
+		_, _ = fmt.Fprintf(output, "ジョブのログ: %s\n", store.WorkerLogPath(job.ID)) // nolint:errcheck // Output to user, error not critical
+	case job.Status == JobFailed:
+		return fmt.Errorf("❌ %s", job.Error)
+	}
+	return nil
+}
+
+// queueAnalysis records the analysis as a queued job of the project.
+func queueAnalysis(opts AnalyzeOptions, getenv func(string) string, now func() time.Time) (*JobStore, *Job, error) {
+	transcriptPath := ExpandTilde(opts.TranscriptPath)
+	if _, err := os.Stat(transcriptPath); os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("❌ ファイルが存在しません: %s", transcriptPath)
+	}
+
+	store, err := NewJobStore(ResolveOutputDir(getenv))
+	if err != nil {
+		return nil, nil, fmt.Errorf("❌ %w", err)
+	}
+	job := &Job{
+		Status:         JobQueued,
+		ProjectRoot:    opts.ProjectRoot,
+		TranscriptPath: transcriptPath,
+		HookInfo:       opts.HookInfo,
+		ConfigPath:     ConfigPath(getenv),
+		Terminal:       terminalName(),
+		CreatedAt:      now(),
+	}
+	if err := store.Create(job); err != nil {
+		return nil, nil, fmt.Errorf("❌ %w", err)
+	}
+	logger.Info("job queued", "job_id", job.ID, "session_id", transcriptSessionID(transcriptPath), "project", job.ProjectRoot)
+	return store, job, nil
+}
+
+// foregroundJob is a job queued by a synchronous hook: its analysis is shown on the
+// hook's output instead of being notified.
+type foregroundJob struct {
+	id     string
+	output io.Writer
+}
+
+// runWorkerCommand runs the internal `worker` command started by async hooks.
+// The worker processes the queued jobs of the job's project; with --detach it only
+// starts the worker in the background.
+func runWorkerCommand(args []string, output io.Writer, getenv func(string) string, now func() time.Time) error {
+	const usage = "使い方: suggest-claude-md worker [--detach] <ジョブファイル>"
+
+	var detach bool
+	fs := flag.NewFlagSet("worker", flag.ContinueOnError)
+	fs.BoolVar(&detach, "detach", false, "Start the worker in the background and exit")
+	rest, err := parseSubcommandFlags(fs, args)
+	if err != nil {
+		return fmt.Errorf("%w\n%s", err, usage)
+	}
+	if len(rest) != 1 {
+		return fmt.Errorf("%s", usage)
+	}
+	if detach {
+		return detachWorker(rest[0], getenv)
+	}
+	return runQueue(rest[0], output, nil, getenv, now)
+}
+
+// detachWorker starts the worker for the project of a job with its output appended
+// to the project's worker log, and returns without waiting for it.
+func detachWorker(jobPath string, getenv func(string) string) error {
+	job, err := LoadJob(jobPath)
+	if err != nil {
+		return err
+	}
+	store := &JobStore{dir: filepath.Dir(jobPath)}
+	logFile, err := os.OpenFile(store.ProjectWorkerLogPath(job.ProjectRoot), os.O_CREATE|os.O_WRONLY|os.O_APPEND, privateFilePerm)
+	if err != nil {
+		return fmt.Errorf("ワーカーのログの作成に失敗: %w", err)
+	}
+	defer logFile.Close() // nolint:errcheck // The worker holds its own descriptor
+
+	executable, err := workerCommand(getenv)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(executable, "worker", jobPath)
+	cmd.Dir = job.ProjectRoot
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("ワーカーの起動に失敗: %w", err)
+	}
+	return cmd.Process.Release()
+}
+
+// runQueue processes the queued jobs of the job's project one at a time, oldest
+// first. Only one worker runs per project: a worker that cannot take the project
+// lock exits and leaves its job to the running one. After releasing the lock the
+// queue is checked again, so that a job queued while the worker was finishing is
+// not left behind. foreground is the job of a synchronous hook, or nil.
+func runQueue(jobPath string, output io.Writer, foreground *foregroundJob, getenv func(string) string, now func() time.Time) error {
+	job, err := LoadJob(jobPath)
+	if err != nil {
+		return err
+	}
+	store := &JobStore{dir: filepath.Dir(jobPath)}
+	projectRoot := job.ProjectRoot
+
+	for {
+		unlock, ok, err := store.LockProject(projectRoot)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			logger.Info("worker already running", "job_id", job.ID, "project", projectRoot)
+			_, _ = fmt.Fprintf(output, "⏭️  %s のワーカーは既に実行中です（ジョブ %s はそのワーカーが処理します）\n", projectRoot, job.ID) // nolint:errcheck // Output to user, error not critical
+			return nil
+		}
+		err = processQueue(store, projectRoot, output, foreground, getenv, now)
+		unlock()
+		if err != nil {
+			return err
+		}
+
+		queued, err := store.Queued(projectRoot)
+		if err != nil || len(queued) == 0 {
+			return err
+		}
+	}
+}
+
+// processQueue runs the queued jobs of the project until none is left. It must be
+// called with the project lock held.
+func processQueue(store *JobStore, projectRoot string, output io.Writer, foreground *foregroundJob, getenv func(string) string, now func() time.Time) error {
+	// 異常終了したワーカーの実行中ジョブを失敗として記録する
+	jobs, err := store.List()
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		if job.ProjectRoot == projectRoot && job.Stale() {
+			job.Status, job.Error, job.FinishedAt = JobFailed, "ワーカーが異常終了しました", now()
+			logger.Warn("stale job marked failed", "job_id", job.ID, "pid", job.PID)
+			if err := store.Save(job); err != nil {
+				return err
+			}
+		}
+	}
+
+	for {
+		queued, err := store.Queued(projectRoot)
+		if err != nil {
+			return err
+		}
+		if len(queued) == 0 {
+			return nil
+		}
+		job := queued[0]
+
+		// ジョブごとの出力はジョブのログに記録する
+		logFile, err := os.OpenFile(store.WorkerLogPath(job.ID), os.O_CREATE|os.O_WRONLY|os.O_APPEND, privateFilePerm)
+		if err != nil {
+			return fmt.Errorf("ジョブのログの作成に失敗: %w", err)
+		}
+		var shown io.Writer
+		if foreground != nil && foreground.id == job.ID {
+			shown = foreground.output
+		}
+		err = runJob(store, job, logFile, shown, getenv, now)
+		_ = logFile.Close() // nolint:errcheck // Log output is best-effort
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(output, "%s %s\n", job.ID, job.StatusLabel()) // nolint:errcheck // Output to user, error not critical
+	}
+}
+
+// runJob runs the analysis of a queued job, records the outcome and notifies the user.
+// The suggestions of earlier jobs that are still pending are passed to the analysis
+// so that they are not suggested again. Analysis failures are recorded in the job;
+// only failures to update the store are returned. The analysis of a job shown on
+// the terminal of a synchronous hook (shown is not nil) is not notified.
+func runJob(store *JobStore, job *Job, logFile, shown io.Writer, getenv func(string) string, now func() time.Time) error {
+	output := logFile
+	if shown != nil {
+		output = io.MultiWriter(logFile, shown)
+	}
+	// フック実行時の設定ファイルを使う
+	if job.ConfigPath != "" {
+		envGetter := getenv
+		getenv = func(key string) string {
+			if key == configEnvVar {
+				return job.ConfigPath
+			}
+			return envGetter(key)
+		}
+	}
+
+	job.Status, job.PID, job.StartedAt = JobRunning, os.Getpid(), now()
+	if err := store.Save(job); err != nil {
+		return err
+	}
+	jobLog := logger.With("job_id", job.ID, "session_id", transcriptSessionID(job.TranscriptPath), "project", job.ProjectRoot)
+	jobLog.Info("job started", "queued_ms", job.StartedAt.Sub(job.CreatedAt).Milliseconds())
+	_, _ = fmt.Fprintf(output, "🕒 ジョブ %s を開始しました（PID %d）\n", job.ID, job.PID) // nolint:errcheck // Output to user, error not critical
+
+	var result AnalyzeResult
+	var analyzeErr error
+	if reason := queuedBudgetReason(getenv, now()); reason != "" {
+		// キューで待つ間に先のジョブが予算を使い切った
+		result = skipAnalysis(result, reason, output, jobLog)
+	} else {
+		pending, err := pendingSuggestionContents(store, job.ProjectRoot)
+		if err != nil {
+			_, _ = fmt.Fprintf(output, "⚠️  未適用の提案の読み込みに失敗: %v\n", err) // nolint:errcheck // Output to user, error not critical
+		}
+		result, analyzeErr = analyzeTranscript(AnalyzeOptions{
+			TranscriptPath:     job.TranscriptPath,
+			ProjectRoot:        job.ProjectRoot,
+			HookInfo:           fmt.Sprintf("%s [job: %s]", job.HookInfo, job.ID),
+			PendingSuggestions: pending,
+		}, output, getenv, now)
+	}
+	job.SuggestionFile, job.LogFile = result.SuggestionFile, result.LogFile
+	switch {
+	case analyzeErr != nil:
+		job.Status = JobFailed
+		job.Error = strings.TrimPrefix(analyzeErr.Error(), "❌ ")
+		// 同期実行のフックにはエラーとして返すため、ジョブのログにのみ記録する
+		_, _ = fmt.Fprintln(logFile, analyzeErr) // nolint:errcheck // Output to user, error not critical
+	case result.Skipped:
+		job.Status, job.SkipReason = JobSkipped, result.SkipReason
+	case !result.Valid:
+		job.Status = JobInvalid
+	default:
+		job.Status = JobSucceeded
+	}
+	job.FinishedAt = now()
+	if err := store.Save(job); err != nil {
+		return err
+	}
+	jobLog.Info("job finished", "status", string(job.Status), "duration_ms", job.FinishedAt.Sub(job.StartedAt).Milliseconds(), "error", job.Error)
+
+	if shown == nil {
+		notifyJob(job, output, getenv)
+	}
+	if err := store.Prune(RetentionPolicyFromEnv(getenv), now()); err != nil {
+		_, _ = fmt.Fprintf(output, "⚠️  %v\n", err) // nolint:errcheck // Output to user, error not critical
+	}
+	return nil
+}
+
+// queuedBudgetReason returns why a queued job is not analyzed because the monthly
+// budget was used up after the hook queued it. Errors are left to the analysis.
+func queuedBudgetReason(getenv func(string) string, now time.Time) string {
+	config, err := LoadConfig(ConfigPath(getenv))
+	if err != nil {
+		return ""
+	}
+	reason, err := budgetExceeded(config, getenv, now)
+	if err != nil {
+		return ""
+	}
+	return reason
+}
+
+// pendingSuggestionContents reads the suggestions of the project that are not applied yet.
+func pendingSuggestionContents(store *JobStore, projectRoot string) ([]string, error) {
+	jobs, err := store.PendingSuggestions(projectRoot)
+	if err != nil {
+		return nil, err
+	}
+	var contents []string
+	for _, job := range jobs {
+		content, err := os.ReadFile(job.SuggestionFile)
+		if err != nil {
+			return contents, fmt.Errorf("提案ファイルの読み込みに失敗: %w", err)
+		}
+		contents = append(contents, string(content))
+	}
+	return contents, nil
+}
+
+// notifyJob runs the configured notifiers. Failures are only logged.
+func notifyJob(job *Job, output io.Writer, getenv func(string) string) {
+	config, err := LoadConfig(ConfigPath(getenv))
+	var notifiers []Notifier
+	if err == nil {
+		notifiers, err = NewNotifiers(config)
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(output, "⚠️  通知の設定が不正です: %v\n", err) // nolint:errcheck // Output to user, error not critical
+		return
+	}
+	for _, notifier := range notifiers {
+		if err := notifier.Notify(job); err != nil {
+			logger.Warn("notification failed", "job_id", job.ID, "notifier", notifier.Name(), "error", err)
+			_, _ = fmt.Fprintf(output, "⚠️  通知に失敗 (%s): %v\n", notifier.Name(), err) // nolint:errcheck // Output to user, error not critical
+		}
+	}
+}
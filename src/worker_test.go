@@ -0,0 +1,367 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseExecutionMode(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    ExecutionMode
+		wantErr bool
+	}{
+		{"", ModeSync, false},
+		{"sync", ModeSync, false},
+		{" Async ", ModeAsync, false},
+		{"background", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseExecutionMode(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseExecutionMode(%q) = %q, %v, want %q (error: %v)", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestRun_AsyncMode(t *testing.T) {
+	tmpDir := t.TempDir()
+	transcriptPath := writeTestTranscript(t, tmpDir)
+
+	// 起動されたワーカーの引数を記録する偽のワーカー
+	argsFile := filepath.Join(tmpDir, "worker-args")
+	workerCommand := filepath.Join(tmpDir, "worker")
+	if err := os.WriteFile(workerCommand, []byte("#!/bin/sh\necho \"$@\" > '"+argsFile+"'\n"), 0o700); err != nil {
+		t.Fatalf("Failed to create fake worker: %v", err)
+	}
+	env := map[string]string{
+		"XDG_RUNTIME_DIR":   tmpDir,
+		executionModeEnvVar: "async",
+		workerCommandEnvVar: workerCommand,
+		configEnvVar:        filepath.Join(tmpDir, "missing-config.json"),
+	}
+	getenv := func(key string) string { return env[key] }
+	now := func() time.Time { return time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	output := &bytes.Buffer{}
+	input := strings.NewReader(`{"transcript_path":"` + transcriptPath + `","hook_event_name":"SessionEnd"}`)
+	if err := run(input, output, func() (string, error) { return tmpDir, nil }, getenv, now); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	if !strings.Contains(output.String(), "分析をキューに追加しました（ジョブ: 20250102-030405-transcript）") {
+		t.Errorf("Output should report the job, got: %s", output.String())
+	}
+	store := &JobStore{dir: filepath.Join(tmpDir, commandName, jobsDirName)}
+	for _, want := range []string{"ジョブのログ: " + store.WorkerLogPath("20250102-030405-transcript"), "ワーカーのログ: " + store.ProjectWorkerLogPath(tmpDir)} {
+		if !strings.Contains(output.String(), want) {
+			t.Errorf("Output should contain %q, got: %s", want, output.String())
+		}
+	}
+
+	jobPath := filepath.Join(tmpDir, commandName, jobsDirName, "20250102-030405-transcript.json")
+	job, err := LoadJob(jobPath)
+	if err != nil {
+		t.Fatalf("LoadJob() error = %v", err)
+	}
+	if job.Status != JobQueued || job.ProjectRoot != tmpDir || job.TranscriptPath != transcriptPath || job.HookInfo != "Hook: SessionEnd (trigger: )" {
+		t.Errorf("job = %+v", job)
+	}
+	if job.ConfigPath != env[configEnvVar] {
+		t.Errorf("ConfigPath = %q, want %q", job.ConfigPath, env[configEnvVar])
+	}
+	args, _ := os.ReadFile(argsFile) // nolint:errcheck // Checked by content
+	if want := "worker --detach " + jobPath + "\n"; string(args) != want {
+		t.Errorf("worker args = %q, want %q", args, want)
+	}
+
+	// 待機中のジョブがあれば件数を表示する
+	output.Reset()
+	input = strings.NewReader(`{"transcript_path":"` + transcriptPath + `"}`)
+	if err := run(input, output, func() (string, error) { return tmpDir, nil }, getenv, now); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	if !strings.Contains(output.String(), "先に1件のジョブが待機しています") {
+		t.Errorf("Output should report the waiting jobs, got: %s", output.String())
+	}
+
+	// ワーカーを起動できなければジョブを失敗として記録する
+	env[workerCommandEnvVar] = filepath.Join(tmpDir, "missing-worker")
+	input = strings.NewReader(`{"transcript_path":"` + transcriptPath + `"}`)
+	if err := run(input, &bytes.Buffer{}, func() (string, error) { return tmpDir, nil }, getenv, now); err == nil {
+		t.Fatal("run() should fail when the worker cannot be started")
+	}
+	job, err = LoadJob(filepath.Join(tmpDir, commandName, jobsDirName, "20250102-030405-transcript-3.json"))
+	if err != nil || job.Status != JobFailed || !strings.Contains(job.Error, "ワーカーの起動に失敗") {
+		t.Errorf("job = %+v, err = %v", job, err)
+	}
+}
+
+func TestRun_AsyncModeInvalidNotifier(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.json")
+	if err := os.WriteFile(configPath, []byte(`{"execution_mode":"async","notify":["pager"]}`), 0o600); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+	getenv := func(key string) string {
+		if key == configEnvVar {
+			return configPath
+		}
+		return ""
+	}
+	input := strings.NewReader(`{"transcript_path":"` + writeTestTranscript(t, tmpDir) + `"}`)
+	err := run(input, &bytes.Buffer{}, func() (string, error) { return tmpDir, nil }, getenv, time.Now)
+	if err == nil || !strings.Contains(err.Error(), "無効な通知方法: pager") {
+		t.Errorf("run() error = %v, want invalid notifier error", err)
+	}
+}
+
+func TestRunQueue(t *testing.T) {
+	tmpDir := t.TempDir()
+	notifyFile := filepath.Join(tmpDir, "notified")
+	configPath := filepath.Join(tmpDir, "config.json")
+	config := `{"notify":["command"],"notify_command":"echo \"$SUGGEST_CLAUDE_MD_JOB_STATUS $SUGGEST_CLAUDE_MD_SUGGESTION_FILE\" >> '` + notifyFile + `'"}`
+	if err := os.WriteFile(configPath, []byte(config), 0o600); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+
+	// プロンプトを記録する偽のclaude
+	promptLog := filepath.Join(tmpDir, "prompts.log")
+	writeFakeClaudeScript(t, "#!/bin/sh\n{ cat; echo '=== end of prompt ==='; } >> '"+promptLog+"'\nprintf '## Testing\\n\\n- go test ./...\\n'\n")
+	// ワーカーの環境には--configが渡らないため、ジョブに記録した設定ファイルを使う
+	getenv := func(key string) string {
+		if key == "XDG_RUNTIME_DIR" {
+			return tmpDir
+		}
+		return ""
+	}
+
+	store, err := NewJobStore(ResolveOutputDir(getenv))
+	if err != nil {
+		t.Fatalf("NewJobStore() error = %v", err)
+	}
+	transcriptPath := writeTestTranscript(t, tmpDir)
+	createdAt := time.Now().Add(-time.Hour)
+	var jobs []*Job
+	for i := 0; i < 2; i++ {
+		job := &Job{
+			Status:         JobQueued,
+			ProjectRoot:    tmpDir,
+			TranscriptPath: transcriptPath,
+			HookInfo:       "Hook: SessionEnd",
+			ConfigPath:     configPath,
+			CreatedAt:      createdAt.Add(time.Duration(i) * time.Minute),
+		}
+		if err := store.Create(job); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		jobs = append(jobs, job)
+	}
+
+	// 後のジョブから起動されても、古いジョブから順に処理する
+	output := &bytes.Buffer{}
+	if err := runQueue(store.Path(jobs[1].ID), output, nil, getenv, time.Now); err != nil {
+		t.Fatalf("runQueue() error = %v\n%s", err, output.String())
+	}
+	var done []*Job
+	for _, job := range jobs {
+		loaded, err := LoadJob(store.Path(job.ID))
+		if err != nil {
+			t.Fatalf("LoadJob() error = %v", err)
+		}
+		if loaded.Status != JobSucceeded || loaded.PID != os.Getpid() || loaded.StartedAt.IsZero() || loaded.FinishedAt.IsZero() {
+			t.Errorf("job = %+v", loaded)
+		}
+		if !strings.HasSuffix(loaded.SuggestionFile, ".md") || !strings.HasSuffix(loaded.LogFile, ".log") {
+			t.Errorf("SuggestionFile = %q, LogFile = %q", loaded.SuggestionFile, loaded.LogFile)
+		}
+		done = append(done, loaded)
+	}
+	if done[1].StartedAt.Before(done[0].FinishedAt) {
+		t.Errorf("jobs should run one at a time, oldest first: %+v", done)
+	}
+	notified, _ := os.ReadFile(notifyFile) // nolint:errcheck // Checked by content
+	if want := "succeeded " + done[0].SuggestionFile + "\nsucceeded " + done[1].SuggestionFile + "\n"; string(notified) != want {
+		t.Errorf("notification = %q, want %q", notified, want)
+	}
+
+	// 2件目の分析には1件目の未適用の提案が渡される
+	prompts, _ := os.ReadFile(promptLog) // nolint:errcheck // Checked by content
+	parts := strings.Split(string(prompts), "=== end of prompt ===")
+	if len(parts) != 3 {
+		t.Fatalf("claude should run twice, got prompts: %s", prompts)
+	}
+	if strings.Contains(parts[0], "未適用の提案") || !strings.Contains(parts[1], "未適用の提案") {
+		t.Errorf("only the second prompt should include the pending suggestion")
+	}
+	jobLog, _ := os.ReadFile(store.WorkerLogPath(jobs[1].ID)) // nolint:errcheck // Checked by content
+	if !strings.Contains(string(jobLog), "未適用の提案1件") {
+		t.Errorf("Job log should report the pending suggestion, got: %s", jobLog)
+	}
+
+	// 処理済みのジョブは再実行しない
+	if err := runQueue(store.Path(jobs[0].ID), &bytes.Buffer{}, nil, getenv, time.Now); err != nil {
+		t.Fatalf("runQueue() error = %v", err)
+	}
+	prompts, _ = os.ReadFile(promptLog) // nolint:errcheck // Checked by content
+	if got := strings.Count(string(prompts), "=== end of prompt ==="); got != 2 {
+		t.Errorf("claude ran %d times, want 2", got)
+	}
+}
+
+func TestRunQueue_WorkerRunning(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := NewJobStore(tmpDir)
+	if err != nil {
+		t.Fatalf("NewJobStore() error = %v", err)
+	}
+	job := &Job{Status: JobQueued, ProjectRoot: tmpDir, TranscriptPath: filepath.Join(tmpDir, "session.jsonl"), CreatedAt: time.Now()}
+	if err := store.Create(job); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	unlock, ok, err := store.LockProject(tmpDir)
+	if err != nil || !ok {
+		t.Fatalf("LockProject() = %v, %v", ok, err)
+	}
+	defer unlock()
+
+	// 実行中のワーカーに任せて、ジョブには触れない
+	output := &bytes.Buffer{}
+	if err := runQueue(store.Path(job.ID), output, nil, os.Getenv, time.Now); err != nil {
+		t.Fatalf("runQueue() error = %v", err)
+	}
+	if !strings.Contains(output.String(), "既に実行中です") {
+		t.Errorf("Output should report the running worker, got: %s", output.String())
+	}
+	if loaded, _ := LoadJob(store.Path(job.ID)); loaded.Status != JobQueued { // nolint:errcheck // Checked by status
+		t.Errorf("Status = %s, want queued", loaded.Status)
+	}
+}
+
+func TestRunQueue_Failed(t *testing.T) {
+	tmpDir := t.TempDir()
+	getenv := func(key string) string {
+		if key == "XDG_RUNTIME_DIR" {
+			return tmpDir
+		}
+		return ""
+	}
+	store, err := NewJobStore(ResolveOutputDir(getenv))
+	if err != nil {
+		t.Fatalf("NewJobStore() error = %v", err)
+	}
+	job := &Job{Status: JobQueued, ProjectRoot: tmpDir, TranscriptPath: filepath.Join(tmpDir, "gone.jsonl"), CreatedAt: time.Now()}
+	if err := store.Create(job); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	// 異常終了したワーカーの実行中ジョブ
+	stale := &Job{Status: JobRunning, ProjectRoot: tmpDir, TranscriptPath: filepath.Join(tmpDir, "stale.jsonl"), PID: 1 << 30, CreatedAt: time.Now()}
+	if err := store.Create(stale); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	// 分析の失敗はジョブに記録し、ワーカーは失敗しない
+	output := &bytes.Buffer{}
+	if err := runQueue(store.Path(job.ID), output, nil, getenv, time.Now); err != nil {
+		t.Fatalf("runQueue() error = %v", err)
+	}
+	failed, _ := LoadJob(store.Path(job.ID)) // nolint:errcheck // Checked by status
+	if failed.Status != JobFailed || !strings.HasPrefix(failed.Error, "ファイルが存在しません") {
+		t.Errorf("job = %+v", failed)
+	}
+	abandoned, _ := LoadJob(store.Path(stale.ID)) // nolint:errcheck // Checked by status
+	if abandoned.Status != JobFailed || abandoned.Error != "ワーカーが異常終了しました" {
+		t.Errorf("stale job = %+v", abandoned)
+	}
+	// 既定の通知（ベル）は端末がなければ失敗するが、ジョブの結果には影響しない
+	jobLog, _ := os.ReadFile(store.WorkerLogPath(job.ID)) // nolint:errcheck // Checked by content
+	if !strings.Contains(string(jobLog), "通知に失敗 (bell)") {
+		t.Errorf("Job log should report the notification failure, got: %s", jobLog)
+	}
+}
+
+func TestRun_ConcurrentSyncHooks(t *testing.T) {
+	tmpDir := t.TempDir()
+	useTempHomeDir(t)
+	// 実行中は running ディレクトリを作り、重なって起動されたら記録する偽のclaude
+	running := filepath.Join(tmpDir, "running")
+	overlaps := filepath.Join(tmpDir, "overlaps")
+	calls := filepath.Join(tmpDir, "calls")
+	writeFakeClaudeScript(t, "#!/bin/sh\ncat > /dev/null\necho call >> '"+calls+"'\n"+
+		"mkdir '"+running+"' 2>/dev/null || echo overlap >> '"+overlaps+"'\n"+
+		"sleep 0.5\nrmdir '"+running+"'\nprintf '## Testing\\n\\n- go test ./...\\n'\n")
+	getenv := func(key string) string {
+		switch key {
+		case "XDG_RUNTIME_DIR":
+			return tmpDir
+		case "XDG_STATE_HOME":
+			return filepath.Join(tmpDir, "state")
+		}
+		return ""
+	}
+	getwd := func() (string, error) { return tmpDir, nil }
+
+	hook := func(sessionID string, output *bytes.Buffer, done chan<- error) {
+		transcriptPath := filepath.Join(tmpDir, sessionID+".jsonl")
+		if err := os.WriteFile(transcriptPath, []byte(`{"message":{"role":"user","content":"Hello"}}`), 0o600); err != nil {
+			done <- err
+			return
+		}
+		input := strings.NewReader(`{"transcript_path":"` + transcriptPath + `","hook_event_name":"SessionEnd"}`)
+		done <- run(input, output, getwd, getenv, time.Now)
+	}
+
+	// 1つ目のフックがclaudeを実行している間に2つ目のフックを実行する
+	first, second := &bytes.Buffer{}, &bytes.Buffer{}
+	firstDone, secondDone := make(chan error, 1), make(chan error, 1)
+	go hook("session-a", first, firstDone)
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if _, err := os.Stat(running); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("First hook did not start claude")
+		}
+	}
+	go hook("session-b", second, secondDone)
+	if err := <-secondDone; err != nil {
+		t.Fatalf("Second run() error = %v\n%s", err, second.String())
+	}
+	if err := <-firstDone; err != nil {
+		t.Fatalf("First run() error = %v\n%s", err, first.String())
+	}
+
+	if content, err := os.ReadFile(overlaps); err == nil {
+		t.Errorf("claude ran in parallel: %s", content)
+	}
+	if content, _ := os.ReadFile(calls); strings.Count(string(content), "call") != 2 { // nolint:errcheck // Checked by content
+		t.Errorf("claude should run once per hook, got %q", content)
+	}
+	if !strings.Contains(second.String(), "既に実行中です") {
+		t.Errorf("Second hook should report that its job is queued, got: %s", second.String())
+	}
+	if !strings.Contains(first.String(), "- go test ./...") {
+		t.Errorf("First hook should show its analysis, got: %s", first.String())
+	}
+
+	// 2つ目のジョブは1つ目のフックが処理する
+	store, err := NewJobStore(ResolveOutputDir(getenv))
+	if err != nil {
+		t.Fatalf("NewJobStore() error = %v", err)
+	}
+	jobs, err := store.ListProject(tmpDir)
+	if err != nil {
+		t.Fatalf("ListProject() error = %v", err)
+	}
+	if len(jobs) != 2 {
+		t.Fatalf("Expected 2 jobs, got %d", len(jobs))
+	}
+	for _, job := range jobs {
+		if job.Status != JobSucceeded || job.PID != os.Getpid() {
+			t.Errorf("Job %s = %s (PID %d)", job.ID, job.Status, job.PID)
+		}
+	}
+}